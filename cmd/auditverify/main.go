@@ -0,0 +1,63 @@
+// Command auditverify walks the audit log hash chain and its daily anchors
+// and reports the first broken link. It exits with status 1 if the chain
+// does not verify.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/services"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if _, err := config.LoadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := db.InitializeDB(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.CloseDB()
+
+	report, err := services.NewAuditService(db.DB).VerifyAuditChain()
+	if err != nil {
+		log.Fatalf("Audit chain verification failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		fmt.Printf("Entries checked:   %d\n", report.EntriesChecked)
+		fmt.Printf("Unchained (older): %d\n", report.UnchainedBefore)
+		fmt.Printf("Anchors checked:   %d\n", report.AnchorsChecked)
+		if report.Head != "" {
+			fmt.Printf("Chain head:        %s\n", report.Head)
+		}
+		if report.Break != nil {
+			fmt.Printf("\nBROKEN at entry %d: %s\n", report.Break.EntryID, report.Break.Reason)
+			if report.Break.Expected != "" {
+				fmt.Printf("  expected: %s\n", report.Break.Expected)
+			}
+			if report.Break.Found != "" {
+				fmt.Printf("  found:    %s\n", report.Break.Found)
+			}
+		} else {
+			fmt.Println("\nAudit chain verified")
+		}
+	}
+
+	if !report.Valid {
+		db.CloseDB()
+		os.Exit(1)
+	}
+}
@@ -161,6 +161,7 @@ contract AluminiumPassport is Initializable, UUPSUpgradeable, AccessControlUpgra
     event Paused(address indexed account, uint256 timestamp);
     event Unpaused(address indexed account, uint256 timestamp);
     event SuperAdminTransferred(address indexed oldSuperAdmin, address indexed newSuperAdmin, uint256 timestamp);
+    event AuditRootAnchored(string indexed date, bytes32 merkleRoot, bytes32 chainHead, string cid, address indexed anchoredBy, uint256 timestamp);
 
     // --- Modifiers ---
     modifier onlyRoleOrAdmin(bytes32 role) {
@@ -565,6 +566,20 @@ contract AluminiumPassport is Initializable, UUPSUpgradeable, AccessControlUpgra
         emit SupplyChainStepAdded(passportId, step, msg.sender, block.timestamp);
     }
 
+    // --- Audit Trail Anchoring ---
+    /// @notice Anchor the Merkle root of a day's off-chain audit log entries
+    /// @param date The UTC day covered, as YYYY-MM-DD
+    /// @param merkleRoot Merkle root of the day's audit entry hashes
+    /// @param chainHead Hash of the day's last audit entry
+    /// @param cid IPFS CID of the published anchor document
+    function anchorAuditRoot(string calldata date, bytes32 merkleRoot, bytes32 chainHead, string calldata cid) external onlyRoleOrAdmin(AUDITOR_ROLE) whenNotPaused {
+        require(bytes(date).length == 10, "date must be YYYY-MM-DD");
+        require(merkleRoot != bytes32(0), "merkleRoot required");
+        require(auditRoots[date] == bytes32(0), "Already anchored");
+        auditRoots[date] = merkleRoot;
+        emit AuditRootAnchored(date, merkleRoot, chainHead, cid, msg.sender, block.timestamp);
+    }
+
     // --- Getters ---
     /// @notice Get passport details
     function getPassport(string memory passportId) external view returns (
@@ -627,6 +642,10 @@ contract AluminiumPassport is Initializable, UUPSUpgradeable, AccessControlUpgra
         return false;
     }
 
+    // --- Audit anchors (appended for upgrade safety) ---
+    /// @notice Anchored audit Merkle roots by UTC day (YYYY-MM-DD)
+    mapping(string => bytes32) public auditRoots;
+
     // --- Storage gap for upgradeability ---
-    uint256[50] private __gap;
+    uint256[49] private __gap;
 }
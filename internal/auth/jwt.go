@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"aluminium-passport/internal/config"
@@ -11,12 +14,14 @@ import (
 )
 
 type Claims struct {
-	UserID      int    `json:"user_id"`
-	Username    string `json:"username"`
-	Email       string `json:"email"`
-	Role        string `json:"role"`
-	WalletAddr  string `json:"wallet_address"`
-	CompanyName string `json:"company_name"`
+	UserID         int    `json:"user_id"`
+	Username       string `json:"username"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	WalletAddr     string `json:"wallet_address"`
+	CompanyName    string `json:"company_name"`
+	OrganisationID int    `json:"org_id,omitempty"`
+	SessionID      string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -25,6 +30,12 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int64  `json:"expires_in"`
 	TokenType    string `json:"token_type"`
+	SessionID    string `json:"session_id"`
+
+	// Token IDs (jti) and refresh expiry, recorded by the session store
+	AccessTokenID    string    `json:"-"`
+	RefreshTokenID   string    `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
 var (
@@ -33,26 +44,47 @@ var (
 	ErrInvalidClaims = errors.New("invalid token claims")
 )
 
-// GenerateTokenPair creates both access and refresh tokens
-func GenerateTokenPair(userID int, username, email, role, walletAddr, companyName string) (*TokenPair, error) {
+// NewTokenID returns a random identifier for a session or token
+func NewTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GenerateTokenPair creates both access and refresh tokens for a session.
+// Each token gets a fresh random ID.
+func GenerateTokenPair(sessionID string, userID int, username, email, role, walletAddr, companyName string, organisationID int) (*TokenPair, error) {
 	cfg := config.AppConfig
 
+	accessID, err := NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	refreshID, err := NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
 	// Access token (shorter expiration)
 	accessExpirationTime := time.Now().Add(time.Duration(cfg.JWTExpirationHours) * time.Hour)
 	accessClaims := &Claims{
-		UserID:      userID,
-		Username:    username,
-		Email:       email,
-		Role:        role,
-		WalletAddr:  walletAddr,
-		CompanyName: companyName,
+		UserID:         userID,
+		Username:       username,
+		Email:          email,
+		Role:           role,
+		WalletAddr:     walletAddr,
+		CompanyName:    companyName,
+		OrganisationID: organisationID,
+		SessionID:      sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessExpirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "aluminium-passport-api",
 			Subject:   fmt.Sprintf("user:%d", userID),
-			ID:        fmt.Sprintf("%d-%d", userID, time.Now().Unix()),
+			ID:        accessID,
 		},
 	}
 
@@ -65,16 +97,17 @@ func GenerateTokenPair(userID int, username, email, role, walletAddr, companyNam
 	// Refresh token (longer expiration)
 	refreshExpirationTime := time.Now().Add(time.Duration(cfg.JWTRefreshHours) * time.Hour)
 	refreshClaims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "aluminium-passport-api",
 			Subject:   fmt.Sprintf("refresh:%d", userID),
-			ID:        fmt.Sprintf("refresh-%d-%d", userID, time.Now().Unix()),
+			ID:        refreshID,
 		},
 	}
 
@@ -85,10 +118,14 @@ func GenerateTokenPair(userID int, username, email, role, walletAddr, companyNam
 	}
 
 	return &TokenPair{
-		AccessToken:  accessTokenString,
-		RefreshToken: refreshTokenString,
-		ExpiresIn:    accessExpirationTime.Unix(),
-		TokenType:    "Bearer",
+		AccessToken:      accessTokenString,
+		RefreshToken:     refreshTokenString,
+		ExpiresIn:        accessExpirationTime.Unix(),
+		TokenType:        "Bearer",
+		SessionID:        sessionID,
+		AccessTokenID:    accessID,
+		RefreshTokenID:   refreshID,
+		RefreshExpiresAt: refreshExpirationTime,
 	}, nil
 }
 
@@ -119,27 +156,24 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// RefreshToken generates a new access token from a valid refresh token
-func RefreshToken(refreshTokenString string) (*TokenPair, error) {
+// IsRefreshToken reports whether claims belong to a refresh token
+func IsRefreshToken(claims *Claims) bool {
+	return strings.HasPrefix(claims.Subject, "refresh:")
+}
+
+// ValidateRefreshToken validates a refresh token. Rotation and reuse
+// detection are done by the session store.
+func ValidateRefreshToken(refreshTokenString string) (*Claims, error) {
 	claims, err := ValidateToken(refreshTokenString)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check if this is actually a refresh token
-	if claims.Subject[:7] != "refresh" {
+	if !IsRefreshToken(claims) || claims.SessionID == "" {
 		return nil, ErrInvalidToken
 	}
-
-	// Generate new token pair
-	return GenerateTokenPair(
-		claims.UserID,
-		claims.Username,
-		claims.Email,
-		claims.Role,
-		claims.WalletAddr,
-		claims.CompanyName,
-	)
+	return claims, nil
 }
 
 // ExtractClaims extracts claims from token without validation (for expired tokens)
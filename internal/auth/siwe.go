@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrInvalidSIWEMessage = errors.New("invalid sign-in with ethereum message")
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// SIWEMessage is a Sign-In with Ethereum (EIP-4361) message
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// String formats the message as the text the wallet signs
+func (m *SIWEMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	b.WriteString(m.Address + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "URI: %s\n", m.URI)
+	fmt.Fprintf(&b, "Version: %s\n", m.Version)
+	fmt.Fprintf(&b, "Chain ID: %d\n", m.ChainID)
+	fmt.Fprintf(&b, "Nonce: %s\n", m.Nonce)
+	fmt.Fprintf(&b, "Issued At: %s", m.IssuedAt.UTC().Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		fmt.Fprintf(&b, "\nExpiration Time: %s", m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		fmt.Fprintf(&b, "\nNot Before: %s", m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		fmt.Fprintf(&b, "\nRequest ID: %s", m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+	return b.String()
+}
+
+// ParseSIWEMessage parses the text of an EIP-4361 message
+func ParseSIWEMessage(message string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidSIWEMessage)
+	}
+
+	m := &SIWEMessage{Domain: strings.TrimSuffix(lines[0], siweHeaderSuffix)}
+	if i := strings.Index(m.Domain, "://"); i >= 0 {
+		m.Domain = m.Domain[i+3:]
+	}
+	m.Address = lines[1]
+	if !common.IsHexAddress(m.Address) || !strings.HasPrefix(m.Address, "0x") {
+		return nil, fmt.Errorf("%w: invalid address", ErrInvalidSIWEMessage)
+	}
+
+	// The address is followed by a blank line and an optional statement,
+	// itself followed by a blank line
+	i := 2
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		m.Statement = lines[i]
+		i++
+		for i < len(lines) && lines[i] == "" {
+			i++
+		}
+	}
+
+	fields := map[string]string{}
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			if i < len(lines) && strings.TrimSpace(strings.Join(lines[i:], "")) != "" {
+				return nil, fmt.Errorf("%w: unexpected text after resources", ErrInvalidSIWEMessage)
+			}
+			break
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidSIWEMessage, line)
+		}
+		if _, seen := fields[key]; seen {
+			return nil, fmt.Errorf("%w: duplicate field %s", ErrInvalidSIWEMessage, key)
+		}
+		fields[key] = value
+	}
+
+	for _, key := range []string{"URI", "Version", "Chain ID", "Nonce", "Issued At"} {
+		if fields[key] == "" {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidSIWEMessage, key)
+		}
+	}
+	for key := range fields {
+		switch key {
+		case "URI", "Version", "Chain ID", "Nonce", "Issued At", "Expiration Time", "Not Before", "Request ID":
+		default:
+			return nil, fmt.Errorf("%w: unknown field %s", ErrInvalidSIWEMessage, key)
+		}
+	}
+
+	m.URI = fields["URI"]
+	m.Version = fields["Version"]
+	m.Nonce = fields["Nonce"]
+	m.RequestID = fields["Request ID"]
+
+	chainID, err := strconv.ParseInt(fields["Chain ID"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid chain ID", ErrInvalidSIWEMessage)
+	}
+	m.ChainID = chainID
+
+	if m.IssuedAt, err = time.Parse(time.RFC3339, fields["Issued At"]); err != nil {
+		return nil, fmt.Errorf("%w: invalid issued at time", ErrInvalidSIWEMessage)
+	}
+	if m.ExpirationTime, err = parseSIWETime(fields["Expiration Time"]); err != nil {
+		return nil, fmt.Errorf("%w: invalid expiration time", ErrInvalidSIWEMessage)
+	}
+	if m.NotBefore, err = parseSIWETime(fields["Not Before"]); err != nil {
+		return nil, fmt.Errorf("%w: invalid not before time", ErrInvalidSIWEMessage)
+	}
+	return m, nil
+}
+
+func parseSIWETime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RecoverSIWESigner returns the checksummed address that produced an
+// EIP-191 personal_sign signature over message. Contract wallets (EIP-1271)
+// are not supported.
+func RecoverSIWESigner(message, signature string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return "", fmt.Errorf("%w: signature must be 65 hex-encoded bytes", ErrInvalidSIWEMessage)
+	}
+	// Wallets return v as 27 or 28; recovery expects 0 or 1
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+
+	hash := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: cannot recover signer", ErrInvalidSIWEMessage)
+	}
+	return crypto.PubkeyToAddress(*pub).Hex(), nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// personalSign signs message the way wallets answer personal_sign
+func personalSign(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	t.Helper()
+	hash := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	sig[64] += 27
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func testSIWEMessage() *SIWEMessage {
+	issuedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	expires := issuedAt.Add(10 * time.Minute)
+	return &SIWEMessage{
+		Domain:         "passports.example.com",
+		Address:        "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Statement:      "Sign in to the Aluminium Passport platform.",
+		URI:            "https://passports.example.com",
+		Version:        "1",
+		ChainID:        137,
+		Nonce:          "32891756",
+		IssuedAt:       issuedAt,
+		ExpirationTime: &expires,
+		RequestID:      "login-1",
+		Resources:      []string{"https://passports.example.com/terms", "ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq"},
+	}
+}
+
+func TestSIWEMessageRoundTrip(t *testing.T) {
+	for name, m := range map[string]*SIWEMessage{
+		"all fields":     testSIWEMessage(),
+		"minimal fields": {Domain: "localhost:8080", Address: testSIWEMessage().Address, URI: "http://localhost:8080", Version: "1", ChainID: 1, Nonce: "abcdefgh", IssuedAt: testSIWEMessage().IssuedAt},
+	} {
+		parsed, err := ParseSIWEMessage(m.String())
+		if err != nil {
+			t.Fatalf("%s: ParseSIWEMessage: %v", name, err)
+		}
+		if !reflect.DeepEqual(parsed, m) {
+			t.Errorf("%s: parsed %+v, want %+v", name, parsed, m)
+		}
+	}
+}
+
+func TestParseSIWEMessageRejects(t *testing.T) {
+	valid := testSIWEMessage().String()
+	tests := map[string]string{
+		"missing header":         strings.Replace(valid, " wants you to sign in", " would like you to sign in", 1),
+		"invalid address":        strings.Replace(valid, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "C02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", 1),
+		"missing nonce":          strings.Replace(valid, "Nonce: 32891756\n", "", 1),
+		"duplicate field":        strings.Replace(valid, "Version: 1\n", "Version: 1\nVersion: 2\n", 1),
+		"unknown field":          strings.Replace(valid, "Version: 1\n", "Version: 1\nScope: admin\n", 1),
+		"invalid chain ID":       strings.Replace(valid, "Chain ID: 137", "Chain ID: polygon", 1),
+		"invalid issued at":      strings.Replace(valid, "Issued At: 2025-03-01T10:00:00Z", "Issued At: yesterday", 1),
+		"invalid expiration":     strings.Replace(valid, "Expiration Time: 2025-03-01T10:10:00Z", "Expiration Time: 2025-03-01", 1),
+		"text after resources":   valid + "\nSigned by: someone",
+		"line without separator": strings.Replace(valid, "Version: 1", "Version 1", 1),
+		"empty message":          "",
+	}
+	for name, message := range tests {
+		if _, err := ParseSIWEMessage(message); !errors.Is(err, ErrInvalidSIWEMessage) {
+			t.Errorf("%s: ParseSIWEMessage error = %v, want %v", name, err, ErrInvalidSIWEMessage)
+		}
+	}
+}
+
+func TestRecoverSIWESigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	message := testSIWEMessage().String()
+	signature := personalSign(t, key, message)
+
+	signer, err := RecoverSIWESigner(message, signature)
+	if err != nil {
+		t.Fatalf("RecoverSIWESigner: %v", err)
+	}
+	if signer != address {
+		t.Errorf("signer = %s, want %s", signer, address)
+	}
+
+	// A changed message recovers some other address
+	if other, err := RecoverSIWESigner(strings.Replace(message, "Nonce: 32891756", "Nonce: 32891757", 1), signature); err == nil && other == address {
+		t.Errorf("a signature over another message recovered the signer")
+	}
+	for _, bad := range []string{"", "0x1234", signature[:len(signature)-2], "0x" + strings.Repeat("zz", 65)} {
+		if _, err := RecoverSIWESigner(message, bad); !errors.Is(err, ErrInvalidSIWEMessage) {
+			t.Errorf("RecoverSIWESigner(%q) error = %v, want %v", bad, err, ErrInvalidSIWEMessage)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,9 +14,10 @@ import (
 
 type Config struct {
 	// Server Configuration
-	Port        string
-	Environment string
-	Debug       bool
+	Port          string
+	Environment   string
+	Debug         bool
+	PublicBaseURL string
 
 	// Database Configuration
 	DatabaseURL     string
@@ -33,6 +36,10 @@ type Config struct {
 	JWTExpirationHours int
 	JWTRefreshHours    int
 
+	// Sign-In with Ethereum
+	SIWEDomain   string
+	SIWENonceTTL time.Duration
+
 	// Blockchain Configuration
 	Web3RPCURL      string
 	PrivateKey      string
@@ -41,6 +48,13 @@ type Config struct {
 	GasLimit        uint64
 	GasPrice        int64
 
+	// Credential Signing
+	CredentialSigningKey string
+	DIDWebDomain         string
+	KeyEncryptionSecret  string
+	StatusSweepInterval  time.Duration
+	StatusListHosts      []string
+
 	// IPFS Configuration
 	IPFSAPIUrl        string
 	IPFSProjectID     string
@@ -67,6 +81,31 @@ type Config struct {
 	UploadPath       string
 	MaxZipSize       int64
 	AllowedFileTypes []string
+
+	// Batch Processing
+	BatchWorkers      int
+	BatchPollInterval time.Duration
+
+	// Audit Trail
+	AuditAnchorInterval time.Duration
+	AuditAnchorOnChain  bool
+
+	// Approval Workflow
+	ApprovalExpiryInterval time.Duration
+
+	// Notifications
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPFrom                 string
+	NotificationPollInterval time.Duration
+	NotificationMaxAttempts  int
+
+	// Webhooks
+	WebhookPollInterval time.Duration
+	WebhookMaxAttempts  int
+	WebhookTimeout      time.Duration
 }
 
 var AppConfig *Config
@@ -83,6 +122,9 @@ func LoadConfig() (*Config, error) {
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Debug:       getEnvBool("DEBUG", true),
 
+		// Public URL used in issued credentials (status lists, contexts)
+		PublicBaseURL: getEnv("PUBLIC_BASE_URL", ""),
+
 		// Database defaults
 		DatabaseURL:     getEnv("DATABASE_URL", ""),
 		DBHost:          getEnv("DB_HOST", "localhost"),
@@ -100,6 +142,10 @@ func LoadConfig() (*Config, error) {
 		JWTExpirationHours: getEnvInt("JWT_EXPIRATION_HOURS", 24),
 		JWTRefreshHours:    getEnvInt("JWT_REFRESH_HOURS", 168), // 7 days
 
+		// Sign-In with Ethereum (the domain defaults to the host of PUBLIC_BASE_URL)
+		SIWEDomain:   getEnv("SIWE_DOMAIN", ""),
+		SIWENonceTTL: time.Duration(getEnvInt("SIWE_NONCE_TTL_MINUTES", 10)) * time.Minute,
+
 		// Blockchain defaults
 		Web3RPCURL:      getEnv("WEB3_RPC_URL", "https://polygon-mainnet.infura.io/v3/YOUR_PROJECT_ID"),
 		PrivateKey:      getEnv("PRIVATE_KEY", ""),
@@ -108,6 +154,13 @@ func LoadConfig() (*Config, error) {
 		GasLimit:        uint64(getEnvInt64("GAS_LIMIT", 300000)),
 		GasPrice:        getEnvInt64("GAS_PRICE", 20000000000), // 20 gwei
 
+		// Credential signing (multibase Ed25519 or secp256k1 private key)
+		CredentialSigningKey: getEnv("CREDENTIAL_SIGNING_KEY", ""),
+		DIDWebDomain:         getEnv("DID_WEB_DOMAIN", ""),
+		KeyEncryptionSecret:  getEnv("KEY_ENCRYPTION_SECRET", ""),
+		StatusSweepInterval:  time.Duration(getEnvInt("STATUS_SWEEP_INTERVAL_MINUTES", 60)) * time.Minute,
+		StatusListHosts:      getEnvSlice("TRUSTED_STATUS_LIST_HOSTS", nil),
+
 		// IPFS defaults
 		IPFSAPIUrl:        getEnv("IPFS_API_URL", "https://ipfs.infura.io:5001"),
 		IPFSProjectID:     getEnv("IPFS_PROJECT_ID", ""),
@@ -134,6 +187,31 @@ func LoadConfig() (*Config, error) {
 		UploadPath:       getEnv("UPLOAD_PATH", "./uploads"),
 		MaxZipSize:       getEnvInt64("MAX_ZIP_SIZE", 100*1024*1024), // 100MB
 		AllowedFileTypes: getEnvSlice("ALLOWED_FILE_TYPES", []string{".json", ".csv", ".xlsx"}),
+
+		// Batch processing
+		BatchWorkers:      getEnvInt("BATCH_WORKERS", 2),
+		BatchPollInterval: time.Duration(getEnvInt("BATCH_POLL_INTERVAL_SECONDS", 10)) * time.Second,
+
+		// Audit trail
+		AuditAnchorInterval: time.Duration(getEnvInt("AUDIT_ANCHOR_INTERVAL_MINUTES", 60)) * time.Minute,
+		AuditAnchorOnChain:  getEnvBool("AUDIT_ANCHOR_ON_CHAIN", false),
+
+		// Approval workflow
+		ApprovalExpiryInterval: time.Duration(getEnvInt("APPROVAL_EXPIRY_INTERVAL_MINUTES", 15)) * time.Minute,
+
+		// Notifications (email is disabled without SMTP_HOST)
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                 getEnv("SMTP_FROM", "noreply@aluminiumpassport.com"),
+		NotificationPollInterval: time.Duration(getEnvInt("NOTIFICATION_POLL_INTERVAL_SECONDS", 30)) * time.Second,
+		NotificationMaxAttempts:  getEnvInt("NOTIFICATION_MAX_ATTEMPTS", 6),
+
+		// Webhooks
+		WebhookPollInterval: time.Duration(getEnvInt("WEBHOOK_POLL_INTERVAL_SECONDS", 10)) * time.Second,
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeout:      time.Duration(getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
 	}
 
 	// Build database URL if not provided
@@ -149,6 +227,16 @@ func LoadConfig() (*Config, error) {
 		)
 	}
 
+	if config.PublicBaseURL == "" {
+		config.PublicBaseURL = fmt.Sprintf("http://localhost:%s", config.Port)
+	}
+
+	if config.SIWEDomain == "" {
+		if u, err := url.Parse(config.PublicBaseURL); err == nil {
+			config.SIWEDomain = u.Host
+		}
+	}
+
 	// Validate required fields
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -173,6 +261,12 @@ func (c *Config) Validate() error {
 		if c.ContractAddress == "" {
 			return fmt.Errorf("CONTRACT_ADDRESS is required in production")
 		}
+		if c.CredentialSigningKey == "" {
+			return fmt.Errorf("CREDENTIAL_SIGNING_KEY is required in production")
+		}
+		if c.KeyEncryptionSecret == "" {
+			return fmt.Errorf("KEY_ENCRYPTION_SECRET is required in production")
+		}
 	}
 
 	return nil
@@ -227,9 +321,14 @@ func getEnvBool(key string, defaultValue bool) bool {
 
 func getEnvSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
-		// Simple comma-separated parsing
-		// In production, you might want more sophisticated parsing
-		return []string{value}
+		// Comma-separated, ignoring blank entries
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
 	}
 	return defaultValue
 }
@@ -3,8 +3,10 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,8 +14,10 @@ import (
 	"aluminium-passport/internal/auth"
 	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 type ApprovalController struct{}
@@ -47,6 +51,28 @@ func (ac *ApprovalController) CreateApprovalRequest(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// A role change must name its target and may not grant more than its approver holds
+	if req.RequestType == models.ApprovalTypeUserRoleChange {
+		change, err := ac.roleChangeData(req.RequestData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !models.HasHigherOrEqualRole(req.ApproverRole, change.NewRole) {
+			http.Error(w, "Approver role must be at least the requested role", http.StatusBadRequest)
+			return
+		}
+		policy, err := services.NewApprovalService(db.DB).ApprovalPolicy(req.RequestType)
+		if err != nil {
+			http.Error(w, "Failed to load approval policy", http.StatusInternalServerError)
+			return
+		}
+		if !policy.AllowsGrant(change.NewRole) {
+			http.Error(w, "Every role in the approval quorum must be at least the requested role", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Set default expiry if not provided
 	expiresInDays := req.ExpiresInDays
 	if expiresInDays <= 0 {
@@ -79,7 +105,7 @@ func (ac *ApprovalController) CreateApprovalRequest(w http.ResponseWriter, r *ht
 	// Log audit event
 	ac.logAuditEvent(claims.UserID, claims.Role, "CREATE", "approval_request", fmt.Sprintf("%d", requestID), nil, approvalRequest, r)
 
-	// Send notification to approvers (placeholder)
+	// Send notification to approvers
 	ac.notifyApprovers(approvalRequest)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -100,9 +126,8 @@ func (ac *ApprovalController) RequestSupplierOnboarding(w http.ResponseWriter, r
 		return
 	}
 
-	// Only admins can request supplier onboarding
-	if !models.HasHigherOrEqualRole(claims.Role, models.RoleAdmin) {
-		http.Error(w, "Only admins can request supplier onboarding", http.StatusForbidden)
+	if !services.HasPermission(claims.Role, models.PermApprovalOnboardSupplier) {
+		http.Error(w, "Insufficient permissions to request supplier onboarding", http.StatusForbidden)
 		return
 	}
 
@@ -156,7 +181,7 @@ func (ac *ApprovalController) RequestSupplierOnboarding(w http.ResponseWriter, r
 	}
 
 	// Create approval request data
-	requestData := &models.JSONMap{
+	requestData := &db.JSONMap{
 		"supplier_data": req,
 	}
 
@@ -188,6 +213,8 @@ func (ac *ApprovalController) RequestSupplierOnboarding(w http.ResponseWriter, r
 		return
 	}
 
+	approvalRequest.ID = requestID
+
 	// Create pending user with reference to approval request
 	pendingUser.ApprovalRequestID = requestID
 	if err := ac.createPendingUserTx(tx, pendingUser); err != nil {
@@ -204,7 +231,7 @@ func (ac *ApprovalController) RequestSupplierOnboarding(w http.ResponseWriter, r
 	// Log audit event
 	ac.logAuditEvent(claims.UserID, claims.Role, "CREATE", "supplier_onboarding_request", fmt.Sprintf("%d", requestID), nil, req, r)
 
-	// Send notification to super admins
+	// Send notification to the voters of the onboarding quorum
 	ac.notifyApprovers(approvalRequest)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -295,6 +322,12 @@ func (ac *ApprovalController) GetApprovalRequest(w http.ResponseWriter, r *http.
 		return
 	}
 
+	approvalRequest.Votes, err = services.NewApprovalService(db.DB).Votes(requestID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// Log audit event
 	ac.logAuditEvent(claims.UserID, claims.Role, "VIEW", "approval_request", fmt.Sprintf("%d", requestID), nil, nil, r)
 
@@ -302,6 +335,69 @@ func (ac *ApprovalController) GetApprovalRequest(w http.ResponseWriter, r *http.
 	json.NewEncoder(w).Encode(approvalRequest)
 }
 
+// GetApprovalPolicies returns the quorum each request type needs
+func (ac *ApprovalController) GetApprovalPolicies(w http.ResponseWriter, r *http.Request) {
+	if _, err := ac.extractUserClaims(r); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	policies, err := services.NewApprovalService(db.DB).ListApprovalPolicies()
+	if err != nil {
+		http.Error(w, "Failed to retrieve approval policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// SetApprovalPolicy replaces the quorum of a request type. An empty list of
+// requirements restores single approval by the request's approver role.
+func (ac *ApprovalController) SetApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := ac.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	requestType := mux.Vars(r)["type"]
+	if !ac.isValidApprovalType(requestType) {
+		http.Error(w, "Invalid request type", http.StatusBadRequest)
+		return
+	}
+
+	var policy models.ApprovalPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy.RequestType = models.ApprovalType(requestType)
+
+	approvals := services.NewApprovalService(db.DB)
+	previous, err := approvals.ApprovalPolicy(policy.RequestType)
+	if err != nil {
+		http.Error(w, "Failed to load approval policy", http.StatusInternalServerError)
+		return
+	}
+
+	if err := approvals.SetApprovalPolicy(&policy, claims.UserID); err != nil {
+		if errors.Is(err, services.ErrInvalidPolicy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update approval policy", http.StatusInternalServerError)
+		return
+	}
+
+	ac.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "approval_policy", requestType, previous, policy, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
 // ApproveRequest approves an approval request
 func (ac *ApprovalController) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 	ac.processApprovalAction(w, r, models.ApprovalStatusApproved)
@@ -312,6 +408,84 @@ func (ac *ApprovalController) RejectRequest(w http.ResponseWriter, r *http.Reque
 	ac.processApprovalAction(w, r, models.ApprovalStatusRejected)
 }
 
+// ExtendApprovalRequest gives a pending request more time, or re-opens one
+// that has expired
+func (ac *ApprovalController) ExtendApprovalRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	requestID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := ac.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var extension models.ApprovalExtension
+	if err := json.NewDecoder(r.Body).Decode(&extension); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if extension.ExpiresInDays <= 0 {
+		extension.ExpiresInDays = 7
+	}
+
+	approvalRequest, err := ac.getApprovalRequestByID(requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Approval request not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !ac.canViewApprovalRequest(approvalRequest, claims.Role, claims.UserID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, extension.ExpiresInDays)
+	previous, err := services.NewApprovalService(db.DB).ExtendApprovalRequest(requestID, expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrApprovalNotFound):
+			http.Error(w, "Approval request not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrApprovalDecided):
+			http.Error(w, fmt.Sprintf("Request has already been %s", previous), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to extend approval request", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	auditAction := "EXTEND"
+	if previous == models.ApprovalStatusExpired {
+		auditAction = "REOPEN"
+	}
+	ac.logAuditEvent(claims.UserID, claims.Role, auditAction, "approval_request", fmt.Sprintf("%d", requestID),
+		map[string]interface{}{"status": previous, "expires_at": approvalRequest.ExpiresAt},
+		map[string]interface{}{"status": models.ApprovalStatusPending, "expires_at": expiresAt, "reason": extension.Reason}, r)
+
+	approvalRequest.Status = models.ApprovalStatusPending
+	approvalRequest.ExpiresAt = &expiresAt
+	if previous == models.ApprovalStatusExpired {
+		ac.notifyApprovers(approvalRequest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Approval request extended successfully",
+		"request_id":      requestID,
+		"previous_status": previous,
+		"status":          models.ApprovalStatusPending,
+		"expires_at":      expiresAt,
+	})
+}
+
 // processApprovalAction handles approval or rejection of requests
 func (ac *ApprovalController) processApprovalAction(w http.ResponseWriter, r *http.Request, action models.ApprovalStatus) {
 	vars := mux.Vars(r)
@@ -344,18 +518,66 @@ func (ac *ApprovalController) processApprovalAction(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Check if user can approve this request
-	if !approvalRequest.CanBeApprovedBy(claims.Role) {
+	approvals := services.NewApprovalService(db.DB)
+	policy, err := approvals.ApprovalPolicy(approvalRequest.RequestType)
+	if err != nil {
+		http.Error(w, "Failed to load approval policy", http.StatusInternalServerError)
+		return
+	}
+
+	// Separation of duties: requesters never vote on their own requests
+	if approvalRequest.RequestedBy == claims.UserID {
+		http.Error(w, "Requesters cannot vote on their own requests", http.StatusForbidden)
+		return
+	}
+
+	// Check if user can vote on this request
+	if !ac.canVote(approvalRequest, policy, claims.Role) {
 		http.Error(w, "Insufficient permissions to approve this request", http.StatusForbidden)
 		return
 	}
 
+	// A role change may not grant more than its approvers hold, whichever
+	// roles the quorum names. The policy may have changed since the request
+	// was made, so this is checked on every approval.
+	if action == models.ApprovalStatusApproved && approvalRequest.RequestType == models.ApprovalTypeUserRoleChange {
+		change, err := ac.roleChangeData(approvalRequest.RequestData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !models.HasHigherOrEqualRole(claims.Role, change.NewRole) {
+			http.Error(w, "Approvers of a role change must hold at least the requested role", http.StatusForbidden)
+			return
+		}
+	}
+
 	// Check if request is still pending
 	if !approvalRequest.IsPending() {
 		http.Error(w, "Request is not pending or has expired", http.StatusBadRequest)
 		return
 	}
 
+	if actionData.OrganisationID != nil {
+		if action != models.ApprovalStatusApproved || approvalRequest.RequestType != models.ApprovalTypeSupplierOnboarding {
+			http.Error(w, "organisation_id can only be given when approving supplier onboarding", http.StatusBadRequest)
+			return
+		}
+		org, err := services.NewOrganisationService(db.DB).GetOrganisation(*actionData.OrganisationID)
+		if errors.Is(err, services.ErrOrganisationNotFound) {
+			http.Error(w, "Organisation not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load organisation", http.StatusInternalServerError)
+			return
+		}
+		if !org.IsActive {
+			http.Error(w, "Organisation is not active", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Begin transaction for approval process
 	tx, err := db.DB.Begin()
 	if err != nil {
@@ -364,30 +586,75 @@ func (ac *ApprovalController) processApprovalAction(w http.ResponseWriter, r *ht
 	}
 	defer tx.Rollback()
 
-	// Update approval request
-	now := time.Now()
-	updateData := map[string]interface{}{
-		"status":      action,
-		"approved_by": claims.UserID,
-		"updated_at":  now,
-		"approved_at": now,
+	// Votes on a request are counted one at a time
+	if err := ac.lockPendingRequestTx(tx, requestID); err != nil {
+		if errors.Is(err, errApprovalNotPending) {
+			http.Error(w, "Request is no longer pending", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to lock approval request", http.StatusInternalServerError)
+		return
 	}
 
-	if action == models.ApprovalStatusApproved {
-		updateData["approval_reason"] = actionData.Reason
-	} else {
-		updateData["rejection_reason"] = actionData.Reason
+	vote := &models.ApprovalVote{
+		ApprovalRequestID: requestID,
+		VoterID:           claims.UserID,
+		VoterRole:         claims.Role,
+		Decision:          action,
+		Reason:            nullableString(actionData.Reason),
+	}
+	if err := approvals.RecordVoteTx(tx, vote); err != nil {
+		if errors.Is(err, services.ErrAlreadyVoted) {
+			http.Error(w, "You have already voted on this request", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		return
 	}
 
-	if err := ac.updateApprovalRequestTx(tx, requestID, updateData); err != nil {
-		http.Error(w, "Failed to update approval request", http.StatusInternalServerError)
+	votes, err := approvals.VotesTx(tx, requestID)
+	if err != nil {
+		http.Error(w, "Failed to count votes", http.StatusInternalServerError)
 		return
 	}
 
-	// Process the approval based on request type
-	if action == models.ApprovalStatusApproved {
-		if err := ac.processApprovedRequest(tx, approvalRequest); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to process approved request: %v", err), http.StatusInternalServerError)
+	// A single rejection decides the request; approval waits for the quorum
+	outstanding := ac.outstandingApprovals(approvalRequest, policy, votes)
+	decided := action == models.ApprovalStatusRejected || len(outstanding) == 0
+
+	now := vote.CreatedAt
+	var updateData map[string]interface{}
+	if decided {
+		updateData = map[string]interface{}{
+			"status":      action,
+			"approved_by": claims.UserID,
+			"updated_at":  now,
+			"approved_at": now,
+		}
+
+		if action == models.ApprovalStatusApproved {
+			updateData["approval_reason"] = actionData.Reason
+		} else {
+			updateData["rejection_reason"] = actionData.Reason
+		}
+
+		if err := ac.updateApprovalRequestTx(tx, requestID, updateData); err != nil {
+			if errors.Is(err, errApprovalNotPending) {
+				http.Error(w, "Request is no longer pending", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to update approval request", http.StatusInternalServerError)
+			return
+		}
+
+		// Process the approval based on request type
+		if action == models.ApprovalStatusApproved {
+			if err := ac.processApprovedRequest(tx, approvalRequest, &actionData); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to process approved request: %v", err), http.StatusInternalServerError)
+				return
+			}
+		} else if _, err := tx.Exec(`DELETE FROM pending_users WHERE approval_request_id = $1`, requestID); err != nil {
+			http.Error(w, "Failed to remove pending user", http.StatusInternalServerError)
 			return
 		}
 	}
@@ -403,7 +670,26 @@ func (ac *ApprovalController) processApprovalAction(w http.ResponseWriter, r *ht
 	if action == models.ApprovalStatusRejected {
 		auditAction = "REJECT"
 	}
-	ac.logAuditEvent(claims.UserID, claims.Role, auditAction, "approval_request", fmt.Sprintf("%d", requestID), approvalRequest, updateData, r)
+	ac.logAuditEvent(claims.UserID, claims.Role, auditAction, "approval_request", fmt.Sprintf("%d", requestID), approvalRequest, map[string]interface{}{
+		"vote":        vote,
+		"decided":     decided,
+		"outstanding": outstanding,
+		"update":      updateData,
+	}, r)
+
+	if !decided {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":     "Vote recorded; awaiting further approvals",
+			"status":      models.ApprovalStatusPending,
+			"votes":       votes,
+			"outstanding": outstanding,
+			"actioned_by": claims.Username,
+			"actioned_at": now,
+		})
+		return
+	}
 
 	// Send notification to requester
 	ac.notifyRequester(approvalRequest, action, actionData.Reason)
@@ -418,6 +704,7 @@ func (ac *ApprovalController) processApprovalAction(w http.ResponseWriter, r *ht
 		"message":     fmt.Sprintf("Request %s successfully", actionStr),
 		"status":      action,
 		"reason":      actionData.Reason,
+		"votes":       votes,
 		"actioned_by": claims.Username,
 		"actioned_at": now,
 	})
@@ -458,9 +745,6 @@ func (ac *ApprovalController) createApprovalRequestTx(tx *sql.Tx, req *models.Ap
 	return requestID, err
 }
 
-// Additional database methods and helper functions would continue here...
-// (Implementation continues with similar patterns for other database operations)
-
 // Helper methods
 func (ac *ApprovalController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
 	authHeader := r.Header.Get("Authorization")
@@ -493,18 +777,17 @@ func (ac *ApprovalController) isValidRole(role string) bool {
 }
 
 func (ac *ApprovalController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
-	// Implementation for audit logging
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
 }
 
 func (ac *ApprovalController) notifyApprovers(req *models.ApprovalRequest) {
-	// Implementation for sending notifications to approvers
+	services.NotifyApprovalVoters(req)
 }
 
 func (ac *ApprovalController) notifyRequester(req *models.ApprovalRequest, action models.ApprovalStatus, reason string) {
-	// Implementation for sending notifications to requester
+	services.NotifyApprovalRequester(req, action, reason)
 }
 
-// Placeholder implementations for remaining methods
 func (ac *ApprovalController) userExists(username, walletAddress string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR wallet_address = $2)`
 	var exists bool
@@ -512,64 +795,300 @@ func (ac *ApprovalController) userExists(username, walletAddress string) (bool,
 	return exists, err
 }
 
+const approvalRequestColumns = `id, request_type, requested_by, approver_role, approved_by, status, title,
+	description, request_data, approval_reason, rejection_reason, expires_at, created_at, updated_at, approved_at`
+
+// approvalUpdateColumns are the columns updateApprovalRequestTx may set
+var approvalUpdateColumns = map[string]bool{
+	"status":           true,
+	"approved_by":      true,
+	"approval_reason":  true,
+	"rejection_reason": true,
+	"updated_at":       true,
+	"approved_at":      true,
+}
+
+// errApprovalNotPending is returned when a request was decided by someone
+// else between being read and being updated
+var errApprovalNotPending = errors.New("approval request is no longer pending")
+
 func (ac *ApprovalController) getApprovalRequestByID(requestID int) (*models.ApprovalRequest, error) {
-	// Implementation to fetch approval request by ID
-	return nil, nil // Placeholder
+	row := db.DB.QueryRow(`SELECT `+approvalRequestColumns+` FROM approval_requests WHERE id = $1`, requestID)
+	return ac.scanApprovalRequest(row)
+}
+
+func (ac *ApprovalController) scanApprovalRequest(row interface{ Scan(...interface{}) error }) (*models.ApprovalRequest, error) {
+	req := &models.ApprovalRequest{}
+	var requestedBy sql.NullInt64
+	var description sql.NullString
+	var requestData db.JSONMap
+	err := row.Scan(
+		&req.ID, &req.RequestType, &requestedBy, &req.ApproverRole, &req.ApprovedBy, &req.Status, &req.Title,
+		&description, &requestData, &req.ApprovalReason, &req.RejectionReason, &req.ExpiresAt,
+		&req.CreatedAt, &req.UpdatedAt, &req.ApprovedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.RequestedBy = int(requestedBy.Int64)
+	req.Description = description.String
+	if requestData != nil {
+		req.RequestData = &requestData
+	}
+	return req, nil
 }
 
+// canViewApprovalRequest allows the requester and anyone who could vote on
+// the request to see it
 func (ac *ApprovalController) canViewApprovalRequest(req *models.ApprovalRequest, userRole string, userID int) bool {
-	// Implementation to check viewing permissions
-	return true // Placeholder
+	if req.RequestedBy == userID || req.CanBeApprovedBy(userRole) {
+		return true
+	}
+	policy, err := services.NewApprovalService(db.DB).ApprovalPolicy(req.RequestType)
+	return err == nil && policy.AllowsVoter(userRole)
 }
 
-func (ac *ApprovalController) listApprovalRequests(page, limit int, status, requestType string, forApproval bool, userRole string, userID int) ([]*models.ApprovalRequest, int, error) {
-	// Implementation to list approval requests with filtering
-	return nil, 0, nil // Placeholder
+// canVote reports whether a role may vote on a request. Without a quorum
+// policy the request's approver role, or any role above it, decides alone.
+func (ac *ApprovalController) canVote(req *models.ApprovalRequest, policy *models.ApprovalPolicy, userRole string) bool {
+	if len(policy.Requirements) == 0 {
+		return req.CanBeApprovedBy(userRole)
+	}
+	return policy.AllowsVoter(userRole)
 }
 
-func (ac *ApprovalController) updateApprovalRequestTx(tx *sql.Tx, requestID int, updateData map[string]interface{}) error {
-	// Implementation to update approval request in transaction
-	return nil // Placeholder
+// outstandingApprovals returns the approvals a request still needs
+func (ac *ApprovalController) outstandingApprovals(req *models.ApprovalRequest, policy *models.ApprovalPolicy, votes []models.ApprovalVote) []models.QuorumRequirement {
+	if len(policy.Requirements) > 0 {
+		return policy.Outstanding(votes)
+	}
+	for _, vote := range votes {
+		if vote.Decision == models.ApprovalStatusApproved {
+			return nil
+		}
+	}
+	return []models.QuorumRequirement{{Role: req.ApproverRole, Count: 1}}
 }
 
-func (ac *ApprovalController) processApprovedRequest(tx *sql.Tx, req *models.ApprovalRequest) error {
-	// Implementation to process approved requests (e.g., create user for supplier onboarding)
-	return nil // Placeholder
+// lockPendingRequestTx locks a request for the rest of the transaction
+func (ac *ApprovalController) lockPendingRequestTx(tx *sql.Tx, requestID int) error {
+	var status models.ApprovalStatus
+	err := tx.QueryRow(`SELECT status FROM approval_requests WHERE id = $1 FOR UPDATE`, requestID).Scan(&status)
+	if err == sql.ErrNoRows || (err == nil && status != models.ApprovalStatusPending) {
+		return errApprovalNotPending
+	}
+	return err
 }
 
-func (ac *ApprovalController) createPendingUserTx(tx *sql.Tx, user *models.PendingUser) error {
-	// Implementation to create pending user record
-	return nil // Placeholder
+// listApprovalRequests returns a page of the requests the user may view,
+// newest first. With forApproval only pending, unexpired requests the user
+// can still vote on are returned.
+func (ac *ApprovalController) listApprovalRequests(page, limit int, status, requestType string, forApproval bool, userRole string, userID int) ([]*models.ApprovalRequest, int, error) {
+	var approvable []string
+	for _, role := range models.GetValidRoles() {
+		if models.HasHigherOrEqualRole(userRole, role) {
+			approvable = append(approvable, role)
+		}
+	}
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	// Requests the user could vote on: by their quorum policy if the type
+	// has one, otherwise by approver role
+	args = append(args, pq.Array(approvable), userRole)
+	voter := fmt.Sprintf(`(CASE WHEN EXISTS (SELECT 1 FROM approval_policies p WHERE p.request_type = approval_requests.request_type)
+		THEN EXISTS (SELECT 1 FROM approval_policies p WHERE p.request_type = approval_requests.request_type AND p.approver_role::text = $%d)
+		ELSE approver_role::text = ANY($%d) END)`, len(args), len(args)-1)
+
+	if forApproval {
+		conditions = append(conditions, voter)
+		where("status = $%d", models.ApprovalStatusPending)
+		conditions = append(conditions, "(expires_at IS NULL OR expires_at > NOW())")
+		where("requested_by IS DISTINCT FROM $%d", userID)
+		where("NOT EXISTS (SELECT 1 FROM approval_votes v WHERE v.approval_request_id = approval_requests.id AND v.voter_id = $%d)", userID)
+	} else {
+		where("(requested_by = $%d OR approver_role::text = ANY($1) OR "+voter+")", userID)
+		if status != "" {
+			where("status::text = $%d", status)
+		}
+	}
+	if requestType != "" {
+		where("request_type::text = $%d", requestType)
+	}
+
+	from := "FROM approval_requests WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := db.DB.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	query := fmt.Sprintf("SELECT %s %s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d",
+		approvalRequestColumns, from, len(args)-1, len(args))
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	requests := []*models.ApprovalRequest{}
+	for rows.Next() {
+		req, err := ac.scanApprovalRequest(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, total, rows.Err()
 }
 
-func timePtr(t time.Time) *time.Time {
-	return &t
+// updateApprovalRequestTx sets the given columns of a pending request
+func (ac *ApprovalController) updateApprovalRequestTx(tx *sql.Tx, requestID int, updateData map[string]interface{}) error {
+	columns := make([]string, 0, len(updateData))
+	for column := range updateData {
+		if !approvalUpdateColumns[column] {
+			return fmt.Errorf("cannot update approval request column %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, len(columns))
+	args := make([]interface{}, 0, len(columns)+2)
+	for i, column := range columns {
+		args = append(args, updateData[column])
+		sets[i] = fmt.Sprintf("%s = $%d", column, len(args))
+	}
+	args = append(args, requestID, models.ApprovalStatusPending)
+
+	query := fmt.Sprintf("UPDATE approval_requests SET %s WHERE id = $%d AND status = $%d",
+		strings.Join(sets, ", "), len(args)-1, len(args))
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errApprovalNotPending
+	}
+	return nil
 }
 
-func nullableString(s string) *string {
-	if s == "" {
+// processApprovedRequest applies the change an approved request asks for,
+// with the choices made by the deciding approval
+func (ac *ApprovalController) processApprovedRequest(tx *sql.Tx, req *models.ApprovalRequest, decision *models.ApprovalAction) error {
+	switch req.RequestType {
+	case models.ApprovalTypeSupplierOnboarding:
+		return ac.activatePendingUserTx(tx, req.ID, decision.OrganisationID)
+	case models.ApprovalTypeUserRoleChange:
+		change, err := ac.roleChangeData(req.RequestData)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`UPDATE users SET role = $1 WHERE id = $2`, change.NewRole, change.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to change role: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("user %d not found", change.UserID)
+		}
+		// Tokens carry the role they were issued with, so the user signs in
+		// again to act under the new one
+		if _, err := services.NewSessionService(db.DB).RevokeUserSessionsTx(tx, change.UserID, models.SessionRevokedRoleChange); err != nil {
+			return err
+		}
+		return nil
+	default:
+		// System configuration requests are recorded for the approval trail only
 		return nil
 	}
-	return &s
 }
 
-func isValidWalletAddress(address string) bool {
-	return len(address) == 42 && strings.HasPrefix(address, "0x")
+// activatePendingUserTx creates the user account held for an onboarding
+// request and removes the pending record. The user joins organisationID
+// when the approver picked one, and otherwise a new organisation for their
+// company.
+func (ac *ApprovalController) activatePendingUserTx(tx *sql.Tx, requestID int, organisationID *int) error {
+	user := &models.PendingUser{}
+	var contactInfo db.JSONMap
+	err := tx.QueryRow(`
+		SELECT id, wallet_address, username, email, password_hash, requested_role, company_name, contact_info
+		FROM pending_users WHERE approval_request_id = $1
+		FOR UPDATE`, requestID,
+	).Scan(&user.ID, &user.WalletAddress, &user.Username, &user.Email, &user.PasswordHash,
+		&user.RequestedRole, &user.CompanyName, &contactInfo)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no pending user for approval request %d", requestID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pending user: %w", err)
+	}
+
+	// A company name is only what the supplier typed in, so it never
+	// places them in an existing organisation
+	if organisationID == nil && user.CompanyName != nil {
+		organisationID, err = services.NewOrganisationService(db.DB).CreateOrganisationTx(tx, *user.CompanyName)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO users (wallet_address, username, email, password_hash, role, company_name, organisation_id, contact_info, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, true)`,
+		user.WalletAddress, user.Username, user.Email, user.PasswordHash,
+		user.RequestedRole, user.CompanyName, organisationID, contactInfo,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM pending_users WHERE id = $1`, user.ID); err != nil {
+		return fmt.Errorf("failed to remove pending user: %w", err)
+	}
+	return nil
 }
 
-// Add missing PendingUser model
-type PendingUser struct {
-	ID                int             `json:"id"`
-	ApprovalRequestID int             `json:"approval_request_id"`
-	WalletAddress     string          `json:"wallet_address"`
-	Username          string          `json:"username"`
-	Email             *string         `json:"email"`
-	PasswordHash      string          `json:"-"`
-	RequestedRole     string          `json:"requested_role"`
-	CompanyName       *string         `json:"company_name"`
-	CompanyType       *string         `json:"company_type"`
-	BusinessLicense   *string         `json:"business_license"`
-	ContactInfo       *models.JSONMap `json:"contact_info"`
-	Justification     *string         `json:"justification"`
-	CreatedAt         time.Time       `json:"created_at"`
+// roleChangeData reads and checks the target of a user_role_change request
+func (ac *ApprovalController) roleChangeData(data *db.JSONMap) (*models.UserRoleChangeData, error) {
+	if data == nil {
+		return nil, fmt.Errorf("role change request has no request data")
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	change := &models.UserRoleChangeData{}
+	if err := json.Unmarshal(encoded, change); err != nil {
+		return nil, fmt.Errorf("invalid role change request data: %w", err)
+	}
+	if change.UserID <= 0 {
+		return nil, fmt.Errorf("role change request data requires user_id")
+	}
+	if !ac.isValidRole(change.NewRole) {
+		return nil, fmt.Errorf("role change request data has invalid new_role %q", change.NewRole)
+	}
+	return change, nil
+}
+
+func (ac *ApprovalController) createPendingUserTx(tx *sql.Tx, user *models.PendingUser) error {
+	query := `
+		INSERT INTO pending_users (approval_request_id, wallet_address, username, email, password_hash, requested_role,
+			company_name, company_type, business_license, contact_info, justification, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`
+
+	return tx.QueryRow(
+		query,
+		user.ApprovalRequestID, user.WalletAddress, user.Username, user.Email, user.PasswordHash, user.RequestedRole,
+		user.CompanyName, user.CompanyType, user.BusinessLicense, user.ContactInfo, user.Justification, user.CreatedAt,
+	).Scan(&user.ID)
 }
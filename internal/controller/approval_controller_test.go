@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// newApprovalDB installs a fake database holding pending request 1, made by
+// user 2, to give user 5 newRole, and a user_role_change policy of
+// requirements. Votes are answered with votes.
+func newApprovalDB(t *testing.T, newRole string, requirements [][]interface{}, votes ...[]interface{}) *dbtest.DB {
+	t.Helper()
+	withTestConfig(t)
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+	fake.OnQuery(`FROM approval_policies`, []string{"approver_role", "required_votes"}, requirements...)
+
+	now := time.Now()
+	fake.OnQuery(`FOR UPDATE`, []string{"status"}, []interface{}{string(models.ApprovalStatusPending)})
+	columns := dbtest.Columns(approvalRequestColumns)
+	fake.OnQuery(`FROM approval_requests WHERE id = $1`, columns, dbtest.Row(columns, map[string]interface{}{
+		"id":            int64(1),
+		"request_type":  string(models.ApprovalTypeUserRoleChange),
+		"requested_by":  int64(2),
+		"approver_role": models.RoleSuperAdmin,
+		"status":        string(models.ApprovalStatusPending),
+		"title":         "Promote user 5",
+		"request_data":  []byte(`{"user_id": 5, "new_role": "` + newRole + `"}`),
+		"expires_at":    now.Add(24 * time.Hour),
+		"created_at":    now,
+		"updated_at":    now,
+	}))
+	fake.OnQuery(`INSERT INTO approval_requests`, []string{"id"}, []interface{}{int64(1)})
+	fake.OnQuery(`INSERT INTO approval_votes`, []string{"id", "created_at"}, []interface{}{int64(1), now})
+	fake.OnQuery(`FROM approval_votes`, dbtest.Columns(`id, approval_request_id, voter_id, voter_role, decision, reason, created_at`), votes...)
+	fake.OnQuery(`FROM audit_logs`, []string{"entry_hash"})
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	return fake
+}
+
+func TestRoleChangeQuorumCannotGrantMoreThanVotersHold(t *testing.T) {
+	quorum := [][]interface{}{{models.RoleAdmin, int64(1)}, {models.RoleAuditor, int64(1)}}
+
+	tests := []struct {
+		name    string
+		newRole string
+		voter   string
+		approve bool
+		want    int
+	}{
+		{name: "voter below the requested role approves", newRole: models.RoleAdmin, voter: models.RoleAuditor, approve: true, want: http.StatusForbidden},
+		{name: "voter below the requested role rejects", newRole: models.RoleAdmin, voter: models.RoleAuditor, want: http.StatusOK},
+		{name: "voter holding the requested role approves", newRole: models.RoleAdmin, voter: models.RoleAdmin, approve: true, want: http.StatusAccepted},
+		{name: "voter above the requested role approves", newRole: models.RoleAuditor, voter: models.RoleAuditor, approve: true, want: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := models.ApprovalStatusRejected
+			if tt.approve {
+				decision = models.ApprovalStatusApproved
+			}
+			fake := newApprovalDB(t, tt.newRole, quorum,
+				[]interface{}{int64(1), int64(1), int64(3), tt.voter, string(decision), nil, time.Now()})
+
+			user := auth.Claims{UserID: 3, Username: "voter", Role: tt.voter}
+			r := authorizedRequest(t, http.MethodPost, "/api/approvals/1/approve", `{"reason": "checked"}`, user,
+				map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+			if tt.approve {
+				NewApprovalController().ApproveRequest(w, r)
+			} else {
+				NewApprovalController().RejectRequest(w, r)
+			}
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if recorded := len(fake.Statements(`INSERT INTO approval_votes`)); (recorded == 1) != (tt.want != http.StatusForbidden) {
+				t.Errorf("recorded %d votes", recorded)
+			}
+			if changed := fake.Statements(`UPDATE users SET role`); len(changed) != 0 {
+				t.Errorf("role changed before the quorum was met: %+v", changed)
+			}
+		})
+	}
+}
+
+func TestCreateRoleChangeChecksQuorum(t *testing.T) {
+	tests := []struct {
+		name    string
+		newRole string
+		quorum  [][]interface{}
+		want    int
+	}{
+		{name: "no quorum policy", newRole: models.RoleAdmin, want: http.StatusCreated},
+		{name: "quorum of higher roles", newRole: models.RoleAuditor,
+			quorum: [][]interface{}{{models.RoleAdmin, int64(1)}, {models.RoleAuditor, int64(1)}}, want: http.StatusCreated},
+		{name: "quorum names a lower role", newRole: models.RoleAdmin,
+			quorum: [][]interface{}{{models.RoleAdmin, int64(1)}, {models.RoleAuditor, int64(1)}}, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newApprovalDB(t, tt.newRole, tt.quorum)
+
+			body := `{"request_type": "user_role_change", "approver_role": "super_admin", "title": "Promote user 5",
+				"description": "Takes over administration", "request_data": {"user_id": 5, "new_role": "` + tt.newRole + `"}}`
+			user := auth.Claims{UserID: 2, Username: "requester", Role: models.RoleAdmin}
+			w := httptest.NewRecorder()
+			NewApprovalController().CreateApprovalRequest(w, authorizedRequest(t, http.MethodPost, "/api/approvals", body, user, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestApprovedRoleChangeRevokesSessions(t *testing.T) {
+	fake := newApprovalDB(t, models.RoleAuditor, nil,
+		[]interface{}{int64(1), int64(1), int64(3), models.RoleSuperAdmin, string(models.ApprovalStatusApproved), nil, time.Now()})
+
+	user := auth.Claims{UserID: 3, Username: "approver", Role: models.RoleSuperAdmin}
+	w := httptest.NewRecorder()
+	NewApprovalController().ApproveRequest(w, authorizedRequest(t, http.MethodPost, "/api/approvals/1/approve", `{}`, user,
+		map[string]string{"id": "1"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	changed := fake.Statements(`UPDATE users SET role`)
+	if len(changed) != 1 || changed[0].Args[0] != models.RoleAuditor || changed[0].Args[1] != int64(5) {
+		t.Fatalf("role changes = %+v, want user 5 made auditor", changed)
+	}
+	revoked := fake.Statements(`UPDATE user_sessions SET revoked_at`)
+	if len(revoked) != 1 || revoked[0].Args[0] != int64(5) || revoked[0].Args[1] != models.SessionRevokedRoleChange {
+		t.Errorf("session revocations = %+v, want the sessions of user 5", revoked)
+	}
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/services"
+)
+
+type AuditController struct{}
+
+func NewAuditController() *AuditController {
+	return &AuditController{}
+}
+
+// GetAuditLogs returns a page of audit log entries, newest first
+func (ac *AuditController) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	claims, err := ac.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 100
+	}
+
+	filter := services.AuditFilter{
+		User:         query.Get("user"),
+		Role:         query.Get("role"),
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+		Page:         page,
+		Limit:        limit,
+	}
+	if filter.From, err = ac.parseTime(query.Get("from"), false); err != nil {
+		http.Error(w, "from must be a date (YYYY-MM-DD) or RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = ac.parseTime(query.Get("to"), true); err != nil {
+		http.Error(w, "to must be a date (YYYY-MM-DD) or RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+
+	logs, total, err := services.NewAuditService(db.DB).ListAuditLogs(filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve audit logs", http.StatusInternalServerError)
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "VIEW", "audit_logs", "", nil, query)
+
+	response := map[string]interface{}{
+		"logs":        logs,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": (total + limit - 1) / limit,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyAuditChain walks the audit hash chain and its anchors and reports
+// the first broken link
+func (ac *AuditController) VerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	claims, err := ac.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := services.NewAuditService(db.DB).VerifyAuditChain()
+	if err != nil {
+		http.Error(w, "Failed to verify audit chain", http.StatusInternalServerError)
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "VERIFY", "audit_logs", "", nil, report)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetAuditAnchors lists the daily Merkle roots published for the audit chain
+func (ac *AuditController) GetAuditAnchors(w http.ResponseWriter, r *http.Request) {
+	if _, err := ac.extractUserClaims(r); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	anchors, err := services.NewAuditService(db.DB).ListAuditAnchors()
+	if err != nil {
+		http.Error(w, "Failed to retrieve audit anchors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"anchors": anchors,
+		"count":   len(anchors),
+	})
+}
+
+// parseTime reads a time filter. A bare date as an upper bound covers the
+// whole day.
+func (ac *AuditController) parseTime(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func (ac *AuditController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
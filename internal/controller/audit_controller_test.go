@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+func TestGetAuditLogs(t *testing.T) {
+	withTestConfig(t)
+	fake := dbtest.New(t)
+	fake.OnQuery(`SELECT COUNT(*) FROM audit_logs`, []string{"count"}, []interface{}{int64(45)})
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	fake.OnQuery(`SELECT entry_hash FROM audit_logs`, []string{"entry_hash"})
+	fake.OnQuery(`FROM audit_logs a`, dbtest.Columns(`id, user_id, user_role, action, resource_type, resource_id,
+		old_values, new_values, ip_address, user_agent, success, error_message, session_id, created_at,
+		prev_hash, entry_hash`),
+		[]interface{}{int64(7), int64(3), "admin", "DELETE", "passport", "AP-1", nil, nil, nil, nil, true, nil, nil,
+			time.Now(), nil, nil})
+
+	auditor := auth.Claims{UserID: 4, Username: "auditor", Role: models.RoleAuditor}
+	w := httptest.NewRecorder()
+	NewAuditController().GetAuditLogs(w, authorizedRequest(t, http.MethodGet,
+		"/api/audit/logs?page=2&limit=20&action=delete&to=2025-03-31", "", auditor, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var response struct {
+		Logs       []map[string]interface{} `json:"logs"`
+		Total      int                      `json:"total"`
+		Page       int                      `json:"page"`
+		TotalPages int                      `json:"total_pages"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(response.Logs) != 1 || response.Total != 45 || response.Page != 2 || response.TotalPages != 3 {
+		t.Errorf("response = %+v, want 1 log of 45 on page 2 of 3", response)
+	}
+
+	// A bare date as the upper bound covers the whole day
+	counts := fake.Statements(`SELECT COUNT(*) FROM audit_logs`)
+	if len(counts) != 1 {
+		t.Fatalf("counted %d times, want once", len(counts))
+	}
+	to, _ := counts[0].Args[1].(time.Time)
+	if counts[0].Args[0] != "DELETE" || !to.Equal(time.Date(2025, 3, 31, 23, 59, 59, 999999999, time.UTC)) {
+		t.Errorf("args = %v, want DELETE up to the end of 2025-03-31", counts[0].Args)
+	}
+	if views := fake.Statements(`INSERT INTO audit_logs`); len(views) != 1 || views[0].Args[2] != "VIEW" {
+		t.Errorf("viewing the audit log was not audited: %+v", views)
+	}
+}
+
+func TestGetAuditLogsRejectsInvalidRequests(t *testing.T) {
+	withTestConfig(t)
+	dbtest.New(t)
+	auditor := auth.Claims{UserID: 4, Username: "auditor", Role: models.RoleAuditor}
+
+	w := httptest.NewRecorder()
+	NewAuditController().GetAuditLogs(w, httptest.NewRequest(http.MethodGet, "/api/audit/logs", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	NewAuditController().GetAuditLogs(w, authorizedRequest(t, http.MethodGet, "/api/audit/logs?from=31/03/2025", "", auditor, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid from: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
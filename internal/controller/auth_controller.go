@@ -3,14 +3,19 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"aluminium-passport/internal/auth"
 	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+
+	"github.com/gorilla/mux"
 )
 
 type AuthController struct{}
@@ -44,16 +49,20 @@ type AuthResponse struct {
 }
 
 type UserResponse struct {
-	ID            int         `json:"id"`
-	WalletAddress string      `json:"wallet_address"`
-	Username      string      `json:"username"`
-	Email         *string     `json:"email"`
-	Role          string      `json:"role"`
-	CompanyName   *string     `json:"company_name"`
-	ContactInfo   *db.JSONMap `json:"contact_info"`
-	IsActive      bool        `json:"is_active"`
-	CreatedAt     time.Time   `json:"created_at"`
-	LastLogin     *time.Time  `json:"last_login"`
+	ID             int         `json:"id"`
+	WalletAddress  string      `json:"wallet_address"`
+	Username       string      `json:"username"`
+	Email          *string     `json:"email"`
+	Role           string      `json:"role"`
+	CompanyName    *string     `json:"company_name"`
+	OrganisationID *int        `json:"organisation_id"`
+	ContactInfo    *db.JSONMap `json:"contact_info"`
+	IsActive       bool        `json:"is_active"`
+	CreatedAt      time.Time   `json:"created_at"`
+	LastLogin      *time.Time  `json:"last_login"`
+
+	// Active sessions, only in the profile
+	Sessions []*models.Session `json:"sessions,omitempty"`
 }
 
 // Login authenticates a user and returns JWT tokens
@@ -87,15 +96,8 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate token pair
-	tokens, err := auth.GenerateTokenPair(
-		user.ID,
-		user.Username,
-		getStringValue(user.Email),
-		user.Role,
-		user.WalletAddress,
-		getStringValue(user.CompanyName),
-	)
+	// Start a session and generate its token pair
+	tokens, err := services.NewSessionService(db.DB).StartSession(user, r)
 	if err != nil {
 		http.Error(w, "Failed to generate tokens", http.StatusInternalServerError)
 		return
@@ -112,16 +114,17 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare response
 	userResponse := &UserResponse{
-		ID:            user.ID,
-		WalletAddress: user.WalletAddress,
-		Username:      user.Username,
-		Email:         user.Email,
-		Role:          user.Role,
-		CompanyName:   user.CompanyName,
-		ContactInfo:   user.ContactInfo,
-		IsActive:      user.IsActive,
-		CreatedAt:     user.CreatedAt,
-		LastLogin:     user.LastLogin,
+		ID:             user.ID,
+		WalletAddress:  user.WalletAddress,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.Role,
+		CompanyName:    user.CompanyName,
+		OrganisationID: user.OrganisationID,
+		ContactInfo:    user.ContactInfo,
+		IsActive:       user.IsActive,
+		CreatedAt:      user.CreatedAt,
+		LastLogin:      user.LastLogin,
 	}
 
 	response := &AuthResponse{
@@ -134,6 +137,88 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// SIWENonce issues a nonce for Sign-In with Ethereum. With ?address= the
+// nonce can only be used by that wallet and the message to sign is
+// returned ready-made.
+func (ac *AuthController) SIWENonce(w http.ResponseWriter, r *http.Request) {
+	challenge, err := services.NewSIWEService(db.DB).IssueNonce(r.URL.Query().Get("address"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSIWE) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to issue nonce", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// SIWEVerify signs in the user whose wallet signed an EIP-4361 message
+func (ac *AuthController) SIWEVerify(w http.ResponseWriter, r *http.Request) {
+	var req models.SIWELoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" || req.Signature == "" {
+		http.Error(w, "message and signature are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := services.NewSIWEService(db.DB).Verify(req.Message, req.Signature)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSIWE), errors.Is(err, services.ErrSIWENonce),
+			errors.Is(err, services.ErrWalletNotRegistered):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !user.IsActive {
+		http.Error(w, "Account is deactivated", http.StatusForbidden)
+		return
+	}
+
+	tokens, err := services.NewSessionService(db.DB).StartSession(user, r)
+	if err != nil {
+		http.Error(w, "Failed to generate tokens", http.StatusInternalServerError)
+		return
+	}
+
+	if err := ac.updateLastLogin(user.ID); err != nil {
+		// Log error but don't fail the login
+		fmt.Printf("Failed to update last login for user %d: %v\n", user.ID, err)
+	}
+
+	ac.logAuditEvent(user.ID, user.Role, "LOGIN", "user", fmt.Sprintf("%d", user.ID), nil, &db.JSONMap{"method": "siwe"}, r)
+
+	response := &AuthResponse{
+		User: &UserResponse{
+			ID:             user.ID,
+			WalletAddress:  user.WalletAddress,
+			Username:       user.Username,
+			Email:          user.Email,
+			Role:           user.Role,
+			CompanyName:    user.CompanyName,
+			OrganisationID: user.OrganisationID,
+			ContactInfo:    user.ContactInfo,
+			IsActive:       user.IsActive,
+			CreatedAt:      user.CreatedAt,
+			LastLogin:      user.LastLogin,
+		},
+		Tokens:  tokens,
+		Message: "Login successful",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // Register creates a new user account
 func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
@@ -203,15 +288,8 @@ func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 
 	user.ID = userID
 
-	// Generate token pair
-	tokens, err := auth.GenerateTokenPair(
-		user.ID,
-		user.Username,
-		getStringValue(user.Email),
-		user.Role,
-		user.WalletAddress,
-		getStringValue(user.CompanyName),
-	)
+	// Start a session and generate its token pair
+	tokens, err := services.NewSessionService(db.DB).StartSession(user, r)
 	if err != nil {
 		http.Error(w, "Failed to generate tokens", http.StatusInternalServerError)
 		return
@@ -222,16 +300,17 @@ func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare response
 	userResponse := &UserResponse{
-		ID:            user.ID,
-		WalletAddress: user.WalletAddress,
-		Username:      user.Username,
-		Email:         user.Email,
-		Role:          user.Role,
-		CompanyName:   user.CompanyName,
-		ContactInfo:   user.ContactInfo,
-		IsActive:      user.IsActive,
-		CreatedAt:     user.CreatedAt,
-		LastLogin:     user.LastLogin,
+		ID:             user.ID,
+		WalletAddress:  user.WalletAddress,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.Role,
+		CompanyName:    user.CompanyName,
+		OrganisationID: user.OrganisationID,
+		ContactInfo:    user.ContactInfo,
+		IsActive:       user.IsActive,
+		CreatedAt:      user.CreatedAt,
+		LastLogin:      user.LastLogin,
 	}
 
 	response := &AuthResponse{
@@ -245,7 +324,8 @@ func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// RefreshToken generates new tokens from a refresh token
+// RefreshToken rotates the tokens of a session. A refresh token can be
+// used once; using it again revokes the session.
 func (ac *AuthController) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var req RefreshTokenRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -253,22 +333,27 @@ func (ac *AuthController) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Refresh tokens
-	tokens, err := auth.RefreshToken(req.RefreshToken)
-	if err != nil {
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
-		return
-	}
-
-	// Get user info from token
-	claims, err := auth.ValidateToken(tokens.AccessToken)
+	tokens, user, err := services.NewSessionService(db.DB).Refresh(req.RefreshToken, r)
 	if err != nil {
-		http.Error(w, "Failed to validate new token", http.StatusInternalServerError)
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			// Someone holds a copy of the token; the session is revoked
+			if claims, err := auth.ExtractClaims(req.RefreshToken); err == nil {
+				ac.logAuditEvent(claims.UserID, claims.Role, "REFRESH_TOKEN_REUSE", "session", claims.SessionID, nil, nil, r)
+			}
+			http.Error(w, "Refresh token has already been used; the session has been revoked", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, services.ErrSessionRevoked) || errors.Is(err, services.ErrSessionNotFound) ||
+			errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrExpiredToken) || errors.Is(err, auth.ErrInvalidClaims) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh tokens", http.StatusInternalServerError)
 		return
 	}
 
 	// Log audit event
-	ac.logAuditEvent(claims.UserID, claims.Role, "REFRESH_TOKEN", "token", "refresh", nil, nil, r)
+	ac.logAuditEvent(user.ID, user.Role, "REFRESH_TOKEN", "session", tokens.SessionID, nil, nil, r)
 
 	response := map[string]interface{}{
 		"tokens":  tokens,
@@ -279,7 +364,7 @@ func (ac *AuthController) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Logout invalidates the user's session (placeholder - would need token blacklisting in production)
+// Logout revokes the session of the request's token
 func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	// Extract user info from token
 	authHeader := r.Header.Get("Authorization")
@@ -295,11 +380,15 @@ func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = services.NewSessionService(db.DB).RevokeSession(claims.UserID, claims.SessionID, models.SessionRevokedLogout)
+	if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		http.Error(w, "Failed to end session", http.StatusInternalServerError)
+		return
+	}
+
 	// Log audit event
 	ac.logAuditEvent(claims.UserID, claims.Role, "LOGOUT", "user", fmt.Sprintf("%d", claims.UserID), nil, nil, r)
 
-	// In production, you would add the token to a blacklist here
-
 	response := map[string]string{
 		"message": "Logout successful",
 	}
@@ -308,6 +397,77 @@ func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// RevokeSession ends one of the caller's own sessions, such as a login on
+// another device
+func (ac *AuthController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ValidateToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := mux.Vars(r)["id"]
+	if err := services.NewSessionService(db.DB).RevokeSession(claims.UserID, sessionID, models.SessionRevokedByUser); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	ac.logAuditEvent(claims.UserID, claims.Role, "REVOKE", "session", sessionID, nil, nil, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeUserSessions signs a user out everywhere. Admins cannot revoke
+// the sessions of users with a higher role.
+func (ac *AuthController) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ValidateToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := ac.getUserByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !models.HasHigherOrEqualRole(claims.Role, user.Role) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	revoked, err := services.NewSessionService(db.DB).RevokeUserSessions(userID, models.SessionRevokedByAdmin)
+	if err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	ac.logAuditEvent(claims.UserID, claims.Role, "REVOKE_SESSIONS", "user", strconv.Itoa(userID), nil, &db.JSONMap{"revoked": revoked}, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Sessions revoked",
+		"revoked": revoked,
+	})
+}
+
 // GetProfile returns the current user's profile
 func (ac *AuthController) GetProfile(w http.ResponseWriter, r *http.Request) {
 	// Extract user info from token
@@ -328,17 +488,25 @@ func (ac *AuthController) GetProfile(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare response
 	userResponse := &UserResponse{
-		ID:            user.ID,
-		WalletAddress: user.WalletAddress,
-		Username:      user.Username,
-		Email:         user.Email,
-		Role:          user.Role,
-		CompanyName:   user.CompanyName,
-		ContactInfo:   user.ContactInfo,
-		IsActive:      user.IsActive,
-		CreatedAt:     user.CreatedAt,
-		LastLogin:     user.LastLogin,
+		ID:             user.ID,
+		WalletAddress:  user.WalletAddress,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.Role,
+		CompanyName:    user.CompanyName,
+		OrganisationID: user.OrganisationID,
+		ContactInfo:    user.ContactInfo,
+		IsActive:       user.IsActive,
+		CreatedAt:      user.CreatedAt,
+		LastLogin:      user.LastLogin,
+	}
+
+	sessions, err := services.NewSessionService(db.DB).ActiveSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
 	}
+	userResponse.Sessions = sessions
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userResponse)
@@ -348,14 +516,14 @@ func (ac *AuthController) GetProfile(w http.ResponseWriter, r *http.Request) {
 func (ac *AuthController) getUserByUsername(username string) (*db.User, error) {
 	query := `
 		SELECT id, wallet_address, username, email, password_hash, role, 
-		       company_name, contact_info, is_active, created_at, updated_at, last_login
+		       company_name, organisation_id, contact_info, is_active, created_at, updated_at, last_login
 		FROM users 
 		WHERE username = $1`
 
 	user := &db.User{}
 	err := db.DB.QueryRow(query, username).Scan(
 		&user.ID, &user.WalletAddress, &user.Username, &user.Email, &user.PasswordHash,
-		&user.Role, &user.CompanyName, &user.ContactInfo, &user.IsActive,
+		&user.Role, &user.CompanyName, &user.OrganisationID, &user.ContactInfo, &user.IsActive,
 		&user.CreatedAt, &user.UpdatedAt, &user.LastLogin,
 	)
 
@@ -365,14 +533,14 @@ func (ac *AuthController) getUserByUsername(username string) (*db.User, error) {
 func (ac *AuthController) getUserByID(userID int) (*db.User, error) {
 	query := `
 		SELECT id, wallet_address, username, email, password_hash, role, 
-		       company_name, contact_info, is_active, created_at, updated_at, last_login
+		       company_name, organisation_id, contact_info, is_active, created_at, updated_at, last_login
 		FROM users 
 		WHERE id = $1`
 
 	user := &db.User{}
 	err := db.DB.QueryRow(query, userID).Scan(
 		&user.ID, &user.WalletAddress, &user.Username, &user.Email, &user.PasswordHash,
-		&user.Role, &user.CompanyName, &user.ContactInfo, &user.IsActive,
+		&user.Role, &user.CompanyName, &user.OrganisationID, &user.ContactInfo, &user.IsActive,
 		&user.CreatedAt, &user.UpdatedAt, &user.LastLogin,
 	)
 
@@ -410,8 +578,7 @@ func (ac *AuthController) updateLastLogin(userID int) error {
 }
 
 func (ac *AuthController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues *db.JSONMap, r *http.Request) {
-	// This would log to the audit_logs table
-	// Implementation depends on your audit logging requirements
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
 }
 
 // Helper functions
@@ -422,13 +589,6 @@ func nullableString(s string) *string {
 	return &s
 }
 
-func getStringValue(s *string) string {
-	if s == nil {
-		return ""
-	}
-	return *s
-}
-
 func isValidWalletAddress(address string) bool {
 	// Basic Ethereum address validation
 	return len(address) == 42 && strings.HasPrefix(address, "0x")
@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+	"aluminium-passport/internal/utils"
+)
+
+type BatchController struct{}
+
+func NewBatchController() *BatchController {
+	return &BatchController{}
+}
+
+// UploadBatch queues the passports in an uploaded ZIP of CSV or JSON files
+// for background import. Progress and per-row errors are reported by
+// GetBatchStatus.
+func (bc *BatchController) UploadBatch(w http.ResponseWriter, r *http.Request) {
+	claims, err := bc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	records, fileErrors, ok := bc.readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	batch, err := services.NewBatchService(db.DB).SubmitBatch(records, fileErrors, &claims.UserID)
+	if errors.Is(err, services.ErrEmptyBatch) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"errors": fileErrors,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to queue batch", http.StatusInternalServerError)
+		return
+	}
+
+	bc.logAuditEvent(claims.UserID, claims.Role, "BATCH_UPLOAD", "batch", batch.BatchID, nil, map[string]interface{}{
+		"total_records": len(records),
+	}, r)
+
+	response := map[string]interface{}{
+		"batch":      batch,
+		"status_url": "/api/batch/status?batch_id=" + batch.BatchID,
+		"message":    "Batch queued for processing",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ValidateBatch checks an uploaded ZIP with the same rules as UploadBatch
+// without storing anything
+func (bc *BatchController) ValidateBatch(w http.ResponseWriter, r *http.Request) {
+	claims, err := bc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	records, fileErrors, ok := bc.readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := services.NewBatchService(db.DB).ValidateBatch(records, fileErrors, claims.Role)
+	if err != nil {
+		http.Error(w, "Failed to validate batch", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetBatchStatus returns the progress and errors of a batch upload
+func (bc *BatchController) GetBatchStatus(w http.ResponseWriter, r *http.Request) {
+	if _, err := bc.extractUserClaims(r); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID == "" {
+		http.Error(w, "batch_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := services.NewBatchService(db.DB).GetBatchStatus(batchID)
+	if errors.Is(err, services.ErrBatchNotFound) {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get batch status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// CancelBatch stops a queued or running batch. Passports already stored
+// are kept.
+func (bc *BatchController) CancelBatch(w http.ResponseWriter, r *http.Request) {
+	claims, err := bc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID == "" {
+		http.Error(w, "batch_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	service := services.NewBatchService(db.DB)
+	batch, err := service.GetBatchStatus(batchID)
+	if errors.Is(err, services.ErrBatchNotFound) {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get batch status", http.StatusInternalServerError)
+		return
+	}
+
+	// Uploaders may cancel their own batches; batch:cancel_any allows any
+	isOwner := batch.CreatedBy != nil && *batch.CreatedBy == claims.UserID
+	if !isOwner && !services.HasPermission(claims.Role, models.PermBatchCancelAny) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	batch, err = service.CancelBatch(batchID)
+	if errors.Is(err, services.ErrBatchFinished) {
+		http.Error(w, "Batch has already finished", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to cancel batch", http.StatusInternalServerError)
+		return
+	}
+
+	bc.logAuditEvent(claims.UserID, claims.Role, "BATCH_CANCEL", "batch", batchID, nil, nil, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetBatchTemplate returns a CSV or JSON template listing the import columns
+func (bc *BatchController) GetBatchTemplate(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=aluminium_passport_template.csv")
+		w.Write(services.BatchTemplateCSV())
+	case "json":
+		template, err := services.BatchTemplateJSON()
+		if err != nil {
+			http.Error(w, "Failed to build template", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=aluminium_passport_template.json")
+		w.Write(template)
+	default:
+		http.Error(w, "Supported formats: csv, json", http.StatusBadRequest)
+	}
+}
+
+// readUpload reads the rows of the zip_file form field. It writes the error
+// response itself and returns false if the upload cannot be read.
+func (bc *BatchController) readUpload(w http.ResponseWriter, r *http.Request) ([]models.BatchRecord, []models.BatchRowError, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxZipSize)
+	if err := r.ParseMultipartForm(utils.MaxZipSize); err != nil {
+		http.Error(w, "File too large or invalid form data", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var fileHeader *multipart.FileHeader
+	if files := r.MultipartForm.File["zip_file"]; len(files) > 0 {
+		fileHeader = files[0]
+	}
+	if fileHeader == nil {
+		http.Error(w, "zip_file is required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".zip") {
+		http.Error(w, "Only ZIP files are allowed", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	records, fileErrors, err := utils.NewZipProcessor().ProcessZipFile(fileHeader)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read ZIP file: %v", err), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	return records, fileErrors, true
+}
+
+func (bc *BatchController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
+
+func (bc *BatchController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
+}
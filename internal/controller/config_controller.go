@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+)
+
+type ConfigController struct{}
+
+func NewConfigController() *ConfigController {
+	return &ConfigController{}
+}
+
+// GetSystemConfig returns the permissions of every role and the permissions
+// that can be granted
+func (cc *ConfigController) GetSystemConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := cc.systemConfig()
+	if err != nil {
+		http.Error(w, "Failed to load system configuration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
+
+// UpdateSystemConfig changes the permissions of some roles. Changes apply
+// to requests made after the update, including those of signed-in users.
+func (cc *ConfigController) UpdateSystemConfig(w http.ResponseWriter, r *http.Request) {
+	claims, err := cc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.SystemConfigUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.RolePermissions) == 0 && len(req.ResetRoles) == 0 {
+		http.Error(w, "role_permissions or reset_roles is required", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := cc.systemConfig()
+	if err != nil {
+		http.Error(w, "Failed to load system configuration", http.StatusInternalServerError)
+		return
+	}
+
+	if err := services.NewPolicyService(db.DB).SetRolePermissions(req.RolePermissions, req.ResetRoles, claims.UserID); err != nil {
+		if errors.Is(err, services.ErrInvalidRolePermissions) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update system configuration", http.StatusInternalServerError)
+		return
+	}
+
+	config, err := cc.systemConfig()
+	if err != nil {
+		http.Error(w, "Failed to load system configuration", http.StatusInternalServerError)
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "UPDATE", "system_config", "role_permissions",
+		previous.RolePermissions, config.RolePermissions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
+
+func (cc *ConfigController) systemConfig() (*models.SystemConfig, error) {
+	bindings, customised, err := services.NewPolicyService(db.DB).RolePermissions()
+	if err != nil {
+		return nil, err
+	}
+	return &models.SystemConfig{
+		RolePermissions: bindings,
+		CustomisedRoles: customised,
+		Permissions:     models.PermissionDefinitions(),
+	}, nil
+}
+
+func (cc *ConfigController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
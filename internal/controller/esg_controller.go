@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -11,6 +12,8 @@ import (
 
 	"aluminium-passport/internal/auth"
 	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
 
 	"github.com/gorilla/mux"
 )
@@ -81,8 +84,7 @@ func (ec *ESGController) CreateESGAssessment(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Check if user has certifier role
-	if !ec.hasRole(claims.Role, []string{"certifier", "admin"}) {
+	if !services.HasPermission(claims.Role, models.PermESGAssess) {
 		http.Error(w, "Insufficient permissions", http.StatusForbidden)
 		return
 	}
@@ -93,12 +95,18 @@ func (ec *ESGController) CreateESGAssessment(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Validate passport exists
-	if exists, err := ec.passportExists(req.PassportID); err != nil {
+	// Validate passport exists and the assessor may change its ESG section
+	access, err := ec.passportAccess(req.PassportID, claims)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
-	} else if !exists {
-		http.Error(w, "Passport not found", http.StatusNotFound)
+	}
+	if denied := access.DeniedFields(claims.Role, models.PassportSections()[models.SectionESG]); len(denied) > 0 {
+		writeDeniedFields(w, denied)
 		return
 	}
 
@@ -150,6 +158,15 @@ func (ec *ESGController) CreateESGAssessment(w http.ResponseWriter, r *http.Requ
 
 	// Log audit event
 	ec.logAuditEvent(claims.UserID, claims.Role, "CREATE", "esg_assessment", req.PassportID, nil, esgMetrics, r)
+	services.PublishWebhookEvent(models.WebhookPassportCertified, req.PassportID, map[string]interface{}{
+		"passport_id":         req.PassportID,
+		"overall_esg_score":   overallScore,
+		"environmental_score": envScore,
+		"social_score":        socialScore,
+		"governance_score":    govScore,
+		"certification_level": ec.getCertificationLevel(overallScore),
+		"assessment":          esgMetrics,
+	})
 
 	// Prepare response
 	response := &ESGResponse{
@@ -184,6 +201,15 @@ func (ec *ESGController) GetESGMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := ec.passportAccess(passportID, claims); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "ESG metrics not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// Get ESG metrics from database
 	esgMetrics, err := ec.getESGMetricsByPassportID(passportID)
 	if err != nil {
@@ -293,7 +319,7 @@ func (ec *ESGController) GetESGRanking(w http.ResponseWriter, r *http.Request) {
 	minScore, _ := strconv.ParseFloat(r.URL.Query().Get("min_score"), 64)
 
 	// Get ranking from database
-	ranking, err := ec.getESGRanking(limit, manufacturer, minScore)
+	ranking, err := ec.getESGRanking(services.NewTenantScope(claims), limit, manufacturer, minScore)
 	if err != nil {
 		http.Error(w, "Failed to retrieve ESG ranking", http.StatusInternalServerError)
 		return
@@ -660,11 +686,15 @@ func (ec *ESGController) getCertificationLevel(score float64) string {
 }
 
 // Database helper methods
-func (ec *ESGController) passportExists(passportID string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM aluminium_passports WHERE passport_id = $1)`
-	var exists bool
-	err := db.DB.QueryRow(query, passportID).Scan(&exists)
-	return exists, err
+
+// passportAccess returns the user's access to a passport. Passports the
+// user cannot read are reported as sql.ErrNoRows.
+func (ec *ESGController) passportAccess(passportID string, claims *auth.Claims) (services.PassportAccess, error) {
+	access, err := services.NewTenantScope(claims).AccessByID(passportID)
+	if err == nil && !access.Read {
+		err = sql.ErrNoRows
+	}
+	return access, err
 }
 
 func (ec *ESGController) createESGMetrics(metrics *db.ESGMetrics) (int, error) {
@@ -728,10 +758,10 @@ func (ec *ESGController) updatePassportESGScores(passportID string, envScore, so
 	return err
 }
 
-func (ec *ESGController) getESGRanking(limit int, manufacturer string, minScore float64) ([]map[string]interface{}, error) {
-	whereClauses := []string{"e.overall_esg_score IS NOT NULL"}
-	args := []interface{}{}
-	argIndex := 1
+func (ec *ESGController) getESGRanking(scope services.TenantScope, limit int, manufacturer string, minScore float64) ([]map[string]interface{}, error) {
+	scopeClause, args := scope.ReadCondition("p", 1)
+	whereClauses := []string{"e.overall_esg_score IS NOT NULL", scopeClause}
+	argIndex := len(args) + 1
 
 	if manufacturer != "" {
 		whereClauses = append(whereClauses, fmt.Sprintf("p.manufacturer ILIKE $%d", argIndex))
@@ -802,17 +832,8 @@ func (ec *ESGController) extractUserClaims(r *http.Request) (*auth.Claims, error
 	return auth.ValidateToken(tokenString)
 }
 
-func (ec *ESGController) hasRole(userRole string, allowedRoles []string) bool {
-	for _, role := range allowedRoles {
-		if userRole == role {
-			return true
-		}
-	}
-	return false
-}
-
 func (ec *ESGController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
-	// Implementation would log to audit_logs table
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
 }
 
 func timePtr(t time.Time) *time.Time {
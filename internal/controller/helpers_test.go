@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+
+	"github.com/gorilla/mux"
+)
+
+// withTestConfig installs a config that signs tokens and seals secrets for
+// the rest of the test
+func withTestConfig(t *testing.T) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		Environment:         "test",
+		JWTSecret:           "controller-test-secret",
+		JWTExpirationHours:  1,
+		JWTRefreshHours:     24,
+		KeyEncryptionSecret: "controller-test-key-secret",
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+// authorizedRequest returns a request carrying an access token issued to
+// user, with the given route variables
+func authorizedRequest(t *testing.T, method, path, body string, user auth.Claims, vars map[string]string) *http.Request {
+	t.Helper()
+	tokens, err := auth.GenerateTokenPair("session-1", user.UserID, user.Username, user.Email, user.Role, "", "", user.OrganisationID)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	r := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	return mux.SetURLVars(r, vars)
+}
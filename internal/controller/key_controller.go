@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+	"aluminium-passport/internal/signing"
+
+	"github.com/gorilla/mux"
+)
+
+type KeyController struct{}
+
+func NewKeyController() *KeyController {
+	return &KeyController{}
+}
+
+type CreateIssuerKeyRequest struct {
+	KeyType        string `json:"key_type"`
+	OrganisationID int    `json:"organisation_id"` // admins only: create a key for another organisation
+}
+
+type RevokeIssuerKeyRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CreateIssuerKey generates the first signing key for the caller's organisation
+func (kc *KeyController) CreateIssuerKey(w http.ResponseWriter, r *http.Request) {
+	claims, err := kc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateIssuerKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	keyType := signing.KeyTypeEd25519
+	if req.KeyType != "" {
+		keyType = signing.KeyType(req.KeyType)
+		if keyType != signing.KeyTypeEd25519 && keyType != signing.KeyTypeSecp256k1 {
+			http.Error(w, "Unsupported key type", http.StatusBadRequest)
+			return
+		}
+	}
+
+	organisationID := claims.OrganisationID
+	if req.OrganisationID != 0 && req.OrganisationID != organisationID {
+		if !kc.isKeyAdmin(claims.Role) {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		organisationID = req.OrganisationID
+	}
+	if organisationID == 0 {
+		http.Error(w, "User does not belong to an organisation", http.StatusForbidden)
+		return
+	}
+
+	org, err := services.NewOrganisationService(db.DB).GetOrganisation(organisationID)
+	if err != nil {
+		if errors.Is(err, services.ErrOrganisationNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	key, err := services.NewKeyRegistry(db.DB).CreateKey(org, keyType, claims.UserID)
+	if errors.Is(err, services.ErrActiveKeyExists) {
+		http.Error(w, "Organisation already has an active key; rotate it instead", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create issuer key", http.StatusInternalServerError)
+		return
+	}
+
+	kc.logAuditEvent(claims.UserID, claims.Role, "CREATE", "issuer_key", strconv.Itoa(key.ID), nil, key, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// ListIssuerKeys returns the caller's organisation keys; admins may list any
+// organisation or all of them
+func (kc *KeyController) ListIssuerKeys(w http.ResponseWriter, r *http.Request) {
+	claims, err := kc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	organisationID := claims.OrganisationID
+	if kc.isKeyAdmin(claims.Role) {
+		organisationID = 0
+		if value := r.URL.Query().Get("organisation_id"); value != "" {
+			if organisationID, err = strconv.Atoi(value); err != nil || organisationID < 1 {
+				http.Error(w, "Invalid organisation ID", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if organisationID == 0 {
+		http.Error(w, "User does not belong to an organisation", http.StatusForbidden)
+		return
+	}
+
+	keys, err := services.NewKeyRegistry(db.DB).ListKeys(organisationID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve issuer keys", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"keys":            keys,
+		"total_count":     len(keys),
+		"organisation_id": organisationID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RotateIssuerKey replaces an active key. Credentials signed before the
+// rotation remain verifiable.
+func (kc *KeyController) RotateIssuerKey(w http.ResponseWriter, r *http.Request) {
+	claims, key, ok := kc.authorizeKeyAccess(w, r)
+	if !ok {
+		return
+	}
+
+	newKey, err := services.NewKeyRegistry(db.DB).RotateKey(key.ID, claims.UserID)
+	if errors.Is(err, services.ErrKeyNotActive) {
+		http.Error(w, "Only active keys can be rotated", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rotate issuer key", http.StatusInternalServerError)
+		return
+	}
+
+	kc.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "issuer_key", strconv.Itoa(key.ID), key, newKey, r)
+
+	response := map[string]interface{}{
+		"message":     "Issuer key rotated successfully",
+		"rotated_key": key.VerificationMethod,
+		"key":         newKey,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeIssuerKey revokes a key. Every credential it signed stops verifying.
+func (kc *KeyController) RevokeIssuerKey(w http.ResponseWriter, r *http.Request) {
+	claims, key, ok := kc.authorizeKeyAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var req RevokeIssuerKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "Revocation reason is required", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := services.NewKeyRegistry(db.DB).RevokeKey(key.ID, req.Reason)
+	if errors.Is(err, services.ErrKeyRevoked) {
+		http.Error(w, "Issuer key is already revoked", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke issuer key", http.StatusInternalServerError)
+		return
+	}
+
+	kc.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "issuer_key", strconv.Itoa(key.ID), key, revoked, r)
+
+	response := map[string]interface{}{
+		"message": "Issuer key revoked successfully",
+		"key":     revoked,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPlatformDIDDocument serves the did:web document of this service
+func (kc *KeyController) GetPlatformDIDDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := services.PlatformDIDDocument()
+	if errors.Is(err, services.ErrDIDNotFound) {
+		http.Error(w, "did:web is not configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to build DID document", http.StatusInternalServerError)
+		return
+	}
+
+	kc.writeDIDDocument(w, doc)
+}
+
+// GetOrganisationDIDDocument serves the did:web document of an organisation
+func (kc *KeyController) GetOrganisationDIDDocument(w http.ResponseWriter, r *http.Request) {
+	organisation := mux.Vars(r)["org"]
+
+	doc, err := services.NewKeyRegistry(db.DB).OrganisationDocument(organisation)
+	if errors.Is(err, services.ErrKeyNotFound) {
+		http.Error(w, "DID not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to build DID document", http.StatusInternalServerError)
+		return
+	}
+
+	kc.writeDIDDocument(w, doc)
+}
+
+// Helper methods
+func (kc *KeyController) authorizeKeyAccess(w http.ResponseWriter, r *http.Request) (*auth.Claims, *db.IssuerKey, bool) {
+	claims, err := kc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid key ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	key, err := services.NewKeyRegistry(db.DB).GetKey(keyID)
+	if errors.Is(err, services.ErrKeyNotFound) {
+		http.Error(w, "Issuer key not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	// Keys belong to an organisation by ID, never by a name from the token
+	if !kc.isKeyAdmin(claims.Role) && (claims.OrganisationID == 0 || key.OrganisationID != claims.OrganisationID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return claims, key, true
+}
+
+func (kc *KeyController) writeDIDDocument(w http.ResponseWriter, doc *signing.DIDDocument) {
+	w.Header().Set("Content-Type", "application/did+json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(5*time.Minute/time.Second)))
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (kc *KeyController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
+
+// isKeyAdmin reports whether role may manage the keys of any organisation
+func (kc *KeyController) isKeyAdmin(role string) bool {
+	return services.HasPermission(role, models.PermKeyManageAny)
+}
+
+func (kc *KeyController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationController struct{}
+
+func NewNotificationController() *NotificationController {
+	return &NotificationController{}
+}
+
+// GetNotifications returns a page of the caller's in-app inbox
+func (nc *NotificationController) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	claims, err := nc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, total, unread, err := services.NewNotificationService(db.DB).ListNotifications(claims.UserID, unreadOnly, page, limit)
+	if err != nil {
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"total":         total,
+		"unread":        unread,
+		"page":          page,
+		"limit":         limit,
+		"total_pages":   (total + limit - 1) / limit,
+	})
+}
+
+// MarkNotificationRead marks one inbox notification as read
+func (nc *NotificationController) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	claims, err := nc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.NewNotificationService(db.DB).MarkNotificationRead(claims.UserID, notificationID); err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			http.Error(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update notification", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead marks the caller's whole inbox as read
+func (nc *NotificationController) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	claims, err := nc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := services.NewNotificationService(db.DB).MarkAllNotificationsRead(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Notifications marked as read",
+		"count":   count,
+	})
+}
+
+// GetPreferences returns the caller's setting for every event and channel
+func (nc *NotificationController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	claims, err := nc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	preferences, err := services.NewNotificationService(db.DB).NotificationPreferences(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": preferences,
+	})
+}
+
+// UpdatePreferences turns channels on or off for events. Settings not in
+// the request are left unchanged.
+func (nc *NotificationController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	claims, err := nc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Preferences []models.NotificationPreference `json:"preferences"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	notifications := services.NewNotificationService(db.DB)
+	if err := notifications.SetNotificationPreferences(claims.UserID, req.Preferences); err != nil {
+		if errors.Is(err, services.ErrInvalidPreference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "UPDATE", "notification_preferences", strconv.Itoa(claims.UserID), nil, req)
+
+	preferences, err := notifications.NotificationPreferences(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": preferences,
+	})
+}
+
+func (nc *NotificationController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type OrganisationController struct{}
+
+func NewOrganisationController() *OrganisationController {
+	return &OrganisationController{}
+}
+
+type CreateOrganisationRequest struct {
+	Name string `json:"name"`
+}
+
+type AssignOrganisationRequest struct {
+	OrganisationID *int `json:"organisation_id"`
+}
+
+// ListOrganisations returns every organisation
+func (oc *OrganisationController) ListOrganisations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := services.NewOrganisationService(db.DB).ListOrganisations()
+	if err != nil {
+		http.Error(w, "Failed to retrieve organisations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"organisations": orgs,
+	})
+}
+
+// CreateOrganisation adds an organisation
+func (oc *OrganisationController) CreateOrganisation(w http.ResponseWriter, r *http.Request) {
+	claims, err := oc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateOrganisationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	org, err := services.NewOrganisationService(db.DB).CreateOrganisation(req.Name, claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOrganisation):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrOrganisationExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to create organisation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "CREATE", "organisation", strconv.Itoa(org.ID), nil, org)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(org)
+}
+
+// AssignUserOrganisation moves a user into an organisation, or out of any
+// when organisation_id is null. The user's tokens carry the new
+// organisation from their next refresh.
+func (oc *OrganisationController) AssignUserOrganisation(w http.ResponseWriter, r *http.Request) {
+	claims, err := oc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AssignOrganisationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	role, previous, err := oc.userOrganisation(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !models.HasHigherOrEqualRole(claims.Role, role) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	if err := services.NewOrganisationService(db.DB).AssignUser(userID, req.OrganisationID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrOrganisationNotFound):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to assign organisation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "UPDATE", "user", strconv.Itoa(userID),
+		db.JSONMap{"organisation_id": previous}, db.JSONMap{"organisation_id": req.OrganisationID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Organisation assigned",
+		"user_id":         userID,
+		"organisation_id": req.OrganisationID,
+	})
+}
+
+func (oc *OrganisationController) userOrganisation(userID int) (string, *int, error) {
+	var role string
+	var organisationID *int
+	err := db.DB.QueryRow(`SELECT role, organisation_id FROM users WHERE id = $1`, userID).Scan(&role, &organisationID)
+	return role, organisationID, err
+}
+
+func (oc *OrganisationController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
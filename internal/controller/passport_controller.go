@@ -3,16 +3,24 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
 	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/ipfs"
+	"aluminium-passport/internal/models"
 	"aluminium-passport/internal/qr"
+	"aluminium-passport/internal/services"
+	"aluminium-passport/internal/validation"
 
 	"github.com/gorilla/mux"
 )
@@ -66,6 +74,11 @@ type CreatePassportRequest struct {
 	CertificationExpiry    *string     `json:"certification_expiry"`
 	VerifierSignature      *string     `json:"verifier_signature"`
 	Metadata               *db.JSONMap `json:"metadata"`
+	OrganisationID         *int        `json:"organisation_id"`
+}
+
+type DeactivatePassportRequest struct {
+	Reason string `json:"reason"`
 }
 
 type UpdatePassportRequest struct {
@@ -83,6 +96,7 @@ type PassportResponse struct {
 	*db.AluminiumPassport
 	QRCodeURL string `json:"qr_code_url,omitempty"`
 	IPFSUrl   string `json:"ipfs_url,omitempty"`
+	Revision  int    `json:"revision,omitempty"`
 }
 
 // RegisterPassport creates a new passport
@@ -94,8 +108,7 @@ func (pc *PassportController) RegisterPassport(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Check if user has miner or manufacturer role
-	if !pc.hasRole(claims.Role, []string{"miner", "manufacturer", "admin"}) {
+	if !services.HasPermission(claims.Role, models.PermPassportCreate) {
 		http.Error(w, "Insufficient permissions", http.StatusForbidden)
 		return
 	}
@@ -106,12 +119,40 @@ func (pc *PassportController) RegisterPassport(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Check if passport ID already exists
-	if exists, err := pc.passportExists(req.PassportID); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Passports belong to the creator's organisation; roles that may change
+	// any passport can create them for another organisation
+	scope := services.NewTenantScope(claims)
+	var organisationID *int
+	if scope.OrganisationID != 0 {
+		organisationID = &scope.OrganisationID
+	}
+	if req.OrganisationID != nil && (organisationID == nil || *req.OrganisationID != *organisationID) {
+		if !scope.WriteAny {
+			http.Error(w, "Cannot create passports for another organisation", http.StatusForbidden)
+			return
+		}
+		if _, err := services.NewOrganisationService(db.DB).GetOrganisation(*req.OrganisationID); err != nil {
+			if errors.Is(err, services.ErrOrganisationNotFound) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		organisationID = req.OrganisationID
+	}
+	if organisationID == nil {
+		if scope.WriteAny {
+			http.Error(w, "organisation_id is required", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "User does not belong to an organisation", http.StatusForbidden)
 		return
-	} else if exists {
-		http.Error(w, "Passport ID already exists", http.StatusConflict)
+	}
+
+	// Each role may only fill in the passport sections it owns
+	if denied := services.DeniedPassportFields(claims.Role, presentFields(req)); len(denied) > 0 {
+		writeDeniedFields(w, denied)
 		return
 	}
 
@@ -158,43 +199,46 @@ func (pc *PassportController) RegisterPassport(w http.ResponseWriter, r *http.Re
 		UpdatedAt:              time.Now(),
 		CreatedBy:              &claims.UserID,
 		UpdatedBy:              &claims.UserID,
+		OrganisationID:         organisationID,
 	}
 
 	// Parse date fields
-	if req.DateOfExtraction != nil {
-		if date, err := time.Parse("2006-01-02", *req.DateOfExtraction); err == nil {
-			passport.DateOfExtraction = &date
-		}
-	}
-	if req.RefiningDate != nil {
-		if date, err := time.Parse("2006-01-02", *req.RefiningDate); err == nil {
-			passport.RefiningDate = &date
-		}
-	}
-	if req.ManufacturingDate != nil {
-		if date, err := time.Parse("2006-01-02", *req.ManufacturingDate); err == nil {
-			passport.ManufacturingDate = &date
-		}
+	var fieldErrors validation.Errors
+	dates := []struct {
+		field string
+		value *string
+		dst   **time.Time
+	}{
+		{"date_of_extraction", req.DateOfExtraction, &passport.DateOfExtraction},
+		{"refining_date", req.RefiningDate, &passport.RefiningDate},
+		{"manufacturing_date", req.ManufacturingDate, &passport.ManufacturingDate},
+		{"shipment_date", req.ShipmentDate, &passport.ShipmentDate},
+		{"recycling_date", req.RecyclingDate, &passport.RecyclingDate},
+		{"date_of_certification", req.DateOfCertification, &passport.DateOfCertification},
+		{"certification_expiry", req.CertificationExpiry, &passport.CertificationExpiry},
 	}
-	if req.ShipmentDate != nil {
-		if date, err := time.Parse("2006-01-02", *req.ShipmentDate); err == nil {
-			passport.ShipmentDate = &date
+	for _, d := range dates {
+		if d.value == nil {
+			continue
 		}
-	}
-	if req.RecyclingDate != nil {
-		if date, err := time.Parse("2006-01-02", *req.RecyclingDate); err == nil {
-			passport.RecyclingDate = &date
+		if fe := validation.ParseDate(d.field, *d.value, d.dst); fe != nil {
+			fieldErrors = append(fieldErrors, *fe)
 		}
 	}
-	if req.DateOfCertification != nil {
-		if date, err := time.Parse("2006-01-02", *req.DateOfCertification); err == nil {
-			passport.DateOfCertification = &date
-		}
+
+	fieldErrors = append(fieldErrors, validation.ValidatePassport(passport)...)
+	if len(fieldErrors) > 0 {
+		pc.writeValidationErrors(w, fieldErrors)
+		return
 	}
-	if req.CertificationExpiry != nil {
-		if date, err := time.Parse("2006-01-02", *req.CertificationExpiry); err == nil {
-			passport.CertificationExpiry = &date
-		}
+
+	// Check if passport ID already exists
+	if exists, err := pc.passportExists(req.PassportID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	} else if exists {
+		http.Error(w, "Passport ID already exists", http.StatusConflict)
+		return
 	}
 
 	// Save to database
@@ -220,6 +264,8 @@ func (pc *PassportController) RegisterPassport(w http.ResponseWriter, r *http.Re
 
 	// Log audit event
 	pc.logAuditEvent(claims.UserID, claims.Role, "CREATE", "passport", passport.PassportID, nil, passport, r)
+	services.NotifyPassportCreated(passport, claims.UserID, claims.Username)
+	services.PublishPassportEvent(models.WebhookPassportCreated, passport, nil)
 
 	// Prepare response
 	response := &PassportResponse{
@@ -239,7 +285,8 @@ func (pc *PassportController) RegisterPassport(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetPassportDetails retrieves passport by ID
+// GetPassportDetails retrieves passport by ID. With ?as_of=<RFC 3339 time>
+// it returns the passport as it was at that time.
 func (pc *PassportController) GetPassportDetails(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	passportID := vars["id"]
@@ -252,7 +299,7 @@ func (pc *PassportController) GetPassportDetails(w http.ResponseWriter, r *http.
 	}
 
 	// Get passport from database
-	passport, err := pc.getPassportByID(passportID)
+	passport, _, err := pc.getAccessiblePassport(passportID, claims)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Passport not found", http.StatusNotFound)
@@ -268,12 +315,37 @@ func (pc *PassportController) GetPassportDetails(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Read an earlier state from the passport's history
+	revision := 0
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		at, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			http.Error(w, "as_of must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		rev, err := services.NewPassportHistoryService(db.DB).RevisionAt(passportID, at)
+		if err != nil {
+			if errors.Is(err, services.ErrRevisionNotFound) {
+				http.Error(w, "Passport did not exist at as_of", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if passport, err = pc.getPassportRevision(passportID, rev.Revision); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		revision = rev.Revision
+	}
+
 	// Log audit event
 	pc.logAuditEvent(claims.UserID, claims.Role, "VIEW", "passport", passportID, nil, nil, r)
 
 	// Prepare response
 	response := &PassportResponse{
 		AluminiumPassport: passport,
+		Revision:          revision,
 	}
 
 	if passport.QRCodeData != nil {
@@ -284,6 +356,10 @@ func (pc *PassportController) GetPassportDetails(w http.ResponseWriter, r *http.
 		response.IPFSUrl = fmt.Sprintf("https://gateway.pinata.cloud/ipfs/%s", strings.TrimPrefix(*passport.IPFSHash, "ipfs://"))
 	}
 
+	// The ETag of the current passport is sent back in If-Match to patch it
+	if revision == 0 {
+		w.Header().Set("ETag", passportETag(passport))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -300,8 +376,7 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Check if user has recycler role
-	if !pc.hasRole(claims.Role, []string{"recycler", "admin"}) {
+	if !services.HasPermission(claims.Role, models.PermPassportRecycle) {
 		http.Error(w, "Insufficient permissions", http.StatusForbidden)
 		return
 	}
@@ -313,7 +388,7 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 	}
 
 	// Get existing passport
-	passport, err := pc.getPassportByID(passportID)
+	passport, access, err := pc.getAccessiblePassport(passportID, claims)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Passport not found", http.StatusNotFound)
@@ -322,6 +397,11 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	// The recycling date is always updated alongside the requested fields
+	if denied := access.DeniedFields(claims.Role, append(presentFields(req), "last_recycling_date")); len(denied) > 0 {
+		writeDeniedFields(w, denied)
+		return
+	}
 
 	// Store old values for audit
 	oldValues := &db.JSONMap{
@@ -332,17 +412,26 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 
 	// Update fields
 	updateFields := make(map[string]interface{})
+	var changed []string
 	if req.RecycledContentPercent != nil {
 		passport.RecycledContentPercent = req.RecycledContentPercent
 		updateFields["recycled_content_percent"] = *req.RecycledContentPercent
+		changed = append(changed, "recycled_content_percent")
 	}
 	if req.RecyclingMethod != nil {
 		passport.RecyclingMethod = req.RecyclingMethod
 		updateFields["recycling_method"] = *req.RecyclingMethod
+		changed = append(changed, "recycling_method")
 	}
 	if req.TimesRecycled != nil {
 		passport.TimesRecycled = *req.TimesRecycled
 		updateFields["times_recycled"] = *req.TimesRecycled
+		changed = append(changed, "times_recycled")
+	}
+
+	if fieldErrors := validation.ValidateChanges(passport, changed); len(fieldErrors) > 0 {
+		pc.writeValidationErrors(w, fieldErrors)
+		return
 	}
 
 	// Add recycling timestamp
@@ -357,11 +446,13 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 	}
 
 	// Log audit event
-	newValues := &db.JSONMap(updateFields)
+	newValues := db.JSONMap(updateFields)
 	pc.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "passport", passportID, oldValues, newValues, r)
 
 	// Add supply chain step
 	pc.addSupplyChainStep(passportID, "Recycling", fmt.Sprintf("Recycled content updated to %.2f%%", getFloatValue(req.RecycledContentPercent, 0)), claims.UserID)
+	services.NotifyPassportRecycled(passport, claims.UserID, claims.Username)
+	services.PublishPassportEvent(models.WebhookPassportRecycled, passport, changed)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -369,18 +460,382 @@ func (pc *PassportController) UpdateRecycledContent(w http.ResponseWriter, r *ht
 	})
 }
 
-// GetQRCode returns the QR code for a passport
+// PatchPassport applies a JSON Merge Patch (RFC 7396) to the section fields
+// of a passport: members set a field, null clears it and objects such as
+// metadata are merged. The request must carry the passport's ETag in
+// If-Match, so that changes made since the client read the passport are not
+// overwritten.
+func (pc *PassportController) PatchPassport(w http.ResponseWriter, r *http.Request) {
+	passportID := mux.Vars(r)["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		http.Error(w, "If-Match with the passport's ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		http.Error(w, "Request body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	passport, access, err := pc.getAccessiblePassport(passportID, claims)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if passport.Status != "active" {
+		http.Error(w, "Passport is not active", http.StatusForbidden)
+		return
+	}
+
+	etag := passportETag(passport)
+	if !matchesETag(ifMatch, etag) {
+		w.Header().Set("ETag", etag)
+		http.Error(w, "Passport has changed since it was read", http.StatusPreconditionFailed)
+		return
+	}
+
+	// Only section fields can be patched; the others are maintained by the
+	// platform
+	fields := []string{}
+	fixed := []string{}
+	for field := range patch {
+		if _, ok := models.PassportFieldSection(field); ok {
+			fields = append(fields, field)
+		} else {
+			fixed = append(fixed, field)
+		}
+	}
+	sort.Strings(fields)
+	sort.Strings(fixed)
+	if len(fixed) > 0 {
+		http.Error(w, fmt.Sprintf("Fields cannot be patched: %s", strings.Join(fixed, ", ")), http.StatusBadRequest)
+		return
+	}
+	if len(fields) == 0 {
+		http.Error(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+
+	if denied := access.DeniedFields(claims.Role, fields); len(denied) > 0 {
+		writeDeniedFields(w, denied)
+		return
+	}
+
+	updated, fieldErrors, err := pc.applyPassportPatch(passport, patch)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		pc.writeValidationErrors(w, fieldErrors)
+		return
+	}
+
+	// Fields patched to their current value are not changes
+	oldValues := db.JSONMap{}
+	updateFields := make(map[string]interface{})
+	var changed []string
+	for _, field := range fields {
+		oldValue, _ := validation.FieldValue(passport, field)
+		newValue, _ := validation.FieldValue(updated, field)
+		if sameFieldValue(oldValue, newValue) {
+			continue
+		}
+		oldValues[field] = oldValue
+		updateFields[field] = newValue
+		changed = append(changed, field)
+	}
+
+	if len(changed) == 0 {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&PassportResponse{AluminiumPassport: passport})
+		return
+	}
+
+	if fieldErrors := validation.ValidateChanges(updated, changed); len(fieldErrors) > 0 {
+		pc.writeValidationErrors(w, fieldErrors)
+		return
+	}
+
+	updatedAt, err := pc.patchPassportFields(passportID, updateFields, claims.UserID, passport.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport has changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+		http.Error(w, "Failed to update passport", http.StatusInternalServerError)
+		return
+	}
+	updated.UpdatedAt = updatedAt
+	updated.UpdatedBy = &claims.UserID
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "passport", passportID, oldValues, db.JSONMap(updateFields), r)
+	pc.addSupplyChainStep(passportID, "Passport Update", fmt.Sprintf("Updated %s", strings.Join(changed, ", ")), claims.UserID)
+	services.PublishPassportEvent(models.WebhookPassportUpdated, updated, changed)
+
+	w.Header().Set("ETag", passportETag(updated))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&PassportResponse{AluminiumPassport: updated})
+}
+
+// applyPassportPatch returns a copy of passport with a merge patch applied.
+// Dates are patched as YYYY-MM-DD, as when a passport is created.
+func (pc *PassportController) applyPassportPatch(passport *db.AluminiumPassport, patch map[string]interface{}) (*db.AluminiumPassport, validation.Errors, error) {
+	var fieldErrors validation.Errors
+	for field, value := range patch {
+		current, _ := validation.FieldValue(passport, field)
+		if _, isDate := current.(*time.Time); !isDate || value == nil {
+			continue
+		}
+		var date *time.Time
+		text, ok := value.(string)
+		if !ok {
+			fieldErrors = append(fieldErrors, validation.FieldError{Field: field, Code: validation.CodeInvalidDate, Message: "must be a date (YYYY-MM-DD)"})
+			continue
+		}
+		if fe := validation.ParseDate(field, text, &date); fe != nil {
+			fieldErrors = append(fieldErrors, *fe)
+			continue
+		}
+		if date == nil {
+			patch[field] = nil
+		} else {
+			patch[field] = date.Format(time.RFC3339)
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors, nil
+	}
+
+	raw, err := json.Marshal(passport)
+	if err != nil {
+		return nil, nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, nil, err
+	}
+	if raw, err = json.Marshal(mergePatch(document, patch)); err != nil {
+		return nil, nil, err
+	}
+
+	updated := &db.AluminiumPassport{}
+	if err := json.Unmarshal(raw, updated); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, validation.Errors{{Field: typeErr.Field, Code: validation.CodeInvalidFormat, Message: "has the wrong type"}}, nil
+		}
+		return nil, nil, err
+	}
+	return updated, nil, nil
+}
+
+// DeactivatePassport marks a passport inactive and revokes every credential
+// issued for it
+func (pc *PassportController) DeactivatePassport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	passportID := vars["id"]
+
+	// Extract user info from token
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !services.HasPermission(claims.Role, models.PermPassportDeactivate) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	var req DeactivatePassportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	passport, access, err := pc.getAccessiblePassport(passportID, claims)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !access.Owner {
+		http.Error(w, "Only the owning organisation can deactivate this passport", http.StatusForbidden)
+		return
+	}
+
+	if passport.Status == "inactive" {
+		http.Error(w, "Passport is already inactive", http.StatusConflict)
+		return
+	}
+
+	updateFields := map[string]interface{}{"status": "inactive"}
+	if err := pc.updatePassportFields(passportID, updateFields, claims.UserID); err != nil {
+		http.Error(w, "Failed to update passport", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := services.NewStatusListService(db.DB).SetPassportStatus(
+		passportID, services.StatusPurposeRevocation, services.StatusReasonPassportDeactivated, true,
+	)
+	if err != nil {
+		http.Error(w, "Passport deactivated but credential revocation failed", http.StatusInternalServerError)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "UPDATE", "passport", passportID,
+		db.JSONMap{"status": passport.Status}, db.JSONMap{"status": "inactive", "reason": req.Reason}, r)
+
+	pc.addSupplyChainStep(passportID, "Deactivation", fmt.Sprintf("Passport deactivated: %s", req.Reason), claims.UserID)
+
+	passport.Status = "inactive"
+	services.PublishPassportEvent(models.WebhookPassportUpdated, passport, []string{"status"})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":             "Passport deactivated successfully",
+		"revoked_credentials": revoked,
+	})
+}
+
+// RegisterOnChain creates the passport on the passport contract and records
+// the transaction. Each passport can be registered once.
+func (pc *PassportController) RegisterOnChain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	passportID := vars["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	passport, access, err := pc.getAccessiblePassport(passportID, claims)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !access.Owner {
+		http.Error(w, "Only the owning organisation can register this passport on chain", http.StatusForbidden)
+		return
+	}
+
+	if passport.Status != "active" {
+		http.Error(w, "Passport is not active", http.StatusConflict)
+		return
+	}
+	if passport.BlockchainTxHash != nil {
+		http.Error(w, "Passport is already registered on chain", http.StatusConflict)
+		return
+	}
+
+	cfg := config.AppConfig
+	txHash, err := services.AnchorPassportOnChain(cfg, passport)
+	if err != nil {
+		if errors.Is(err, services.ErrPassportNotAnchorable) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to register passport on chain: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	updateFields := map[string]interface{}{
+		"blockchain_tx_hash": txHash,
+		"contract_address":   cfg.ContractAddress,
+	}
+	if err := pc.updatePassportFields(passportID, updateFields, claims.UserID); err != nil {
+		http.Error(w, "Passport registered on chain but the transaction could not be recorded", http.StatusInternalServerError)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "ANCHOR", "passport", passportID, nil, db.JSONMap(updateFields), r)
+	pc.addSupplyChainStep(passportID, "Blockchain Registration", fmt.Sprintf("Registered on chain in transaction %s", txHash), claims.UserID)
+
+	services.PublishWebhookEvent(models.WebhookPassportAnchored, passportID, map[string]interface{}{
+		"passport_id":      passportID,
+		"transaction_hash": txHash,
+		"contract_address": cfg.ContractAddress,
+		"chain_id":         cfg.ChainID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":          "Passport registered on chain",
+		"passport_id":      passportID,
+		"transaction_hash": txHash,
+		"contract_address": cfg.ContractAddress,
+	})
+}
+
+// GetQRCode returns the QR code for a passport the user can access
 func (pc *PassportController) GetQRCode(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	passportID := vars["id"]
 
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Get passport from database
-	passport, err := pc.getPassportByID(passportID)
+	passport, _, err := pc.getAccessiblePassport(passportID, claims)
 	if err != nil {
 		http.Error(w, "Passport not found", http.StatusNotFound)
 		return
 	}
 
+	pc.writeQRCode(w, passport)
+}
+
+// GetPublicQRCode returns the QR code of an active passport without
+// authentication, for printing on products
+func (pc *PassportController) GetPublicQRCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	passportID := vars["id"]
+
+	passport, err := pc.getPassportByID(passportID)
+	if err != nil || passport.Status != "active" {
+		http.Error(w, "Passport not found", http.StatusNotFound)
+		return
+	}
+
+	pc.writeQRCode(w, passport)
+}
+
+// writeQRCode writes the QR code image of a passport
+func (pc *PassportController) writeQRCode(w http.ResponseWriter, passport *db.AluminiumPassport) {
 	// Generate or retrieve QR code
 	var qrCode []byte
 	if passport.QRCodeData != nil {
@@ -388,6 +843,7 @@ func (pc *PassportController) GetQRCode(w http.ResponseWriter, r *http.Request)
 		qrCode = []byte(*passport.QRCodeData) // This would need proper base64 decoding
 	} else {
 		// Generate new QR code
+		var err error
 		qrCode, err = qr.GenerateQRCodeImage(passport)
 		if err != nil {
 			http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
@@ -424,7 +880,7 @@ func (pc *PassportController) ListPassports(w http.ResponseWriter, r *http.Reque
 	batchID := r.URL.Query().Get("batch_id")
 
 	// Get passports from database
-	passports, total, err := pc.listPassports(page, limit, manufacturer, status, batchID)
+	passports, total, err := pc.listPassports(services.NewTenantScope(claims), page, limit, manufacturer, status, batchID)
 	if err != nil {
 		http.Error(w, "Failed to retrieve passports", http.StatusInternalServerError)
 		return
@@ -445,94 +901,309 @@ func (pc *PassportController) ListPassports(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetPassportHistory returns the revisions of a passport, newest first
+func (pc *PassportController) GetPassportHistory(w http.ResponseWriter, r *http.Request) {
+	passportID := mux.Vars(r)["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, _, err := pc.getAccessiblePassport(passportID, claims); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	revisions, total, err := services.NewPassportHistoryService(db.DB).Revisions(passportID, limit, (page-1)*limit)
+	if err != nil {
+		http.Error(w, "Failed to retrieve passport history", http.StatusInternalServerError)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "VIEW", "passport_history", passportID, nil, nil, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"passport_id": passportID,
+		"revisions":   revisions,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": (total + limit - 1) / limit,
+	})
+}
+
+// GetPassportDiff compares two revisions of a passport. from defaults to
+// the revision before to, and to defaults to the latest revision.
+func (pc *PassportController) GetPassportDiff(w http.ResponseWriter, r *http.Request) {
+	passportID := mux.Vars(r)["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, _, err := pc.getAccessiblePassport(passportID, claims); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	history := services.NewPassportHistoryService(db.DB)
+	revision := func(name string) (int, bool) {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			return 0, true
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("%s must be a revision number", name), http.StatusBadRequest)
+			return 0, false
+		}
+		return n, true
+	}
+	from, ok := revision("from")
+	if !ok {
+		return
+	}
+	to, ok := revision("to")
+	if !ok {
+		return
+	}
+	if from == 0 {
+		latest, err := history.Revision(passportID, to)
+		if err != nil {
+			pc.writeRevisionError(w, err)
+			return
+		}
+		from = latest.Revision - 1
+		if from < 1 {
+			from = 1
+		}
+	}
+
+	diff, err := history.Diff(passportID, from, to)
+	if err != nil {
+		pc.writeRevisionError(w, err)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "VIEW", "passport_history", passportID, nil, nil, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func (pc *PassportController) writeRevisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrRevisionNotFound) {
+		http.Error(w, "Passport revision not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// ListPassportGrants returns the organisations a passport is shared with
+func (pc *PassportController) ListPassportGrants(w http.ResponseWriter, r *http.Request) {
+	passportID := mux.Vars(r)["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, ok := pc.ownedPassport(w, passportID, claims); !ok {
+		return
+	}
+
+	grants, err := services.NewOrganisationService(db.DB).ListPassportGrants(passportID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve passport grants", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"passport_id": passportID,
+		"grants":      grants,
+	})
+}
+
+// CreatePassportGrant shares a passport with another organisation
+func (pc *PassportController) CreatePassportGrant(w http.ResponseWriter, r *http.Request) {
+	passportID := mux.Vars(r)["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.PassportGrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	passport, ok := pc.ownedPassport(w, passportID, claims)
+	if !ok {
+		return
+	}
+
+	grant, err := services.NewOrganisationService(db.DB).GrantPassport(passportID, passport.OrganisationID, &req, claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPassportGrant) || errors.Is(err, services.ErrOrganisationNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create passport grant", http.StatusInternalServerError)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "CREATE", "passport_grant", passportID, nil, grant, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// RevokePassportGrant stops sharing a passport through a grant
+func (pc *PassportController) RevokePassportGrant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	passportID := vars["id"]
+
+	claims, err := pc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	grantID, err := strconv.Atoi(vars["grantId"])
+	if err != nil {
+		http.Error(w, "Invalid grant ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := pc.ownedPassport(w, passportID, claims); !ok {
+		return
+	}
+
+	grant, err := services.NewOrganisationService(db.DB).RevokePassportGrant(passportID, grantID, claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrPassportGrantNotFound) {
+			http.Error(w, "Passport grant not found or already revoked", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke passport grant", http.StatusInternalServerError)
+		return
+	}
+
+	pc.logAuditEvent(claims.UserID, claims.Role, "REVOKE", "passport_grant", passportID, nil, grant, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grant)
+}
+
+// ownedPassport returns a passport whose grants the user may manage, or
+// writes the error response and returns false
+func (pc *PassportController) ownedPassport(w http.ResponseWriter, passportID string, claims *auth.Claims) (*db.AluminiumPassport, bool) {
+	passport, access, err := pc.getAccessiblePassport(passportID, claims)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Passport not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !access.Owner {
+		http.Error(w, "Only the owning organisation can share this passport", http.StatusForbidden)
+		return nil, false
+	}
+	return passport, true
+}
+
 // Database helper methods
 func (pc *PassportController) passportExists(passportID string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM aluminium_passports WHERE passport_id = $1)`
-	var exists bool
-	err := db.DB.QueryRow(query, passportID).Scan(&exists)
-	return exists, err
+	return services.PassportExists(passportID)
 }
 
 func (pc *PassportController) createPassport(passport *db.AluminiumPassport) (int, error) {
-	query := `
-		INSERT INTO aluminium_passports (
-			passport_id, batch_id, manufacturer, origin, bauxite_source, alloy_composition,
-			mine_operator, date_of_extraction, extraction_method, mine_location,
-			refinery_location, refiner_id, refining_date, refining_method,
-			smelting_location, smelting_energy_source, process_type, manufactured_product, manufacturing_date,
-			product_weight, energy_used, water_used, waste_generated,
-			carbon_emissions_per_kg, co2_footprint, manufacturing_emissions,
-			transport_mode, distance_travelled, logistics_partner_id, shipment_date,
-			recycled_content_percent, recycling_date, recycler_id, recycling_method, times_recycled,
-			certification_agency, certifier, compliance_standards, date_of_certification, certification_expiry, verifier_signature,
-			metadata, status, is_verified, created_at, updated_at, created_by, updated_by
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
-			$20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35,
-			$36, $37, $38, $39, $40, $41, $42, $43, $44, $45, $46, $47, $48
-		) RETURNING id`
-
-	var passportID int
-	err := db.DB.QueryRow(
-		query,
-		passport.PassportID, passport.BatchID, passport.Manufacturer, passport.Origin, passport.BauxiteSource, passport.AlloyComposition,
-		passport.MineOperator, passport.DateOfExtraction, passport.ExtractionMethod, passport.MineLocation,
-		passport.RefineryLocation, passport.RefinerID, passport.RefiningDate, passport.RefiningMethod,
-		passport.SmeltingLocation, passport.SmeltingEnergySource, passport.ProcessType, passport.ManufacturedProduct, passport.ManufacturingDate,
-		passport.ProductWeight, passport.EnergyUsed, passport.WaterUsed, passport.WasteGenerated,
-		passport.CarbonEmissionsPerKg, passport.CO2Footprint, passport.ManufacturingEmissions,
-		passport.TransportMode, passport.DistanceTravelled, passport.LogisticsPartnerID, passport.ShipmentDate,
-		passport.RecycledContentPercent, passport.RecyclingDate, passport.RecyclerID, passport.RecyclingMethod, passport.TimesRecycled,
-		passport.CertificationAgency, passport.Certifier, passport.ComplianceStandards, passport.DateOfCertification, passport.CertificationExpiry, passport.VerifierSignature,
-		passport.Metadata, passport.Status, passport.IsVerified, passport.CreatedAt, passport.UpdatedAt, passport.CreatedBy, passport.UpdatedBy,
-	).Scan(&passportID)
-
-	return passportID, err
+	return services.InsertPassportRecord(passport)
 }
 
 func (pc *PassportController) getPassportByID(passportID string) (*db.AluminiumPassport, error) {
+	query := `SELECT ` + services.PassportColumns + ` FROM aluminium_passports WHERE passport_id = $1`
+	return services.ScanPassport(db.DB.QueryRow(query, passportID))
+}
+
+// getPassportRevision returns a passport as stored in one of its revisions
+func (pc *PassportController) getPassportRevision(passportID string, revision int) (*db.AluminiumPassport, error) {
 	query := `
-		SELECT id, passport_id, batch_id, manufacturer, origin, bauxite_source, alloy_composition,
-		       mine_operator, date_of_extraction, extraction_method, mine_location,
-		       refinery_location, refiner_id, refining_date, refining_method,
-		       smelting_location, smelting_energy_source, process_type, manufactured_product, manufacturing_date,
-		       product_weight, energy_used, water_used, waste_generated,
-		       carbon_emissions_per_kg, co2_footprint, manufacturing_emissions,
-		       transport_mode, distance_travelled, logistics_partner_id, shipment_date,
-		       recycled_content_percent, recycling_date, recycler_id, recycling_method, times_recycled, last_recycling_date,
-		       certification_agency, certifier, compliance_standards, date_of_certification, certification_expiry, verifier_signature,
-		       esg_score, environmental_score, social_score, governance_score, esg_last_updated,
-		       ipfs_hash, qr_code_data, digital_signature,
-		       blockchain_tx_hash, contract_address, block_number,
-		       status, is_verified, verification_date,
-		       metadata, supply_chain_steps, certifications,
-		       created_at, updated_at, created_by, updated_by
-		FROM aluminium_passports 
-		WHERE passport_id = $1`
-
-	passport := &db.AluminiumPassport{}
-	err := db.DB.QueryRow(query, passportID).Scan(
-		&passport.ID, &passport.PassportID, &passport.BatchID, &passport.Manufacturer, &passport.Origin, &passport.BauxiteSource, &passport.AlloyComposition,
-		&passport.MineOperator, &passport.DateOfExtraction, &passport.ExtractionMethod, &passport.MineLocation,
-		&passport.RefineryLocation, &passport.RefinerID, &passport.RefiningDate, &passport.RefiningMethod,
-		&passport.SmeltingLocation, &passport.SmeltingEnergySource, &passport.ProcessType, &passport.ManufacturedProduct, &passport.ManufacturingDate,
-		&passport.ProductWeight, &passport.EnergyUsed, &passport.WaterUsed, &passport.WasteGenerated,
-		&passport.CarbonEmissionsPerKg, &passport.CO2Footprint, &passport.ManufacturingEmissions,
-		&passport.TransportMode, &passport.DistanceTravelled, &passport.LogisticsPartnerID, &passport.ShipmentDate,
-		&passport.RecycledContentPercent, &passport.RecyclingDate, &passport.RecyclerID, &passport.RecyclingMethod, &passport.TimesRecycled, &passport.LastRecyclingDate,
-		&passport.CertificationAgency, &passport.Certifier, &passport.ComplianceStandards, &passport.DateOfCertification, &passport.CertificationExpiry, &passport.VerifierSignature,
-		&passport.ESGScore, &passport.EnvironmentalScore, &passport.SocialScore, &passport.GovernanceScore, &passport.ESGLastUpdated,
-		&passport.IPFSHash, &passport.QRCodeData, &passport.DigitalSignature,
-		&passport.BlockchainTxHash, &passport.ContractAddress, &passport.BlockNumber,
-		&passport.Status, &passport.IsVerified, &passport.VerificationDate,
-		&passport.Metadata, &passport.SupplyChainSteps, &passport.Certifications,
-		&passport.CreatedAt, &passport.UpdatedAt, &passport.CreatedBy, &passport.UpdatedBy,
-	)
+		SELECT ` + services.PassportColumns + `
+		FROM jsonb_populate_record(NULL::aluminium_passports,
+			(SELECT snapshot FROM passport_revisions WHERE passport_id = $1 AND revision = $2))
+		WHERE passport_id IS NOT NULL`
+	return services.ScanPassport(db.DB.QueryRow(query, passportID, revision))
+}
 
-	return passport, err
+// getAccessiblePassport returns a passport and the user's access to it.
+// Passports the user cannot read are reported as sql.ErrNoRows, so that
+// their existence is not disclosed.
+func (pc *PassportController) getAccessiblePassport(passportID string, claims *auth.Claims) (*db.AluminiumPassport, services.PassportAccess, error) {
+	passport, err := pc.getPassportByID(passportID)
+	if err != nil {
+		return nil, services.PassportAccess{}, err
+	}
+	access, err := services.NewTenantScope(claims).Access(passportID, passport.OrganisationID)
+	if err != nil {
+		return nil, access, err
+	}
+	if !access.Read {
+		return nil, access, sql.ErrNoRows
+	}
+	return passport, access, nil
 }
 
 func (pc *PassportController) updatePassportFields(passportID string, fields map[string]interface{}, userID int) error {
+	query, args := pc.passportUpdateQuery(passportID, fields, userID)
+	_, err := db.DB.Exec(query, args...)
+	return err
+}
+
+// patchPassportFields updates a passport only if it has not changed since
+// updatedAt and returns its new updated_at. It returns sql.ErrNoRows when
+// the passport changed in the meantime.
+func (pc *PassportController) patchPassportFields(passportID string, fields map[string]interface{}, userID int, updatedAt time.Time) (time.Time, error) {
+	query, args := pc.passportUpdateQuery(passportID, fields, userID)
+	query += fmt.Sprintf(" AND updated_at = $%d RETURNING updated_at", len(args)+1)
+	args = append(args, updatedAt)
+
+	var newUpdatedAt time.Time
+	err := db.DB.QueryRow(query, args...).Scan(&newUpdatedAt)
+	return newUpdatedAt, err
+}
+
+func (pc *PassportController) passportUpdateQuery(passportID string, fields map[string]interface{}, userID int) (string, []interface{}) {
 	// Build dynamic update query
 	setParts := []string{}
 	args := []interface{}{}
@@ -555,9 +1226,7 @@ func (pc *PassportController) updatePassportFields(passportID string, fields map
 	args = append(args, passportID)
 
 	query := fmt.Sprintf("UPDATE aluminium_passports SET %s WHERE passport_id = $%d", strings.Join(setParts, ", "), argIndex)
-
-	_, err := db.DB.Exec(query, args...)
-	return err
+	return query, args
 }
 
 func (pc *PassportController) updatePassportIPFS(passportID, ipfsHash string) error {
@@ -572,11 +1241,11 @@ func (pc *PassportController) updatePassportQR(passportID, qrData string) error
 	return err
 }
 
-func (pc *PassportController) listPassports(page, limit int, manufacturer, status, batchID string) ([]*db.AluminiumPassport, int, error) {
-	// Build WHERE clause
-	whereClauses := []string{"1=1"}
-	args := []interface{}{}
-	argIndex := 1
+func (pc *PassportController) listPassports(scope services.TenantScope, page, limit int, manufacturer, status, batchID string) ([]*db.AluminiumPassport, int, error) {
+	// Build WHERE clause, starting with the passports the user may read
+	scopeClause, args := scope.ReadCondition("aluminium_passports", 1)
+	whereClauses := []string{scopeClause}
+	argIndex := len(args) + 1
 
 	if manufacturer != "" {
 		whereClauses = append(whereClauses, fmt.Sprintf("manufacturer ILIKE $%d", argIndex))
@@ -661,17 +1330,108 @@ func (pc *PassportController) extractUserClaims(r *http.Request) (*auth.Claims,
 	return auth.ValidateToken(tokenString)
 }
 
-func (pc *PassportController) hasRole(userRole string, allowedRoles []string) bool {
-	for _, role := range allowedRoles {
-		if userRole == role {
+// writeValidationErrors responds 422 with the failed passport fields
+func (pc *PassportController) writeValidationErrors(w http.ResponseWriter, fieldErrors validation.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Validation failed",
+		"errors": fieldErrors,
+	})
+}
+
+func (pc *PassportController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
+}
+
+// presentFields returns the JSON names, sorted, of the fields set in a
+// request struct
+func presentFields(req interface{}) []string {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil
+	}
+	fields := []string{}
+	for name, value := range values {
+		if value != nil {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// writeDeniedFields responds 403 with the passport fields the user may not
+// write and the sections they belong to
+func writeDeniedFields(w http.ResponseWriter, fields []string) {
+	sections := []string{}
+	for _, field := range fields {
+		section, _ := models.PassportFieldSection(field)
+		if !contains(sections, section) {
+			sections = append(sections, section)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    "Not permitted to write these passport fields",
+		"fields":   fields,
+		"sections": sections,
+	})
+}
+
+// passportETag identifies the stored state of a passport. Every update
+// changes updated_at.
+func passportETag(passport *db.AluminiumPassport) string {
+	return fmt.Sprintf(`"%d"`, passport.UpdatedAt.UnixMicro())
+}
+
+// matchesETag reports whether an If-Match header lists etag
+func matchesETag(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == etag {
 			return true
 		}
 	}
 	return false
 }
 
-func (pc *PassportController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
-	// Implementation would log to audit_logs table
+// mergePatch applies a JSON Merge Patch (RFC 7396) to a decoded JSON
+// document
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// sameFieldValue compares passport field values, dates by instant
+func sameFieldValue(a, b interface{}) bool {
+	if at, ok := a.(*time.Time); ok {
+		bt, _ := b.(*time.Time)
+		if at == nil || bt == nil {
+			return at == bt
+		}
+		return at.Equal(*bt)
+	}
+	return reflect.DeepEqual(a, b)
 }
 
 func getIntValue(ptr *int, defaultValue int) int {
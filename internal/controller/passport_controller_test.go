@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// TestMergePatch runs the examples from RFC 7396 Appendix A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var target, patch, want interface{}
+			for _, doc := range []struct {
+				raw string
+				v   *interface{}
+			}{{tt.target, &target}, {tt.patch, &patch}, {tt.want, &want}} {
+				if err := json.Unmarshal([]byte(doc.raw), doc.v); err != nil {
+					t.Fatalf("invalid test document %s: %v", doc.raw, err)
+				}
+			}
+
+			if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	passport := &db.AluminiumPassport{UpdatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)}
+	etag := passportETag(passport)
+
+	stale := *passport
+	stale.UpdatedAt = passport.UpdatedAt.Add(-time.Microsecond)
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    bool
+	}{
+		{"same tag", etag, true},
+		{"one of a list", `"1", ` + etag + `, "2"`, true},
+		{"surrounding whitespace", "  " + etag + "\t", true},
+		{"stale tag", passportETag(&stale), false},
+		{"unquoted tag", etag[1 : len(etag)-1], false},
+		{"empty header", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesETag(tt.ifMatch, etag); got != tt.want {
+				t.Errorf("matchesETag(%q, %q) = %v, want %v", tt.ifMatch, etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPassportChecksSections(t *testing.T) {
+	withTestConfig(t)
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+
+	miner := auth.Claims{UserID: 3, Username: "miner", Role: models.RoleMiner, OrganisationID: 7}
+	body := `{"passport_id": "AP-1", "manufacturer": "Example Smelter", "origin": "AU",
+		"mine_operator": "Example Mining", "product_weight": 12.5, "recycled_content_percent": 30}`
+	w := httptest.NewRecorder()
+	NewPassportController().RegisterPassport(w, authorizedRequest(t, http.MethodPost, "/api/passports", body, miner, nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	var response struct {
+		Fields   []string `json:"fields"`
+		Sections []string `json:"sections"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if want := []string{"product_weight", "recycled_content_percent"}; !reflect.DeepEqual(response.Fields, want) {
+		t.Errorf("fields = %v, want %v", response.Fields, want)
+	}
+	if want := []string{models.SectionSmelting, models.SectionRecycling}; !reflect.DeepEqual(response.Sections, want) {
+		t.Errorf("sections = %v, want %v", response.Sections, want)
+	}
+	if inserts := fake.Statements(`INSERT INTO aluminium_passports`); len(inserts) != 0 {
+		t.Errorf("stored a passport with denied fields")
+	}
+}
+
+func TestPresentFields(t *testing.T) {
+	weight := 0.0
+	req := CreatePassportRequest{PassportID: "AP-1", ProductWeight: &weight}
+
+	got := presentFields(req)
+	want := []string{"manufacturer", "origin", "passport_id", "product_weight"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("presentFields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"aluminium-passport/internal/services"
+)
+
+type PresentationController struct{}
+
+func NewPresentationController() *PresentationController {
+	return &PresentationController{}
+}
+
+type CreatePresentationRequest struct {
+	SDJWT   string   `json:"sd_jwt" binding:"required"`
+	Profile string   `json:"profile"` // consumer, recycler or regulator
+	Claims  []string `json:"claims"`  // dotted claim paths, used when no profile is given
+}
+
+type VerifyPresentationRequest struct {
+	Presentation string `json:"presentation" binding:"required"`
+}
+
+// CreatePresentation derives an SD-JWT presentation that reveals only the
+// claims the holder selects
+func (pc *PresentationController) CreatePresentation(w http.ResponseWriter, r *http.Request) {
+	var req CreatePresentationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.SDJWT == "" {
+		http.Error(w, "sd_jwt is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := services.DisclosureClaims(req.Profile, req.Claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	presentation, disclosed, err := services.PresentPassportSDJWT(req.SDJWT, claims)
+	if err != nil {
+		http.Error(w, "Invalid SD-JWT", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"presentation": presentation,
+		"disclosed":    disclosed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyPresentation verifies an SD-JWT presentation and returns the claims
+// it reveals
+func (pc *PresentationController) VerifyPresentation(w http.ResponseWriter, r *http.Request) {
+	var req VerifyPresentationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Presentation == "" {
+		http.Error(w, "presentation is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := services.VerifyPassportSDJWT(req.Presentation)
+	response := map[string]interface{}{
+		"valid":   err == nil,
+		"message": "Presentation verification completed",
+	}
+	if result != nil {
+		response["issuer"] = result.Issuer
+		response["issued_at"] = result.IssuedAt
+		response["claims"] = result.Claims
+		response["disclosed"] = result.Disclosed
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDisclosureProfiles lists the predefined claim selections
+func (pc *PresentationController) GetDisclosureProfiles(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(services.DisclosureProfiles))
+	for name := range services.DisclosureProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		profiles = append(profiles, map[string]interface{}{
+			"name":   name,
+			"claims": services.DisclosureProfiles[name],
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"profiles": profiles})
+}
@@ -0,0 +1,421 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookController struct{}
+
+func NewWebhookController() *WebhookController {
+	return &WebhookController{}
+}
+
+// GetWebhooks lists the subscriptions the user may manage with the event
+// types available. Users who may read any passport see every subscription,
+// or those of ?organisation_id.
+func (wc *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	claims, err := wc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	organisationID := claims.OrganisationID
+	if services.NewTenantScope(claims).ReadAny {
+		organisationID = 0
+		if value := r.URL.Query().Get("organisation_id"); value != "" {
+			if organisationID, err = strconv.Atoi(value); err != nil || organisationID < 1 {
+				http.Error(w, "Invalid organisation ID", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if organisationID == 0 {
+		http.Error(w, "User does not belong to an organisation", http.StatusForbidden)
+		return
+	}
+
+	subs, err := services.NewWebhookService(db.DB).ListSubscriptions(organisationID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks":    subs,
+		"event_types": models.WebhookEventTypes(),
+	})
+}
+
+// CreateWebhook stores a subscription. It receives the events of the
+// user's organisation; users who may read any passport may choose another
+// organisation, or none to receive every event. The response is the only
+// time its signing secret is shown.
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	claims, err := wc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		OrganisationID *int     `json:"organisation_id"`
+		URL            string   `json:"url"`
+		EventTypes     []string `json:"event_types"`
+		Secret         string   `json:"secret"`
+		Description    string   `json:"description"`
+		IsActive       *bool    `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !services.NewTenantScope(claims).ReadAny {
+		if claims.OrganisationID == 0 {
+			http.Error(w, "User does not belong to an organisation", http.StatusForbidden)
+			return
+		}
+		if req.OrganisationID != nil && *req.OrganisationID != claims.OrganisationID {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		req.OrganisationID = &claims.OrganisationID
+	}
+	if req.Secret != "" && len(req.Secret) < 16 {
+		http.Error(w, "Secret must be at least 16 characters", http.StatusBadRequest)
+		return
+	}
+
+	sub := &models.WebhookSubscription{
+		OrganisationID: req.OrganisationID,
+		URL:            strings.TrimSpace(req.URL),
+		EventTypes:     req.EventTypes,
+		Secret:         req.Secret,
+		Description:    nullableString(req.Description),
+		IsActive:       req.IsActive == nil || *req.IsActive,
+	}
+	if err := services.NewWebhookService(db.DB).CreateSubscription(sub, claims.UserID); err != nil {
+		if errors.Is(err, services.ErrInvalidWebhook) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "CREATE", "webhook_subscription", strconv.Itoa(sub.ID), nil, map[string]interface{}{
+		"organisation_id": sub.OrganisationID,
+		"url":             sub.URL,
+		"event_types":     sub.EventTypes,
+		"is_active":       sub.IsActive,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// GetWebhook returns one subscription
+func (wc *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	_, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateWebhook changes a subscription's URL, event types, description or
+// whether it is active
+func (wc *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	claims, old, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var update models.WebhookSubscriptionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := services.NewWebhookService(db.DB).UpdateSubscription(old.ID, &update)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to update webhook")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "UPDATE", "webhook_subscription", strconv.Itoa(old.ID), old, sub)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteWebhook removes a subscription and its delivery history
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	claims, old, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+
+	if err := services.NewWebhookService(db.DB).DeleteSubscription(old.ID); err != nil {
+		wc.writeServiceError(w, err, "Failed to delete webhook")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "DELETE", "webhook_subscription", strconv.Itoa(old.ID), old, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Webhook deleted",
+	})
+}
+
+// RotateWebhookSecret replaces a subscription's signing secret and returns
+// the new one
+func (wc *WebhookController) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	claims, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+	id := sub.ID
+
+	secret, err := services.NewWebhookService(db.DB).RotateSecret(id)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to rotate webhook secret")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "ROTATE", "webhook_subscription", strconv.Itoa(id), nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"secret": secret,
+	})
+}
+
+// GetWebhookDeliveries returns a page of a subscription's deliveries.
+// ?status=dead lists the dead letters.
+func (wc *WebhookController) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		http.Error(w, "Invalid delivery status", http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, total, err := services.NewWebhookService(db.DB).ListDeliveries(sub.ID, status, page, limit)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to retrieve webhook deliveries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries":  deliveries,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": (total + limit - 1) / limit,
+	})
+}
+
+// GetWebhookDeliveryAttempts returns every attempt of one delivery
+func (wc *WebhookController) GetWebhookDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	_, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(mux.Vars(r)["deliveryId"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	attempts, err := services.NewWebhookService(db.DB).DeliveryAttempts(sub.ID, deliveryID)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to retrieve webhook attempts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempts": attempts,
+	})
+}
+
+// RetryWebhookDelivery queues a dead-lettered delivery again
+func (wc *WebhookController) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	claims, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(mux.Vars(r)["deliveryId"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.NewWebhookService(db.DB).RetryDelivery(sub.ID, deliveryID); err != nil {
+		wc.writeServiceError(w, err, "Failed to retry webhook delivery")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "RETRY", "webhook_delivery", strconv.Itoa(deliveryID), nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Delivery queued",
+	})
+}
+
+// RetryDeadWebhookDeliveries queues every dead-lettered delivery of a
+// subscription again
+func (wc *WebhookController) RetryDeadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	claims, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+	id := sub.ID
+
+	count, err := services.NewWebhookService(db.DB).RetryDeadDeliveries(id)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to retry webhook deliveries")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "RETRY", "webhook_subscription", strconv.Itoa(id), nil, map[string]interface{}{
+		"retried": count,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Dead-lettered deliveries queued",
+		"retried": count,
+	})
+}
+
+// ReplayWebhook queues the events of a time window again for a
+// subscription
+func (wc *WebhookController) ReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	claims, sub, ok := wc.authorizeWebhookAccess(w, r)
+	if !ok {
+		return
+	}
+	id := sub.ID
+
+	var replay models.WebhookReplay
+	if err := json.NewDecoder(r.Body).Decode(&replay); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	count, err := services.NewWebhookService(db.DB).Replay(id, &replay)
+	if err != nil {
+		wc.writeServiceError(w, err, "Failed to replay webhook events")
+		return
+	}
+
+	services.RecordAuditEvent(r, claims.UserID, claims.Role, "REPLAY", "webhook_subscription", strconv.Itoa(id), nil, map[string]interface{}{
+		"from":        replay.From,
+		"to":          replay.To,
+		"event_types": replay.EventTypes,
+		"queued":      count,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Events queued for replay",
+		"queued":  count,
+	})
+}
+
+// authorizeWebhookAccess loads the subscription named in the URL and checks
+// that the user may manage it: subscriptions belong to the organisation
+// they receive events for, and those of no organisation receive every
+// event, so only users who may read any passport manage them
+func (wc *WebhookController) authorizeWebhookAccess(w http.ResponseWriter, r *http.Request) (*auth.Claims, *models.WebhookSubscription, bool) {
+	claims, err := wc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	sub, err := services.NewWebhookService(db.DB).Subscription(id)
+	if err != nil {
+		wc.writeServiceError(w, err, "Internal server error")
+		return nil, nil, false
+	}
+
+	if !services.NewTenantScope(claims).ReadAny &&
+		(claims.OrganisationID == 0 || sub.OrganisationID == nil || *sub.OrganisationID != claims.OrganisationID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return claims, sub, true
+}
+
+// writeServiceError maps webhook service errors to HTTP responses
+func (wc *WebhookController) writeServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrWebhookDeliveryNotDead):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidWebhook), errors.Is(err, services.ErrInvalidReplay):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (wc *WebhookController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
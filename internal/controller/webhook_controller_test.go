@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// newWebhookDB installs a fake database holding subscription 1, which
+// belongs to organisationID, and the default role permissions
+func newWebhookDB(t *testing.T, organisationID interface{}) *dbtest.DB {
+	t.Helper()
+	withTestConfig(t)
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+	fake.OnQuery(`FROM webhook_subscriptions WHERE id = $1`,
+		dbtest.Columns(`id, organisation_id, url, event_types, description, is_active, created_by, created_at, updated_at`),
+		[]interface{}{int64(1), organisationID, "https://erp.example.com/hooks", []string{models.WebhookPassportCreated},
+			nil, true, int64(1), time.Now(), time.Now()})
+	fake.OnQuery(`INSERT INTO webhook_subscriptions`, []string{"id", "created_at", "updated_at"},
+		[]interface{}{int64(2), time.Now(), time.Now()})
+	fake.OnQuery(`FROM audit_logs`, []string{"entry_hash"})
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	return fake
+}
+
+// webhookRequest returns a request for subscription 1 made by a user of
+// role in organisationID
+func webhookRequest(t *testing.T, method, path, body, role string, organisationID int) *http.Request {
+	t.Helper()
+	user := auth.Claims{UserID: 3, Username: "user", Role: role, OrganisationID: organisationID}
+	return authorizedRequest(t, method, path, body, user, map[string]string{"id": "1"})
+}
+
+func TestWebhookAccessFollowsOrganisation(t *testing.T) {
+	tests := []struct {
+		name         string
+		organisation interface{}
+		role         string
+		userOrg      int
+		want         int
+	}{
+		{name: "own organisation", organisation: int64(7), role: models.RoleManufacturer, userOrg: 7, want: http.StatusOK},
+		{name: "other organisation", organisation: int64(7), role: models.RoleManufacturer, userOrg: 8, want: http.StatusForbidden},
+		{name: "user without an organisation", organisation: int64(7), role: models.RoleManufacturer, want: http.StatusForbidden},
+		{name: "subscription to every organisation", organisation: nil, role: models.RoleManufacturer, userOrg: 7, want: http.StatusForbidden},
+		{name: "read any", organisation: int64(7), role: models.RoleAdmin, want: http.StatusOK},
+		{name: "read any, every organisation", organisation: nil, role: models.RoleAdmin, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newWebhookDB(t, tt.organisation)
+
+			w := httptest.NewRecorder()
+			NewWebhookController().GetWebhook(w, webhookRequest(t, http.MethodGet, "/api/webhooks/1", "", tt.role, tt.userOrg))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateWebhookScopesOrganisation(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		userOrg int
+		body    string
+		want    int
+		wantOrg interface{}
+	}{
+		{
+			name:    "defaults to the user's organisation",
+			role:    models.RoleManufacturer,
+			userOrg: 7,
+			body:    `{"url": "https://93.184.216.34/hooks", "event_types": ["passport.created"]}`,
+			want:    http.StatusCreated,
+			wantOrg: int64(7),
+		},
+		{
+			name:    "another organisation",
+			role:    models.RoleManufacturer,
+			userOrg: 7,
+			body:    `{"organisation_id": 8, "url": "https://93.184.216.34/hooks", "event_types": ["passport.created"]}`,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "user without an organisation",
+			role: models.RoleManufacturer,
+			body: `{"url": "https://93.184.216.34/hooks", "event_types": ["passport.created"]}`,
+			want: http.StatusForbidden,
+		},
+		{
+			name:    "read any chooses an organisation",
+			role:    models.RoleAdmin,
+			body:    `{"organisation_id": 8, "url": "https://93.184.216.34/hooks", "event_types": ["passport.created"]}`,
+			want:    http.StatusCreated,
+			wantOrg: int64(8),
+		},
+		{
+			name:    "read any subscribes to every organisation",
+			role:    models.RoleAdmin,
+			body:    `{"url": "https://93.184.216.34/hooks", "event_types": ["passport.created"]}`,
+			want:    http.StatusCreated,
+			wantOrg: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newWebhookDB(t, nil)
+
+			w := httptest.NewRecorder()
+			NewWebhookController().CreateWebhook(w, webhookRequest(t, http.MethodPost, "/api/webhooks", tt.body, tt.role, tt.userOrg))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+
+			inserts := fake.Statements(`INSERT INTO webhook_subscriptions`)
+			if tt.want != http.StatusCreated {
+				if len(inserts) != 0 {
+					t.Errorf("subscription stored for a rejected request")
+				}
+				return
+			}
+			if len(inserts) != 1 {
+				t.Fatalf("stored %d subscriptions, want 1", len(inserts))
+			}
+			if got := inserts[0].Args[0]; got != tt.wantOrg {
+				t.Errorf("organisation_id = %v, want %v", got, tt.wantOrg)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/services"
+	"aluminium-passport/internal/zk"
+)
+
+type ZKController struct{}
+
+func NewZKController() *ZKController {
+	return &ZKController{}
+}
+
+type VerifyZKProofRequest struct {
+	ProofID      int        `json:"proof_id"`      // verify a stored proof
+	ProofData    string     `json:"proof_data"`    // or verify a proof received out of band
+	PublicInputs db.JSONMap `json:"public_inputs"` // together with its public inputs
+}
+
+// GenerateProof creates a range proof that a passport value is above or below
+// a threshold without disclosing the value
+func (zc *ZKController) GenerateProof(w http.ResponseWriter, r *http.Request) {
+	claims, err := zc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req services.RangeProofRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.PassportID == "" || req.Attribute == "" {
+		http.Error(w, "passport_id and attribute are required", http.StatusBadRequest)
+		return
+	}
+
+	// Only the passport's organisation may prove its values; anyone else
+	// could learn them by asking for proofs against different thresholds
+	access, err := services.NewTenantScope(claims).AccessByID(req.PassportID)
+	if err == nil && !access.Read {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Passport not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to generate ZK proof", http.StatusInternalServerError)
+		return
+	}
+	if !access.Owner {
+		http.Error(w, "Only the passport's organisation can generate proofs for it", http.StatusForbidden)
+		return
+	}
+
+	proof, err := services.NewZKProofService(db.DB).GenerateRangeProof(req, &claims.UserID)
+	switch {
+	case errors.Is(err, services.ErrZKAttributeUnsupported), errors.Is(err, zk.ErrInvalidOperator):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, services.ErrPassportNotFound):
+		http.Error(w, "Passport not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrZKAttributeMissing), errors.Is(err, zk.ErrStatementFalse):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, "Failed to generate ZK proof", http.StatusInternalServerError)
+		return
+	}
+
+	zc.logAuditEvent(claims.UserID, claims.Role, "CREATE", "zk_proof", strconv.Itoa(proof.ID), nil, proof.PublicInputs, r)
+
+	response := map[string]interface{}{
+		"proof":     proof,
+		"statement": services.ZKStatementSummary(*proof.PublicInputs),
+		"message":   "ZK proof generated successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyProof checks a stored proof by ID, or proof data and public inputs
+// supplied by the caller
+func (zc *ZKController) VerifyProof(w http.ResponseWriter, r *http.Request) {
+	claims, err := zc.extractUserClaims(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req VerifyZKProofRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	publicInputs := req.PublicInputs
+	response := map[string]interface{}{}
+
+	if req.ProofID != 0 {
+		proof, err := services.NewZKProofService(db.DB).VerifyStoredProof(req.ProofID, claims.WalletAddr)
+		if errors.Is(err, services.ErrZKProofNotFound) {
+			http.Error(w, "ZK proof not found", http.StatusNotFound)
+			return
+		}
+		if err != nil && !zc.isVerificationFailure(err) {
+			http.Error(w, "Failed to verify ZK proof", http.StatusInternalServerError)
+			return
+		}
+		if proof.PublicInputs != nil {
+			publicInputs = *proof.PublicInputs
+		}
+		response["proof_id"] = proof.ID
+		response["passport_id"] = proof.PassportID
+		response["valid"] = err == nil
+		if err != nil {
+			response["error"] = err.Error()
+		}
+	} else {
+		if req.ProofData == "" || req.PublicInputs == nil {
+			http.Error(w, "proof_id or proof_data and public_inputs are required", http.StatusBadRequest)
+			return
+		}
+		err := services.VerifyRangeProof(req.ProofData, req.PublicInputs)
+		if err != nil && !zc.isVerificationFailure(err) {
+			http.Error(w, "Failed to verify ZK proof", http.StatusInternalServerError)
+			return
+		}
+		response["valid"] = err == nil
+		if err != nil {
+			response["error"] = err.Error()
+		}
+	}
+
+	response["public_inputs"] = publicInputs
+	response["statement"] = services.ZKStatementSummary(publicInputs)
+	response["message"] = "ZK proof verification completed"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Helper methods
+func (zc *ZKController) isVerificationFailure(err error) bool {
+	return errors.Is(err, zk.ErrInvalidProof) || errors.Is(err, zk.ErrInvalidOperator)
+}
+
+func (zc *ZKController) extractUserClaims(r *http.Request) (*auth.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header required")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return auth.ValidateToken(tokenString)
+}
+
+func (zc *ZKController) logAuditEvent(userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}, r *http.Request) {
+	services.RecordAuditEvent(r, userID, userRole, action, resourceType, resourceID, oldValues, newValues)
+}
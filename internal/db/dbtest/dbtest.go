@@ -0,0 +1,275 @@
+// Package dbtest is an in-memory database/sql driver for tests of code that
+// talks to PostgreSQL through db.DB or an injected *sql.DB.
+//
+// Statements are answered by the first rule whose SQL fragment they contain,
+// compared with whitespace collapsed. A query without a rule fails, so a test
+// names every read it expects; an exec without a rule succeeds and affects
+// one row. Every statement is recorded for assertions.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"aluminium-passport/internal/db"
+)
+
+// Statement is an executed statement and its arguments
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// QueryFunc answers a query from its arguments
+type QueryFunc func(args []interface{}) (columns []string, rows [][]interface{}, err error)
+
+// ExecFunc answers an exec from its arguments with the number of rows it
+// affected
+type ExecFunc func(args []interface{}) (int64, error)
+
+type rule struct {
+	fragment string
+	query    QueryFunc
+	exec     ExecFunc
+}
+
+// DB is a fake database. The embedded *sql.DB is also installed as db.DB
+// for the duration of the test.
+type DB struct {
+	*sql.DB
+
+	mu         sync.Mutex
+	rules      []rule
+	statements []Statement
+}
+
+var (
+	registerOnce sync.Once
+	registryMu   sync.Mutex
+	registry     = map[string]*DB{}
+	nextID       int
+)
+
+// New opens a fake database, installs it as db.DB and restores the previous
+// connection when the test ends
+func New(t testing.TB) *DB {
+	t.Helper()
+	registerOnce.Do(func() { sql.Register("dbtest", fakeDriver{}) })
+
+	registryMu.Lock()
+	nextID++
+	name := fmt.Sprintf("%s#%d", t.Name(), nextID)
+	fake := &DB{}
+	registry[name] = fake
+	registryMu.Unlock()
+
+	conn, err := sql.Open("dbtest", name)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	fake.DB = conn
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+		registryMu.Lock()
+		delete(registry, name)
+		registryMu.Unlock()
+	})
+	return fake
+}
+
+// OnQuery answers queries containing fragment with fixed rows
+func (d *DB) OnQuery(fragment string, columns []string, rows ...[]interface{}) {
+	d.OnQueryFunc(fragment, func([]interface{}) ([]string, [][]interface{}, error) {
+		return columns, rows, nil
+	})
+}
+
+// OnQueryFunc answers queries containing fragment with fn
+func (d *DB) OnQueryFunc(fragment string, fn QueryFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = append(d.rules, rule{fragment: normalize(fragment), query: fn})
+}
+
+// OnExec answers execs containing fragment with fn
+func (d *DB) OnExec(fragment string, fn ExecFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = append(d.rules, rule{fragment: normalize(fragment), exec: fn})
+}
+
+// Statements returns the executed statements that contain fragment, in
+// order
+func (d *DB) Statements(fragment string) []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fragment = normalize(fragment)
+	var matched []Statement
+	for _, s := range d.statements {
+		if strings.Contains(s.Query, fragment) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+func (d *DB) record(query string, args []driver.NamedValue) (string, []interface{}) {
+	query = normalize(query)
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.mu.Lock()
+	d.statements = append(d.statements, Statement{Query: query, Args: values})
+	d.mu.Unlock()
+	return query, values
+}
+
+func (d *DB) match(query string, exec bool) (rule, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.rules {
+		if (r.exec != nil) == exec && strings.Contains(query, r.fragment) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+// Columns splits a comma separated column list, such as a select list
+// constant, into column names
+func Columns(list string) []string {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		columns = append(columns, strings.TrimSpace(column))
+	}
+	return columns
+}
+
+// Row builds a row for columns from the values of the named columns;
+// other columns are NULL
+func Row(columns []string, values map[string]interface{}) []interface{} {
+	row := make([]interface{}, len(columns))
+	for i, column := range columns {
+		row[i] = values[column]
+	}
+	return row
+}
+
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	fake, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("dbtest: unknown database %q", name)
+	}
+	return &conn{db: fake}, nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("dbtest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, values := c.db.record(query, args)
+	r, ok := c.db.match(query, false)
+	if !ok {
+		return nil, fmt.Errorf("dbtest: unexpected query %q", query)
+	}
+	columns, rows, err := r.query(values)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("dbtest: row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+		converted[i] = make([]driver.Value, len(row))
+		for j, value := range row {
+			if converted[i][j], err = driverValue(value); err != nil {
+				return nil, fmt.Errorf("dbtest: column %s: %w", columns[j], err)
+			}
+		}
+	}
+	return &resultRows{columns: columns, rows: converted}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, values := c.db.record(query, args)
+	r, ok := c.db.match(query, true)
+	if !ok {
+		return driver.RowsAffected(1), nil
+	}
+	affected, err := r.exec(values)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+// driverValue converts a row value the way the PostgreSQL driver would
+// return it; string slices become array literals
+func driverValue(value interface{}) (driver.Value, error) {
+	switch v := value.(type) {
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		return "{" + strings.Join(quoted, ",") + "}", nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(value)
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type resultRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *resultRows) Columns() []string { return r.columns }
+
+func (r *resultRows) Close() error { return nil }
+
+func (r *resultRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -9,18 +9,19 @@ import (
 
 // User represents a system user
 type User struct {
-	ID            int        `json:"id" db:"id"`
-	WalletAddress string     `json:"wallet_address" db:"wallet_address"`
-	Username      string     `json:"username" db:"username"`
-	Email         *string    `json:"email" db:"email"`
-	PasswordHash  string     `json:"-" db:"password_hash"`
-	Role          string     `json:"role" db:"role"`
-	CompanyName   *string    `json:"company_name" db:"company_name"`
-	ContactInfo   *JSONMap   `json:"contact_info" db:"contact_info"`
-	IsActive      bool       `json:"is_active" db:"is_active"`
-	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
-	LastLogin     *time.Time `json:"last_login" db:"last_login"`
+	ID             int        `json:"id" db:"id"`
+	WalletAddress  string     `json:"wallet_address" db:"wallet_address"`
+	Username       string     `json:"username" db:"username"`
+	Email          *string    `json:"email" db:"email"`
+	PasswordHash   string     `json:"-" db:"password_hash"`
+	Role           string     `json:"role" db:"role"`
+	CompanyName    *string    `json:"company_name" db:"company_name"`
+	OrganisationID *int       `json:"organisation_id" db:"organisation_id"`
+	ContactInfo    *JSONMap   `json:"contact_info" db:"contact_info"`
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	LastLogin      *time.Time `json:"last_login" db:"last_login"`
 }
 
 // AluminiumPassport represents a passport record
@@ -119,6 +120,9 @@ type AluminiumPassport struct {
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	CreatedBy *int      `json:"created_by" db:"created_by"`
 	UpdatedBy *int      `json:"updated_by" db:"updated_by"`
+
+	// OrganisationID is the tenant that owns the passport
+	OrganisationID *int `json:"organisation_id" db:"organisation_id"`
 }
 
 // ESGMetrics represents detailed ESG scoring
@@ -189,6 +193,23 @@ type AuditLog struct {
 	ErrorMessage *string   `json:"error_message" db:"error_message"`
 	SessionID    *string   `json:"session_id" db:"session_id"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	PrevHash     *string   `json:"prev_hash" db:"prev_hash"`
+	EntryHash    *string   `json:"entry_hash" db:"entry_hash"`
+}
+
+// AuditAnchor is the published Merkle root of a day's audit log entries
+type AuditAnchor struct {
+	ID           int        `json:"id" db:"id"`
+	AnchorDate   time.Time  `json:"anchor_date" db:"anchor_date"`
+	FirstEntryID int        `json:"first_entry_id" db:"first_entry_id"`
+	LastEntryID  int        `json:"last_entry_id" db:"last_entry_id"`
+	EntryCount   int        `json:"entry_count" db:"entry_count"`
+	MerkleRoot   string     `json:"merkle_root" db:"merkle_root"`
+	ChainHead    string     `json:"chain_head" db:"chain_head"`
+	IPFSHash     *string    `json:"ipfs_hash" db:"ipfs_hash"`
+	TxHash       *string    `json:"tx_hash" db:"tx_hash"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	PublishedAt  *time.Time `json:"published_at" db:"published_at"`
 }
 
 // Certification represents a certification record
@@ -214,11 +235,15 @@ type BatchOperation struct {
 	TotalRecords      *int       `json:"total_records" db:"total_records"`
 	SuccessfulRecords *int       `json:"successful_records" db:"successful_records"`
 	FailedRecords     *int       `json:"failed_records" db:"failed_records"`
+	ProcessedRecords  int        `json:"processed_records" db:"processed_records"`
 	Status            string     `json:"status" db:"status"`
 	ErrorLog          *JSONMap   `json:"error_log" db:"error_log"`
 	IPFSHash          *string    `json:"ipfs_hash" db:"ipfs_hash"`
+	CancelRequested   bool       `json:"cancel_requested" db:"cancel_requested"`
 	CreatedBy         *int       `json:"created_by" db:"created_by"`
 	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	StartedAt         *time.Time `json:"started_at" db:"started_at"`
+	UpdatedAt         *time.Time `json:"updated_at" db:"updated_at"`
 	CompletedAt       *time.Time `json:"completed_at" db:"completed_at"`
 }
 
@@ -237,6 +262,26 @@ type ZKProof struct {
 	VerifiedAt      *time.Time `json:"verified_at" db:"verified_at"`
 }
 
+// IssuerKey represents an organisation's credential signing key
+type IssuerKey struct {
+	ID                  int        `json:"id" db:"id"`
+	OrganisationID      int        `json:"organisation_id" db:"organisation_id"`
+	Organisation        string     `json:"organisation" db:"organisation"` // slug naming the key's did:web
+	CompanyName         *string    `json:"company_name" db:"company_name"`
+	WalletAddress       *string    `json:"wallet_address" db:"wallet_address"`
+	ControllerDID       string     `json:"controller_did" db:"controller_did"`
+	VerificationMethod  string     `json:"verification_method" db:"verification_method"`
+	KeyType             string     `json:"key_type" db:"key_type"`
+	PublicKeyMultibase  string     `json:"public_key_multibase" db:"public_key_multibase"`
+	EncryptedPrivateKey string     `json:"-" db:"encrypted_private_key"`
+	Status              string     `json:"status" db:"status"`
+	CreatedBy           *int       `json:"created_by" db:"created_by"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	RotatedAt           *time.Time `json:"rotated_at" db:"rotated_at"`
+	RevokedAt           *time.Time `json:"revoked_at" db:"revoked_at"`
+	RevocationReason    *string    `json:"revocation_reason" db:"revocation_reason"`
+}
+
 // JSONMap for handling JSONB fields
 type JSONMap map[string]interface{}
 
@@ -3,35 +3,64 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/qr"
 	"aluminium-passport/internal/services"
-	"aluminium-passport/internal/utils"
 
 	"github.com/gorilla/mux"
 )
 
-// VerifySignatureHandler verifies digital signatures
+// VerifySignatureHandler verifies a signed verifiable credential, either a
+// Data Integrity secured document or a VC-JOSE JWT
 func VerifySignatureHandler(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Data      string `json:"data"`
-		Signature string `json:"signature"`
-		PublicKey string `json:"public_key"`
+		Credential json.RawMessage `json:"credential"`
+		JWT        string          `json:"jwt"`
+		PublicKey  string          `json:"public_key"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || (len(request.Credential) == 0 && request.JWT == "") {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	isValid := services.VerifySignature(request.Data, request.Signature, request.PublicKey)
+	// Resolve the issuer's key from the proof; a supplied key must match it.
+	// Data Integrity proofs are checked over the credential as sent.
+	var credential *models.VerifiableClaim
+	var verifyErr error
+	resource := ""
+	switch {
+	case request.JWT != "":
+		credential, verifyErr = services.VerifyCredentialJWT(request.JWT)
+		if credential != nil {
+			resource = credential.ID
+		}
+	case request.PublicKey != "":
+		credential, verifyErr = services.VerifyVerifiableClaimWithKey(request.Credential, request.PublicKey)
+	default:
+		credential, verifyErr = services.VerifyVerifiableClaim(request.Credential)
+	}
 
+	if credential != nil && credential.Proof != nil {
+		resource = credential.Proof.VerificationMethod
+	}
 	user, role := extractUserRole(r)
-	services.LogEvent(user, role, "SIGNATURE_VERIFY", request.Signature)
+	services.LogEvent(user, role, "SIGNATURE_VERIFY", resource)
 
 	response := map[string]interface{}{
-		"valid":   isValid,
+		"valid":   verifyErr == nil,
 		"message": "Signature verification completed",
 	}
+	if verifyErr != nil {
+		response["error"] = verifyErr.Error()
+	} else if request.JWT != "" {
+		response["credential"] = credential
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -42,13 +71,13 @@ func GenerateQRHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	passport, err := services.GetPassportById(id)
+	passport, err := services.GetPassportRecord(id)
 	if err != nil {
 		http.Error(w, "Passport not found", http.StatusNotFound)
 		return
 	}
 
-	qrCode, err := utils.GenerateQRCode(passport)
+	qrCode, err := qr.GenerateQRCodeImage(passport)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
@@ -122,14 +151,14 @@ func VerifyZKProofHandler(w http.ResponseWriter, r *http.Request) {
 // GetAuditLogsHandler returns audit logs
 func GetAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
 	// Query parameters for filtering
-	userFilter := r.URL.Query().Get("user")
-	actionFilter := r.URL.Query().Get("action")
-	limit := r.URL.Query().Get("limit")
-	if limit == "" {
-		limit = "100"
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	filter := services.AuditFilter{
+		User:   r.URL.Query().Get("user"),
+		Action: r.URL.Query().Get("action"),
+		Limit:  limit,
 	}
 
-	logs, err := services.GetAuditLogs(userFilter, actionFilter, limit)
+	logs, _, err := services.NewAuditService(db.DB).ListAuditLogs(filter)
 	if err != nil {
 		http.Error(w, "Failed to retrieve audit logs", http.StatusInternalServerError)
 		return
@@ -147,7 +176,13 @@ func ExportCSVHandler(w http.ResponseWriter, r *http.Request) {
 	// Query parameters for filtering
 	batchID := r.URL.Query().Get("batch_id")
 
-	csvData, err := services.ExportPassportsCSV(batchID)
+	scope, err := tenantScope(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	csvData, err := services.ExportPassportsCSV(scope, batchID)
 	if err != nil {
 		http.Error(w, "Failed to export CSV", http.StatusInternalServerError)
 		return
@@ -166,7 +201,13 @@ func ExportJSONHandler(w http.ResponseWriter, r *http.Request) {
 	// Query parameters for filtering
 	batchID := r.URL.Query().Get("batch_id")
 
-	jsonData, err := services.ExportPassportsJSON(batchID)
+	scope, err := tenantScope(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jsonData, err := services.ExportPassportsJSON(scope, batchID)
 	if err != nil {
 		http.Error(w, "Failed to export JSON", http.StatusInternalServerError)
 		return
@@ -179,3 +220,13 @@ func ExportJSONHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Disposition", "attachment; filename=passports_export.json")
 	w.Write(jsonData)
 }
+
+// tenantScope returns the tenant scope of the user the request's token was
+// issued to
+func tenantScope(r *http.Request) (services.TenantScope, error) {
+	claims, err := auth.ValidateToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		return services.TenantScope{}, err
+	}
+	return services.NewTenantScope(claims), nil
+}
@@ -3,30 +3,130 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
-    "time"
+    "strconv"
 
-    "aluminium-passport/internal/models"
+    "aluminium-passport/internal/db"
+    "aluminium-passport/internal/middleware"
     "aluminium-passport/internal/services"
+
+    "github.com/gorilla/mux"
 )
 
+// ExportSignedCredentialHandler exports a passport as a W3C Verifiable
+// Credential 2.0. The default is a Data Integrity secured JSON-LD document;
+// ?format=jwt returns a VC-JOSE JWT and ?format=sd-jwt a selectively
+// disclosable SD-JWT VC instead. Passports outside the caller's tenant scope
+// are reported as not found.
 func ExportSignedCredentialHandler(w http.ResponseWriter, r *http.Request) {
-    claim := &models.VerifiableClaim{
-        Context: []string{"https://www.w3.org/2018/credentials/v1"},
-        Type:    []string{"VerifiableCredential", "AluminiumPassport"},
-        Issuer:  "did:example:issuer",
-        IssuanceDate: time.Now().Format(time.RFC3339),
-        CredentialSubject: map[string]interface{}{
-            "passportId": "ALU123",
-            "batchId": "BATCH456",
-            "bauxiteOrigin": "Australia",
-            "carbonEmissionsPerKg": 6.2,
-        },
-    }
-
-    signed := services.SignVerifiableClaim(claim, "dummy-private-key")
-
-    w.Header().Set("Content-Type", "application/json")
-    w.Header().Set("Content-Disposition", "attachment; filename=verifiable-passport.json")
+    passportID := mux.Vars(r)["id"]
+
+    claims, ok := middleware.GetUserFromContext(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    passport, err := services.GetPassportRecord(passportID)
+    if errors.Is(err, services.ErrPassportNotFound) {
+        http.Error(w, "Passport not found", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to load passport", http.StatusInternalServerError)
+        return
+    }
+
+    access, err := services.NewTenantScope(claims).Access(passportID, passport.OrganisationID)
+    if err != nil {
+        http.Error(w, "Failed to load passport", http.StatusInternalServerError)
+        return
+    }
+    if !access.Read {
+        http.Error(w, "Passport not found", http.StatusNotFound)
+        return
+    }
+
+    format := r.URL.Query().Get("format")
+    if format != "" && format != "jsonld" && format != "jwt" && format != "sd-jwt" {
+        http.Error(w, "Unsupported format; use jsonld, jwt or sd-jwt", http.StatusBadRequest)
+        return
+    }
+
+    if format == "jwt" || format == "sd-jwt" {
+        issue, contentType, extension := services.IssuePassportCredentialJWT, "application/vc+jwt", ".vc.jwt"
+        if format == "sd-jwt" {
+            issue, contentType, extension = services.IssuePassportSDJWT, "application/dc+sd-jwt", ".sd-jwt"
+        }
+
+        token, err := issue(passport)
+        if errors.Is(err, services.ErrPassportInactive) {
+            http.Error(w, "Passport has been deactivated", http.StatusConflict)
+            return
+        }
+        if err != nil {
+            http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
+            return
+        }
+
+        user, role := extractUserRole(r)
+        services.LogEvent(user, role, "EXPORT_CREDENTIAL", passportID)
+
+        w.Header().Set("Content-Type", contentType)
+        w.Header().Set("Content-Disposition", "attachment; filename="+passportID+extension)
+        w.Write([]byte(token))
+        return
+    }
+
+    signed, err := services.IssuePassportCredential(passport)
+    if errors.Is(err, services.ErrPassportInactive) {
+        http.Error(w, "Passport has been deactivated", http.StatusConflict)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
+        return
+    }
+
+    user, role := extractUserRole(r)
+    services.LogEvent(user, role, "EXPORT_CREDENTIAL", passportID)
+
+    w.Header().Set("Content-Type", "application/vc")
+    w.Header().Set("Content-Disposition", "attachment; filename="+passportID+".vc.json")
     json.NewEncoder(w).Encode(signed)
 }
+
+// StatusListCredentialHandler publishes a signed Bitstring Status List
+// credential
+func StatusListCredentialHandler(w http.ResponseWriter, r *http.Request) {
+    listID, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        http.Error(w, "Invalid status list ID", http.StatusBadRequest)
+        return
+    }
+
+    credential, err := services.NewStatusListService(db.DB).StatusListCredential(listID)
+    if errors.Is(err, services.ErrStatusListNotFound) {
+        http.Error(w, "Status list not found", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to build status list", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/vc")
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    json.NewEncoder(w).Encode(credential)
+}
+
+// PassportContextHandler publishes the JSON-LD @context referenced by
+// aluminium passport credentials
+func PassportContextHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/ld+json")
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.Write([]byte(services.PassportContextDocument))
+}
@@ -1,21 +1,22 @@
-
 package handlers
 
 import (
     "encoding/csv"
+    "log"
     "net/http"
-    "os"
-    "strings"
+    "strconv"
+    "time"
+
+    "aluminium-passport/internal/db"
+    "aluminium-passport/internal/services"
 )
 
-// ExportAuditCSVHandler streams audit.log as a CSV file
+// ExportAuditCSVHandler streams the audit log as a CSV file
 func ExportAuditCSVHandler(w http.ResponseWriter, r *http.Request) {
-    file, err := os.Open("audit.log")
-    if err != nil {
-        http.Error(w, "Failed to open audit log", http.StatusInternalServerError)
-        return
+    filter := services.AuditFilter{
+        User:   r.URL.Query().Get("user"),
+        Action: r.URL.Query().Get("action"),
     }
-    defer file.Close()
 
     w.Header().Set("Content-Type", "text/csv")
     w.Header().Set("Content-Disposition", "attachment; filename=audit_log.csv")
@@ -23,28 +24,32 @@ func ExportAuditCSVHandler(w http.ResponseWriter, r *http.Request) {
     writer := csv.NewWriter(w)
     defer writer.Flush()
 
-    writer.Write([]string{"Timestamp", "Username", "Role", "Action", "Resource"})
+    writer.Write([]string{"Timestamp", "UserID", "Role", "Action", "ResourceType", "Resource", "IPAddress"})
 
-    var buf = make([]byte, 4096)
-    n, _ := file.Read(buf)
-    lines := strings.Split(string(buf[:n]), "\n")
-
-    for _, line := range lines {
-        if strings.TrimSpace(line) == "" {
-            continue
-        }
-        // Example: [2025-07-31T12:45:10Z] USER=admin ROLE=issuer ACTION=CREATE RESOURCE=ALU123
-        line = strings.TrimPrefix(line, "[")
-        parts := strings.Split(line, "] ")
-        if len(parts) < 2 {
-            continue
+    err := services.NewAuditService(db.DB).EachAuditLog(filter, func(entry *db.AuditLog) error {
+        userID := ""
+        if entry.UserID != nil {
+            userID = strconv.Itoa(*entry.UserID)
         }
-        timestamp := parts[0]
-        fields := strings.Fields(parts[1])
-        if len(fields) < 5 {
-            continue
-        }
-        row := []string{timestamp, strings.Split(fields[0], "=")[1], strings.Split(fields[1], "=")[1], strings.Split(fields[2], "=")[1], strings.Split(fields[3], "=")[1]}
-        writer.Write(row)
+        writer.Write([]string{
+            entry.CreatedAt.UTC().Format(time.RFC3339),
+            userID,
+            stringOrEmpty(entry.UserRole),
+            entry.Action,
+            stringOrEmpty(entry.ResourceType),
+            stringOrEmpty(entry.ResourceID),
+            stringOrEmpty(entry.IPAddress),
+        })
+        return writer.Error()
+    })
+    if err != nil {
+        log.Printf("Audit log export interrupted: %v", err)
+    }
+}
+
+func stringOrEmpty(s *string) string {
+    if s == nil {
+        return ""
     }
+    return *s
 }
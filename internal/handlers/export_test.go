@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/middleware"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+const testJWTSecret = "handlers-test-secret"
+
+// newExportDB installs a fake database holding passport AP-1, owned by
+// organisation 7, and the default role permissions
+func newExportDB(t *testing.T) *dbtest.DB {
+	t.Helper()
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+
+	now := time.Now().UTC()
+	columns := dbtest.Columns(services.PassportColumns)
+	fake.OnQuery(`FROM aluminium_passports WHERE passport_id = $1`, columns, dbtest.Row(columns, map[string]interface{}{
+		"id":              int64(1),
+		"passport_id":     "AP-1",
+		"manufacturer":    "Example Smelter",
+		"origin":          "Guinea",
+		"times_recycled":  int64(0),
+		"status":          "active",
+		"is_verified":     false,
+		"created_at":      now,
+		"updated_at":      now,
+		"organisation_id": int64(7),
+	}))
+	fake.OnQuery(`FROM passport_grants`, []string{"access", "sections"})
+	fake.OnQuery(`FROM issuer_keys`, dbtest.Columns(`id, organisation_id, organisation, company_name,
+		wallet_address, controller_did, verification_method, key_type, public_key_multibase,
+		encrypted_private_key, status, created_by, created_at, rotated_at, revoked_at, revocation_reason`))
+	fake.OnQuery(`FROM status_lists`, []string{"id", "next_index"}, []interface{}{int64(1), int64(0)})
+	fake.OnQuery(`FROM audit_logs`, []string{"entry_hash"})
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	return fake
+}
+
+// credentialRequest requests the credential of passportID as the user
+// the claims were issued to
+func credentialRequest(t *testing.T, passportID string, claims *auth.Claims) *http.Request {
+	t.Helper()
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": claims.Username,
+		"role":     claims.Role,
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/passports/"+passportID+"/credential", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = mux.SetURLVars(r, map[string]string{"id": passportID})
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, claims))
+}
+
+func TestExportSignedCredentialAsOrganisationOwner(t *testing.T) {
+	newExportDB(t)
+	claims := &auth.Claims{UserID: 3, Username: "owner", Role: models.RoleManufacturer, OrganisationID: 7}
+
+	w := httptest.NewRecorder()
+	ExportSignedCredentialHandler(w, credentialRequest(t, "AP-1", claims))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var credential models.VerifiableClaim
+	if err := json.Unmarshal(w.Body.Bytes(), &credential); err != nil {
+		t.Fatalf("decode credential: %v", err)
+	}
+	if credential.Issuer == "" || credential.Proof == nil {
+		t.Fatalf("credential is not signed: issuer %q, proof %v", credential.Issuer, credential.Proof)
+	}
+	subject, _ := credential.CredentialSubject.(map[string]interface{})
+	if subject["passportId"] != "AP-1" {
+		t.Errorf("credentialSubject = %v, want passport AP-1", credential.CredentialSubject)
+	}
+}
+
+func TestExportSignedCredentialOutsideTenant(t *testing.T) {
+	newExportDB(t)
+	claims := &auth.Claims{UserID: 4, Username: "other", Role: models.RoleManufacturer, OrganisationID: 8}
+
+	w := httptest.NewRecorder()
+	ExportSignedCredentialHandler(w, credentialRequest(t, "AP-1", claims))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
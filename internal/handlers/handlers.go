@@ -5,6 +5,7 @@ import (
     "encoding/json"
     "net/http"
     "github.com/gorilla/mux"
+    "aluminium-passport/internal/db"
     "aluminium-passport/internal/models"
     "aluminium-passport/internal/services"
     "github.com/golang-jwt/jwt/v5"
@@ -44,13 +45,13 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func CreatePassportHandler(w http.ResponseWriter, r *http.Request) {
-    var passport models.AluminiumPassport
+    var passport db.AluminiumPassport
     if err := json.NewDecoder(r.Body).Decode(&passport); err != nil {
         http.Error(w, "Invalid input", http.StatusBadRequest)
         return
     }
 
-    if err := services.SavePassport(&passport); err != nil {
+    if _, err := services.InsertPassportRecord(&passport); err != nil {
         http.Error(w, "Failed to save passport", http.StatusInternalServerError)
         return
     }
@@ -65,7 +66,7 @@ func GetPassportByIdHandler(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     id := vars["id"]
 
-    passport, err := services.GetPassportById(id)
+    passport, err := services.GetPassportRecord(id)
     if err != nil {
         http.Error(w, "Passport not found", http.StatusNotFound)
         return
@@ -2,11 +2,15 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/services"
 )
 
 type contextKey string
@@ -40,6 +44,16 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Reject tokens of logged-out, revoked or rotated sessions
+		if err := services.NewSessionService(db.DB).ValidateAccess(claims); err != nil {
+			if errors.Is(err, services.ErrSessionRevoked) || errors.Is(err, services.ErrSessionNotFound) {
+				http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Failed to validate session", http.StatusInternalServerError)
+			return
+		}
+
 		// Add user info to request context
 		ctx := context.WithValue(r.Context(), UserContextKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -51,9 +65,15 @@ func AuthMiddlewareFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return AuthMiddleware(handlerFunc).ServeHTTP
 }
 
-// RoleMiddleware checks if user has required role(s)
-func RoleMiddleware(allowedRoles ...string) func(http.Handler) http.Handler {
+// RequirePermission checks that the user's role holds a permission. Which
+// roles hold which permissions is decided by the policy service.
+// models.PermPublic lets every request through, including unauthenticated
+// ones.
+func RequirePermission(permission models.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if permission == models.PermPublic {
+			return next
+		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get user from context (should be set by AuthMiddleware)
 			claims, ok := r.Context().Value(UserContextKey).(*auth.Claims)
@@ -62,17 +82,13 @@ func RoleMiddleware(allowedRoles ...string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Check if user role is in allowed roles
-			authorized := false
-			for _, role := range allowedRoles {
-				if claims.Role == role {
-					authorized = true
-					break
-				}
+			allowed, err := services.NewPolicyService(db.DB).Allows(claims.Role, permission)
+			if err != nil {
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
 			}
-
-			if !authorized {
-				http.Error(w, fmt.Sprintf("Insufficient permissions. Required roles: %v", allowedRoles), http.StatusForbidden)
+			if !allowed {
+				http.Error(w, fmt.Sprintf("Insufficient permissions. Required permission: %s", permission), http.StatusForbidden)
 				return
 			}
 
@@ -81,10 +97,10 @@ func RoleMiddleware(allowedRoles ...string) func(http.Handler) http.Handler {
 	}
 }
 
-// RoleMiddlewareFunc wraps a handler function with role checking
-func RoleMiddlewareFunc(allowedRoles ...string) func(http.HandlerFunc) http.HandlerFunc {
+// RequirePermissionFunc wraps a handler function with permission checking
+func RequirePermissionFunc(permission models.Permission) func(http.HandlerFunc) http.HandlerFunc {
 	return func(handlerFunc http.HandlerFunc) http.HandlerFunc {
-		return RoleMiddleware(allowedRoles...)(handlerFunc).ServeHTTP
+		return RequirePermission(permission)(handlerFunc).ServeHTTP
 	}
 }
 
@@ -94,10 +110,10 @@ func GetUserFromContext(r *http.Request) (*auth.Claims, bool) {
 	return claims, ok
 }
 
-// RequireAuth is a convenience function that combines auth and role checking
-func RequireAuth(allowedRoles ...string) func(http.Handler) http.Handler {
+// RequireAuth is a convenience function that combines auth and permission checking
+func RequireAuth(permission models.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return AuthMiddleware(RoleMiddleware(allowedRoles...)(next))
+		return AuthMiddleware(RequirePermission(permission)(next))
 	}
 }
 
@@ -108,7 +124,8 @@ func OptionalAuth(next http.Handler) http.Handler {
 		if authHeader != "" {
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 			if tokenString != authHeader {
-				if claims, err := auth.ValidateToken(tokenString); err == nil {
+				if claims, err := auth.ValidateToken(tokenString); err == nil &&
+					services.NewSessionService(db.DB).ValidateAccess(claims) == nil {
 					ctx := context.WithValue(r.Context(), UserContextKey, claims)
 					r = r.WithContext(ctx)
 				}
@@ -44,6 +44,48 @@ type ApprovalRequest struct {
 	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
 	ApprovedAt      *time.Time     `json:"approved_at" db:"approved_at"`
+	Votes           []ApprovalVote `json:"votes,omitempty"`
+}
+
+// ApprovalVote is one user's decision on a request
+type ApprovalVote struct {
+	ID                int            `json:"id" db:"id"`
+	ApprovalRequestID int            `json:"approval_request_id" db:"approval_request_id"`
+	VoterID           int            `json:"voter_id" db:"voter_id"`
+	VoterRole         string         `json:"voter_role" db:"voter_role"`
+	Decision          ApprovalStatus `json:"decision" db:"decision"`
+	Reason            *string        `json:"reason" db:"reason"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+}
+
+// QuorumRequirement is the number of distinct voters of a role that must
+// approve
+type QuorumRequirement struct {
+	Role  string `json:"role"`
+	Count int    `json:"count"`
+}
+
+// ApprovalPolicy is the quorum a request type needs before it is applied
+type ApprovalPolicy struct {
+	RequestType  ApprovalType        `json:"request_type"`
+	Requirements []QuorumRequirement `json:"requirements"`
+}
+
+// PendingUser is an account awaiting approval of its onboarding request
+type PendingUser struct {
+	ID                int         `json:"id" db:"id"`
+	ApprovalRequestID int         `json:"approval_request_id" db:"approval_request_id"`
+	WalletAddress     string      `json:"wallet_address" db:"wallet_address"`
+	Username          string      `json:"username" db:"username"`
+	Email             *string     `json:"email" db:"email"`
+	PasswordHash      string      `json:"-" db:"password_hash"`
+	RequestedRole     string      `json:"requested_role" db:"requested_role"`
+	CompanyName       *string     `json:"company_name" db:"company_name"`
+	CompanyType       *string     `json:"company_type" db:"company_type"`
+	BusinessLicense   *string     `json:"business_license" db:"business_license"`
+	ContactInfo       *db.JSONMap `json:"contact_info" db:"contact_info"`
+	Justification     *string     `json:"justification" db:"justification"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
 }
 
 // SupplierOnboardingRequest represents the data for supplier onboarding
@@ -59,6 +101,12 @@ type SupplierOnboardingRequest struct {
 	Justification   string     `json:"justification"`
 }
 
+// UserRoleChangeData is the request data of a user_role_change request
+type UserRoleChangeData struct {
+	UserID  int    `json:"user_id"`
+	NewRole string `json:"new_role"`
+}
+
 // ApprovalRequestCreateData represents data needed to create an approval request
 type ApprovalRequestCreateData struct {
 	RequestType   ApprovalType `json:"request_type" binding:"required"`
@@ -73,6 +121,15 @@ type ApprovalRequestCreateData struct {
 type ApprovalAction struct {
 	Action ApprovalStatus `json:"action" binding:"required"` // "approved" or "rejected"
 	Reason string         `json:"reason"`
+	// OrganisationID is the existing organisation an approved supplier
+	// joins. Without it, a new organisation is created for the supplier.
+	OrganisationID *int `json:"organisation_id,omitempty"`
+}
+
+// ApprovalExtension moves the expiry of a pending or expired request
+type ApprovalExtension struct {
+	ExpiresInDays int    `json:"expires_in_days"` // Default 7 days if not specified
+	Reason        string `json:"reason"`
 }
 
 // IsExpired checks if the approval request has expired
@@ -92,3 +149,43 @@ func (ar *ApprovalRequest) CanBeApprovedBy(userRole string) bool {
 func (ar *ApprovalRequest) IsPending() bool {
 	return ar.Status == ApprovalStatusPending && !ar.IsExpired()
 }
+
+// AllowsVoter reports whether a user of the role may vote under the policy
+func (p *ApprovalPolicy) AllowsVoter(role string) bool {
+	for _, requirement := range p.Requirements {
+		if requirement.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGrant reports whether every role the policy needs votes from holds
+// at least role, so that its quorum never grants more than its voters hold
+func (p *ApprovalPolicy) AllowsGrant(role string) bool {
+	for _, requirement := range p.Requirements {
+		if !HasHigherOrEqualRole(requirement.Role, role) {
+			return false
+		}
+	}
+	return true
+}
+
+// Outstanding returns the approvals still needed, by role. A vote counts
+// only towards the requirement for the voter's own role.
+func (p *ApprovalPolicy) Outstanding(votes []ApprovalVote) []QuorumRequirement {
+	approvals := map[string]int{}
+	for _, vote := range votes {
+		if vote.Decision == ApprovalStatusApproved {
+			approvals[vote.VoterRole]++
+		}
+	}
+
+	var outstanding []QuorumRequirement
+	for _, requirement := range p.Requirements {
+		if missing := requirement.Count - approvals[requirement.Role]; missing > 0 {
+			outstanding = append(outstanding, QuorumRequirement{Role: requirement.Role, Count: missing})
+		}
+	}
+	return outstanding
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApprovalPolicyAllowsGrant(t *testing.T) {
+	policy := &ApprovalPolicy{Requirements: []QuorumRequirement{{Role: RoleAdmin, Count: 1}, {Role: RoleAuditor, Count: 1}}}
+
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{RoleViewer, true},
+		{RoleAuditor, true},
+		{RoleCertifier, false},
+		{RoleAdmin, false},
+		{RoleSuperAdmin, false},
+	}
+	for _, tt := range tests {
+		if got := policy.AllowsGrant(tt.role); got != tt.want {
+			t.Errorf("AllowsGrant(%s) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+
+	if !(&ApprovalPolicy{}).AllowsGrant(RoleSuperAdmin) {
+		t.Errorf("a policy without requirements restricted a grant")
+	}
+}
+
+func TestApprovalPolicyOutstanding(t *testing.T) {
+	policy := &ApprovalPolicy{Requirements: []QuorumRequirement{{Role: RoleSuperAdmin, Count: 2}, {Role: RoleAuditor, Count: 1}}}
+	approve := func(role string) ApprovalVote {
+		return ApprovalVote{VoterRole: role, Decision: ApprovalStatusApproved}
+	}
+
+	tests := []struct {
+		name  string
+		votes []ApprovalVote
+		want  []QuorumRequirement
+	}{
+		{
+			name: "no votes",
+			want: policy.Requirements,
+		},
+		{
+			name:  "partial quorum",
+			votes: []ApprovalVote{approve(RoleSuperAdmin), approve(RoleAuditor)},
+			want:  []QuorumRequirement{{Role: RoleSuperAdmin, Count: 1}},
+		},
+		{
+			name:  "votes count only for the voter's own role",
+			votes: []ApprovalVote{approve(RoleSuperAdmin), approve(RoleSuperAdmin), approve(RoleSuperAdmin)},
+			want:  []QuorumRequirement{{Role: RoleAuditor, Count: 1}},
+		},
+		{
+			name:  "rejections do not count",
+			votes: []ApprovalVote{approve(RoleSuperAdmin), approve(RoleSuperAdmin), {VoterRole: RoleAuditor, Decision: ApprovalStatusRejected}},
+			want:  []QuorumRequirement{{Role: RoleAuditor, Count: 1}},
+		},
+		{
+			name:  "quorum met",
+			votes: []ApprovalVote{approve(RoleSuperAdmin), approve(RoleAuditor), approve(RoleSuperAdmin)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Outstanding(tt.votes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Outstanding = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
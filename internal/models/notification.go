@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+// Notification events
+const (
+	EventApprovalRequested = "approval_requested"
+	EventApprovalApproved  = "approval_approved"
+	EventApprovalRejected  = "approval_rejected"
+	EventApprovalExpired   = "approval_expired"
+	EventPassportCreated   = "passport_created"
+	EventPassportRecycled  = "passport_recycled"
+)
+
+// Notification channels
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// NotificationEvents returns every event users can be notified of
+func NotificationEvents() []string {
+	return []string{
+		EventApprovalRequested,
+		EventApprovalApproved,
+		EventApprovalRejected,
+		EventApprovalExpired,
+		EventPassportCreated,
+		EventPassportRecycled,
+	}
+}
+
+// NotificationChannels returns every channel notifications can be sent on
+func NotificationChannels() []string {
+	return []string{ChannelInApp, ChannelEmail, ChannelWebhook}
+}
+
+// Notification is a rendered message for one user. Notifications with
+// InApp set appear in the user's inbox.
+type Notification struct {
+	ID        int         `json:"id" db:"id"`
+	UserID    int         `json:"user_id" db:"user_id"`
+	Event     string      `json:"event" db:"event"`
+	Subject   string      `json:"subject" db:"subject"`
+	Body      string      `json:"body" db:"body"`
+	Data      *db.JSONMap `json:"data,omitempty" db:"data"`
+	InApp     bool        `json:"-" db:"in_app"`
+	ReadAt    *time.Time  `json:"read_at" db:"read_at"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
+
+// NotificationPreference turns one channel on or off for one event
+type NotificationPreference struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
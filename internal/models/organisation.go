@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Organisation is a tenant. Users and passports belong to at most one
+// organisation.
+type Organisation struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	IsActive  bool      `json:"is_active"`
+	CreatedBy *int      `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Passport grant access levels
+const (
+	GrantAccessRead  = "read"
+	GrantAccessWrite = "write"
+)
+
+// PassportGrant shares a passport with another organisation. Read grants
+// expose the whole passport; write grants also allow changes to Sections.
+type PassportGrant struct {
+	ID               int        `json:"id"`
+	PassportID       string     `json:"passport_id"`
+	OrganisationID   int        `json:"organisation_id"`
+	OrganisationName string     `json:"organisation_name,omitempty"`
+	Access           string     `json:"access"`
+	Sections         []string   `json:"sections"`
+	GrantedBy        *int       `json:"granted_by,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// PassportGrantRequest creates a passport grant
+type PassportGrantRequest struct {
+	OrganisationID int        `json:"organisation_id"`
+	Access         string     `json:"access"`
+	Sections       []string   `json:"sections"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// Passport sections group the fields of a passport that one supply chain
+// party usually maintains
+const (
+	SectionBasic         = "basic"
+	SectionMining        = "mining"
+	SectionRefining      = "refining"
+	SectionSmelting      = "smelting"
+	SectionLogistics     = "logistics"
+	SectionRecycling     = "recycling"
+	SectionCertification = "certification"
+	SectionESG           = "esg"
+)
+
+// PassportSections returns the columns of every passport section
+func PassportSections() map[string][]string {
+	return map[string][]string{
+		SectionBasic:    {"batch_id", "manufacturer", "origin", "bauxite_source", "alloy_composition", "metadata"},
+		SectionMining:   {"mine_operator", "date_of_extraction", "extraction_method", "mine_location"},
+		SectionRefining: {"refinery_location", "refiner_id", "refining_date", "refining_method"},
+		SectionSmelting: {
+			"smelting_location", "smelting_energy_source", "process_type", "manufactured_product", "manufacturing_date",
+			"product_weight", "energy_used", "water_used", "waste_generated",
+			"carbon_emissions_per_kg", "co2_footprint", "manufacturing_emissions",
+		},
+		SectionLogistics: {"transport_mode", "distance_travelled", "logistics_partner_id", "shipment_date"},
+		SectionRecycling: {
+			"recycled_content_percent", "recycling_date", "recycler_id", "recycling_method",
+			"times_recycled", "last_recycling_date",
+		},
+		SectionCertification: {
+			"certification_agency", "certifier", "compliance_standards", "date_of_certification",
+			"certification_expiry", "verifier_signature",
+		},
+		SectionESG: {"esg_score", "environmental_score", "social_score", "governance_score", "esg_last_updated"},
+	}
+}
+
+// PassportFieldSection returns the section of a passport column. Columns
+// outside every section, such as status or ipfs_hash, are maintained by the
+// platform.
+func PassportFieldSection(field string) (string, bool) {
+	for section, fields := range PassportSections() {
+		for _, f := range fields {
+			if f == field {
+				return section, true
+			}
+		}
+	}
+	return "", false
+}
+
+// IsValidPassportSection checks if section is one of the passport sections
+func IsValidPassportSection(section string) bool {
+	_, ok := PassportSections()[section]
+	return ok
+}
@@ -50,12 +50,51 @@ type BatchUploadRequest struct {
 }
 
 type BatchUploadResponse struct {
-	BatchID        string   `json:"batch_id"`
-	TotalProcessed int      `json:"total_processed"`
-	Successful     int      `json:"successful"`
-	Failed         int      `json:"failed"`
-	Errors         []string `json:"errors,omitempty"`
-	IPFSHash       string   `json:"ipfs_hash,omitempty"`
+	BatchID        string          `json:"batch_id"`
+	Status         string          `json:"status"`
+	TotalProcessed int             `json:"total_processed"`
+	Successful     int             `json:"successful"`
+	Failed         int             `json:"failed"`
+	Errors         []BatchRowError `json:"errors,omitempty"`
+	IPFSHash       string          `json:"ipfs_hash,omitempty"`
+}
+
+// BatchRecord is one row of an uploaded file. Fields are keyed by the
+// column header as written in the file. Spreadsheet rows also record the
+// cell each field was read from, as a passport may span several sheets.
+type BatchRecord struct {
+	File   string             `json:"file"`
+	Sheet  string             `json:"sheet,omitempty"`
+	Row    int                `json:"row"`
+	Fields map[string]string  `json:"fields"`
+	Cells  map[string]CellRef `json:"cells,omitempty"`
+}
+
+// CellRef locates a spreadsheet cell
+type CellRef struct {
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+}
+
+// BatchRowError reports a problem with a file, a row or a single field.
+// Spreadsheet errors also carry the sheet and column.
+type BatchRowError struct {
+	File       string `json:"file,omitempty"`
+	Sheet      string `json:"sheet,omitempty"`
+	Row        int    `json:"row,omitempty"`
+	Column     string `json:"column,omitempty"`
+	PassportID string `json:"passport_id,omitempty"`
+	Field      string `json:"field,omitempty"`
+	Message    string `json:"message"`
+}
+
+type BatchValidationResponse struct {
+	Valid          bool            `json:"valid"`
+	TotalRecords   int             `json:"total_records"`
+	ValidRecords   int             `json:"valid_records"`
+	InvalidRecords int             `json:"invalid_records"`
+	Errors         []BatchRowError `json:"errors,omitempty"`
 }
 
 type FileUploadMetadata struct {
@@ -0,0 +1,98 @@
+package models
+
+// PassportSubject is the credentialSubject of an AluminiumPassportCredential.
+// JSON names are the terms defined by the aluminium passport @context; dates
+// are xsd:date (YYYY-MM-DD) and sections without data are omitted.
+type PassportSubject struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	PassportID       string `json:"passportId"`
+	BatchID          string `json:"batchId,omitempty"`
+	Manufacturer     string `json:"manufacturer"`
+	Origin           string `json:"origin"`
+	BauxiteSource    string `json:"bauxiteSource,omitempty"`
+	AlloyComposition string `json:"alloyComposition,omitempty"`
+	TraceMetals      string `json:"traceMetals,omitempty"`
+	Status           string `json:"status"`
+
+	Mining              *MiningSection        `json:"mining,omitempty"`
+	Refining            *RefiningSection      `json:"refining,omitempty"`
+	Smelting            *SmeltingSection      `json:"smelting,omitempty"`
+	EnvironmentalImpact *EnvironmentalSection `json:"environmentalImpact,omitempty"`
+	Logistics           *LogisticsSection     `json:"logistics,omitempty"`
+	Recycling           *RecyclingSection     `json:"recycling,omitempty"`
+	Certification       *CertificationSection `json:"certification,omitempty"`
+	ESG                 *ESGSection           `json:"esg,omitempty"`
+	Anchoring           *AnchoringSection     `json:"anchoring,omitempty"`
+}
+
+type MiningSection struct {
+	MineOperator     string `json:"mineOperator,omitempty"`
+	MineLocation     string `json:"mineLocation,omitempty"`
+	ExtractionDate   string `json:"extractionDate,omitempty"`
+	ExtractionMethod string `json:"extractionMethod,omitempty"`
+}
+
+type RefiningSection struct {
+	RefineryLocation string `json:"refineryLocation,omitempty"`
+	RefinerID        string `json:"refinerId,omitempty"`
+	RefiningDate     string `json:"refiningDate,omitempty"`
+	RefiningMethod   string `json:"refiningMethod,omitempty"`
+}
+
+type SmeltingSection struct {
+	SmeltingLocation    string   `json:"smeltingLocation,omitempty"`
+	EnergySource        string   `json:"energySource,omitempty"`
+	ProcessType         string   `json:"processType,omitempty"`
+	ManufacturedProduct string   `json:"manufacturedProduct,omitempty"`
+	ManufacturingDate   string   `json:"manufacturingDate,omitempty"`
+	ProductWeight       *float64 `json:"productWeight,omitempty"`
+	EnergyUsed          *float64 `json:"energyUsed,omitempty"`
+	WaterUsed           *float64 `json:"waterUsed,omitempty"`
+	WasteGenerated      *float64 `json:"wasteGenerated,omitempty"`
+}
+
+type EnvironmentalSection struct {
+	CarbonEmissionsPerKg   *float64 `json:"carbonEmissionsPerKg,omitempty"`
+	CO2Footprint           *float64 `json:"co2Footprint,omitempty"`
+	ManufacturingEmissions *float64 `json:"manufacturingEmissions,omitempty"`
+}
+
+type LogisticsSection struct {
+	TransportMode      string   `json:"transportMode,omitempty"`
+	DistanceTravelled  *float64 `json:"distanceTravelled,omitempty"`
+	LogisticsPartnerID string   `json:"logisticsPartnerId,omitempty"`
+	ShipmentDate       string   `json:"shipmentDate,omitempty"`
+}
+
+type RecyclingSection struct {
+	RecycledContentPercent *float64 `json:"recycledContentPercent,omitempty"`
+	RecyclingDate          string   `json:"recyclingDate,omitempty"`
+	RecyclerID             string   `json:"recyclerId,omitempty"`
+	RecyclingMethod        string   `json:"recyclingMethod,omitempty"`
+	TimesRecycled          int      `json:"timesRecycled"`
+	LastRecyclingDate      string   `json:"lastRecyclingDate,omitempty"`
+}
+
+type CertificationSection struct {
+	CertificationAgency string `json:"certificationAgency,omitempty"`
+	Certifier           string `json:"certifier,omitempty"`
+	ComplianceStandards string `json:"complianceStandards,omitempty"`
+	CertificationDate   string `json:"certificationDate,omitempty"`
+	CertificationExpiry string `json:"certificationExpiry,omitempty"`
+}
+
+type ESGSection struct {
+	ESGScore           *float64 `json:"esgScore,omitempty"`
+	EnvironmentalScore *float64 `json:"environmentalScore,omitempty"`
+	SocialScore        *float64 `json:"socialScore,omitempty"`
+	GovernanceScore    *float64 `json:"governanceScore,omitempty"`
+	LastUpdated        string   `json:"esgLastUpdated,omitempty"`
+}
+
+type AnchoringSection struct {
+	IPFSHash         string `json:"ipfsHash,omitempty"`
+	BlockchainTxHash string `json:"blockchainTxHash,omitempty"`
+	ContractAddress  string `json:"contractAddress,omitempty"`
+	BlockNumber      *int64 `json:"blockNumber,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+// Passport revision change types
+const (
+	RevisionBaseline = "baseline"
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+)
+
+// PassportRevision is one stored change of a passport. Baseline revisions
+// record the state of passports created before history was kept.
+type PassportRevision struct {
+	PassportID        string      `json:"passport_id"`
+	Revision          int         `json:"revision"`
+	ChangeType        string      `json:"change_type"`
+	ChangedBy         *int        `json:"changed_by,omitempty"`
+	ChangedByUsername *string     `json:"changed_by_username,omitempty"`
+	ChangedAt         time.Time   `json:"changed_at"`
+	Fields            []string    `json:"fields"`
+	OldValues         *db.JSONMap `json:"old_values,omitempty"`
+	NewValues         *db.JSONMap `json:"new_values,omitempty"`
+}
+
+// PassportFieldChange is a field that differs between two revisions
+type PassportFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// PassportDiff compares two revisions of a passport
+type PassportDiff struct {
+	PassportID    string                `json:"passport_id"`
+	FromRevision  int                   `json:"from_revision"`
+	ToRevision    int                   `json:"to_revision"`
+	FromChangedAt time.Time             `json:"from_changed_at"`
+	ToChangedAt   time.Time             `json:"to_changed_at"`
+	Changes       []PassportFieldChange `json:"changes"`
+}
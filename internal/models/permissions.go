@@ -0,0 +1,193 @@
+package models
+
+// Permission names an action a role may be allowed to perform. Routes and
+// controllers check permissions; which roles hold them is configurable at
+// runtime, starting from DefaultRolePermissions.
+type Permission string
+
+const (
+	// PermPublic marks routes anyone may call without signing in. Every
+	// route names a permission; this one is held by every caller, signed in
+	// or not, and cannot be granted or removed.
+	PermPublic Permission = "public"
+
+	PermPassportRead       Permission = "passport:read"
+	PermPassportCreate     Permission = "passport:create"
+	PermPassportRecycle    Permission = "passport:recycle"
+	PermPassportDeactivate Permission = "passport:deactivate"
+	PermPassportAnchor     Permission = "passport:anchor"
+	PermPassportShare      Permission = "passport:share"
+	PermPassportReadAny    Permission = "passport:read_any"
+	PermPassportWriteAny   Permission = "passport:write_any"
+
+	PermESGRead   Permission = "esg:read"
+	PermESGAssess Permission = "esg:assess"
+
+	PermBatchRead      Permission = "batch:read"
+	PermBatchUpload    Permission = "batch:upload"
+	PermBatchValidate  Permission = "batch:validate"
+	PermBatchCancel    Permission = "batch:cancel"
+	PermBatchCancelAny Permission = "batch:cancel_any"
+
+	PermCredentialVerify Permission = "credential:verify"
+	PermNotificationRead Permission = "notification:read"
+
+	PermExportRead   Permission = "export:read"
+	PermIPFSUpload   Permission = "ipfs:upload"
+	PermZKGenerate   Permission = "zk:generate"
+	PermAuditRead    Permission = "audit:read"
+	PermKeyManage    Permission = "key:manage"
+	PermKeyManageAny Permission = "key:manage_any"
+
+	PermApprovalRead            Permission = "approval:read"
+	PermApprovalCreate          Permission = "approval:create"
+	PermApprovalOnboardSupplier Permission = "approval:onboard_supplier"
+	PermApprovalVote            Permission = "approval:vote"
+	PermApprovalExtend          Permission = "approval:extend"
+	PermApprovalPolicyRead      Permission = "approval:policy_read"
+	PermApprovalPolicyManage    Permission = "approval:policy_manage"
+
+	PermWebhookManage    Permission = "webhook:manage"
+	PermSessionRevokeAny Permission = "session:revoke_any"
+	PermUserManage       Permission = "user:manage"
+	PermSystemStats      Permission = "system:stats"
+	PermAdminManage      Permission = "admin:manage"
+	PermConfigManage     Permission = "config:manage"
+
+	PermOrganisationManage Permission = "organisation:manage"
+
+	// Section permissions decide which passport fields a role may write;
+	// see PassportSections
+	PermSectionBasic         Permission = "section:write_basic"
+	PermSectionMining        Permission = "section:write_mining"
+	PermSectionRefining      Permission = "section:write_refining"
+	PermSectionSmelting      Permission = "section:write_smelting"
+	PermSectionLogistics     Permission = "section:write_logistics"
+	PermSectionRecycling     Permission = "section:write_recycling"
+	PermSectionCertification Permission = "section:write_certification"
+	PermSectionESG           Permission = "section:write_esg"
+)
+
+// SectionPermission returns the permission needed to write the fields of a
+// passport section
+func SectionPermission(section string) Permission {
+	return Permission("section:write_" + section)
+}
+
+// PermissionDefinition describes a permission for the configuration API
+type PermissionDefinition struct {
+	Name        Permission `json:"name"`
+	Description string     `json:"description"`
+}
+
+// PermissionDefinitions returns every permission in a stable order
+func PermissionDefinitions() []PermissionDefinition {
+	return []PermissionDefinition{
+		{PermPassportRead, "View and list passports, their credentials and QR codes"},
+		{PermPassportCreate, "Create passports"},
+		{PermPassportRecycle, "Update the recycled content of passports"},
+		{PermPassportDeactivate, "Deactivate passports and revoke their credentials"},
+		{PermPassportAnchor, "Register passports on chain"},
+		{PermPassportShare, "Grant other organisations access to the user's organisation's passports"},
+		{PermPassportReadAny, "View the passports of every organisation"},
+		{PermPassportWriteAny, "Change the passports of every organisation"},
+		{PermESGRead, "View ESG metrics and rankings"},
+		{PermESGAssess, "Create ESG assessments and generate ESG scores"},
+		{PermBatchRead, "View batch status"},
+		{PermBatchUpload, "Upload passport batches"},
+		{PermBatchValidate, "Validate passport batches without importing them"},
+		{PermBatchCancel, "Cancel batches the user uploaded"},
+		{PermBatchCancelAny, "Cancel batches uploaded by anyone"},
+		{PermCredentialVerify, "Verify credential signatures and zero-knowledge proofs"},
+		{PermNotificationRead, "View the user's notifications and set their notification preferences"},
+		{PermExportRead, "Export passports as CSV or JSON"},
+		{PermIPFSUpload, "Upload passport data to IPFS"},
+		{PermZKGenerate, "Generate zero-knowledge range proofs"},
+		{PermAuditRead, "View and verify the audit trail"},
+		{PermKeyManage, "Manage the signing keys of the user's organisation"},
+		{PermKeyManageAny, "Manage the signing keys of any organisation"},
+		{PermApprovalRead, "List and view the approval requests the user created or may vote on"},
+		{PermApprovalCreate, "Create approval requests"},
+		{PermApprovalOnboardSupplier, "Request supplier onboarding"},
+		{PermApprovalVote, "Vote on approval requests allowed by their quorum policy"},
+		{PermApprovalExtend, "Extend or re-open approval requests"},
+		{PermApprovalPolicyRead, "View approval quorum policies"},
+		{PermApprovalPolicyManage, "Change approval quorum policies"},
+		{PermWebhookManage, "Manage webhook subscriptions and deliveries"},
+		{PermSessionRevokeAny, "Sign other users out of every session"},
+		{PermUserManage, "Manage users"},
+		{PermSystemStats, "View system statistics"},
+		{PermAdminManage, "Manage admin users"},
+		{PermConfigManage, "Change system configuration, including role permissions"},
+		{PermOrganisationManage, "Create organisations and assign users to them"},
+		{PermSectionBasic, "Write the product fields of passports: batch, manufacturer, origin, alloy and metadata"},
+		{PermSectionMining, "Write the mining and extraction fields of passports"},
+		{PermSectionRefining, "Write the refining fields of passports"},
+		{PermSectionSmelting, "Write the smelting and manufacturing fields of passports"},
+		{PermSectionLogistics, "Write the logistics fields of passports"},
+		{PermSectionRecycling, "Write the recycling fields of passports"},
+		{PermSectionCertification, "Write the certification fields of passports"},
+		{PermSectionESG, "Write the ESG scores of passports"},
+	}
+}
+
+// IsValidPermission checks if permission is one of the known permissions
+func IsValidPermission(permission Permission) bool {
+	for _, definition := range PermissionDefinitions() {
+		if definition.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions returns the permissions each role holds until a
+// super admin configures it otherwise
+func DefaultRolePermissions() map[string][]Permission {
+	read := []Permission{PermPassportRead, PermESGRead, PermBatchRead, PermCredentialVerify, PermNotificationRead}
+	issuing := append([]Permission{
+		PermPassportCreate, PermPassportAnchor, PermPassportShare, PermBatchUpload, PermBatchValidate,
+		PermBatchCancel, PermIPFSUpload, PermKeyManage,
+		PermSectionBasic, PermSectionRefining, PermSectionLogistics,
+	}, read...)
+	admin := append([]Permission{
+		PermPassportCreate, PermPassportRecycle, PermPassportDeactivate, PermPassportAnchor,
+		PermPassportShare, PermPassportReadAny, PermPassportWriteAny, PermOrganisationManage,
+		PermESGAssess, PermBatchUpload, PermBatchValidate, PermBatchCancel, PermBatchCancelAny,
+		PermExportRead, PermIPFSUpload, PermZKGenerate, PermAuditRead, PermKeyManage, PermKeyManageAny,
+		PermApprovalRead, PermApprovalCreate, PermApprovalVote, PermApprovalExtend, PermApprovalPolicyRead,
+		PermWebhookManage, PermSessionRevokeAny, PermUserManage, PermSystemStats,
+		PermSectionBasic, PermSectionMining, PermSectionRefining, PermSectionSmelting,
+		PermSectionLogistics, PermSectionRecycling, PermSectionCertification, PermSectionESG,
+	}, read...)
+	certifying := append([]Permission{
+		PermESGAssess, PermBatchValidate, PermExportRead, PermIPFSUpload, PermZKGenerate, PermKeyManage,
+		PermSectionCertification, PermSectionESG,
+	}, read...)
+
+	return map[string][]Permission{
+		RoleSuperAdmin:   append([]Permission{PermApprovalPolicyManage, PermAdminManage, PermConfigManage}, admin...),
+		RoleAdmin:        append([]Permission{PermApprovalOnboardSupplier}, admin...),
+		RoleCertifier:    certifying,
+		RoleAuditor:      append([]Permission{PermPassportReadAny, PermExportRead, PermAuditRead, PermApprovalRead, PermApprovalVote}, read...),
+		RoleMiner:        append([]Permission{PermSectionMining}, issuing...),
+		RoleManufacturer: append([]Permission{PermSectionSmelting}, issuing...),
+		RoleRecycler:     append([]Permission{PermPassportRecycle, PermKeyManage, PermSectionRecycling}, read...),
+		RoleViewer:       read,
+	}
+}
+
+// SystemConfig is the runtime configuration managed by super admins
+type SystemConfig struct {
+	RolePermissions map[string][]Permission `json:"role_permissions"`
+	CustomisedRoles []string                `json:"customised_roles"`
+	Permissions     []PermissionDefinition  `json:"permissions"`
+}
+
+// SystemConfigUpdate replaces the permissions of the roles in
+// RolePermissions and restores the defaults of the roles in ResetRoles.
+// Roles in neither are left unchanged.
+type SystemConfigUpdate struct {
+	RolePermissions map[string][]Permission `json:"role_permissions"`
+	ResetRoles      []string                `json:"reset_roles"`
+}
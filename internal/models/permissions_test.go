@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestDefaultRolePermissionsAreValid(t *testing.T) {
+	for role, permissions := range DefaultRolePermissions() {
+		for _, permission := range permissions {
+			if !IsValidPermission(permission) {
+				t.Errorf("role %s holds unknown permission %q", role, permission)
+			}
+		}
+	}
+}
+
+func TestPublicPermissionCannotBeGranted(t *testing.T) {
+	if IsValidPermission(PermPublic) {
+		t.Errorf("IsValidPermission(%q) = true, want false", PermPublic)
+	}
+}
+
+func TestDefaultRolePermissions(t *testing.T) {
+	defaults := DefaultRolePermissions()
+	holds := func(role string, permission Permission) bool {
+		for _, p := range defaults[role] {
+			if p == permission {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := []struct {
+		permission Permission
+		roles      []string
+	}{
+		{PermApprovalVote, []string{RoleSuperAdmin, RoleAdmin, RoleAuditor}},
+		{PermConfigManage, []string{RoleSuperAdmin}},
+		{PermPassportWriteAny, []string{RoleSuperAdmin, RoleAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.permission), func(t *testing.T) {
+			want := map[string]bool{}
+			for _, role := range tt.roles {
+				want[role] = true
+			}
+			for role := range defaults {
+				if got := holds(role, tt.permission); got != want[role] {
+					t.Errorf("role %s holds %s = %v, want %v", role, tt.permission, got, want[role])
+				}
+			}
+		})
+	}
+}
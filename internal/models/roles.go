@@ -60,6 +60,16 @@ func GetValidRoles() []string {
 	}
 }
 
+// IsValidRole checks if role is one of the known roles
+func IsValidRole(role string) bool {
+	for _, validRole := range GetValidRoles() {
+		if role == validRole {
+			return true
+		}
+	}
+	return false
+}
+
 // GetSupplierRoles returns roles that are considered suppliers
 func GetSupplierRoles() []string {
 	return []string{
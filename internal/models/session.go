@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Session revocation reasons
+const (
+	SessionRevokedLogout       = "logout"
+	SessionRevokedByUser       = "revoked_by_user"
+	SessionRevokedByAdmin      = "revoked_by_admin"
+	SessionRevokedRefreshReuse = "refresh_token_reuse"
+	SessionRevokedUserInactive = "user_inactive"
+	SessionRevokedRoleChange   = "role_changed"
+)
+
+// Session is an active login. Current marks the session of the request it
+// is returned to.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserAgent  *string   `json:"user_agent"`
+	IPAddress  *string   `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
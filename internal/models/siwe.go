@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// SIWEChallenge is a nonce for Sign-In with Ethereum with the values the
+// signed message must contain. Message is prepared when the wallet address
+// is known.
+type SIWEChallenge struct {
+	Nonce     string    `json:"nonce"`
+	Domain    string    `json:"domain"`
+	URI       string    `json:"uri"`
+	ChainID   int64     `json:"chain_id"`
+	Statement string    `json:"statement"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// SIWELoginRequest is a signed EIP-4361 message
+type SIWELoginRequest struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
@@ -1,19 +1,47 @@
-
 package models
 
+// VerifiableClaim is a W3C Verifiable Credential. Credentials are issued in
+// the VC 2.0 data model (validFrom/validUntil); issuanceDate is kept so
+// VC 1.1 credentials can still be verified.
 type VerifiableClaim struct {
-    Context           []string               `json:"@context"`
-    Type              []string               `json:"type"`
-    Issuer            string                 `json:"issuer"`
-    IssuanceDate      string                 `json:"issuanceDate"`
-    CredentialSubject map[string]interface{} `json:"credentialSubject"`
-    Proof             Proof                  `json:"proof"`
+    Context           []string           `json:"@context"`
+    ID                string             `json:"id,omitempty"`
+    Type              []string           `json:"type"`
+    Issuer            string             `json:"issuer"`
+    ValidFrom         string             `json:"validFrom,omitempty"`
+    ValidUntil        string             `json:"validUntil,omitempty"`
+    IssuanceDate      string             `json:"issuanceDate,omitempty"`
+    CredentialSubject interface{}        `json:"credentialSubject"`
+    CredentialStatus  []CredentialStatus `json:"credentialStatus,omitempty"`
+    Proof             *Proof             `json:"proof,omitempty"`
+}
+
+// CredentialStatus is a BitstringStatusListEntry pointing at one bit of a
+// published status list credential
+type CredentialStatus struct {
+    ID                   string `json:"id"`
+    Type                 string `json:"type"`
+    StatusPurpose        string `json:"statusPurpose"`
+    StatusListIndex      string `json:"statusListIndex"`
+    StatusListCredential string `json:"statusListCredential"`
+}
+
+// StatusListSubject is the credentialSubject of a BitstringStatusListCredential
+type StatusListSubject struct {
+    ID            string `json:"id"`
+    Type          string `json:"type"`
+    StatusPurpose string `json:"statusPurpose"`
+    EncodedList   string `json:"encodedList"`
 }
 
+// Proof is a DataIntegrityProof. Context repeats the @context of the
+// secured document, as the JCS cryptosuites do.
 type Proof struct {
-    Type               string `json:"type"`
-    Created            string `json:"created"`
-    ProofPurpose       string `json:"proofPurpose"`
-    VerificationMethod string `json:"verificationMethod"`
-    SignatureValue     string `json:"signatureValue"`
+    Context            []string `json:"@context,omitempty"`
+    Type               string   `json:"type"`
+    Cryptosuite        string   `json:"cryptosuite"`
+    Created            string   `json:"created"`
+    ProofPurpose       string   `json:"proofPurpose"`
+    VerificationMethod string   `json:"verificationMethod"`
+    ProofValue         string   `json:"proofValue"`
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+// Webhook event types
+const (
+	WebhookPassportCreated   = "passport.created"
+	WebhookPassportUpdated   = "passport.updated"
+	WebhookPassportRecycled  = "passport.recycled"
+	WebhookPassportCertified = "passport.certified"
+	WebhookPassportAnchored  = "passport.anchored"
+)
+
+// WebhookEventTypes returns every event type subscriptions can receive
+func WebhookEventTypes() []string {
+	return []string{
+		WebhookPassportCreated,
+		WebhookPassportUpdated,
+		WebhookPassportRecycled,
+		WebhookPassportCertified,
+		WebhookPassportAnchored,
+	}
+}
+
+// Webhook delivery statuses. Dead deliveries ran out of attempts and stay
+// until they are retried by hand.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookSubscription is an endpoint that receives passport events. The
+// signing secret is only returned when the subscription is created.
+// Subscriptions of an organisation receive the events of its passports
+// only; those without one receive every event.
+type WebhookSubscription struct {
+	ID             int       `json:"id"`
+	OrganisationID *int      `json:"organisation_id"`
+	URL            string    `json:"url"`
+	EventTypes     []string  `json:"event_types"`
+	Secret         string    `json:"secret,omitempty"`
+	Description    *string   `json:"description"`
+	IsActive       bool      `json:"is_active"`
+	CreatedBy      *int      `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WebhookSubscriptionUpdate changes a subscription. Fields left nil are
+// unchanged.
+type WebhookSubscriptionUpdate struct {
+	URL         *string  `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// WebhookReplay asks for the events of a time window to be sent again.
+// EventTypes defaults to the subscription's event types.
+type WebhookReplay struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	EventTypes []string  `json:"event_types"`
+}
+
+// WebhookEvent is a published event, kept so that it can be replayed
+type WebhookEvent struct {
+	ID         int         `json:"id"`
+	EventType  string      `json:"event_type"`
+	ResourceID string      `json:"resource_id"`
+	Payload    *db.JSONMap `json:"payload"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             int        `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	EventID        int        `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      *string    `json:"last_error"`
+	Replay         bool       `json:"replay"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// WebhookAttempt records one try at sending a delivery
+type WebhookAttempt struct {
+	ID           int       `json:"id"`
+	DeliveryID   int       `json:"delivery_id"`
+	StatusCode   *int      `json:"status_code"`
+	Error        *string   `json:"error"`
+	ResponseBody *string   `json:"response_body"`
+	DurationMS   int       `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
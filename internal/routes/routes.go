@@ -2,9 +2,12 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"aluminium-passport/internal/controller"
+	"aluminium-passport/internal/handlers"
 	"aluminium-passport/internal/middleware"
+	"aluminium-passport/internal/models"
 
 	"github.com/gorilla/mux"
 )
@@ -18,6 +21,16 @@ func SetupRoutes() *mux.Router {
 	esgController := controller.NewESGController()
 	approvalController := controller.NewApprovalController()
 	demoController := controller.NewDemoController()
+	keyController := controller.NewKeyController()
+	zkController := controller.NewZKController()
+	presentationController := controller.NewPresentationController()
+	batchController := controller.NewBatchController()
+	exportController := controller.NewExportController()
+	auditController := controller.NewAuditController()
+	notificationController := controller.NewNotificationController()
+	webhookController := controller.NewWebhookController()
+	configController := controller.NewConfigController()
+	organisationController := controller.NewOrganisationController()
 
 	// Health check endpoint
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -26,10 +39,34 @@ func SetupRoutes() *mux.Router {
 		w.Write([]byte(`{"status": "healthy", "service": "aluminium-passport-api"}`))
 	}).Methods("GET")
 
+	// DID documents for did:web issuer identifiers
+	r.HandleFunc("/.well-known/did.json", keyController.GetPlatformDIDDocument).Methods("GET")
+	r.HandleFunc("/orgs/{org}/did.json", keyController.GetOrganisationDIDDocument).Methods("GET")
+
+	// JSON-LD context for passport credentials
+	r.HandleFunc("/contexts/aluminium-passport/v1", handlers.PassportContextHandler).Methods("GET")
+
+	// Bitstring status list credentials for issued passport credentials
+	r.HandleFunc("/status/{id}", handlers.StatusListCredentialHandler).Methods("GET")
+
 	// Public endpoints (no authentication required)
 	public := r.PathPrefix("/api/public").Subrouter()
-	public.HandleFunc("/verify/{id}", passportController.GetPassportDetails).Methods("GET")
-	public.HandleFunc("/qr/{id}", passportController.GetQRCode).Methods("GET")
+
+	// Passport verification shows the full passport, so it needs a signed
+	// in session like GET /api/passports/{id}
+	public.HandleFunc("/verify/{id}", middleware.AuthMiddlewareFunc(middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.GetPassportDetails))).Methods("GET")
+	public.HandleFunc("/qr/{id}", middleware.RequirePermissionFunc(models.PermPublic)(
+		passportController.GetPublicQRCode)).Methods("GET")
+
+	// Selective disclosure: derive and verify SD-JWT presentations. Deriving
+	// one needs the holder's SD-JWT, so neither reveals anything by itself.
+	public.HandleFunc("/presentations", middleware.RequirePermissionFunc(models.PermPublic)(
+		presentationController.CreatePresentation)).Methods("POST")
+	public.HandleFunc("/presentations/verify", middleware.RequirePermissionFunc(models.PermPublic)(
+		presentationController.VerifyPresentation)).Methods("POST")
+	public.HandleFunc("/presentations/profiles", middleware.RequirePermissionFunc(models.PermPublic)(
+		presentationController.GetDisclosureProfiles)).Methods("GET")
 
 	// Demo endpoints (no auth for demo convenience)
 	demo := r.PathPrefix("/api/demo").Subrouter()
@@ -49,8 +86,11 @@ func SetupRoutes() *mux.Router {
 	auth.HandleFunc("/login", authController.Login).Methods("POST")
 	auth.HandleFunc("/register", authController.Register).Methods("POST")
 	auth.HandleFunc("/refresh", authController.RefreshToken).Methods("POST")
-	auth.HandleFunc("/logout", middleware.AuthMiddleware(authController.Logout)).Methods("POST")
-	auth.HandleFunc("/profile", middleware.AuthMiddleware(authController.GetProfile)).Methods("GET")
+	auth.HandleFunc("/siwe/nonce", authController.SIWENonce).Methods("GET")
+	auth.HandleFunc("/siwe/verify", authController.SIWEVerify).Methods("POST")
+	auth.HandleFunc("/logout", middleware.AuthMiddlewareFunc(authController.Logout)).Methods("POST")
+	auth.HandleFunc("/profile", middleware.AuthMiddlewareFunc(authController.GetProfile)).Methods("GET")
+	auth.HandleFunc("/sessions/{id}", middleware.AuthMiddlewareFunc(authController.RevokeSession)).Methods("DELETE")
 
 	// Protected API routes
 	api := r.PathPrefix("/api").Subrouter()
@@ -59,159 +99,227 @@ func SetupRoutes() *mux.Router {
 	// Passport management routes
 	passports := api.PathPrefix("/passports").Subrouter()
 
-	// Create passport (miners, manufacturers, admins)
-	passports.HandleFunc("", middleware.RoleMiddleware("miner", "manufacturer", "admin")(
+	// Create passport
+	passports.HandleFunc("", middleware.RequirePermissionFunc(models.PermPassportCreate)(
 		passportController.RegisterPassport)).Methods("POST")
 
-	// Get passport details (all authenticated users)
-	passports.HandleFunc("/{id}", passportController.GetPassportDetails).Methods("GET")
+	// Get passport details
+	passports.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.GetPassportDetails)).Methods("GET")
+
+	// Patch passport fields; section permissions decide which fields
+	passports.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.PatchPassport)).Methods("PATCH")
 
-	// Update recycled content (recyclers, admins)
-	passports.HandleFunc("/{id}/recycle", middleware.RoleMiddleware("recycler", "admin")(
+	// Update recycled content
+	passports.HandleFunc("/{id}/recycle", middleware.RequirePermissionFunc(models.PermPassportRecycle)(
 		passportController.UpdateRecycledContent)).Methods("PUT")
 
-	// Get QR code (all authenticated users)
-	passports.HandleFunc("/{id}/qr", passportController.GetQRCode).Methods("GET")
+	// Deactivate passport and revoke its credentials
+	passports.HandleFunc("/{id}/deactivate", middleware.RequirePermissionFunc(models.PermPassportDeactivate)(
+		passportController.DeactivatePassport)).Methods("POST")
 
-	// List passports with pagination (all authenticated users)
-	passports.HandleFunc("", passportController.ListPassports).Methods("GET")
+	// Export passport as a signed verifiable credential
+	passports.HandleFunc("/{id}/credential", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		handlers.ExportSignedCredentialHandler)).Methods("GET")
+
+	// Get QR code
+	passports.HandleFunc("/{id}/qr", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.GetQRCode)).Methods("GET")
+
+	// List passports with pagination
+	passports.HandleFunc("", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.ListPassports)).Methods("GET")
+
+	// Passport revisions and the differences between them
+	passports.HandleFunc("/{id}/history", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.GetPassportHistory)).Methods("GET")
+	passports.HandleFunc("/{id}/diff", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		passportController.GetPassportDiff)).Methods("GET")
+
+	// Share passports with other organisations (owning organisation only)
+	passports.HandleFunc("/{id}/grants", middleware.RequirePermissionFunc(models.PermPassportShare)(
+		passportController.ListPassportGrants)).Methods("GET")
+	passports.HandleFunc("/{id}/grants", middleware.RequirePermissionFunc(models.PermPassportShare)(
+		passportController.CreatePassportGrant)).Methods("POST")
+	passports.HandleFunc("/{id}/grants/{grantId}", middleware.RequirePermissionFunc(models.PermPassportShare)(
+		passportController.RevokePassportGrant)).Methods("DELETE")
 
 	// ESG management routes
 	esg := api.PathPrefix("/esg").Subrouter()
 
-	// Create ESG assessment (certifiers, admins)
-	esg.HandleFunc("/assess", middleware.RoleMiddleware("certifier", "admin")(
+	// Create ESG assessment
+	esg.HandleFunc("/assess", middleware.RequirePermissionFunc(models.PermESGAssess)(
 		esgController.CreateESGAssessment)).Methods("POST")
 
-	// Get ESG metrics (all authenticated users)
-	esg.HandleFunc("/{id}", esgController.GetESGMetrics).Methods("GET")
+	// Get ESG metrics
+	esg.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermESGRead)(
+		esgController.GetESGMetrics)).Methods("GET")
 
-	// Generate AI-based ESG score (certifiers, admins)
-	esg.HandleFunc("/generate", middleware.RoleMiddleware("certifier", "admin")(
+	// Generate AI-based ESG score
+	esg.HandleFunc("/generate", middleware.RequirePermissionFunc(models.PermESGAssess)(
 		esgController.GenerateESGScore)).Methods("POST")
 
-	// Get ESG ranking (all authenticated users)
-	esg.HandleFunc("/ranking", esgController.GetESGRanking).Methods("GET")
+	// Get ESG ranking
+	esg.HandleFunc("/ranking", middleware.RequirePermissionFunc(models.PermESGRead)(
+		esgController.GetESGRanking)).Methods("GET")
 
 	// Batch operations routes
 	batch := api.PathPrefix("/batch").Subrouter()
 
-	// Upload ZIP file (miners, manufacturers, admins)
-	batch.HandleFunc("/upload", middleware.RoleMiddleware("miner", "manufacturer", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for batch upload
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "Batch upload functionality to be implemented"}`))
-		})).Methods("POST")
+	// Upload ZIP file
+	batch.HandleFunc("/upload", middleware.RequirePermissionFunc(models.PermBatchUpload)(
+		batchController.UploadBatch)).Methods("POST")
 
-	// Validate ZIP file (miners, manufacturers, certifiers, admins)
-	batch.HandleFunc("/validate", middleware.RoleMiddleware("miner", "manufacturer", "certifier", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for batch validation
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "Batch validation functionality to be implemented"}`))
-		})).Methods("POST")
+	// Validate ZIP file
+	batch.HandleFunc("/validate", middleware.RequirePermissionFunc(models.PermBatchValidate)(
+		batchController.ValidateBatch)).Methods("POST")
 
-	// Get batch status (all authenticated users)
-	batch.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for batch status
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "Batch status functionality to be implemented"}`))
-	}).Methods("GET")
+	// Get batch status
+	batch.HandleFunc("/status", middleware.RequirePermissionFunc(models.PermBatchRead)(
+		batchController.GetBatchStatus)).Methods("GET")
+
+	// Cancel a queued or running batch (own batches, or any with batch:cancel_any)
+	batch.HandleFunc("/cancel", middleware.RequirePermissionFunc(models.PermBatchCancel)(
+		batchController.CancelBatch)).Methods("POST")
+
+	// Download upload template
+	batch.HandleFunc("/template", middleware.RequirePermissionFunc(models.PermBatchValidate)(
+		batchController.GetBatchTemplate)).Methods("GET")
 
 	// Export routes
 	export := api.PathPrefix("/export").Subrouter()
 
-	// Export CSV (auditors, certifiers, admins)
-	export.HandleFunc("/csv", middleware.RoleMiddleware("auditor", "certifier", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for CSV export
-			w.Header().Set("Content-Type", "text/csv")
-			w.Header().Set("Content-Disposition", "attachment; filename=passports.csv")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte("passport_id,manufacturer,origin,esg_score\n"))
-		})).Methods("GET")
+	// Export CSV
+	export.HandleFunc("/csv", middleware.RequirePermissionFunc(models.PermExportRead)(
+		exportController.ExportCSV)).Methods("GET")
 
-	// Export JSON (auditors, certifiers, admins)
-	export.HandleFunc("/json", middleware.RoleMiddleware("auditor", "certifier", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for JSON export
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "JSON export functionality to be implemented"}`))
-		})).Methods("GET")
+	// Export JSON
+	export.HandleFunc("/json", middleware.RequirePermissionFunc(models.PermExportRead)(
+		exportController.ExportJSON)).Methods("GET")
+
+	// List exportable columns
+	export.HandleFunc("/columns", middleware.RequirePermissionFunc(models.PermExportRead)(
+		exportController.GetExportColumns)).Methods("GET")
 
 	// Verification routes
 	verify := api.PathPrefix("/verify").Subrouter()
 
-	// Verify signature (all authenticated users)
-	verify.HandleFunc("/signature", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for signature verification
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "Signature verification functionality to be implemented"}`))
-	}).Methods("POST")
+	// Verify signature
+	verify.HandleFunc("/signature", middleware.RequirePermissionFunc(models.PermCredentialVerify)(
+		handlers.VerifySignatureHandler)).Methods("POST")
+
+	// Issuer key management routes
+	keys := api.PathPrefix("/keys").Subrouter()
+
+	// Create, list, rotate and revoke organisation signing keys (own
+	// organisation, or any with key:manage_any)
+	keys.HandleFunc("", middleware.RequirePermissionFunc(models.PermKeyManage)(
+		keyController.CreateIssuerKey)).Methods("POST")
+	keys.HandleFunc("", middleware.RequirePermissionFunc(models.PermKeyManage)(
+		keyController.ListIssuerKeys)).Methods("GET")
+	keys.HandleFunc("/{id}/rotate", middleware.RequirePermissionFunc(models.PermKeyManage)(
+		keyController.RotateIssuerKey)).Methods("POST")
+	keys.HandleFunc("/{id}/revoke", middleware.RequirePermissionFunc(models.PermKeyManage)(
+		keyController.RevokeIssuerKey)).Methods("POST")
 
 	// Zero-knowledge proof routes
 	zk := api.PathPrefix("/zk").Subrouter()
 
-	// Generate ZK proof (certifiers, admins)
-	zk.HandleFunc("/generate", middleware.RoleMiddleware("certifier", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for ZK proof generation
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "ZK proof generation functionality to be implemented"}`))
-		})).Methods("POST")
+	// Generate ZK range proof, rate limited so that thresholds cannot be
+	// probed quickly
+	zk.Handle("/generate", middleware.CustomRateLimitMiddleware(10, time.Minute)(
+		middleware.RequirePermissionFunc(models.PermZKGenerate)(zkController.GenerateProof))).Methods("POST")
 
-	// Verify ZK proof (all authenticated users)
-	zk.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for ZK proof verification
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "ZK proof verification functionality to be implemented"}`))
-	}).Methods("POST")
+	// Verify ZK range proof
+	zk.HandleFunc("/verify", middleware.RequirePermissionFunc(models.PermCredentialVerify)(
+		zkController.VerifyProof)).Methods("POST")
 
 	// Audit routes
 	audit := api.PathPrefix("/audit").Subrouter()
 
-	// Get audit logs (auditors, admins)
-	audit.HandleFunc("/logs", middleware.RoleMiddleware("auditor", "admin")(
-		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for audit logs
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "Audit logs functionality to be implemented"}`))
-		})).Methods("GET")
+	// Get audit logs
+	audit.HandleFunc("/logs", middleware.RequirePermissionFunc(models.PermAuditRead)(
+		auditController.GetAuditLogs)).Methods("GET")
+
+	// Verify the audit hash chain and list its published anchors
+	audit.HandleFunc("/verify", middleware.RequirePermissionFunc(models.PermAuditRead)(
+		auditController.VerifyAuditChain)).Methods("GET")
+	audit.HandleFunc("/anchors", middleware.RequirePermissionFunc(models.PermAuditRead)(
+		auditController.GetAuditAnchors)).Methods("GET")
+
+	// Notification inbox and channel preferences of the caller
+	notifications := api.PathPrefix("/notifications").Subrouter()
+	notifications.HandleFunc("", middleware.RequirePermissionFunc(models.PermNotificationRead)(
+		notificationController.GetNotifications)).Methods("GET")
+	notifications.HandleFunc("/read", middleware.RequirePermissionFunc(models.PermNotificationRead)(
+		notificationController.MarkAllNotificationsRead)).Methods("POST")
+	notifications.HandleFunc("/preferences", middleware.RequirePermissionFunc(models.PermNotificationRead)(
+		notificationController.GetPreferences)).Methods("GET")
+	notifications.HandleFunc("/preferences", middleware.RequirePermissionFunc(models.PermNotificationRead)(
+		notificationController.UpdatePreferences)).Methods("PUT")
+	notifications.HandleFunc("/{id}/read", middleware.RequirePermissionFunc(models.PermNotificationRead)(
+		notificationController.MarkNotificationRead)).Methods("POST")
+
+	// Organisations and their members
+	organisations := api.PathPrefix("/organisations").Subrouter()
+	organisations.HandleFunc("", middleware.RequirePermissionFunc(models.PermOrganisationManage)(
+		organisationController.ListOrganisations)).Methods("GET")
+	organisations.HandleFunc("", middleware.RequirePermissionFunc(models.PermOrganisationManage)(
+		organisationController.CreateOrganisation)).Methods("POST")
+	api.HandleFunc("/users/{id}/organisation", middleware.RequirePermissionFunc(models.PermOrganisationManage)(
+		organisationController.AssignUserOrganisation)).Methods("PUT")
+
+	// Sign a user out of every session
+	api.HandleFunc("/users/{id}/sessions/revoke", middleware.RequirePermissionFunc(models.PermSessionRevokeAny)(
+		authController.RevokeUserSessions)).Methods("POST")
+
+	// Outbound webhook subscriptions for passport events
+	webhooks := api.PathPrefix("/webhooks").Subrouter()
+	webhooks.HandleFunc("", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.GetWebhooks)).Methods("GET")
+	webhooks.HandleFunc("", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.CreateWebhook)).Methods("POST")
+	webhooks.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.GetWebhook)).Methods("GET")
+	webhooks.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.UpdateWebhook)).Methods("PUT")
+	webhooks.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.DeleteWebhook)).Methods("DELETE")
+	webhooks.HandleFunc("/{id}/rotate-secret", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.RotateWebhookSecret)).Methods("POST")
+	webhooks.HandleFunc("/{id}/replay", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.ReplayWebhook)).Methods("POST")
+	webhooks.HandleFunc("/{id}/deliveries", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.GetWebhookDeliveries)).Methods("GET")
+	webhooks.HandleFunc("/{id}/deliveries/retry", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.RetryDeadWebhookDeliveries)).Methods("POST")
+	webhooks.HandleFunc("/{id}/deliveries/{deliveryId}/attempts", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.GetWebhookDeliveryAttempts)).Methods("GET")
+	webhooks.HandleFunc("/{id}/deliveries/{deliveryId}/retry", middleware.RequirePermissionFunc(models.PermWebhookManage)(
+		webhookController.RetryWebhookDelivery)).Methods("POST")
 
 	// Blockchain integration routes
 	blockchain := api.PathPrefix("/blockchain").Subrouter()
 
-	// Register passport on blockchain (miners, manufacturers, admins)
-	blockchain.HandleFunc("/register/{id}", middleware.RoleMiddleware("miner", "manufacturer", "admin")(
+	// Register passport on blockchain
+	blockchain.HandleFunc("/register/{id}", middleware.RequirePermissionFunc(models.PermPassportAnchor)(
+		passportController.RegisterOnChain)).Methods("POST")
+
+	// Get blockchain transaction
+	blockchain.HandleFunc("/tx/{hash}", middleware.RequirePermissionFunc(models.PermPassportRead)(
 		func(w http.ResponseWriter, r *http.Request) {
-			// Placeholder for blockchain registration
+			// Placeholder for blockchain transaction info
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusNotImplemented)
-			w.Write([]byte(`{"message": "Blockchain registration functionality to be implemented"}`))
-		})).Methods("POST")
-
-	// Get blockchain transaction (all authenticated users)
-	blockchain.HandleFunc("/tx/{hash}", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for blockchain transaction info
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "Blockchain transaction info functionality to be implemented"}`))
-	}).Methods("GET")
+			w.Write([]byte(`{"message": "Blockchain transaction info functionality to be implemented"}`))
+		})).Methods("GET")
 
 	// IPFS routes
 	ipfs := api.PathPrefix("/ipfs").Subrouter()
 
-	// Upload to IPFS (miners, manufacturers, certifiers, admins)
-	ipfs.HandleFunc("/upload/{id}", middleware.RoleMiddleware("miner", "manufacturer", "certifier", "admin")(
+	// Upload to IPFS
+	ipfs.HandleFunc("/upload/{id}", middleware.RequirePermissionFunc(models.PermIPFSUpload)(
 		func(w http.ResponseWriter, r *http.Request) {
 			// Placeholder for IPFS upload
 			w.Header().Set("Content-Type", "application/json")
@@ -219,76 +327,89 @@ func SetupRoutes() *mux.Router {
 			w.Write([]byte(`{"message": "IPFS upload functionality to be implemented"}`))
 		})).Methods("POST")
 
-	// Get from IPFS (all authenticated users)
-	ipfs.HandleFunc("/{hash}", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for IPFS retrieval
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "IPFS retrieval functionality to be implemented"}`))
-	}).Methods("GET")
+	// Get from IPFS
+	ipfs.HandleFunc("/{hash}", middleware.RequirePermissionFunc(models.PermPassportRead)(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Placeholder for IPFS retrieval
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotImplemented)
+			w.Write([]byte(`{"message": "IPFS retrieval functionality to be implemented"}`))
+		})).Methods("GET")
 
 	// Approval workflow routes
 	approvals := api.PathPrefix("/approvals").Subrouter()
 
-	// Create approval request (admins and above)
-	approvals.HandleFunc("", middleware.RoleMiddleware("admin", "super_admin")(
+	// Create approval request
+	approvals.HandleFunc("", middleware.RequirePermissionFunc(models.PermApprovalCreate)(
 		approvalController.CreateApprovalRequest)).Methods("POST")
 
-	// Request supplier onboarding (admins only - requires super admin approval)
-	approvals.HandleFunc("/supplier-onboarding", middleware.RoleMiddleware("admin")(
+	// Request supplier onboarding (requires super admin approval)
+	approvals.HandleFunc("/supplier-onboarding", middleware.RequirePermissionFunc(models.PermApprovalOnboardSupplier)(
 		approvalController.RequestSupplierOnboarding)).Methods("POST")
 
-	// Get approval requests (all authenticated users can see their own, approvers can see pending)
-	approvals.HandleFunc("", approvalController.GetApprovalRequests).Methods("GET")
+	// Get approval requests (users see their own, approvers also those they can vote on)
+	approvals.HandleFunc("", middleware.RequirePermissionFunc(models.PermApprovalRead)(
+		approvalController.GetApprovalRequests)).Methods("GET")
+
+	// Quorum policies per request type
+	approvals.HandleFunc("/policies", middleware.RequirePermissionFunc(models.PermApprovalPolicyRead)(
+		approvalController.GetApprovalPolicies)).Methods("GET")
+	approvals.HandleFunc("/policies/{type}", middleware.RequirePermissionFunc(models.PermApprovalPolicyManage)(
+		approvalController.SetApprovalPolicy)).Methods("PUT")
 
 	// Get specific approval request
-	approvals.HandleFunc("/{id}", approvalController.GetApprovalRequest).Methods("GET")
+	approvals.HandleFunc("/{id}", middleware.RequirePermissionFunc(models.PermApprovalRead)(
+		approvalController.GetApprovalRequest)).Methods("GET")
 
-	// Approve request (super admins can approve supplier onboarding)
-	approvals.HandleFunc("/{id}/approve", middleware.RoleMiddleware("super_admin")(
+	// Vote to approve a request (voters are checked against the request's quorum policy)
+	approvals.HandleFunc("/{id}/approve", middleware.RequirePermissionFunc(models.PermApprovalVote)(
 		approvalController.ApproveRequest)).Methods("POST")
 
-	// Reject request (super admins can reject supplier onboarding)
-	approvals.HandleFunc("/{id}/reject", middleware.RoleMiddleware("super_admin")(
+	// Vote to reject a request; a single rejection decides it
+	approvals.HandleFunc("/{id}/reject", middleware.RequirePermissionFunc(models.PermApprovalVote)(
 		approvalController.RejectRequest)).Methods("POST")
 
-	// Admin routes (requires admin or super_admin)
+	// Extend a pending request or re-open an expired one
+	approvals.HandleFunc("/{id}/extend", middleware.RequirePermissionFunc(models.PermApprovalExtend)(
+		approvalController.ExtendApprovalRequest)).Methods("POST")
+
+	// Admin routes
 	admin := api.PathPrefix("/admin").Subrouter()
-	admin.Use(middleware.RoleMiddleware("admin", "super_admin"))
 
 	// User management
-	admin.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for user management
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "User management functionality to be implemented"}`))
-	}).Methods("GET")
+	admin.HandleFunc("/users", middleware.RequirePermissionFunc(models.PermUserManage)(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Placeholder for user management
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotImplemented)
+			w.Write([]byte(`{"message": "User management functionality to be implemented"}`))
+		})).Methods("GET")
 
 	// System statistics
-	admin.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder for system stats
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "System statistics functionality to be implemented"}`))
-	}).Methods("GET")
+	admin.HandleFunc("/stats", middleware.RequirePermissionFunc(models.PermSystemStats)(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Placeholder for system stats
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotImplemented)
+			w.Write([]byte(`{"message": "System statistics functionality to be implemented"}`))
+		})).Methods("GET")
 
-	// Super Admin only routes
+	// Super admin routes
 	superAdmin := api.PathPrefix("/super-admin").Subrouter()
-	superAdmin.Use(middleware.RoleMiddleware("super_admin"))
 
 	// Manage admin users
-	superAdmin.HandleFunc("/admins", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "Admin user management functionality to be implemented"}`))
-	}).Methods("GET")
+	superAdmin.HandleFunc("/admins", middleware.RequirePermissionFunc(models.PermAdminManage)(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotImplemented)
+			w.Write([]byte(`{"message": "Admin user management functionality to be implemented"}`))
+		})).Methods("GET")
 
-	// System configuration
-	superAdmin.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte(`{"message": "System configuration functionality to be implemented"}`))
-	}).Methods("GET", "POST")
+	// System configuration: role permission bindings
+	superAdmin.HandleFunc("/config", middleware.RequirePermissionFunc(models.PermConfigManage)(
+		configController.GetSystemConfig)).Methods("GET")
+	superAdmin.HandleFunc("/config", middleware.RequirePermissionFunc(models.PermConfigManage)(
+		configController.UpdateSystemConfig)).Methods("PUT")
 
 	// Apply CORS middleware to all routes
 	r.Use(middleware.CORSMiddleware)
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/services"
+)
+
+func TestPublicVerifyRequiresSession(t *testing.T) {
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWTSecret: "routes-test-secret", JWTExpirationHours: 1, JWTRefreshHours: 24, RateLimitRPM: 100}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	tokens, err := auth.GenerateTokenPair("session-1", 3, "viewer", "viewer@example.com", "viewer", "", "", 7)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	accessClaims, err := auth.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		sessionToken  string
+		revoked       bool
+		want          int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "refresh token", authorization: "Bearer " + tokens.RefreshToken, sessionToken: accessClaims.ID, want: http.StatusUnauthorized},
+		{name: "revoked session", authorization: "Bearer " + tokens.AccessToken, sessionToken: accessClaims.ID, revoked: true, want: http.StatusUnauthorized},
+		{name: "rotated access token", authorization: "Bearer " + tokens.AccessToken, sessionToken: "newer-token", want: http.StatusUnauthorized},
+		{name: "active session", authorization: "Bearer " + tokens.AccessToken, sessionToken: accessClaims.ID, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dbtest.New(t)
+			fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+			fake.OnQuery(`FROM user_sessions`, []string{"access_token_id", "revoked", "last_used_at"},
+				[]interface{}{tt.sessionToken, tt.revoked, time.Now()})
+
+			now := time.Now().UTC()
+			columns := dbtest.Columns(services.PassportColumns)
+			fake.OnQuery(`FROM aluminium_passports WHERE passport_id = $1`, columns, dbtest.Row(columns, map[string]interface{}{
+				"id":              int64(1),
+				"passport_id":     "AP-1",
+				"manufacturer":    "Example Smelter",
+				"origin":          "Guinea",
+				"times_recycled":  int64(0),
+				"status":          "active",
+				"is_verified":     false,
+				"created_at":      now,
+				"updated_at":      now,
+				"organisation_id": int64(7),
+			}))
+			fake.OnQuery(`FROM audit_logs`, []string{"entry_hash"})
+			fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+
+			r := httptest.NewRequest(http.MethodGet, "/api/public/verify/AP-1", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			SetupRoutes().ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+)
+
+var (
+	ErrApprovalNotFound = errors.New("approval request not found")
+	ErrApprovalDecided  = errors.New("approval request has already been decided")
+	ErrAlreadyVoted     = errors.New("user has already voted on this request")
+	ErrInvalidPolicy    = errors.New("invalid approval policy")
+)
+
+// ApprovalService maintains approval requests outside of the decisions
+// made through the API
+type ApprovalService struct {
+	db *sql.DB
+}
+
+func NewApprovalService(db *sql.DB) *ApprovalService {
+	return &ApprovalService{db: db}
+}
+
+// ExpireApprovalRequests marks pending requests whose expiry has passed as
+// expired, audits the change and notifies each requester
+func (as *ApprovalService) ExpireApprovalRequests() (int, error) {
+	rows, err := as.db.Query(`
+		UPDATE approval_requests
+		SET status = $1
+		WHERE status = $2 AND expires_at IS NOT NULL AND expires_at <= NOW()
+		RETURNING id, request_type, requested_by, approver_role, title, expires_at`,
+		models.ApprovalStatusExpired, models.ApprovalStatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire approval requests: %w", err)
+	}
+
+	var expired []*models.ApprovalRequest
+	for rows.Next() {
+		req := &models.ApprovalRequest{Status: models.ApprovalStatusExpired}
+		var requestedBy sql.NullInt64
+		if err := rows.Scan(&req.ID, &req.RequestType, &requestedBy, &req.ApproverRole, &req.Title, &req.ExpiresAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired approval request: %w", err)
+		}
+		req.RequestedBy = int(requestedBy.Int64)
+		expired = append(expired, req)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to expire approval requests: %w", err)
+	}
+
+	audit := NewAuditService(as.db)
+	for _, req := range expired {
+		entry := &db.AuditLog{
+			UserRole:     nullableString("system"),
+			Action:       "EXPIRE",
+			ResourceType: nullableString("approval_request"),
+			ResourceID:   nullableString(strconv.Itoa(req.ID)),
+			OldValues:    &db.JSONMap{"status": models.ApprovalStatusPending},
+			NewValues:    &db.JSONMap{"status": models.ApprovalStatusExpired, "expires_at": req.ExpiresAt},
+			Success:      true,
+		}
+		if err := audit.Record(entry); err != nil {
+			log.Printf("Audit of expired approval request %d not recorded: %v", req.ID, err)
+		}
+		NotifyApprovalRequester(req, models.ApprovalStatusExpired, "")
+	}
+
+	if len(expired) > 0 {
+		log.Printf("Approval expiry sweep: %d requests expired", len(expired))
+	}
+	return len(expired), nil
+}
+
+// RunExpirySweeper runs ExpireApprovalRequests on an interval until ctx is
+// cancelled
+func (as *ApprovalService) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := as.ExpireApprovalRequests(); err != nil {
+			log.Printf("Approval expiry sweep failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExtendApprovalRequest moves the expiry of a pending request, or re-opens
+// an expired one, and returns the status it had before
+func (as *ApprovalService) ExtendApprovalRequest(requestID int, expiresAt time.Time) (models.ApprovalStatus, error) {
+	tx, err := as.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status models.ApprovalStatus
+	err = tx.QueryRow(`SELECT status FROM approval_requests WHERE id = $1 FOR UPDATE`, requestID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrApprovalNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read approval request: %w", err)
+	}
+	if status != models.ApprovalStatusPending && status != models.ApprovalStatusExpired {
+		return status, ErrApprovalDecided
+	}
+
+	_, err = tx.Exec(`UPDATE approval_requests SET status = $1, expires_at = $2 WHERE id = $3`,
+		models.ApprovalStatusPending, expiresAt, requestID)
+	if err != nil {
+		return "", fmt.Errorf("failed to extend approval request: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return status, nil
+}
+
+// ApprovalPolicy returns the quorum configured for a request type. A policy
+// without requirements means a single approval by the request's approver
+// role is enough.
+func (as *ApprovalService) ApprovalPolicy(requestType models.ApprovalType) (*models.ApprovalPolicy, error) {
+	rows, err := as.db.Query(`
+		SELECT approver_role, required_votes FROM approval_policies
+		WHERE request_type = $1 ORDER BY approver_role`, requestType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approval policy: %w", err)
+	}
+	defer rows.Close()
+
+	policy := &models.ApprovalPolicy{RequestType: requestType, Requirements: []models.QuorumRequirement{}}
+	for rows.Next() {
+		var requirement models.QuorumRequirement
+		if err := rows.Scan(&requirement.Role, &requirement.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan approval policy: %w", err)
+		}
+		policy.Requirements = append(policy.Requirements, requirement)
+	}
+	return policy, rows.Err()
+}
+
+// ListApprovalPolicies returns the policy of every request type
+func (as *ApprovalService) ListApprovalPolicies() ([]*models.ApprovalPolicy, error) {
+	requestTypes := []models.ApprovalType{
+		models.ApprovalTypeSupplierOnboarding,
+		models.ApprovalTypeUserRoleChange,
+		models.ApprovalTypeSystemConfiguration,
+	}
+
+	policies := make([]*models.ApprovalPolicy, 0, len(requestTypes))
+	for _, requestType := range requestTypes {
+		policy, err := as.ApprovalPolicy(requestType)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// SetApprovalPolicy replaces the quorum of a request type. Requests already
+// pending are decided under the new policy.
+func (as *ApprovalService) SetApprovalPolicy(policy *models.ApprovalPolicy, updatedBy int) error {
+	seen := map[string]bool{}
+	for _, requirement := range policy.Requirements {
+		if !models.IsValidRole(requirement.Role) {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidPolicy, requirement.Role)
+		}
+		if requirement.Count < 1 {
+			return fmt.Errorf("%w: %s needs at least one vote", ErrInvalidPolicy, requirement.Role)
+		}
+		if seen[requirement.Role] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidPolicy, requirement.Role)
+		}
+		seen[requirement.Role] = true
+	}
+
+	tx, err := as.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM approval_policies WHERE request_type = $1`, policy.RequestType); err != nil {
+		return fmt.Errorf("failed to replace approval policy: %w", err)
+	}
+	for _, requirement := range policy.Requirements {
+		_, err := tx.Exec(`
+			INSERT INTO approval_policies (request_type, approver_role, required_votes, updated_by)
+			VALUES ($1, $2, $3, $4)`,
+			policy.RequestType, requirement.Role, requirement.Count, updatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to replace approval policy: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RecordVoteTx stores a vote. Each user votes at most once per request.
+func (as *ApprovalService) RecordVoteTx(tx *sql.Tx, vote *models.ApprovalVote) error {
+	err := tx.QueryRow(`
+		INSERT INTO approval_votes (approval_request_id, voter_id, voter_role, decision, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (approval_request_id, voter_id) DO NOTHING
+		RETURNING id, created_at`,
+		vote.ApprovalRequestID, vote.VoterID, vote.VoterRole, vote.Decision, vote.Reason,
+	).Scan(&vote.ID, &vote.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyVoted
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record vote: %w", err)
+	}
+	return nil
+}
+
+// Votes returns the votes cast on a request, oldest first
+func (as *ApprovalService) Votes(requestID int) ([]models.ApprovalVote, error) {
+	return approvalVotes(as.db.Query, requestID)
+}
+
+// VotesTx returns the votes cast on a request within a transaction
+func (as *ApprovalService) VotesTx(tx *sql.Tx, requestID int) ([]models.ApprovalVote, error) {
+	return approvalVotes(tx.Query, requestID)
+}
+
+func approvalVotes(query func(string, ...interface{}) (*sql.Rows, error), requestID int) ([]models.ApprovalVote, error) {
+	rows, err := query(`
+		SELECT id, approval_request_id, voter_id, voter_role, decision, reason, created_at
+		FROM approval_votes WHERE approval_request_id = $1 ORDER BY created_at, id`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read votes: %w", err)
+	}
+	defer rows.Close()
+
+	votes := []models.ApprovalVote{}
+	for rows.Next() {
+		var vote models.ApprovalVote
+		err := rows.Scan(&vote.ID, &vote.ApprovalRequestID, &vote.VoterID, &vote.VoterRole,
+			&vote.Decision, &vote.Reason, &vote.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vote: %w", err)
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
+}
+
+// NotifyApprovalRequester tells the requester of an approval request that
+// it was decided or expired. Failures are logged; the decision stands.
+func NotifyApprovalRequester(req *models.ApprovalRequest, status models.ApprovalStatus, reason string) {
+	events := map[models.ApprovalStatus]string{
+		models.ApprovalStatusApproved: models.EventApprovalApproved,
+		models.ApprovalStatusRejected: models.EventApprovalRejected,
+		models.ApprovalStatusExpired:  models.EventApprovalExpired,
+	}
+	event, ok := events[status]
+	if !ok || req.RequestedBy == 0 {
+		return
+	}
+
+	data := approvalNotificationData(req)
+	data["reason"] = reason
+	if err := NewNotificationService(db.DB).Notify(event, []int{req.RequestedBy}, data); err != nil {
+		log.Printf("Notification of approval request %d %s failed: %v", req.ID, status, err)
+	}
+}
+
+// NotifyApprovalVoters tells everyone who may vote on a request that it is
+// waiting for them
+func NotifyApprovalVoters(req *models.ApprovalRequest) {
+	policy, err := NewApprovalService(db.DB).ApprovalPolicy(req.RequestType)
+	if err != nil {
+		log.Printf("Notification of approval request %d failed: %v", req.ID, err)
+		return
+	}
+
+	var roles []string
+	for _, requirement := range policy.Requirements {
+		roles = append(roles, requirement.Role)
+	}
+	if len(roles) == 0 {
+		for _, role := range models.GetValidRoles() {
+			if req.CanBeApprovedBy(role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	err = NewNotificationService(db.DB).NotifyRoles(models.EventApprovalRequested, roles, []int{req.RequestedBy}, approvalNotificationData(req))
+	if err != nil {
+		log.Printf("Notification of approval request %d failed: %v", req.ID, err)
+	}
+}
+
+func approvalNotificationData(req *models.ApprovalRequest) map[string]interface{} {
+	data := map[string]interface{}{
+		"request_id":   req.ID,
+		"request_type": string(req.RequestType),
+		"title":        req.Title,
+		"description":  req.Description,
+		"expires_at":   "",
+	}
+	if req.ExpiresAt != nil {
+		data["expires_at"] = req.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return data
+}
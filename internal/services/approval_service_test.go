@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/models"
+)
+
+func TestExpireApprovalRequests(t *testing.T) {
+	fake := newTestDB(t)
+	expiredAt := time.Now().Add(-time.Hour)
+	fake.OnQuery(`UPDATE approval_requests`, []string{"id", "request_type", "requested_by", "approver_role", "title", "expires_at"},
+		[]interface{}{int64(1), string(models.ApprovalTypeUserRoleChange), int64(5), "admin", "Promote user 5", expiredAt},
+		[]interface{}{int64(2), string(models.ApprovalTypeSystemConfiguration), nil, "super_admin", "Change limits", expiredAt})
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	fake.OnQuery(`SELECT entry_hash FROM audit_logs`, []string{"entry_hash"})
+	fake.OnQuery(`FROM users`, []string{"id", "username", "email"}, []interface{}{int64(5), "requester", "requester@example.com"})
+	fake.OnQuery(`FROM notification_preferences`, []string{"user_id", "channel"})
+	fake.OnQuery(`INSERT INTO notifications`, []string{"id"}, []interface{}{int64(1)})
+
+	expired, err := NewApprovalService(fake.DB).ExpireApprovalRequests()
+	if err != nil {
+		t.Fatalf("ExpireApprovalRequests: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("expired %d requests, want 2", expired)
+	}
+
+	sweep := fake.Statements(`UPDATE approval_requests`)
+	if len(sweep) != 1 || sweep[0].Args[0] != string(models.ApprovalStatusExpired) || sweep[0].Args[1] != string(models.ApprovalStatusPending) {
+		t.Errorf("sweep = %+v, want pending requests set to expired", sweep)
+	}
+
+	audits := fake.Statements(`INSERT INTO audit_logs`)
+	if len(audits) != 2 {
+		t.Fatalf("audited %d expiries, want 2", len(audits))
+	}
+	for i, audit := range audits {
+		if audit.Args[2] != "EXPIRE" || audit.Args[4] != []string{"1", "2"}[i] {
+			t.Errorf("audit %d = %v, want EXPIRE of request %d", i, audit.Args[:5], i+1)
+		}
+	}
+
+	// Only the request with a requester notifies anyone
+	notifications := fake.Statements(`INSERT INTO notifications`)
+	if len(notifications) != 1 || notifications[0].Args[0] != int64(5) || notifications[0].Args[1] != models.EventApprovalExpired {
+		t.Errorf("notifications = %+v, want %s for user 5", notifications, models.EventApprovalExpired)
+	}
+}
+
+func TestExtendApprovalRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  []interface{}
+		want    models.ApprovalStatus
+		wantErr error
+	}{
+		{name: "pending", status: []interface{}{string(models.ApprovalStatusPending)}, want: models.ApprovalStatusPending},
+		{name: "expired", status: []interface{}{string(models.ApprovalStatusExpired)}, want: models.ApprovalStatusExpired},
+		{name: "approved", status: []interface{}{string(models.ApprovalStatusApproved)}, want: models.ApprovalStatusApproved, wantErr: ErrApprovalDecided},
+		{name: "missing", wantErr: ErrApprovalNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestDB(t)
+			var rows [][]interface{}
+			if tt.status != nil {
+				rows = append(rows, tt.status)
+			}
+			fake.OnQuery(`SELECT status FROM approval_requests`, []string{"status"}, rows...)
+
+			expiresAt := time.Now().Add(72 * time.Hour)
+			previous, err := NewApprovalService(fake.DB).ExtendApprovalRequest(3, expiresAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExtendApprovalRequest error = %v, want %v", err, tt.wantErr)
+			}
+			if previous != tt.want {
+				t.Errorf("previous status = %q, want %q", previous, tt.want)
+			}
+
+			updates := fake.Statements(`UPDATE approval_requests`)
+			if tt.wantErr != nil {
+				if len(updates) != 0 {
+					t.Errorf("updated a request that may not be extended")
+				}
+				return
+			}
+			if len(updates) != 1 {
+				t.Fatalf("updated %d times, want once", len(updates))
+			}
+			if args := updates[0].Args; args[0] != string(models.ApprovalStatusPending) || !args[1].(time.Time).Equal(expiresAt) || args[2] != int64(3) {
+				t.Errorf("update args = %v, want request 3 pending until %v", args, expiresAt)
+			}
+		})
+	}
+}
@@ -1,24 +1,322 @@
 package services
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
 	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
 )
 
-var auditLog *os.File
+// AuditService stores and queries the audit_logs table
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// AuditFilter selects audit log entries. User matches a user ID or
+// username; From and To bound created_at.
+type AuditFilter struct {
+	User         string
+	Role         string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	From         *time.Time
+	To           *time.Time
+	Page         int
+	Limit        int
+}
+
+const auditLogColumns = `a.id, a.user_id, a.user_role, a.action, a.resource_type, a.resource_id,
+	a.old_values, a.new_values, host(a.ip_address), a.user_agent, a.success, a.error_message,
+	a.session_id, a.created_at, a.prev_hash, a.entry_hash`
 
-func init() {
-	var err error
-	auditLog, err = os.OpenFile("audit.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// Record appends an entry to the audit chain. Appends are serialised so
+// that each entry links to the one stored before it.
+func (as *AuditService) Record(entry *db.AuditLog) error {
+	tx, err := as.db.Begin()
 	if err != nil {
-		log.Fatalf("Failed to open audit log: %v", err)
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
 	}
+
+	var prevHash sql.NullString
+	err = tx.QueryRow(`SELECT entry_hash FROM audit_logs WHERE entry_hash IS NOT NULL ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+
+	// Postgres keeps microseconds; hash the time as it will be read back
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = nullableString(prevHash.String)
+	hash, err := auditEntryHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.EntryHash = &hash
+
+	query := `
+		INSERT INTO audit_logs (user_id, user_role, action, resource_type, resource_id,
+			old_values, new_values, ip_address, user_agent, success, error_message, session_id,
+			created_at, prev_hash, entry_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::inet, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id`
+
+	err = tx.QueryRow(query,
+		entry.UserID, entry.UserRole, entry.Action, entry.ResourceType, entry.ResourceID,
+		entry.OldValues, entry.NewValues, entry.IPAddress, entry.UserAgent, entry.Success,
+		entry.ErrorMessage, entry.SessionID, entry.CreatedAt, entry.PrevHash, entry.EntryHash,
+	).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return tx.Commit()
 }
 
+// ListAuditLogs returns a page of matching entries, newest first, and the
+// total number of matches
+func (as *AuditService) ListAuditLogs(filter AuditFilter) ([]*db.AuditLog, int, error) {
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	from, args := auditLogQuery(filter)
+
+	var total int
+	if err := as.db.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	query := fmt.Sprintf("SELECT %s %s ORDER BY a.created_at DESC, a.id DESC LIMIT $%d OFFSET $%d",
+		auditLogColumns, from, len(args)-1, len(args))
+
+	rows, err := as.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*db.AuditLog
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, total, rows.Err()
+}
+
+// EachAuditLog calls fn for every matching entry, oldest first, reading
+// them from the database as it goes. Page and Limit are ignored.
+func (as *AuditService) EachAuditLog(filter AuditFilter, fn func(*db.AuditLog) error) error {
+	from, args := auditLogQuery(filter)
+	rows, err := as.db.Query("SELECT "+auditLogColumns+" "+from+" ORDER BY a.created_at, a.id", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// auditLogQuery builds the FROM and WHERE clauses of a filter
+func auditLogQuery(filter AuditFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.User != "" {
+		where("(a.user_id::text = $%[1]d OR u.username = $%[1]d)", filter.User)
+	}
+	if filter.Role != "" {
+		where("a.user_role = $%d", filter.Role)
+	}
+	if filter.Action != "" {
+		where("a.action = $%d", strings.ToUpper(filter.Action))
+	}
+	if filter.ResourceType != "" {
+		where("a.resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		where("a.resource_id = $%d", filter.ResourceID)
+	}
+	if filter.From != nil {
+		where("a.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("a.created_at <= $%d", *filter.To)
+	}
+
+	from := "FROM audit_logs a LEFT JOIN users u ON u.id = a.user_id"
+	if len(conditions) > 0 {
+		from += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return from, args
+}
+
+func scanAuditLog(row rowScanner) (*db.AuditLog, error) {
+	entry := &db.AuditLog{}
+	var oldValues, newValues []byte
+	err := row.Scan(
+		&entry.ID, &entry.UserID, &entry.UserRole, &entry.Action, &entry.ResourceType, &entry.ResourceID,
+		&oldValues, &newValues, &entry.IPAddress, &entry.UserAgent, &entry.Success, &entry.ErrorMessage,
+		&entry.SessionID, &entry.CreatedAt, &entry.PrevHash, &entry.EntryHash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan audit log: %w", err)
+	}
+	if entry.OldValues, err = auditJSONMap(oldValues); err != nil {
+		return nil, err
+	}
+	if entry.NewValues, err = auditJSONMap(newValues); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func auditJSONMap(data []byte) (*db.JSONMap, error) {
+	if data == nil {
+		return nil, nil
+	}
+	values := db.JSONMap{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode audit values: %w", err)
+	}
+	return &values, nil
+}
+
+// RecordAuditEvent stores a successful action taken through an API request.
+// The client address, user agent and session are taken from the request.
+// Failures are logged rather than returned so that auditing never undoes a
+// change that has already been made.
+func RecordAuditEvent(r *http.Request, userID int, userRole, action, resourceType, resourceID string, oldValues, newValues interface{}) {
+	entry := &db.AuditLog{
+		UserID:       &userID,
+		UserRole:     nullableString(userRole),
+		Action:       action,
+		ResourceType: nullableString(resourceType),
+		ResourceID:   nullableString(resourceID),
+		OldValues:    auditValues(oldValues),
+		NewValues:    auditValues(newValues),
+		Success:      true,
+	}
+	if userID == 0 {
+		entry.UserID = nil
+	}
+	if r != nil {
+		entry.IPAddress = nullableString(clientIP(r))
+		entry.UserAgent = nullableString(r.UserAgent())
+		entry.SessionID = nullableString(sessionID(r))
+	}
+
+	if err := NewAuditService(db.DB).Record(entry); err != nil {
+		log.Printf("Audit %s %s/%s by user %d not recorded: %v", action, resourceType, resourceID, userID, err)
+	}
+}
+
+// LogEvent records an action of the legacy handlers, which identify users
+// by username only
 func LogEvent(username, role, action, resource string) {
-	timestamp := time.Now().Format(time.RFC3339)
-	entry := fmt.Sprintf("[%s] USER=%s ROLE=%s ACTION=%s RESOURCE=%s\n", timestamp, username, role, action, resource)
-	_, _ = auditLog.WriteString(entry)
+	entry := &db.AuditLog{
+		UserRole:   nullableString(role),
+		Action:     action,
+		ResourceID: nullableString(resource),
+		NewValues:  &db.JSONMap{"username": username},
+		Success:    true,
+	}
+	if err := NewAuditService(db.DB).Record(entry); err != nil {
+		log.Printf("Audit %s %s by %s not recorded: %v", action, resource, username, err)
+	}
+}
+
+// auditValues converts old or new values to a JSON object. Values that are
+// not objects are stored under "value".
+func auditValues(v interface{}) *db.JSONMap {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return &db.JSONMap{"error": "values could not be encoded"}
+	}
+	values := db.JSONMap{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		var value interface{}
+		json.Unmarshal(data, &value)
+		values = db.JSONMap{"value": value}
+	}
+	return &values
+}
+
+// clientIP returns the address of the client, preferring the first
+// X-Forwarded-For hop set by a proxy
+func clientIP(r *http.Request) string {
+	candidates := []string{r.Header.Get("X-Real-IP")}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		candidates = append([]string{strings.Split(forwarded, ",")[0]}, candidates...)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	candidates = append(candidates, host)
+
+	for _, candidate := range candidates {
+		if ip := net.ParseIP(strings.TrimSpace(candidate)); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// sessionID returns the login session of the request's access token, or
+// the token ID for tokens issued without one
+func sessionID(r *http.Request) string {
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return ""
+	}
+	claims, err := auth.ValidateToken(tokenString)
+	if err != nil {
+		return ""
+	}
+	if claims.SessionID != "" {
+		return claims.SessionID
+	}
+	return claims.ID
 }
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Minimal ABI with only the audit anchoring method of AluminiumPassport
+const auditAnchorABI = `[
+  {"inputs":[{"internalType":"string","name":"date","type":"string"},{"internalType":"bytes32","name":"merkleRoot","type":"bytes32"},{"internalType":"bytes32","name":"chainHead","type":"bytes32"},{"internalType":"string","name":"cid","type":"string"}],"name":"anchorAuditRoot","outputs":[],"stateMutability":"nonpayable","type":"function"}
+]`
+
+// anchorAuditRootOnChain records an anchor on the passport contract and
+// returns the transaction hash. The configured key needs AUDITOR_ROLE.
+func anchorAuditRootOnChain(cfg *config.Config, anchor *db.AuditAnchor, cid string) (string, error) {
+	if cfg.ContractAddress == "" || cfg.PrivateKey == "" {
+		return "", fmt.Errorf("CONTRACT_ADDRESS and PRIVATE_KEY are required")
+	}
+
+	root, err := auditHashBytes(anchor.MerkleRoot)
+	if err != nil {
+		return "", err
+	}
+	head, err := auditHashBytes(anchor.ChainHead)
+	if err != nil {
+		return "", err
+	}
+
+	return transactPassportContract(cfg, auditAnchorABI, "anchorAuditRoot",
+		anchor.AnchorDate.Format("2006-01-02"), root, head, cid)
+}
+
+// transactPassportContract sends a transaction calling method on the
+// passport contract, signed with the configured key, and returns its hash
+func transactPassportContract(cfg *config.Config, contractABI, method string, args ...interface{}) (string, error) {
+	client, err := ethclient.Dial(cfg.Web3RPCURL)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return "", err
+	}
+	pk, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.PrivateKey, "0x"))
+	if err != nil {
+		return "", err
+	}
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return "", err
+	}
+	txOpts, err := bind.NewKeyedTransactorWithChainID(pk, chainID)
+	if err != nil {
+		return "", err
+	}
+
+	contract := bind.NewBoundContract(common.HexToAddress(cfg.ContractAddress), parsed, client, client, client)
+	tx, err := contract.Transact(txOpts, method, args...)
+	if err != nil {
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/ipfs"
+)
+
+// auditChainLock is the advisory lock key that serialises audit appends
+const auditChainLock = 7305811
+
+// auditAnchorDelay is how long after the end of a UTC day it is anchored
+const auditAnchorDelay = 5 * time.Minute
+
+// auditHashInput is the canonical form of an audit entry that is hashed.
+// Fields are listed explicitly so the hash does not depend on the JSON
+// names of db.AuditLog.
+type auditHashInput struct {
+	PrevHash     string      `json:"prev_hash"`
+	UserID       *int        `json:"user_id"`
+	UserRole     *string     `json:"user_role"`
+	Action       string      `json:"action"`
+	ResourceType *string     `json:"resource_type"`
+	ResourceID   *string     `json:"resource_id"`
+	OldValues    *db.JSONMap `json:"old_values"`
+	NewValues    *db.JSONMap `json:"new_values"`
+	IPAddress    *string     `json:"ip_address"`
+	UserAgent    *string     `json:"user_agent"`
+	Success      bool        `json:"success"`
+	ErrorMessage *string     `json:"error_message"`
+	SessionID    *string     `json:"session_id"`
+	CreatedAt    string      `json:"created_at"`
+}
+
+// auditEntryHash returns the hex SHA-256 of an entry and its PrevHash
+func auditEntryHash(entry *db.AuditLog) (string, error) {
+	data, err := json.Marshal(auditHashInput{
+		PrevHash:     stringValue(entry.PrevHash),
+		UserID:       entry.UserID,
+		UserRole:     entry.UserRole,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		OldValues:    entry.OldValues,
+		NewValues:    entry.NewValues,
+		IPAddress:    entry.IPAddress,
+		UserAgent:    entry.UserAgent,
+		Success:      entry.Success,
+		ErrorMessage: entry.ErrorMessage,
+		SessionID:    entry.SessionID,
+		CreatedAt:    entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditMerkleRoot returns the Merkle root of hex entry hashes. Each level
+// hashes pairs of nodes; an odd node is carried up unchanged.
+func auditMerkleRoot(hashes []string) (string, error) {
+	if len(hashes) == 0 {
+		return "", fmt.Errorf("no audit entries to anchor")
+	}
+
+	level := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		node, err := hex.DecodeString(h)
+		if err != nil {
+			return "", fmt.Errorf("invalid audit entry hash %q: %w", h, err)
+		}
+		level[i] = node
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0]), nil
+}
+
+// AuditChainBreak is the first place where the audit chain fails to verify
+type AuditChainBreak struct {
+	EntryID  int    `json:"entry_id"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+// AuditChainReport is the result of walking the audit chain
+type AuditChainReport struct {
+	Valid           bool             `json:"valid"`
+	EntriesChecked  int              `json:"entries_checked"`
+	UnchainedBefore int              `json:"unchained_before"`
+	Head            string           `json:"head,omitempty"`
+	AnchorsChecked  int              `json:"anchors_checked"`
+	Break           *AuditChainBreak `json:"break,omitempty"`
+}
+
+// VerifyAuditChain walks the audit log in order, recomputing every entry
+// hash and link, then checks each stored anchor against the entries it
+// covers. It stops at the first broken link.
+func (as *AuditService) VerifyAuditChain() (*AuditChainReport, error) {
+	report := &AuditChainReport{}
+
+	rows, err := as.db.Query("SELECT " + auditLogColumns + " FROM audit_logs a ORDER BY a.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	prevHash := ""
+	chained := false
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		// Entries written before the chain existed have no hash
+		if !chained && entry.EntryHash == nil {
+			report.UnchainedBefore++
+			continue
+		}
+		chained = true
+		report.EntriesChecked++
+
+		if entry.EntryHash == nil {
+			report.Break = &AuditChainBreak{EntryID: entry.ID, Reason: "entry has no hash"}
+			return report, nil
+		}
+		if stringValue(entry.PrevHash) != prevHash {
+			report.Break = &AuditChainBreak{
+				EntryID:  entry.ID,
+				Reason:   "previous hash does not match the preceding entry; an entry was removed or reordered",
+				Expected: prevHash,
+				Found:    stringValue(entry.PrevHash),
+			}
+			return report, nil
+		}
+		hash, err := auditEntryHash(entry)
+		if err != nil {
+			return nil, err
+		}
+		if hash != *entry.EntryHash {
+			report.Break = &AuditChainBreak{
+				EntryID:  entry.ID,
+				Reason:   "entry hash does not match its contents; the entry was modified",
+				Expected: hash,
+				Found:    *entry.EntryHash,
+			}
+			return report, nil
+		}
+		prevHash = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit logs: %w", err)
+	}
+	report.Head = prevHash
+
+	if err := as.verifyAnchors(report); err != nil {
+		return nil, err
+	}
+	report.Valid = report.Break == nil
+	return report, nil
+}
+
+// verifyAnchors recomputes the Merkle root of each anchored day. This
+// catches entries removed from the end of the chain, which leave no broken
+// link.
+func (as *AuditService) verifyAnchors(report *AuditChainReport) error {
+	anchors, err := as.ListAuditAnchors()
+	if err != nil {
+		return err
+	}
+
+	for _, anchor := range anchors {
+		report.AnchorsChecked++
+		ids, hashes, err := as.auditDayHashes(anchor.AnchorDate)
+		if err != nil {
+			return err
+		}
+		if len(hashes) != anchor.EntryCount || ids[len(ids)-1] != anchor.LastEntryID {
+			report.Break = &AuditChainBreak{
+				EntryID:  anchor.LastEntryID,
+				Reason:   fmt.Sprintf("anchor for %s covered %d entries, %d remain", anchor.AnchorDate.Format("2006-01-02"), anchor.EntryCount, len(hashes)),
+				Expected: anchor.ChainHead,
+			}
+			return nil
+		}
+		root, err := auditMerkleRoot(hashes)
+		if err != nil {
+			return err
+		}
+		if root != anchor.MerkleRoot {
+			report.Break = &AuditChainBreak{
+				EntryID:  anchor.FirstEntryID,
+				Reason:   fmt.Sprintf("Merkle root for %s does not match its anchor", anchor.AnchorDate.Format("2006-01-02")),
+				Expected: anchor.MerkleRoot,
+				Found:    root,
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// auditDayHashes returns the IDs and hashes of the chained entries created
+// on a UTC day, in chain order
+func (as *AuditService) auditDayHashes(day time.Time) ([]int, []string, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := as.db.Query(`
+		SELECT id, entry_hash FROM audit_logs
+		WHERE entry_hash IS NOT NULL AND created_at >= $1 AND created_at < $2
+		ORDER BY id`, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	var hashes []string
+	for rows.Next() {
+		var id int
+		var hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		ids = append(ids, id)
+		hashes = append(hashes, hash)
+	}
+	return ids, hashes, rows.Err()
+}
+
+// ListAuditAnchors returns the stored anchors, oldest first
+func (as *AuditService) ListAuditAnchors() ([]*db.AuditAnchor, error) {
+	rows, err := as.db.Query(`
+		SELECT id, anchor_date, first_entry_id, last_entry_id, entry_count, merkle_root,
+			chain_head, ipfs_hash, tx_hash, created_at, published_at
+		FROM audit_anchors ORDER BY anchor_date`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit anchors: %w", err)
+	}
+	defer rows.Close()
+
+	var anchors []*db.AuditAnchor
+	for rows.Next() {
+		anchor := &db.AuditAnchor{}
+		err := rows.Scan(&anchor.ID, &anchor.AnchorDate, &anchor.FirstEntryID, &anchor.LastEntryID,
+			&anchor.EntryCount, &anchor.MerkleRoot, &anchor.ChainHead, &anchor.IPFSHash, &anchor.TxHash,
+			&anchor.CreatedAt, &anchor.PublishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit anchor: %w", err)
+		}
+		anchors = append(anchors, anchor)
+	}
+	return anchors, rows.Err()
+}
+
+// AnchorAuditLog anchors every completed UTC day with chained entries that
+// has no anchor yet, then publishes anchors that are not yet on IPFS
+func (as *AuditService) AnchorAuditLog() error {
+	// Leave a margin after midnight for appends still committing
+	today := time.Now().UTC().Add(-auditAnchorDelay).Truncate(24 * time.Hour)
+
+	rows, err := as.db.Query(`
+		SELECT DISTINCT (a.created_at AT TIME ZONE 'UTC')::date AS day
+		FROM audit_logs a
+		WHERE a.entry_hash IS NOT NULL AND a.created_at < $1
+			AND NOT EXISTS (
+				SELECT 1 FROM audit_anchors x WHERE x.anchor_date = (a.created_at AT TIME ZONE 'UTC')::date
+			)
+		ORDER BY day`, today)
+	if err != nil {
+		return fmt.Errorf("failed to find days to anchor: %w", err)
+	}
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan anchor day: %w", err)
+		}
+		days = append(days, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		if err := as.anchorDay(day); err != nil {
+			return err
+		}
+	}
+	return as.publishAnchors()
+}
+
+// anchorDay stores the Merkle root of one day's entries
+func (as *AuditService) anchorDay(day time.Time) error {
+	ids, hashes, err := as.auditDayHashes(day)
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	root, err := auditMerkleRoot(hashes)
+	if err != nil {
+		return err
+	}
+
+	// Another instance may anchor the same day; the first one wins
+	_, err = as.db.Exec(`
+		INSERT INTO audit_anchors (anchor_date, first_entry_id, last_entry_id, entry_count, merkle_root, chain_head)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (anchor_date) DO NOTHING`,
+		day.Format("2006-01-02"), ids[0], ids[len(ids)-1], len(hashes), root, hashes[len(hashes)-1])
+	if err != nil {
+		return fmt.Errorf("failed to store audit anchor: %w", err)
+	}
+	return nil
+}
+
+// auditAnchorDocument is the JSON published to IPFS for each anchor. It
+// links to the previous day's document, so the published anchors form a
+// chain of their own.
+type auditAnchorDocument struct {
+	Type         string `json:"type"`
+	Date         string `json:"date"`
+	FirstEntryID int    `json:"first_entry_id"`
+	LastEntryID  int    `json:"last_entry_id"`
+	EntryCount   int    `json:"entry_count"`
+	MerkleRoot   string `json:"merkle_root"`
+	ChainHead    string `json:"chain_head"`
+	HashAlgo     string `json:"hash_algorithm"`
+	Previous     string `json:"previous,omitempty"`
+}
+
+// publishAnchors uploads unpublished anchors to IPFS, in date order, and
+// records them on the passport contract when enabled. Anchors that fail
+// are retried on the next run.
+func (as *AuditService) publishAnchors() error {
+	anchors, err := as.ListAuditAnchors()
+	if err != nil {
+		return err
+	}
+
+	previous := ""
+	for _, anchor := range anchors {
+		if anchor.IPFSHash != nil {
+			previous = *anchor.IPFSHash
+			continue
+		}
+		if ipfs.DefaultClient == nil {
+			return fmt.Errorf("IPFS client not initialised")
+		}
+
+		cid, err := ipfs.DefaultClient.UploadJSON(auditAnchorDocument{
+			Type:         "AuditLogAnchor",
+			Date:         anchor.AnchorDate.Format("2006-01-02"),
+			FirstEntryID: anchor.FirstEntryID,
+			LastEntryID:  anchor.LastEntryID,
+			EntryCount:   anchor.EntryCount,
+			MerkleRoot:   anchor.MerkleRoot,
+			ChainHead:    anchor.ChainHead,
+			HashAlgo:     "sha256",
+			Previous:     previous,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish audit anchor for %s: %w", anchor.AnchorDate.Format("2006-01-02"), err)
+		}
+
+		var txHash string
+		if cfg := config.AppConfig; cfg != nil && cfg.AuditAnchorOnChain {
+			txHash, err = anchorAuditRootOnChain(cfg, anchor, cid)
+			if err != nil {
+				log.Printf("Audit anchor for %s not recorded on chain: %v", anchor.AnchorDate.Format("2006-01-02"), err)
+			}
+		}
+
+		_, err = as.db.Exec(`
+			UPDATE audit_anchors SET ipfs_hash = $2, tx_hash = $3, published_at = CURRENT_TIMESTAMP
+			WHERE id = $1`, anchor.ID, cid, nullableString(txHash))
+		if err != nil {
+			return fmt.Errorf("failed to update audit anchor: %w", err)
+		}
+		previous = cid
+	}
+	return nil
+}
+
+// RunAuditAnchoring runs AnchorAuditLog on an interval until ctx is
+// cancelled
+func (as *AuditService) RunAuditAnchoring(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := as.AnchorAuditLog(); err != nil {
+			log.Printf("Audit anchoring failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// auditHashBytes decodes a hex hash for the contract
+func auditHashBytes(h string) ([32]byte, error) {
+	var out [32]byte
+	b, err := hex.DecodeString(h)
+	if err != nil || len(b) != len(out) {
+		return out, fmt.Errorf("invalid hash %q", h)
+	}
+	copy(out[:], b)
+	return out, nil
+}
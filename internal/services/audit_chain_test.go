@@ -0,0 +1,144 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+func testAuditEntry() *db.AuditLog {
+	userID := 7
+	role := "admin"
+	resourceType := "passport"
+	resourceID := "ALU-2024-001"
+	prevHash := hex.EncodeToString(make([]byte, sha256.Size))
+	return &db.AuditLog{
+		ID:           42,
+		UserID:       &userID,
+		UserRole:     &role,
+		Action:       "UPDATE_PASSPORT",
+		ResourceType: &resourceType,
+		ResourceID:   &resourceID,
+		NewValues:    &db.JSONMap{"recycled_content_percent": 42.5},
+		Success:      true,
+		CreatedAt:    time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC),
+		PrevHash:     &prevHash,
+	}
+}
+
+func TestAuditEntryHash(t *testing.T) {
+	entry := testAuditEntry()
+	want, err := auditEntryHash(entry)
+	if err != nil {
+		t.Fatalf("auditEntryHash() error = %v", err)
+	}
+
+	// Fields that are not part of the hashed content
+	stored := testAuditEntry()
+	stored.ID = 99
+	stored.EntryHash = &want
+	stored.CreatedAt = stored.CreatedAt.In(time.FixedZone("CEST", 2*60*60))
+	if got, err := auditEntryHash(stored); err != nil || got != want {
+		t.Errorf("auditEntryHash() of the same entry = %s, %v, want %s", got, err, want)
+	}
+
+	otherRole := "viewer"
+	otherResource := "ALU-2024-002"
+	otherPrev := hex.EncodeToString(make([]byte, sha256.Size-1)) + "01"
+
+	tests := []struct {
+		name   string
+		change func(e *db.AuditLog)
+	}{
+		{"action", func(e *db.AuditLog) { e.Action = "DELETE_PASSPORT" }},
+		{"user role", func(e *db.AuditLog) { e.UserRole = &otherRole }},
+		{"user removed", func(e *db.AuditLog) { e.UserID = nil }},
+		{"resource", func(e *db.AuditLog) { e.ResourceID = &otherResource }},
+		{"new values", func(e *db.AuditLog) { e.NewValues = &db.JSONMap{"recycled_content_percent": 95.0} }},
+		{"success", func(e *db.AuditLog) { e.Success = false }},
+		{"creation time", func(e *db.AuditLog) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) }},
+		{"previous hash", func(e *db.AuditLog) { e.PrevHash = &otherPrev }},
+		{"previous hash removed", func(e *db.AuditLog) { e.PrevHash = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := testAuditEntry()
+			tt.change(tampered)
+			got, err := auditEntryHash(tampered)
+			if err != nil {
+				t.Fatalf("auditEntryHash() error = %v", err)
+			}
+			if got == want {
+				t.Errorf("auditEntryHash() did not change when the %s changed", tt.name)
+			}
+		})
+	}
+}
+
+func TestAuditMerkleRoot(t *testing.T) {
+	leaf := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	node := func(left, right string) string {
+		l, _ := hex.DecodeString(left)
+		r, _ := hex.DecodeString(right)
+		sum := sha256.Sum256(append(l, r...))
+		return hex.EncodeToString(sum[:])
+	}
+	a, b, c, d := leaf("a"), leaf("b"), leaf("c"), leaf("d")
+
+	tests := []struct {
+		name   string
+		hashes []string
+		want   string
+	}{
+		{"single entry", []string{a}, a},
+		{"pair", []string{a, b}, node(a, b)},
+		{"odd entry carried up", []string{a, b, c}, node(node(a, b), c)},
+		{"full tree", []string{a, b, c, d}, node(node(a, b), node(c, d))},
+		{"order matters", []string{b, a, c, d}, node(node(b, a), node(c, d))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auditMerkleRoot(tt.hashes)
+			if err != nil {
+				t.Fatalf("auditMerkleRoot() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("auditMerkleRoot() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	root, _ := auditMerkleRoot([]string{a, b, c, d})
+	if tampered, _ := auditMerkleRoot([]string{a, b, leaf("x"), d}); tampered == root {
+		t.Errorf("auditMerkleRoot() did not change when an entry changed")
+	}
+	if reordered, _ := auditMerkleRoot([]string{b, a, c, d}); reordered == root {
+		t.Errorf("auditMerkleRoot() did not change when entries were reordered")
+	}
+}
+
+func TestAuditMerkleRootRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		hashes []string
+	}{
+		{"no entries", nil},
+		{"not hex", []string{"not-a-hash"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := auditMerkleRoot(tt.hashes); err == nil {
+				t.Errorf("auditMerkleRoot() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+)
+
+func TestRecordAuditEventStoresRequestContext(t *testing.T) {
+	withConfig(t, &config.Config{JWTSecret: "audit-test-secret", JWTExpirationHours: 1, JWTRefreshHours: 24})
+	fake := newTestDB(t)
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(2)})
+	fake.OnQuery(`SELECT entry_hash FROM audit_logs`, []string{"entry_hash"}, []interface{}{"previous-hash"})
+
+	tokens, err := auth.GenerateTokenPair("session-9", 3, "owner", "owner@example.com", "manufacturer", "", "", 7)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	r := httptest.NewRequest("PUT", "/api/passports/AP-1", nil)
+	r.RemoteAddr = "10.0.0.5:51234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+	r.Header.Set("User-Agent", "passport-cli/1.0")
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	RecordAuditEvent(r, 3, "manufacturer", "UPDATE", "passport", "AP-1",
+		map[string]interface{}{"origin": "GN"}, map[string]interface{}{"origin": "AU"})
+
+	inserts := fake.Statements(`INSERT INTO audit_logs`)
+	if len(inserts) != 1 {
+		t.Fatalf("recorded %d audit entries, want 1", len(inserts))
+	}
+	args := inserts[0].Args
+	want := map[int]interface{}{
+		0:  int64(3),
+		1:  "manufacturer",
+		2:  "UPDATE",
+		3:  "passport",
+		4:  "AP-1",
+		5:  []byte(`{"origin":"GN"}`),
+		6:  []byte(`{"origin":"AU"}`),
+		7:  "203.0.113.9",
+		8:  "passport-cli/1.0",
+		9:  true,
+		11: "session-9",
+		13: "previous-hash",
+	}
+	for i, value := range want {
+		if !reflect.DeepEqual(args[i], value) {
+			t.Errorf("argument %d = %#v, want %#v", i+1, args[i], value)
+		}
+	}
+	if hash, ok := args[14].(string); !ok || len(hash) != 64 {
+		t.Errorf("entry_hash = %#v, want a SHA-256 hex digest", args[14])
+	}
+}
+
+func TestRecordAuditEventWithoutUser(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`INSERT INTO audit_logs`, []string{"id"}, []interface{}{int64(1)})
+	fake.OnQuery(`SELECT entry_hash FROM audit_logs`, []string{"entry_hash"})
+
+	RecordAuditEvent(nil, 0, "", "EXPIRE", "approval_request", "REQ-1", nil, "deadline passed")
+
+	inserts := fake.Statements(`INSERT INTO audit_logs`)
+	if len(inserts) != 1 {
+		t.Fatalf("recorded %d audit entries, want 1", len(inserts))
+	}
+	args := inserts[0].Args
+	for _, i := range []int{0, 1, 5, 7, 8, 11, 13} {
+		if args[i] != nil {
+			t.Errorf("argument %d = %#v, want NULL", i+1, args[i])
+		}
+	}
+	if !reflect.DeepEqual(args[6], []byte(`{"value":"deadline passed"}`)) {
+		t.Errorf("new_values = %s, want the value wrapped in an object", args[6])
+	}
+}
+
+func TestListAuditLogsFilters(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`SELECT COUNT(*) FROM audit_logs`, []string{"count"}, []interface{}{int64(0)})
+	fake.OnQuery(`FROM audit_logs a`, []string{"id"})
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	logs, total, err := NewAuditService(fake.DB).ListAuditLogs(AuditFilter{
+		User:         "owner",
+		Action:       "update",
+		ResourceType: "passport",
+		From:         &from,
+		Page:         3,
+		Limit:        20,
+	})
+	if err != nil || len(logs) != 0 || total != 0 {
+		t.Fatalf("ListAuditLogs = %v, %d, %v", logs, total, err)
+	}
+
+	counts := fake.Statements(`SELECT COUNT(*) FROM audit_logs`)
+	if len(counts) != 1 {
+		t.Fatalf("counted %d times, want once", len(counts))
+	}
+	for _, condition := range []string{
+		"(a.user_id::text = $1 OR u.username = $1)", "a.action = $2", "a.resource_type = $3", "a.created_at >= $4",
+	} {
+		if !strings.Contains(counts[0].Query, condition) {
+			t.Errorf("query has no %q: %s", condition, counts[0].Query)
+		}
+	}
+
+	pages := fake.Statements(`ORDER BY a.created_at DESC, a.id DESC LIMIT $5 OFFSET $6`)
+	if len(pages) != 1 {
+		t.Fatalf("listed %d pages, want 1", len(pages))
+	}
+	want := []interface{}{"owner", "UPDATE", "passport", from, int64(20), int64(40)}
+	if !reflect.DeepEqual(pages[0].Args, want) {
+		t.Errorf("args = %#v, want %#v", pages[0].Args, want)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded string
+		realIP    string
+		remote    string
+		want      string
+	}{
+		{name: "forwarded", forwarded: " 2001:db8::1 , 10.0.0.1", realIP: "198.51.100.2", remote: "10.0.0.5:1234", want: "2001:db8::1"},
+		{name: "real IP", forwarded: "unknown", realIP: "198.51.100.2", remote: "10.0.0.5:1234", want: "198.51.100.2"},
+		{name: "remote address", remote: "10.0.0.5:1234", want: "10.0.0.5"},
+		{name: "no address", remote: "pipe", want: ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("%s: clientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
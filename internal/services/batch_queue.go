@@ -0,0 +1,437 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/utils"
+
+	"github.com/lib/pq"
+)
+
+// BatchQueue processes queued batch uploads in the background with a fixed
+// number of workers. Queued batches live in batch_operations, so the queue
+// itself holds no state that a restart could lose.
+type BatchQueue struct {
+	service      *BatchService
+	workers      int
+	pollInterval time.Duration
+	wake         chan struct{}
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+var (
+	batchQueueMu      sync.Mutex
+	defaultBatchQueue *BatchQueue
+)
+
+// StartBatchQueue resumes batches interrupted by a previous shutdown and
+// starts the workers. Workers pick up new batches when notified by
+// SubmitBatch, and otherwise check for queued batches every pollInterval.
+func StartBatchQueue(ctx context.Context, database *sql.DB, workers int, pollInterval time.Duration) (*BatchQueue, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Batches still marked processing were interrupted; this assumes a single
+	// API instance processes batches
+	result, err := database.Exec(`UPDATE batch_operations SET status = 'pending' WHERE status = 'processing'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume batches: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Resuming %d interrupted batch uploads", n)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	q := &BatchQueue{
+		service:      NewBatchService(database),
+		workers:      workers,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, workers),
+		cancel:       cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+
+	batchQueueMu.Lock()
+	defaultBatchQueue = q
+	batchQueueMu.Unlock()
+
+	return q, nil
+}
+
+// Stop stops the workers and waits for them to return. A batch being
+// processed stops after its current chunk and is resumed on next start.
+func (q *BatchQueue) Stop() {
+	batchQueueMu.Lock()
+	if defaultBatchQueue == q {
+		defaultBatchQueue = nil
+	}
+	batchQueueMu.Unlock()
+
+	q.cancel()
+	q.wg.Wait()
+}
+
+// notifyBatchQueue wakes an idle worker, if the queue is running
+func notifyBatchQueue() {
+	batchQueueMu.Lock()
+	q := defaultBatchQueue
+	batchQueueMu.Unlock()
+
+	if q == nil {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *BatchQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before waiting again
+		for ctx.Err() == nil {
+			batchID, err := q.service.claimBatch()
+			if err != nil {
+				log.Printf("Failed to claim batch: %v", err)
+				break
+			}
+			if batchID == "" {
+				break
+			}
+			if err := q.service.runBatch(ctx, batchID); err != nil {
+				log.Printf("Batch %s failed: %v", batchID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimBatch marks the oldest pending batch as processing and returns its
+// ID, or "" if none is pending
+func (bs *BatchService) claimBatch() (string, error) {
+	var batchID string
+	err := bs.db.QueryRow(`
+		UPDATE batch_operations
+		SET status = 'processing', started_at = COALESCE(started_at, CURRENT_TIMESTAMP)
+		WHERE id = (
+			SELECT id FROM batch_operations
+			WHERE status = 'pending' AND operation_type = 'upload'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING batch_id`,
+	).Scan(&batchID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return batchID, err
+}
+
+// batchJob is the in-memory state of a batch being processed
+type batchJob struct {
+	batchID        string
+	records        []models.BatchRecord
+	processed      int
+	successful     int
+	failed         int
+	errs           []models.BatchRowError
+	passportIDs    []string
+	createdBy      *int
+	organisationID *int
+	checker        *batchChecker
+}
+
+// runBatch stores the rows of a claimed batch, a chunk per transaction,
+// starting after the last committed chunk. It returns early without
+// finishing the batch if ctx is cancelled, leaving it to be resumed.
+func (bs *BatchService) runBatch(ctx context.Context, batchID string) error {
+	job, err := bs.loadBatchJob(batchID)
+	if err != nil {
+		bs.failBatch(batchID, err)
+		return err
+	}
+
+	for job.processed < len(job.records) {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		cancelled, err := bs.storeBatchChunk(job)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			bs.failBatch(batchID, err)
+			return err
+		}
+		if cancelled {
+			return bs.finishBatch(job, "cancelled")
+		}
+	}
+
+	status := "completed"
+	if job.successful == 0 {
+		status = "failed"
+	}
+	return bs.finishBatch(job, status)
+}
+
+// loadBatchJob reads a batch and replays the checks of the rows it has
+// already processed, so that IDs repeated across the resume point are still
+// caught
+func (bs *BatchService) loadBatchJob(batchID string) (*batchJob, error) {
+	var payload []byte
+	var errorLog *db.JSONMap
+	var role string
+	job := &batchJob{batchID: batchID}
+
+	// Rows are stored with the uploader's current role and organisation
+	err := bs.db.QueryRow(`
+		SELECT payload, COALESCE(processed_records, 0), COALESCE(successful_records, 0),
+			COALESCE(failed_records, 0), error_log, created_by,
+			(SELECT organisation_id FROM users WHERE id = batch_operations.created_by),
+			COALESCE((SELECT role::text FROM users WHERE id = batch_operations.created_by), '')
+		FROM batch_operations WHERE batch_id = $1`, batchID,
+	).Scan(&payload, &job.processed, &job.successful, &job.failed, &errorLog, &job.createdBy, &job.organisationID, &role)
+	if err == sql.ErrNoRows {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batch: %w", err)
+	}
+	job.checker = newBatchChecker(role)
+
+	var p batchPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode batch: %w", err)
+	}
+	job.records = p.Records
+
+	if job.errs, err = batchErrors(errorLog); err != nil {
+		return nil, fmt.Errorf("failed to decode batch errors: %w", err)
+	}
+
+	if job.processed > len(job.records) {
+		job.processed = len(job.records)
+	}
+
+	var candidates []string
+	for _, record := range job.records[:job.processed] {
+		passport, _, _ := job.checker.check(record, func(string) (bool, error) { return false, nil })
+		if passport != nil {
+			candidates = append(candidates, passport.PassportID)
+		}
+	}
+
+	// Of the rows that passed validation, the ones stored by this batch are
+	// those created since it was submitted
+	if len(candidates) > 0 {
+		rows, err := bs.db.Query(`
+			SELECT a.passport_id FROM aluminium_passports a, batch_operations b
+			WHERE b.batch_id = $1 AND a.passport_id = ANY($2) AND a.created_at >= b.created_at
+			ORDER BY a.id`, batchID, pq.Array(candidates))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stored passports: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var passportID string
+			if err := rows.Scan(&passportID); err != nil {
+				return nil, fmt.Errorf("failed to load stored passports: %w", err)
+			}
+			job.passportIDs = append(job.passportIDs, passportID)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to load stored passports: %w", err)
+		}
+	}
+
+	return job, nil
+}
+
+// storeBatchChunk stores the next chunk of rows and saves progress in one
+// transaction. Each row has its own savepoint, so a row that fails to insert
+// does not abort the others. It reports whether cancellation was requested.
+func (bs *BatchService) storeBatchChunk(job *batchJob) (bool, error) {
+	tx, err := bs.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cancelled bool
+	err = tx.QueryRow(`SELECT COALESCE(cancel_requested, false) FROM batch_operations WHERE batch_id = $1 FOR UPDATE`,
+		job.batchID).Scan(&cancelled)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock batch: %w", err)
+	}
+	if cancelled {
+		return true, nil
+	}
+
+	exists := func(passportID string) (bool, error) {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM aluminium_passports WHERE passport_id = $1)`, passportID).Scan(&exists)
+		return exists, err
+	}
+
+	end := job.processed + batchChunkSize
+	if end > len(job.records) {
+		end = len(job.records)
+	}
+
+	// Work on copies so a failed chunk leaves the job as it was committed
+	successful, failed := job.successful, job.failed
+	errs := append([]models.BatchRowError{}, job.errs...)
+	var stored []string
+	queued := 0
+	seen := make(map[string]models.BatchRecord, len(job.checker.firstSeen))
+	for id, record := range job.checker.firstSeen {
+		seen[id] = record
+	}
+	checker := &batchChecker{role: job.checker.role, firstSeen: seen}
+
+	for _, record := range job.records[job.processed:end] {
+		passport, rowErrors, err := checker.check(record, exists)
+		if err != nil {
+			return false, err
+		}
+		if len(rowErrors) > 0 {
+			failed++
+			errs = append(errs, rowErrors...)
+			continue
+		}
+
+		if passport.BatchID == nil {
+			passport.BatchID = &job.batchID
+		}
+		passport.Status = "active"
+		passport.CreatedAt = time.Now()
+		passport.UpdatedAt = passport.CreatedAt
+		passport.CreatedBy = job.createdBy
+		passport.UpdatedBy = job.createdBy
+		passport.OrganisationID = job.organisationID
+
+		if _, err := tx.Exec(`SAVEPOINT batch_row`); err != nil {
+			return false, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if _, err := insertPassportRecordTx(tx, passport); err != nil {
+			if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT batch_row`); rbErr != nil {
+				return false, fmt.Errorf("failed to roll back row: %w", rbErr)
+			}
+			failed++
+			errs = append(errs, models.BatchRowError{
+				File:       record.File,
+				Sheet:      record.Sheet,
+				Row:        record.Row,
+				PassportID: passport.PassportID,
+				Message:    fmt.Sprintf("failed to store passport: %v", err),
+			})
+			continue
+		}
+		if _, err := tx.Exec(`RELEASE SAVEPOINT batch_row`); err != nil {
+			return false, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		n, err := publishWebhookEventTx(tx, models.WebhookPassportCreated, passport.PassportID, passportWebhookData(passport, nil))
+		if err != nil {
+			return false, err
+		}
+		queued += n
+		successful++
+		stored = append(stored, passport.PassportID)
+	}
+
+	errorLog, err := batchErrorLog(errs)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`
+		UPDATE batch_operations
+		SET processed_records = $2, successful_records = $3, failed_records = $4, error_log = $5
+		WHERE batch_id = $1`,
+		job.batchID, end, successful, failed, errorLog,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to save batch progress: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit batch chunk: %w", err)
+	}
+	if queued > 0 {
+		wakeWebhookDelivery()
+	}
+
+	job.processed, job.successful, job.failed, job.errs = end, successful, failed, errs
+	job.passportIDs = append(job.passportIDs, stored...)
+	job.checker = checker
+	return false, nil
+}
+
+// finishBatch records the outcome of a batch, pins a manifest of the stored
+// passports and drops the uploaded rows
+func (bs *BatchService) finishBatch(job *batchJob, status string) error {
+	var ipfsHash string
+	if len(job.passportIDs) > 0 {
+		manifest := map[string]interface{}{
+			"batch_id":     job.batchID,
+			"passport_ids": job.passportIDs,
+			"processed_at": time.Now().UTC(),
+		}
+		if hash, err := utils.UploadToIPFS(manifest); err == nil {
+			ipfsHash = hash
+		}
+	}
+
+	_, err := bs.db.Exec(`
+		UPDATE batch_operations
+		SET status = $2, ipfs_hash = $3, payload = NULL, completed_at = CURRENT_TIMESTAMP
+		WHERE batch_id = $1`,
+		job.batchID, status, nullableString(ipfsHash),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update batch: %w", err)
+	}
+	return nil
+}
+
+// failBatch marks a batch that could not be processed as failed, keeping the
+// rows stored so far
+func (bs *BatchService) failBatch(batchID string, cause error) {
+	_, err := bs.db.Exec(`
+		UPDATE batch_operations
+		SET status = 'failed', payload = NULL, completed_at = CURRENT_TIMESTAMP,
+			error_log = jsonb_set(COALESCE(error_log, '{}'::jsonb), '{failure}', to_jsonb($2::text))
+		WHERE batch_id = $1`,
+		batchID, cause.Error(),
+	)
+	if err != nil {
+		log.Printf("Failed to mark batch %s as failed: %v", batchID, err)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// newBatchDB installs a fake database holding batch BATCH_1 with records,
+// of which processed were handled before it was interrupted
+func newBatchDB(t *testing.T, records []models.BatchRecord, processed int, cancelled bool) *dbtest.DB {
+	t.Helper()
+	t.Setenv("IPFS_API_URL", "")
+	fake := newTestDB(t)
+
+	payload, err := json.Marshal(batchPayload{Records: records})
+	if err != nil {
+		t.Fatalf("encode batch: %v", err)
+	}
+	fake.OnQuery(`FOR UPDATE`, []string{"cancel_requested"}, []interface{}{cancelled})
+	fake.OnQuery(`FROM batch_operations WHERE batch_id = $1`,
+		dbtest.Columns(`payload, processed, successful, failed, error_log, created_by, organisation_id, role`),
+		[]interface{}{payload, int64(processed), int64(processed), int64(0), nil, int64(3), int64(7), models.RoleManufacturer})
+	fake.OnQueryFunc(`SELECT a.passport_id FROM aluminium_passports a`, func(args []interface{}) ([]string, [][]interface{}, error) {
+		var rows [][]interface{}
+		for _, record := range records[:processed] {
+			rows = append(rows, []interface{}{record.Fields["passport_id"]})
+		}
+		return []string{"passport_id"}, rows, nil
+	})
+	fake.OnQuery(`SELECT EXISTS(SELECT 1 FROM aluminium_passports`, []string{"exists"}, []interface{}{false})
+	fake.OnQuery(`INSERT INTO aluminium_passports`, []string{"id"}, []interface{}{int64(10)})
+	fake.OnQuery(`INSERT INTO webhook_events`, []string{"id", "organisation_id"}, []interface{}{int64(1), int64(7)})
+	return fake
+}
+
+func batchRow(number int, passportID string) models.BatchRecord {
+	return models.BatchRecord{File: "passports.csv", Row: number, Fields: map[string]string{
+		"passport_id": passportID, "manufacturer": "Example Smelter", "origin": "AU",
+	}}
+}
+
+func TestRunBatchResumesAfterCommittedRows(t *testing.T) {
+	records := []models.BatchRecord{batchRow(2, "AP-1"), batchRow(3, "AP-2"), batchRow(4, "AP-1"), batchRow(5, "AP-3")}
+	fake := newBatchDB(t, records, 2, false)
+
+	if err := NewBatchService(fake.DB).runBatch(context.Background(), "BATCH_1"); err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+
+	// Only the rows after the resume point are stored, under the uploader's
+	// organisation
+	inserts := fake.Statements(`INSERT INTO aluminium_passports`)
+	if len(inserts) != 1 || inserts[0].Args[0] != "AP-3" {
+		t.Fatalf("stored %+v, want AP-3 only", inserts)
+	}
+	if organisation := inserts[0].Args[len(inserts[0].Args)-1]; organisation != int64(7) {
+		t.Errorf("organisation_id = %v, want 7", organisation)
+	}
+
+	progress := fake.Statements(`SET processed_records = $2`)
+	if len(progress) != 1 {
+		t.Fatalf("saved progress %d times, want once", len(progress))
+	}
+	if args := progress[0].Args; args[1] != int64(4) || args[2] != int64(3) || args[3] != int64(1) {
+		t.Errorf("progress = %v processed, %v successful, %v failed; want 4, 3, 1", args[1], args[2], args[3])
+	}
+	var errorLog db.JSONMap
+	if err := errorLog.Scan(progress[0].Args[4]); err != nil {
+		t.Fatalf("decode error log: %v", err)
+	}
+	errs, err := batchErrors(&errorLog)
+	if err != nil {
+		t.Fatalf("batchErrors: %v", err)
+	}
+	want := []models.BatchRowError{{
+		File: "passports.csv", Row: 4, PassportID: "AP-1", Field: "passport_id", Message: "duplicates passports.csv row 2",
+	}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %+v, want %+v", errs, want)
+	}
+
+	finished := fake.Statements(`SET status = $2, ipfs_hash = $3`)
+	if len(finished) != 1 || finished[0].Args[1] != "completed" {
+		t.Errorf("finished with %+v, want completed", finished)
+	}
+}
+
+func TestRunBatchStopsWhenCancelled(t *testing.T) {
+	fake := newBatchDB(t, []models.BatchRecord{batchRow(2, "AP-1"), batchRow(3, "AP-2")}, 0, true)
+
+	if err := NewBatchService(fake.DB).runBatch(context.Background(), "BATCH_1"); err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	if inserts := fake.Statements(`INSERT INTO aluminium_passports`); len(inserts) != 0 {
+		t.Errorf("stored %d passports after cancellation", len(inserts))
+	}
+	finished := fake.Statements(`SET status = $2, ipfs_hash = $3`)
+	if len(finished) != 1 || finished[0].Args[1] != "cancelled" {
+		t.Errorf("finished with %+v, want cancelled", finished)
+	}
+}
+
+func TestRunBatchStopsWithContext(t *testing.T) {
+	fake := newBatchDB(t, []models.BatchRecord{batchRow(2, "AP-1")}, 0, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := NewBatchService(fake.DB).runBatch(ctx, "BATCH_1"); err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	if updates := fake.Statements(`UPDATE batch_operations`); len(updates) != 0 {
+		t.Errorf("a stopped batch was updated: %+v", updates)
+	}
+}
+
+func TestClaimBatch(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`RETURNING batch_id`, []string{"batch_id"})
+
+	batchID, err := NewBatchService(fake.DB).claimBatch()
+	if err != nil || batchID != "" {
+		t.Errorf("claimBatch = %q, %v; want no batch", batchID, err)
+	}
+}
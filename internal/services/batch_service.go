@@ -1,15 +1,32 @@
 package services
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/models"
 	"aluminium-passport/internal/utils"
 )
 
+// batchChunkSize is how many rows are stored per transaction. Progress is
+// saved in the same transaction, so an interrupted batch resumes after the
+// last chunk it committed.
+const batchChunkSize = 100
+
+var (
+	ErrBatchNotFound = errors.New("batch not found")
+	ErrBatchFinished = errors.New("batch has already finished")
+	ErrEmptyBatch    = errors.New("no passport records found")
+)
+
 // BatchService handles batch operations for passport processing
 type BatchService struct {
 	db *sql.DB
@@ -19,156 +36,372 @@ func NewBatchService(db *sql.DB) *BatchService {
 	return &BatchService{db: db}
 }
 
-// ProcessBatchPassports saves multiple passports to database
-func (bs *BatchService) ProcessBatchPassports(passports []models.AluminiumPassport) (*models.BatchUploadResponse, error) {
-	if len(passports) == 0 {
-		return nil, fmt.Errorf("no passports to process")
+// batchPayload holds the uploaded rows of a batch until it finishes
+type batchPayload struct {
+	Records []models.BatchRecord `json:"records"`
+}
+
+// ValidateBatch checks uploaded rows without storing anything. Rows are
+// checked field by field, for fields outside the sections role owns, for IDs
+// repeated within the upload and for IDs that already exist.
+func (bs *BatchService) ValidateBatch(records []models.BatchRecord, fileErrors []models.BatchRowError, role string) (*models.BatchValidationResponse, error) {
+	checker := newBatchChecker(role)
+	errs := append([]models.BatchRowError{}, fileErrors...)
+	validRecords := 0
+
+	for _, record := range records {
+		_, rowErrors, err := checker.check(record, PassportExists)
+		if err != nil {
+			return nil, err
+		}
+		if len(rowErrors) > 0 {
+			errs = append(errs, rowErrors...)
+			continue
+		}
+		validRecords++
 	}
 
-	batchID := utils.GenerateBatchID()
-	successful := 0
-	var errors []string
+	return &models.BatchValidationResponse{
+		Valid:          len(errs) == 0 && validRecords > 0,
+		TotalRecords:   len(records),
+		ValidRecords:   validRecords,
+		InvalidRecords: len(records) - validRecords,
+		Errors:         errs,
+	}, nil
+}
 
-	// Begin transaction
-	tx, err := bs.db.Begin()
+// SubmitBatch queues uploaded rows for background processing and returns the
+// pending batch. The rows are kept with the batch so that processing can
+// resume after a restart.
+func (bs *BatchService) SubmitBatch(records []models.BatchRecord, fileErrors []models.BatchRowError, createdBy *int) (*db.BatchOperation, error) {
+	if len(records) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	payload, err := json.Marshal(batchPayload{Records: records})
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Prepare insert statement
-	stmt, err := tx.Prepare(`
-		INSERT INTO aluminium_passports (
-			passport_id, batch_id, bauxite_origin, mine_operator, date_of_extraction,
-			refinery_location, refiner_id, smelting_energy_source, carbon_emissions_per_kg,
-			alloy_composition, trace_metals, manufacturer_id, process_type, manufactured_product,
-			product_weight, energy_used, manufacturing_emissions, transport_mode, distance_travelled,
-			logistics_partner_id, shipment_date, recycled_content_percent, recycling_date,
-			recycler_id, recycling_method, times_recycled, certification_agency, esg_score,
-			compliance_standards, date_of_certification, verifier_signature
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
-			$21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31
-		)
-	`)
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+	errorLog, err := batchErrorLog(fileErrors)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, err
 	}
-	defer stmt.Close()
 
-	// Process each passport
-	for i, passport := range passports {
-		if passport.BatchID == "" {
-			passport.BatchID = batchID
-		}
+	batchID := utils.GenerateBatchID()
+	_, err = bs.db.Exec(`
+		INSERT INTO batch_operations (
+			batch_id, operation_type, total_records, successful_records, failed_records,
+			processed_records, status, error_log, payload, created_by
+		) VALUES ($1, 'upload', $2, 0, 0, 0, 'pending', $3, $4, $5)`,
+		batchID, len(records), errorLog, string(payload), createdBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue batch: %w", err)
+	}
 
-		_, err := stmt.Exec(
-			passport.PassportID, passport.BatchID, passport.BauxiteOrigin, passport.MineOperator,
-			passport.DateOfExtraction, passport.RefineryLocation, passport.RefinerID,
-			passport.SmeltingEnergySource, passport.CarbonEmissionsPerKg, passport.AlloyComposition,
-			passport.TraceMetals, passport.ManufacturerID, passport.ProcessType, passport.ManufacturedProduct,
-			passport.ProductWeight, passport.EnergyUsed, passport.ManufacturingEmissions, passport.TransportMode,
-			passport.DistanceTravelled, passport.LogisticsPartnerID, passport.ShipmentDate,
-			passport.RecycledContentPercent, passport.RecyclingDate, passport.RecyclerID, passport.RecyclingMethod,
-			passport.TimesRecycled, passport.CertificationAgency, passport.ESGScore, passport.ComplianceStandards,
-			passport.DateOfCertification, passport.VerifierSignature,
-		)
+	notifyBatchQueue()
+	return bs.GetBatchStatus(batchID)
+}
 
+// CancelBatch stops a batch. A pending batch is cancelled at once; a batch
+// being processed stops after the rows in progress, keeping the passports
+// already stored.
+func (bs *BatchService) CancelBatch(batchID string) (*db.BatchOperation, error) {
+	result, err := bs.db.Exec(`
+		UPDATE batch_operations
+		SET status = 'cancelled', cancel_requested = true, payload = NULL, completed_at = CURRENT_TIMESTAMP
+		WHERE batch_id = $1 AND status = 'pending'`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel batch: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		result, err = bs.db.Exec(`
+			UPDATE batch_operations SET cancel_requested = true
+			WHERE batch_id = $1 AND status = 'processing'`, batchID)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("Passport %d (%s): %v", i+1, passport.PassportID, err))
-		} else {
-			successful++
+			return nil, fmt.Errorf("failed to cancel batch: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			if _, err := bs.GetBatchStatus(batchID); err != nil {
+				return nil, err
+			}
+			return nil, ErrBatchFinished
+		}
+	}
+
+	return bs.GetBatchStatus(batchID)
+}
+
+// GetBatchStatus returns the batch_operations row of a batch
+func (bs *BatchService) GetBatchStatus(batchID string) (*db.BatchOperation, error) {
+	batch := &db.BatchOperation{}
+	err := bs.db.QueryRow(`
+		SELECT id, batch_id, operation_type, total_records, successful_records, failed_records,
+			COALESCE(processed_records, 0), status, error_log, ipfs_hash, COALESCE(cancel_requested, false),
+			created_by, created_at, started_at, updated_at, completed_at
+		FROM batch_operations WHERE batch_id = $1`, batchID,
+	).Scan(
+		&batch.ID, &batch.BatchID, &batch.OperationType, &batch.TotalRecords, &batch.SuccessfulRecords, &batch.FailedRecords,
+		&batch.ProcessedRecords, &batch.Status, &batch.ErrorLog, &batch.IPFSHash, &batch.CancelRequested,
+		&batch.CreatedBy, &batch.CreatedAt, &batch.StartedAt, &batch.UpdatedAt, &batch.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch status: %w", err)
+	}
+	return batch, nil
+}
+
+// batchChecker validates uploaded rows in order, remembering the passport IDs
+// seen so far to catch IDs repeated within an upload. Rows may only fill in
+// the passport sections the uploader's role owns.
+type batchChecker struct {
+	role      string
+	firstSeen map[string]models.BatchRecord
+}
+
+func newBatchChecker(role string) *batchChecker {
+	return &batchChecker{role: role, firstSeen: make(map[string]models.BatchRecord)}
+}
+
+// check converts an uploaded row into a passport. Rows with invalid fields,
+// with fields of sections the role does not own, with a passport ID repeated
+// earlier in the upload or with an ID that already exists are returned with
+// their errors instead.
+func (bc *batchChecker) check(record models.BatchRecord, exists func(string) (bool, error)) (*db.AluminiumPassport, []models.BatchRowError, error) {
+	passport, fieldErrors := PassportFromRecord(record.Fields)
+	fieldErrors = append(fieldErrors, bc.deniedFieldErrors(record)...)
+	if len(fieldErrors) > 0 {
+		passportID := recordPassportID(record)
+		for i := range fieldErrors {
+			fe := &fieldErrors[i]
+			fe.File, fe.Sheet, fe.Row, fe.PassportID = record.File, record.Sheet, record.Row, passportID
+			if cell, ok := record.Cells[fe.Field]; ok {
+				fe.Sheet, fe.Row, fe.Column = cell.Sheet, cell.Row, cell.Column
+			}
 		}
+		return nil, fieldErrors, nil
+	}
+
+	rowError := func(message string) []models.BatchRowError {
+		rowErr := models.BatchRowError{
+			File:       record.File,
+			Sheet:      record.Sheet,
+			Row:        record.Row,
+			PassportID: passport.PassportID,
+			Field:      "passport_id",
+			Message:    message,
+		}
+		for name, cell := range record.Cells {
+			if importColumnLookup[normaliseColumnName(name)] == "passport_id" {
+				rowErr.Sheet, rowErr.Row, rowErr.Column = cell.Sheet, cell.Row, cell.Column
+			}
+		}
+		return []models.BatchRowError{rowErr}
+	}
+
+	if first, ok := bc.firstSeen[passport.PassportID]; ok {
+		return nil, rowError("duplicates " + batchRecordLocation(first)), nil
 	}
+	bc.firstSeen[passport.PassportID] = record
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	taken, err := exists(passport.PassportID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check passport ID: %w", err)
+	}
+	if taken {
+		return nil, rowError("passport ID already exists"), nil
 	}
 
-	// Upload to IPFS if successful
-	var ipfsHash string
-	if successful > 0 {
-		ipfsData := map[string]interface{}{
-			"batch_id":     batchID,
-			"passports":    passports[:successful],
-			"processed_at": time.Now().UTC(),
+	return passport, nil, nil
+}
+
+// deniedFieldErrors reports the filled-in columns of a row whose section
+// the role does not own. Errors carry the column name as written in the
+// upload.
+func (bc *batchChecker) deniedFieldErrors(record models.BatchRecord) []models.BatchRowError {
+	var columns []string
+	headers := make(map[string]string)
+	for _, name := range sortedFieldNames(record.Fields) {
+		column, ok := importColumnLookup[normaliseColumnName(name)]
+		if !ok || strings.TrimSpace(record.Fields[name]) == "" {
+			continue
+		}
+		if isMetadataImportColumn(column) {
+			column = "metadata"
 		}
-		hash, err := utils.UploadToIPFS(ipfsData)
-		if err == nil {
-			ipfsHash = hash
+		if _, ok := headers[column]; ok {
+			continue
 		}
+		headers[column] = name
+		columns = append(columns, column)
 	}
 
-	response := &models.BatchUploadResponse{
-		BatchID:        batchID,
-		TotalProcessed: len(passports),
-		Successful:     successful,
-		Failed:         len(errors),
-		Errors:         errors,
-		IPFSHash:       ipfsHash,
+	var errs []models.BatchRowError
+	for _, column := range DeniedPassportFields(bc.role, columns) {
+		section, _ := models.PassportFieldSection(column)
+		errs = append(errs, models.BatchRowError{
+			Field:   headers[column],
+			Message: fmt.Sprintf("the %s section is not writable by role %s", section, bc.role),
+		})
 	}
+	return errs
+}
 
-	return response, nil
+// batchRecordLocation describes where a row was read from
+func batchRecordLocation(record models.BatchRecord) string {
+	if record.Sheet != "" {
+		return fmt.Sprintf("%s sheet %s row %d", record.File, record.Sheet, record.Row)
+	}
+	return fmt.Sprintf("%s row %d", record.File, record.Row)
 }
 
-// GetBatchStatus returns the status of a batch
-func (bs *BatchService) GetBatchStatus(batchID string) (map[string]interface{}, error) {
-	var count int
-	err := bs.db.QueryRow("SELECT COUNT(*) FROM aluminium_passports WHERE batch_id = $1", batchID).Scan(&count)
+// batchErrorLog stores row errors as {"errors": [...]}
+func batchErrorLog(errs []models.BatchRowError) (db.JSONMap, error) {
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(errs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get batch status: %w", err)
+		return nil, fmt.Errorf("failed to encode batch errors: %w", err)
 	}
+	var list []interface{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("failed to encode batch errors: %w", err)
+	}
+	return db.JSONMap{"errors": list}, nil
+}
 
-	status := map[string]interface{}{
-		"batch_id":    batchID,
-		"status":      "completed",
-		"total_count": count,
-		"created_at":  time.Now().UTC(),
+// batchErrors reads the row errors back from a batch error log
+func batchErrors(errorLog *db.JSONMap) ([]models.BatchRowError, error) {
+	if errorLog == nil || (*errorLog)["errors"] == nil {
+		return nil, nil
 	}
+	raw, err := json.Marshal((*errorLog)["errors"])
+	if err != nil {
+		return nil, err
+	}
+	var errs []models.BatchRowError
+	err = json.Unmarshal(raw, &errs)
+	return errs, err
+}
 
-	return status, nil
+// recordPassportID returns the passport ID of a raw row, if it has one
+func recordPassportID(record models.BatchRecord) string {
+	for name, value := range record.Fields {
+		if importColumnLookup[normaliseColumnName(name)] == "passport_id" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
 }
 
-// ExportPassportsCSV exports passports as CSV
-func ExportPassportsCSV(batchID string) ([]byte, error) {
-	// This would query the database and format as CSV
-	// For now, return a placeholder
-	csvContent := "passport_id,batch_id,bauxite_origin,mine_operator\n"
-	csvContent += "PASS001,BATCH001,Australia,BHP Billiton\n"
+// BatchTemplateCSV returns a CSV template with the import columns and one
+// example row
+func BatchTemplateCSV() []byte {
+	example := batchTemplateExample()
+	values := make([]string, len(PassportImportColumns))
+	for i, column := range PassportImportColumns {
+		values[i] = example[column]
+		if strings.ContainsAny(values[i], ",\"\n") {
+			values[i] = `"` + strings.ReplaceAll(values[i], `"`, `""`) + `"`
+		}
+	}
+	return []byte(strings.Join(PassportImportColumns, ",") + "\n" + strings.Join(values, ",") + "\n")
+}
 
-	return []byte(csvContent), nil
+// BatchTemplateJSON returns a JSON template with one example passport
+func BatchTemplateJSON() ([]byte, error) {
+	example := batchTemplateExample()
+	passport := make(map[string]interface{}, len(example))
+	for column, value := range example {
+		passport[column] = value
+		if i, ok := passportFieldIndex[column]; ok {
+			t := reflect.TypeOf(db.AluminiumPassport{}).Field(i).Type
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Int || t.Kind() == reflect.Float64 {
+				passport[column], _ = strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+	passport["metadata"] = map[string]interface{}{"trace_metals": "Fe-0.2%"}
+	return json.MarshalIndent([]map[string]interface{}{passport}, "", "  ")
 }
 
-// ExportPassportsJSON exports passports as JSON
-func ExportPassportsJSON(batchID string) ([]byte, error) {
-	// This would query the database and format as JSON
-	// For now, return a placeholder
-	passports := []models.AluminiumPassport{
-		{
-			PassportID:    "PASS001",
-			BatchID:       batchID,
-			BauxiteOrigin: "Australia",
-			MineOperator:  "BHP Billiton",
-		},
+func batchTemplateExample() map[string]string {
+	return map[string]string{
+		"passport_id":              "PASS001",
+		"batch_id":                 "BATCH001",
+		"manufacturer":             "Hydro Aluminium",
+		"origin":                   "AU",
+		"bauxite_source":           "Weipa Mine",
+		"alloy_composition":        "AA6061",
+		"mine_operator":            "Rio Tinto",
+		"date_of_extraction":       "2024-01-01",
+		"extraction_method":        "Open-pit",
+		"mine_location":            "Queensland",
+		"refinery_location":        "Gladstone",
+		"refiner_id":               "REF001",
+		"refining_date":            "2024-01-05",
+		"refining_method":          "Bayer",
+		"smelting_location":        "Tomago",
+		"smelting_energy_source":   "Hydro",
+		"process_type":             "Extrusion",
+		"manufactured_product":     "Window Frame",
+		"manufacturing_date":       "2024-01-10",
+		"product_weight":           "10.5",
+		"energy_used":              "150",
+		"water_used":               "20",
+		"waste_generated":          "0.4",
+		"carbon_emissions_per_kg":  "2.5",
+		"co2_footprint":            "26.25",
+		"manufacturing_emissions":  "1.2",
+		"transport_mode":           "Truck",
+		"distance_travelled":       "500",
+		"logistics_partner_id":     "LOG001",
+		"shipment_date":            "2024-01-15",
+		"recycled_content_percent": "25",
+		"recycling_date":           "2023-12-01",
+		"recycler_id":              "REC001",
+		"recycling_method":         "Mechanical",
+		"times_recycled":           "3",
+		"certification_agency":     "ASI",
+		"certifier":                "ASI Auditor",
+		"compliance_standards":     "ASI Performance Standard",
+		"date_of_certification":    "2024-01-12",
+		"certification_expiry":     "2027-01-12",
+		"verifier_signature":       "SIG001",
+		"metadata":                 `{"trace_metals":"Fe-0.2%"}`,
 	}
+}
 
-	return json.Marshal(passports)
+// ExportPassportsCSV exports the passports of a batch that scope may read as
+// CSV
+func ExportPassportsCSV(scope TenantScope, batchID string) ([]byte, error) {
+	return exportPassports(scope, batchID, (*PassportExport).WriteCSV)
 }
 
-// GetAuditLogs returns audit logs with filtering
-func GetAuditLogs(userFilter, actionFilter, limit string) ([]map[string]interface{}, error) {
-	// This would query the audit log table
-	// For now, return placeholder data
-	logs := []map[string]interface{}{
-		{
-			"timestamp": time.Now().UTC(),
-			"user":      "admin",
-			"role":      "issuer",
-			"action":    "BATCH_UPLOAD",
-			"target":    "BATCH001",
-		},
+// ExportPassportsJSON exports the passports of a batch that scope may read
+// as JSON
+func ExportPassportsJSON(scope TenantScope, batchID string) ([]byte, error) {
+	return exportPassports(scope, batchID, (*PassportExport).WriteJSON)
+}
+
+func exportPassports(scope TenantScope, batchID string, write func(*PassportExport, io.Writer) error) ([]byte, error) {
+	export, err := NewExportService(db.DB).OpenExport(scope, ExportFilter{BatchID: batchID})
+	if err != nil {
+		return nil, err
 	}
+	defer export.Close()
 
-	return logs, nil
+	var buf bytes.Buffer
+	if err := write(export, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
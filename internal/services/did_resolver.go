@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/signing"
+)
+
+var (
+	ErrDIDNotFound                = errors.New("DID could not be resolved")
+	ErrVerificationMethodNotFound = errors.New("verification method not found in DID document")
+)
+
+var didWebClient = newPublicHTTPClient(10 * time.Second)
+
+// PlatformDID returns the service's did:web identifier, or an empty string
+// when DID_WEB_DOMAIN is not configured
+func PlatformDID() string {
+	cfg := config.AppConfig
+	if cfg == nil || cfg.DIDWebDomain == "" {
+		return ""
+	}
+	return signing.WebDID(cfg.DIDWebDomain)
+}
+
+// PlatformDIDDocument builds the document served at /.well-known/did.json
+func PlatformDIDDocument() (*signing.DIDDocument, error) {
+	did := PlatformDID()
+	if did == "" {
+		return nil, ErrDIDNotFound
+	}
+
+	issuer, err := PlatformIssuerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := signing.NewDIDDocument(did)
+	doc.AddVerificationMethod(signing.VerificationMethod{
+		ID:                 issuer.VerificationMethod,
+		PublicKeyMultibase: issuer.PrivateKey.Public().Multibase(),
+	})
+	return doc, nil
+}
+
+// ResolveDID returns the DID document for a did:key or did:web identifier.
+// DIDs hosted by this service are built from the key registry directly;
+// other did:web documents are fetched over HTTPS.
+func ResolveDID(did string) (*signing.DIDDocument, error) {
+	method, err := signing.DIDMethod(did)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case "key":
+		return resolveDIDKey(did)
+	case "web":
+		platformDID := PlatformDID()
+		if platformDID != "" && did == platformDID {
+			return PlatformDIDDocument()
+		}
+		if platformDID != "" && strings.HasPrefix(did, platformDID+":orgs:") {
+			doc, err := NewKeyRegistry(db.DB).OrganisationDocument(strings.TrimPrefix(did, platformDID+":orgs:"))
+			if errors.Is(err, ErrKeyNotFound) {
+				return nil, ErrDIDNotFound
+			}
+			return doc, err
+		}
+		return fetchDIDWeb(did)
+	default:
+		return nil, signing.ErrUnsupportedDIDMethod
+	}
+}
+
+// ResolveVerificationMethod resolves a DID URL to its verification method
+// and the document that lists it
+func ResolveVerificationMethod(didURL string) (*signing.VerificationMethod, *signing.DIDDocument, error) {
+	did, fragment := signing.SplitDIDURL(didURL)
+	if fragment == "" {
+		return nil, nil, ErrVerificationMethodNotFound
+	}
+
+	doc, err := ResolveDID(did)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vm, ok := doc.FindVerificationMethod(didURL)
+	if !ok {
+		return nil, nil, ErrVerificationMethodNotFound
+	}
+	return vm, doc, nil
+}
+
+// resolveDIDKey expands a did:key and applies any rotation or revocation
+// recorded for it in the registry, which the DID itself cannot express
+func resolveDIDKey(did string) (*signing.DIDDocument, error) {
+	doc, err := signing.ResolveDIDKey(did)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.DB == nil {
+		return doc, nil
+	}
+
+	key, err := NewKeyRegistry(db.DB).GetKeyByPublicKey(doc.VerificationMethod[0].PublicKeyMultibase)
+	if errors.Is(err, ErrKeyNotFound) {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vm := doc.VerificationMethod[0]
+	registered := issuerKeyVerificationMethod(key)
+	vm.Expires = registered.Expires
+	vm.Revoked = registered.Revoked
+
+	doc = signing.NewDIDDocument(did)
+	doc.AddVerificationMethod(vm)
+	return doc, nil
+}
+
+// fetchDIDWeb downloads a did:web document from a public host
+func fetchDIDWeb(did string) (*signing.DIDDocument, error) {
+	docURL, err := signing.DIDWebURL(did)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := didWebClient.Get(docURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", docURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %d", ErrDIDNotFound, docURL, resp.StatusCode)
+	}
+
+	var doc signing.DIDDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRemoteDocumentSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid DID document at %s: %w", docURL, err)
+	}
+	if doc.ID != did {
+		return nil, fmt.Errorf("DID document at %s is for %s", docURL, doc.ID)
+	}
+
+	return &doc, nil
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// exportFlushRows is how many rows are written between flushes, so clients
+// receive a large export while it is still being read
+const exportFlushRows = 500
+
+var ErrUnknownExportColumn = errors.New("unknown export column")
+
+// exportKind decides how a column is written to JSON
+type exportKind int
+
+const (
+	exportText exportKind = iota
+	exportNumber
+	exportBool
+)
+
+// ExportColumn is a column that can be selected for export
+type ExportColumn struct {
+	Name string
+	expr string
+	kind exportKind
+}
+
+// dateExpr formats a DATE column as YYYY-MM-DD
+func dateExpr(column string) string {
+	return "to_char(" + column + ", 'YYYY-MM-DD')"
+}
+
+// ExportColumns are the columns available for export, in output order.
+// Passport fields come from aluminium_passports, esg_* from the latest
+// esg_metrics assessment and certification_* from certifications.
+var ExportColumns = []ExportColumn{
+	{"passport_id", "p.passport_id", exportText},
+	{"batch_id", "p.batch_id", exportText},
+	{"manufacturer", "p.manufacturer", exportText},
+	{"origin", "p.origin", exportText},
+	{"bauxite_source", "p.bauxite_source", exportText},
+	{"alloy_composition", "p.alloy_composition", exportText},
+	{"mine_operator", "p.mine_operator", exportText},
+	{"date_of_extraction", dateExpr("p.date_of_extraction"), exportText},
+	{"extraction_method", "p.extraction_method", exportText},
+	{"mine_location", "p.mine_location", exportText},
+	{"refinery_location", "p.refinery_location", exportText},
+	{"refiner_id", "p.refiner_id", exportText},
+	{"refining_date", dateExpr("p.refining_date"), exportText},
+	{"refining_method", "p.refining_method", exportText},
+	{"smelting_location", "p.smelting_location", exportText},
+	{"smelting_energy_source", "p.smelting_energy_source", exportText},
+	{"process_type", "p.process_type", exportText},
+	{"manufactured_product", "p.manufactured_product", exportText},
+	{"manufacturing_date", dateExpr("p.manufacturing_date"), exportText},
+	{"product_weight", "p.product_weight", exportNumber},
+	{"energy_used", "p.energy_used", exportNumber},
+	{"water_used", "p.water_used", exportNumber},
+	{"waste_generated", "p.waste_generated", exportNumber},
+	{"carbon_emissions_per_kg", "p.carbon_emissions_per_kg", exportNumber},
+	{"co2_footprint", "p.co2_footprint", exportNumber},
+	{"manufacturing_emissions", "p.manufacturing_emissions", exportNumber},
+	{"transport_mode", "p.transport_mode", exportText},
+	{"distance_travelled", "p.distance_travelled", exportNumber},
+	{"logistics_partner_id", "p.logistics_partner_id", exportText},
+	{"shipment_date", dateExpr("p.shipment_date"), exportText},
+	{"recycled_content_percent", "p.recycled_content_percent", exportNumber},
+	{"recycling_date", dateExpr("p.recycling_date"), exportText},
+	{"recycler_id", "p.recycler_id", exportText},
+	{"recycling_method", "p.recycling_method", exportText},
+	{"times_recycled", "p.times_recycled", exportNumber},
+	{"certification_agency", "p.certification_agency", exportText},
+	{"certifier", "p.certifier", exportText},
+	{"compliance_standards", "p.compliance_standards", exportText},
+	{"date_of_certification", dateExpr("p.date_of_certification"), exportText},
+	{"certification_expiry", dateExpr("p.certification_expiry"), exportText},
+	{"verifier_signature", "p.verifier_signature", exportText},
+	{"esg_score", "p.esg_score", exportNumber},
+	{"environmental_score", "p.environmental_score", exportNumber},
+	{"social_score", "p.social_score", exportNumber},
+	{"governance_score", "p.governance_score", exportNumber},
+	{"ipfs_hash", "p.ipfs_hash", exportText},
+	{"status", "p.status::text", exportText},
+	{"is_verified", "p.is_verified", exportBool},
+	{"created_at", "p.created_at", exportText},
+	{"updated_at", "p.updated_at", exportText},
+
+	{"esg_assessment_date", dateExpr("e.assessment_date"), exportText},
+	{"esg_overall_score", "e.overall_esg_score", exportNumber},
+	{"esg_carbon_footprint", "e.carbon_footprint", exportNumber},
+	{"esg_renewable_energy_percent", "e.renewable_energy_percent", exportNumber},
+	{"esg_methodology", "e.assessment_methodology", exportText},
+
+	{"certification_names", "c.names", exportText},
+	{"certification_count", "COALESCE(c.active, 0)", exportNumber},
+	{"certification_next_expiry", dateExpr("c.next_expiry"), exportText},
+}
+
+// DefaultExportColumns are exported when the caller picks no columns
+var DefaultExportColumns = []string{
+	"passport_id", "batch_id", "manufacturer", "origin", "alloy_composition",
+	"manufacturing_date", "carbon_emissions_per_kg", "recycled_content_percent",
+	"esg_score", "esg_overall_score", "certification_names", "status", "is_verified", "created_at",
+}
+
+// exportDateFields may be used for the date range of an export
+var exportDateFields = map[string]string{
+	"created_at":            "p.created_at",
+	"manufacturing_date":    "p.manufacturing_date",
+	"shipment_date":         "p.shipment_date",
+	"date_of_certification": "p.date_of_certification",
+}
+
+// ExportFilter selects the passports and columns of an export. From and To
+// are inclusive dates applied to DateField (created_at by default). The ESG
+// bounds apply to the latest assessment, or the passport's esg_score if it
+// has none.
+type ExportFilter struct {
+	BatchID      string
+	Manufacturer string
+	Status       string
+	DateField    string
+	From         *time.Time
+	To           *time.Time
+	MinESGScore  *float64
+	MaxESGScore  *float64
+	Columns      []string
+}
+
+// ExportService reads passports for export
+type ExportService struct {
+	db *sql.DB
+}
+
+func NewExportService(db *sql.DB) *ExportService {
+	return &ExportService{db: db}
+}
+
+// PassportExport is an open export query. Rows are read from the database
+// as they are written, so an export is never held in memory.
+type PassportExport struct {
+	Columns []ExportColumn
+	rows    *sql.Rows
+}
+
+// OpenExport runs the export query over the passports scope may read. The
+// caller must Close the export.
+func (es *ExportService) OpenExport(scope TenantScope, filter ExportFilter) (*PassportExport, error) {
+	columns, err := resolveExportColumns(filter.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	dateColumn := "p.created_at"
+	if filter.DateField != "" {
+		column, ok := exportDateFields[filter.DateField]
+		if !ok {
+			return nil, fmt.Errorf("%w: date field %s", ErrUnknownExportColumn, filter.DateField)
+		}
+		dateColumn = column
+	}
+
+	scopeClause, args := scope.ReadCondition("p", 1)
+	conditions := []string{scopeClause}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.BatchID != "" {
+		where("p.batch_id = $%d", filter.BatchID)
+	}
+	if filter.Manufacturer != "" {
+		where("p.manufacturer ILIKE $%d", "%"+filter.Manufacturer+"%")
+	}
+	if filter.Status != "" {
+		where("p.status::text = $%d", filter.Status)
+	}
+	if filter.From != nil {
+		where(dateColumn+" >= $%d", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		where(dateColumn+" < $%d", filter.To.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	if filter.MinESGScore != nil {
+		where("COALESCE(e.overall_esg_score, p.esg_score) >= $%d", *filter.MinESGScore)
+	}
+	if filter.MaxESGScore != nil {
+		where("COALESCE(e.overall_esg_score, p.esg_score) <= $%d", *filter.MaxESGScore)
+	}
+
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		selects[i] = column.expr
+	}
+
+	query := `
+		SELECT ` + strings.Join(selects, ", ") + `
+		FROM aluminium_passports p
+		LEFT JOIN LATERAL (
+			SELECT overall_esg_score, carbon_footprint, renewable_energy_percent,
+				assessment_date, assessment_methodology
+			FROM esg_metrics
+			WHERE passport_id = p.passport_id
+			ORDER BY assessment_date DESC NULLS LAST, created_at DESC
+			LIMIT 1
+		) e ON true
+		LEFT JOIN LATERAL (
+			SELECT string_agg(certification_name, '; ' ORDER BY certification_name) AS names,
+				COUNT(*) AS active,
+				MIN(expiry_date) FILTER (WHERE expiry_date >= CURRENT_DATE) AS next_expiry
+			FROM certifications
+			WHERE passport_id = p.passport_id AND status = 'active'
+		) c ON true
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY p.created_at, p.passport_id`
+
+	rows, err := es.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query passports for export: %w", err)
+	}
+	return &PassportExport{Columns: columns, rows: rows}, nil
+}
+
+// Close releases the export query
+func (pe *PassportExport) Close() error {
+	return pe.rows.Close()
+}
+
+// WriteCSV writes a header row and one row per passport
+func (pe *PassportExport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(pe.Columns))
+	for i, column := range pe.Columns {
+		header[i] = column.Name
+	}
+	writer.Write(header)
+
+	record := make([]string, len(pe.Columns))
+	err := pe.each(func(values []sql.NullString, n int) error {
+		for i, v := range values {
+			record[i] = v.String
+		}
+		writer.Write(record)
+		if n%exportFlushRows == 0 {
+			writer.Flush()
+			flushWriter(w)
+		}
+		return writer.Error()
+	})
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteJSON writes a JSON array with one object per passport. Object keys
+// follow the column order.
+func (pe *PassportExport) WriteJSON(w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	keys := make([][]byte, len(pe.Columns))
+	for i, column := range pe.Columns {
+		keys[i], _ = json.Marshal(column.Name)
+	}
+
+	var b strings.Builder
+	err := pe.each(func(values []sql.NullString, n int) error {
+		b.Reset()
+		if n > 1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for i, v := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.Write(keys[i])
+			b.WriteString(": ")
+			b.WriteString(exportJSONValue(pe.Columns[i].kind, v))
+		}
+		b.WriteString("}")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+		if n%exportFlushRows == 0 {
+			flushWriter(w)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+// each calls fn for every row with its 1-based row number
+func (pe *PassportExport) each(fn func(values []sql.NullString, n int) error) error {
+	values := make([]sql.NullString, len(pe.Columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	n := 0
+	for pe.rows.Next() {
+		if err := pe.rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan export row: %w", err)
+		}
+		n++
+		if err := fn(values, n); err != nil {
+			return err
+		}
+	}
+	return pe.rows.Err()
+}
+
+func exportJSONValue(kind exportKind, v sql.NullString) string {
+	if !v.Valid {
+		return "null"
+	}
+	switch kind {
+	case exportNumber, exportBool:
+		return v.String
+	}
+	quoted, _ := json.Marshal(v.String)
+	return string(quoted)
+}
+
+// flushWriter sends buffered output to the client if w supports it, as
+// http.ResponseWriter does
+func flushWriter(w io.Writer) {
+	if f, ok := w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// resolveExportColumns looks up the requested columns, or the default set
+// if none were requested
+func resolveExportColumns(names []string) ([]ExportColumn, error) {
+	if len(names) == 0 {
+		names = DefaultExportColumns
+	}
+	if len(names) == 1 && names[0] == "all" {
+		return ExportColumns, nil
+	}
+
+	columns := make([]ExportColumn, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		found := false
+		for _, column := range ExportColumns {
+			if column.Name == name {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownExportColumn, name)
+		}
+		seen[name] = true
+	}
+	return columns, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveExportColumns(t *testing.T) {
+	columns, err := resolveExportColumns([]string{"origin", " passport_id", "origin", "esg_overall_score"})
+	if err != nil {
+		t.Fatalf("resolveExportColumns: %v", err)
+	}
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	if want := []string{"origin", "passport_id", "esg_overall_score"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %v, want %v", names, want)
+	}
+
+	if columns, _ := resolveExportColumns(nil); len(columns) != len(DefaultExportColumns) {
+		t.Errorf("default export has %d columns, want %d", len(columns), len(DefaultExportColumns))
+	}
+	if columns, _ := resolveExportColumns([]string{"all"}); len(columns) != len(ExportColumns) {
+		t.Errorf("full export has %d columns, want %d", len(columns), len(ExportColumns))
+	}
+	if _, err := resolveExportColumns([]string{"password_hash"}); !errors.Is(err, ErrUnknownExportColumn) {
+		t.Errorf("unknown column error = %v, want %v", err, ErrUnknownExportColumn)
+	}
+}
+
+func TestOpenExportFilters(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM aluminium_passports p`, []string{"passport_id"})
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	minScore := 60.0
+	export, err := NewExportService(fake.DB).OpenExport(TenantScope{OrganisationID: 7}, ExportFilter{
+		BatchID:      "B-1",
+		Manufacturer: "smelter",
+		DateField:    "shipment_date",
+		From:         &from,
+		To:           &to,
+		MinESGScore:  &minScore,
+		Columns:      []string{"passport_id"},
+	})
+	if err != nil {
+		t.Fatalf("OpenExport: %v", err)
+	}
+	export.Close()
+
+	statements := fake.Statements(`FROM aluminium_passports p`)
+	if len(statements) != 1 {
+		t.Fatalf("ran %d export queries, want 1", len(statements))
+	}
+	query := statements[0].Query
+	for _, condition := range []string{
+		"p.organisation_id = $1",
+		"p.batch_id = $2",
+		"p.manufacturer ILIKE $3",
+		"p.shipment_date >= $4",
+		"p.shipment_date < $5",
+		"COALESCE(e.overall_esg_score, p.esg_score) >= $6",
+	} {
+		if !strings.Contains(query, condition) {
+			t.Errorf("export query has no %q: %s", condition, query)
+		}
+	}
+	want := []interface{}{int64(7), "B-1", "%smelter%", "2025-01-01", "2025-02-01", 60.0}
+	if !reflect.DeepEqual(statements[0].Args, want) {
+		t.Errorf("args = %#v, want %#v", statements[0].Args, want)
+	}
+}
+
+func TestOpenExportRejectsUnknownDateField(t *testing.T) {
+	fake := newTestDB(t)
+	_, err := NewExportService(fake.DB).OpenExport(TenantScope{ReadAny: true}, ExportFilter{DateField: "password_changed_at"})
+	if !errors.Is(err, ErrUnknownExportColumn) {
+		t.Errorf("OpenExport error = %v, want %v", err, ErrUnknownExportColumn)
+	}
+	if statements := fake.Statements(`FROM aluminium_passports p`); len(statements) != 0 {
+		t.Errorf("ran the export query for an invalid filter")
+	}
+}
+
+// openTestExport returns an export of two passports with a text, number
+// and boolean column
+func openTestExport(t *testing.T) *PassportExport {
+	t.Helper()
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM aluminium_passports p`, []string{"passport_id", "product_weight", "is_verified"},
+		[]interface{}{`AP-"1", north`, 12.5, true},
+		[]interface{}{"AP-2", nil, false})
+
+	export, err := NewExportService(fake.DB).OpenExport(TenantScope{ReadAny: true}, ExportFilter{
+		Columns: []string{"passport_id", "product_weight", "is_verified"},
+	})
+	if err != nil {
+		t.Fatalf("OpenExport: %v", err)
+	}
+	t.Cleanup(func() { export.Close() })
+	return export
+}
+
+func TestPassportExportWriteCSV(t *testing.T) {
+	var b strings.Builder
+	if err := openTestExport(t).WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "passport_id,product_weight,is_verified\n\"AP-\"\"1\"\", north\",12.5,true\nAP-2,,false\n"
+	if b.String() != want {
+		t.Errorf("CSV = %q, want %q", b.String(), want)
+	}
+}
+
+func TestPassportExportWriteJSON(t *testing.T) {
+	var b strings.Builder
+	if err := openTestExport(t).WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	want := "[\n" +
+		`  {"passport_id": "AP-\"1\", north", "product_weight": 12.5, "is_verified": true},` + "\n" +
+		`  {"passport_id": "AP-2", "product_weight": null, "is_verified": false}` + "\n]\n"
+	if b.String() != want {
+		t.Errorf("JSON = %s, want %s", b.String(), want)
+	}
+}
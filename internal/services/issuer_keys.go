@@ -0,0 +1,377 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+)
+
+// Issuer key lifecycle states
+const (
+	KeyStatusActive  = "active"
+	KeyStatusRotated = "rotated"
+	KeyStatusRevoked = "revoked"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("issuer key not found")
+	ErrActiveKeyExists = errors.New("organisation already has an active issuer key")
+	ErrKeyNotActive    = errors.New("issuer key is not active")
+	ErrKeyRevoked      = errors.New("issuer key has already been revoked")
+)
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// OrganisationSlug derives the URL-safe form of an organisation name used
+// in its did:web. Different names can share a slug, so it never identifies
+// who owns a key.
+func OrganisationSlug(name string) string {
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// OrganisationDID returns the did:web identifier of an organisation, or an
+// empty string when DID_WEB_DOMAIN is not configured and keys use did:key
+func OrganisationDID(slug string) string {
+	cfg := config.AppConfig
+	if cfg == nil || cfg.DIDWebDomain == "" {
+		return ""
+	}
+	return signing.WebDID(cfg.DIDWebDomain, "orgs", slug)
+}
+
+// KeyRegistry stores organisation issuer keys and tracks their rotation and
+// revocation
+type KeyRegistry struct {
+	db *sql.DB
+}
+
+func NewKeyRegistry(db *sql.DB) *KeyRegistry {
+	return &KeyRegistry{db: db}
+}
+
+// CreateKey generates the first active key for an organisation
+func (kr *KeyRegistry) CreateKey(org *models.Organisation, keyType signing.KeyType, createdBy int) (*db.IssuerKey, error) {
+	tx, err := kr.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM issuer_keys WHERE organisation_id = $1 AND status = $2)`,
+		org.ID, KeyStatusActive,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrActiveKeyExists
+	}
+
+	key, err := kr.createKeyTx(tx, org, keyType, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return key, nil
+}
+
+// RotateKey retires an active key and replaces it with a new one of the same
+// type. The old key stays resolvable so credentials it signed before the
+// rotation still verify.
+func (kr *KeyRegistry) RotateKey(keyID, rotatedBy int) (*db.IssuerKey, error) {
+	tx, err := kr.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := scanIssuerKey(tx.QueryRow(issuerKeySelect+` WHERE id = $1 FOR UPDATE`, keyID))
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != KeyStatusActive {
+		return nil, ErrKeyNotActive
+	}
+
+	_, err = tx.Exec(
+		`UPDATE issuer_keys SET status = $1, rotated_at = $2 WHERE id = $3`,
+		KeyStatusRotated, time.Now().UTC(), keyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire key: %w", err)
+	}
+
+	org := &models.Organisation{
+		ID:   current.OrganisationID,
+		Slug: current.Organisation,
+		Name: stringValue(current.CompanyName),
+	}
+	key, err := kr.createKeyTx(tx, org, signing.KeyType(current.KeyType), rotatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return key, nil
+}
+
+// RevokeKey marks a key as compromised. Credentials signed with a revoked key
+// no longer verify, whenever they were issued.
+func (kr *KeyRegistry) RevokeKey(keyID int, reason string) (*db.IssuerKey, error) {
+	key, err := kr.GetKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Status == KeyStatusRevoked {
+		return nil, ErrKeyRevoked
+	}
+
+	now := time.Now().UTC()
+	_, err = kr.db.Exec(
+		`UPDATE issuer_keys SET status = $1, revoked_at = $2, revocation_reason = $3 WHERE id = $4`,
+		KeyStatusRevoked, now, nullableString(reason), keyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke key: %w", err)
+	}
+
+	key.Status = KeyStatusRevoked
+	key.RevokedAt = &now
+	key.RevocationReason = nullableString(reason)
+	return key, nil
+}
+
+// GetKey returns a key by its database ID
+func (kr *KeyRegistry) GetKey(keyID int) (*db.IssuerKey, error) {
+	return scanIssuerKey(kr.db.QueryRow(issuerKeySelect+` WHERE id = $1`, keyID))
+}
+
+// GetKeyByPublicKey returns the registered key with the given public key
+func (kr *KeyRegistry) GetKeyByPublicKey(publicKeyMultibase string) (*db.IssuerKey, error) {
+	return scanIssuerKey(kr.db.QueryRow(issuerKeySelect+` WHERE public_key_multibase = $1`, publicKeyMultibase))
+}
+
+// ListKeys returns the keys of an organisation, or of every organisation when
+// organisationID is 0
+func (kr *KeyRegistry) ListKeys(organisationID int) ([]*db.IssuerKey, error) {
+	query := issuerKeySelect
+	args := []interface{}{}
+	if organisationID != 0 {
+		query += ` WHERE organisation_id = $1`
+		args = append(args, organisationID)
+	}
+	query += ` ORDER BY organisation_id, created_at`
+
+	rows, err := kr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*db.IssuerKey
+	for rows.Next() {
+		key, err := scanIssuerKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SigningKey returns the decrypted active key of an organisation
+func (kr *KeyRegistry) SigningKey(organisationID int) (*IssuerKey, error) {
+	key, err := scanIssuerKey(kr.db.QueryRow(
+		issuerKeySelect+` WHERE organisation_id = $1 AND status = $2`, organisationID, KeyStatusActive,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := keyEncryptionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := signing.OpenPrivateKey(key.EncryptedPrivateKey, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IssuerKey{
+		Controller:         key.ControllerDID,
+		VerificationMethod: key.VerificationMethod,
+		PrivateKey:         privateKey,
+	}, nil
+}
+
+// OrganisationDocument builds the did:web document of the organisation with
+// the given slug from every key it has registered under that DID
+func (kr *KeyRegistry) OrganisationDocument(slug string) (*signing.DIDDocument, error) {
+	did := OrganisationDID(slug)
+	if did == "" {
+		return nil, ErrKeyNotFound
+	}
+
+	var organisationID int
+	err := kr.db.QueryRow(`SELECT id FROM organisations WHERE slug = $1`, slug).Scan(&organisationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := kr.ListKeys(organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := signing.NewDIDDocument(did)
+	for _, key := range keys {
+		if key.ControllerDID == did {
+			doc.AddVerificationMethod(issuerKeyVerificationMethod(key))
+		}
+	}
+	if len(doc.VerificationMethod) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return doc, nil
+}
+
+func (kr *KeyRegistry) createKeyTx(tx *sql.Tx, org *models.Organisation, keyType signing.KeyType, createdBy int) (*db.IssuerKey, error) {
+	privateKey, err := signing.GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := keyEncryptionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := signing.SealPrivateKey(privateKey, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	publicKey := privateKey.Public()
+	controller, verificationMethod := signing.DIDKey(publicKey)
+	if did := OrganisationDID(org.Slug); did != "" {
+		controller = did
+		verificationMethod = did + "#" + publicKey.Multibase()
+	}
+
+	key := &db.IssuerKey{
+		OrganisationID:      org.ID,
+		Organisation:        org.Slug,
+		CompanyName:         nullableString(org.Name),
+		ControllerDID:       controller,
+		VerificationMethod:  verificationMethod,
+		KeyType:             string(keyType),
+		PublicKeyMultibase:  publicKey.Multibase(),
+		EncryptedPrivateKey: sealed,
+		Status:              KeyStatusActive,
+		CreatedBy:           &createdBy,
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO issuer_keys (
+			organisation_id, organisation, company_name, wallet_address, controller_did, verification_method,
+			key_type, public_key_multibase, encrypted_private_key, status, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`,
+		key.OrganisationID, key.Organisation, key.CompanyName, key.WalletAddress, key.ControllerDID, key.VerificationMethod,
+		key.KeyType, key.PublicKeyMultibase, key.EncryptedPrivateKey, key.Status, key.CreatedBy,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store key: %w", err)
+	}
+
+	return key, nil
+}
+
+const issuerKeySelect = `
+	SELECT id, organisation_id, organisation, company_name, wallet_address, controller_did, verification_method,
+	       key_type, public_key_multibase, encrypted_private_key, status, created_by,
+	       created_at, rotated_at, revoked_at, revocation_reason
+	FROM issuer_keys`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIssuerKey(row rowScanner) (*db.IssuerKey, error) {
+	key := &db.IssuerKey{}
+	err := row.Scan(
+		&key.ID, &key.OrganisationID, &key.Organisation, &key.CompanyName, &key.WalletAddress, &key.ControllerDID,
+		&key.VerificationMethod, &key.KeyType, &key.PublicKeyMultibase, &key.EncryptedPrivateKey,
+		&key.Status, &key.CreatedBy, &key.CreatedAt, &key.RotatedAt, &key.RevokedAt, &key.RevocationReason,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+	return key, err
+}
+
+// issuerKeyVerificationMethod maps a registry key onto its DID document
+// entry, carrying over rotation and revocation times
+func issuerKeyVerificationMethod(key *db.IssuerKey) signing.VerificationMethod {
+	vm := signing.VerificationMethod{
+		ID:                 key.VerificationMethod,
+		Controller:         key.ControllerDID,
+		PublicKeyMultibase: key.PublicKeyMultibase,
+	}
+	if key.RotatedAt != nil {
+		vm.Expires = key.RotatedAt.UTC().Format(time.RFC3339)
+	}
+	if key.RevokedAt != nil {
+		vm.Revoked = key.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return vm
+}
+
+// keyEncryptionSecret returns the secret protecting stored issuer keys.
+// Development falls back to the JWT secret so a fresh checkout works.
+func keyEncryptionSecret() (string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not loaded")
+	}
+	if cfg.KeyEncryptionSecret != "" {
+		return cfg.KeyEncryptionSecret, nil
+	}
+	if cfg.IsProduction() {
+		return "", fmt.Errorf("KEY_ENCRYPTION_SECRET is not configured")
+	}
+	return cfg.JWTSecret, nil
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
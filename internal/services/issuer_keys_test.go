@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/signing"
+)
+
+func TestIssuePassportCredentialSelectsIssuerKey(t *testing.T) {
+	const secret = "issuer-key-test-secret"
+	withConfig(t, &config.Config{
+		Environment:         "test",
+		PublicBaseURL:       "https://passports.example.com",
+		DIDWebDomain:        "passports.example.com",
+		KeyEncryptionSecret: secret,
+	})
+
+	key, err := signing.GenerateKey(signing.KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	sealed, err := signing.SealPrivateKey(key, secret)
+	if err != nil {
+		t.Fatalf("SealPrivateKey: %v", err)
+	}
+	organisationDID := OrganisationDID("example-smelter")
+	organisationMethod := organisationDID + "#" + key.Public().Multibase()
+
+	platform, err := PlatformIssuerKey()
+	if err != nil {
+		t.Fatalf("PlatformIssuerKey: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		organisation interface{}
+		issuer       string
+		method       string
+		publicKey    string
+	}{
+		{
+			name:         "organisation passport",
+			organisation: int64(7),
+			issuer:       organisationDID,
+			method:       organisationMethod,
+			publicKey:    key.Public().Multibase(),
+		},
+		{
+			name:         "organisation without a key",
+			organisation: int64(8),
+			issuer:       platform.Controller,
+			method:       platform.VerificationMethod,
+			publicKey:    platform.PrivateKey.Public().Multibase(),
+		},
+		{
+			name:         "platform passport",
+			organisation: nil,
+			issuer:       platform.Controller,
+			method:       platform.VerificationMethod,
+			publicKey:    platform.PrivateKey.Public().Multibase(),
+		},
+	}
+
+	keyColumns := dbtest.Columns(`id, organisation_id, organisation, company_name, wallet_address,
+		controller_did, verification_method, key_type, public_key_multibase, encrypted_private_key,
+		status, created_by, created_at, rotated_at, revoked_at, revocation_reason`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestDB(t)
+			fake.OnQuery(`FROM aluminium_passports WHERE passport_id = $1`, dbtest.Columns(PassportColumns),
+				passportRow("AP-1", map[string]interface{}{"organisation_id": tt.organisation}))
+			fake.OnQueryFunc(`FROM issuer_keys`, func(args []interface{}) ([]string, [][]interface{}, error) {
+				if args[0] != int64(7) {
+					return keyColumns, nil, nil
+				}
+				return keyColumns, [][]interface{}{dbtest.Row(keyColumns, map[string]interface{}{
+					"id":                    int64(1),
+					"organisation_id":       int64(7),
+					"organisation":          "example-smelter",
+					"controller_did":        organisationDID,
+					"verification_method":   organisationMethod,
+					"key_type":              string(signing.KeyTypeEd25519),
+					"public_key_multibase":  key.Public().Multibase(),
+					"encrypted_private_key": sealed,
+					"status":                KeyStatusActive,
+					"created_at":            time.Now(),
+				})}, nil
+			})
+			fake.OnQuery(`FROM status_lists`, []string{"id", "next_index"}, []interface{}{int64(1), int64(0)})
+
+			passport, err := GetPassportRecord("AP-1")
+			if err != nil {
+				t.Fatalf("GetPassportRecord: %v", err)
+			}
+			signed, err := IssuePassportCredential(passport)
+			if err != nil {
+				t.Fatalf("IssuePassportCredential: %v", err)
+			}
+
+			if signed.Issuer != tt.issuer {
+				t.Errorf("issuer = %q, want %q", signed.Issuer, tt.issuer)
+			}
+			if signed.Proof.Type != signing.ProofTypeDataIntegrity || signed.Proof.Cryptosuite != signing.CryptosuiteEdDSAJCS2022 {
+				t.Errorf("proof suite = %s/%s, want %s/%s", signed.Proof.Type, signed.Proof.Cryptosuite,
+					signing.ProofTypeDataIntegrity, signing.CryptosuiteEdDSAJCS2022)
+			}
+			if signed.Proof.VerificationMethod != tt.method {
+				t.Errorf("verificationMethod = %q, want %q", signed.Proof.VerificationMethod, tt.method)
+			}
+			data, err := json.Marshal(signed)
+			if err != nil {
+				t.Fatalf("encode credential: %v", err)
+			}
+			claim, unsecured, err := parseSecuredClaim(data)
+			if err != nil {
+				t.Fatalf("parseSecuredClaim: %v", err)
+			}
+			if err := verifyProofWithKey(claim.Proof, unsecured, tt.publicKey); err != nil {
+				t.Errorf("proof does not verify with the issuer key: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/models"
+)
+
+// NotificationRecipient is the user a notification is delivered to
+type NotificationRecipient struct {
+	UserID   int
+	Username string
+	Email    *string
+}
+
+// NotificationChannel delivers notifications outside the application. The
+// in-app inbox is not a channel; it is read straight from the database.
+type NotificationChannel interface {
+	// Name is the channel's key in delivery records and user preferences
+	Name() string
+	// Accepts reports whether the recipient can be reached on the channel
+	Accepts(recipient *NotificationRecipient) bool
+	Send(recipient *NotificationRecipient, notification *models.Notification) error
+}
+
+var (
+	notificationChannelsMu sync.RWMutex
+	notificationChannels   = map[string]NotificationChannel{}
+)
+
+// RegisterNotificationChannel makes a channel available for delivery,
+// replacing any channel with the same name
+func RegisterNotificationChannel(channel NotificationChannel) {
+	notificationChannelsMu.Lock()
+	defer notificationChannelsMu.Unlock()
+	notificationChannels[channel.Name()] = channel
+}
+
+// RegisterConfiguredNotificationChannels registers the email and webhook
+// channels that are configured
+func RegisterConfiguredNotificationChannels(cfg *config.Config) {
+	if cfg.SMTPHost != "" {
+		RegisterNotificationChannel(&EmailChannel{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			Host:     cfg.SMTPHost,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	if cfg.NotificationURL != "" {
+		RegisterNotificationChannel(&WebhookChannel{
+			URL:    cfg.NotificationURL,
+			Client: &http.Client{Timeout: 10 * time.Second},
+		})
+	}
+}
+
+func notificationChannel(name string) (NotificationChannel, bool) {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+	channel, ok := notificationChannels[name]
+	return channel, ok
+}
+
+// registeredNotificationChannels returns the registered channels by name
+func registeredNotificationChannels() []NotificationChannel {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+
+	channels := make([]NotificationChannel, 0, len(notificationChannels))
+	for _, channel := range notificationChannels {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name() < channels[j].Name() })
+	return channels
+}
+
+// EmailChannel sends notifications as plain-text email over SMTP
+type EmailChannel struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (ec *EmailChannel) Name() string {
+	return models.ChannelEmail
+}
+
+func (ec *EmailChannel) Accepts(recipient *NotificationRecipient) bool {
+	return recipient.Email != nil && *recipient.Email != ""
+}
+
+func (ec *EmailChannel) Send(recipient *NotificationRecipient, notification *models.Notification) error {
+	if !ec.Accepts(recipient) {
+		return errors.New("recipient has no email address")
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", ec.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", *recipient.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", notification.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if ec.Username != "" {
+		auth = smtp.PlainAuth("", ec.Username, ec.Password, ec.Host)
+	}
+	return smtp.SendMail(ec.Addr, auth, ec.From, []string{*recipient.Email}, []byte(msg.String()))
+}
+
+// WebhookChannel posts notifications as JSON to a single URL
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+func (wc *WebhookChannel) Name() string {
+	return models.ChannelWebhook
+}
+
+func (wc *WebhookChannel) Accepts(recipient *NotificationRecipient) bool {
+	return true
+}
+
+func (wc *WebhookChannel) Send(recipient *NotificationRecipient, notification *models.Notification) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":         notification.ID,
+		"event":      notification.Event,
+		"user_id":    recipient.UserID,
+		"username":   recipient.Username,
+		"subject":    notification.Subject,
+		"body":       notification.Body,
+		"data":       notification.Data,
+		"created_at": notification.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := wc.Client.Post(wc.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,512 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrInvalidPreference    = errors.New("invalid notification preference")
+)
+
+// notificationLease is how long a claimed delivery is held before another
+// worker may retry it
+const notificationLease = 5 * time.Minute
+
+// notificationWake wakes the delivery worker when deliveries are queued
+var notificationWake = make(chan struct{}, 1)
+
+// NotificationService stores notifications, queues their delivery on the
+// registered channels and keeps each user's channel preferences
+type NotificationService struct {
+	db *sql.DB
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// Notify renders an event for each active user and stores it. A delivery
+// is queued on every registered channel the user has not turned off.
+func (ns *NotificationService) Notify(event string, userIDs []int, data map[string]interface{}) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	subject, body, err := renderNotification(event, data)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := ns.recipients(`SELECT id, username, email FROM users WHERE id = ANY($1) AND is_active = true`, pq.Array(userIDs))
+	if err != nil {
+		return err
+	}
+	return ns.store(event, subject, body, data, recipients)
+}
+
+// NotifyRoles notifies every active user holding one of the roles, except
+// the excluded users
+func (ns *NotificationService) NotifyRoles(event string, roles []string, exclude []int, data map[string]interface{}) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	if exclude == nil {
+		exclude = []int{}
+	}
+	subject, body, err := renderNotification(event, data)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := ns.recipients(`
+		SELECT id, username, email FROM users
+		WHERE role::text = ANY($1) AND is_active = true AND NOT (id = ANY($2))`,
+		pq.Array(roles), pq.Array(exclude))
+	if err != nil {
+		return err
+	}
+	return ns.store(event, subject, body, data, recipients)
+}
+
+func (ns *NotificationService) recipients(query string, args ...interface{}) ([]*NotificationRecipient, error) {
+	rows, err := ns.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []*NotificationRecipient
+	for rows.Next() {
+		recipient := &NotificationRecipient{}
+		if err := rows.Scan(&recipient.UserID, &recipient.Username, &recipient.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, rows.Err()
+}
+
+// store saves one notification per recipient with its deliveries
+func (ns *NotificationService) store(event, subject, body string, data map[string]interface{}, recipients []*NotificationRecipient) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int, len(recipients))
+	for i, recipient := range recipients {
+		userIDs[i] = recipient.UserID
+	}
+	disabled, err := ns.disabledChannels(event, userIDs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ns.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	channels := registeredNotificationChannels()
+	var payload *db.JSONMap
+	if data != nil {
+		values := db.JSONMap(data)
+		payload = &values
+	}
+
+	queued := 0
+	for _, recipient := range recipients {
+		inApp := !disabled[recipient.UserID][models.ChannelInApp]
+		var deliver []string
+		for _, channel := range channels {
+			if !disabled[recipient.UserID][channel.Name()] && channel.Accepts(recipient) {
+				deliver = append(deliver, channel.Name())
+			}
+		}
+		if !inApp && len(deliver) == 0 {
+			continue
+		}
+
+		var notificationID int
+		err := tx.QueryRow(`
+			INSERT INTO notifications (user_id, event, subject, body, data, in_app)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`,
+			recipient.UserID, event, subject, body, payload, inApp,
+		).Scan(&notificationID)
+		if err != nil {
+			return fmt.Errorf("failed to store notification: %w", err)
+		}
+
+		for _, channel := range deliver {
+			_, err := tx.Exec(`INSERT INTO notification_deliveries (notification_id, channel) VALUES ($1, $2)`,
+				notificationID, channel)
+			if err != nil {
+				return fmt.Errorf("failed to queue %s delivery: %w", channel, err)
+			}
+			queued++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if queued > 0 {
+		select {
+		case notificationWake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// disabledChannels returns, per user, the channels turned off for an event
+func (ns *NotificationService) disabledChannels(event string, userIDs []int) (map[int]map[string]bool, error) {
+	rows, err := ns.db.Query(`
+		SELECT user_id, channel FROM notification_preferences
+		WHERE event = $1 AND user_id = ANY($2) AND enabled = false`, event, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	disabled := map[int]map[string]bool{}
+	for rows.Next() {
+		var userID int
+		var channel string
+		if err := rows.Scan(&userID, &channel); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		if disabled[userID] == nil {
+			disabled[userID] = map[string]bool{}
+		}
+		disabled[userID][channel] = true
+	}
+	return disabled, rows.Err()
+}
+
+// notificationDelivery is a claimed delivery with what is needed to send it
+type notificationDelivery struct {
+	id           int
+	channel      string
+	attempts     int
+	notification models.Notification
+	recipient    NotificationRecipient
+}
+
+// claimDelivery takes the oldest due delivery and holds it for
+// notificationLease, or returns nil if none is due
+func (ns *NotificationService) claimDelivery() (*notificationDelivery, error) {
+	d := &notificationDelivery{}
+	err := ns.db.QueryRow(`
+		UPDATE notification_deliveries
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $1 * INTERVAL '1 second'
+		WHERE id = (
+			SELECT id FROM notification_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, channel, attempts, notification_id`, int(notificationLease.Seconds()),
+	).Scan(&d.id, &d.channel, &d.attempts, &d.notification.ID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notification delivery: %w", err)
+	}
+
+	var data db.JSONMap
+	err = ns.db.QueryRow(`
+		SELECT n.user_id, n.event, n.subject, n.body, n.data, n.created_at, u.username, u.email
+		FROM notifications n JOIN users u ON u.id = n.user_id
+		WHERE n.id = $1`, d.notification.ID,
+	).Scan(&d.notification.UserID, &d.notification.Event, &d.notification.Subject, &d.notification.Body,
+		&data, &d.notification.CreatedAt, &d.recipient.Username, &d.recipient.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification %d: %w", d.notification.ID, err)
+	}
+	if data != nil {
+		d.notification.Data = &data
+	}
+	d.recipient.UserID = d.notification.UserID
+	return d, nil
+}
+
+// DeliverDue sends every due delivery. Failed deliveries are retried after
+// one minute, doubling each time, until maxAttempts is reached.
+func (ns *NotificationService) DeliverDue(ctx context.Context, maxAttempts int) (int, error) {
+	sent := 0
+	for ctx.Err() == nil {
+		d, err := ns.claimDelivery()
+		if err != nil {
+			return sent, err
+		}
+		if d == nil {
+			break
+		}
+
+		channel, ok := notificationChannel(d.channel)
+		if !ok {
+			err = fmt.Errorf("channel %s is not configured", d.channel)
+		} else {
+			err = channel.Send(&d.recipient, &d.notification)
+		}
+
+		if err == nil {
+			sent++
+			_, err = ns.db.Exec(`
+				UPDATE notification_deliveries
+				SET status = 'sent', delivered_at = NOW(), last_error = NULL
+				WHERE id = $1`, d.id)
+		} else if d.attempts >= maxAttempts {
+			log.Printf("Notification %d on %s failed after %d attempts: %v", d.notification.ID, d.channel, d.attempts, err)
+			_, err = ns.db.Exec(`
+				UPDATE notification_deliveries SET status = 'failed', last_error = $2 WHERE id = $1`,
+				d.id, err.Error())
+		} else {
+			backoff := time.Minute << uint(d.attempts-1)
+			_, err = ns.db.Exec(`
+				UPDATE notification_deliveries
+				SET next_attempt_at = NOW() + $2 * INTERVAL '1 second', last_error = $3
+				WHERE id = $1`, d.id, int(backoff.Seconds()), err.Error())
+		}
+		if err != nil {
+			return sent, fmt.Errorf("failed to update notification delivery: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+// RunNotificationDelivery sends due deliveries whenever notifications are
+// queued, and otherwise every interval, until ctx is cancelled
+func (ns *NotificationService) RunNotificationDelivery(ctx context.Context, interval time.Duration, maxAttempts int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ns.DeliverDue(ctx, maxAttempts); err != nil {
+			log.Printf("Notification delivery failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-notificationWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ListNotifications returns a page of a user's inbox, newest first, with
+// the total and unread counts
+func (ns *NotificationService) ListNotifications(userID int, unreadOnly bool, page, limit int) ([]*models.Notification, int, int, error) {
+	var total, unread int
+	err := ns.db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE read_at IS NULL)
+		FROM notifications WHERE user_id = $1 AND in_app`, userID,
+	).Scan(&total, &unread)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	if unreadOnly {
+		total = unread
+	}
+
+	rows, err := ns.db.Query(`
+		SELECT id, user_id, event, subject, body, data, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND in_app AND ($2 = false OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`, userID, unreadOnly, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n := &models.Notification{InApp: true}
+		var data db.JSONMap
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Event, &n.Subject, &n.Body, &data, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		if data != nil {
+			n.Data = &data
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, total, unread, rows.Err()
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func (ns *NotificationService) MarkNotificationRead(userID, notificationID int) error {
+	result, err := ns.db.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2 AND in_app`, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks a user's whole inbox as read
+func (ns *NotificationService) MarkAllNotificationsRead(userID int) (int, error) {
+	result, err := ns.db.Exec(`
+		UPDATE notifications SET read_at = NOW()
+		WHERE user_id = $1 AND in_app AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// preferenceChannels returns the channels users can set preferences for
+func preferenceChannels() []string {
+	channels := models.NotificationChannels()
+	known := map[string]bool{}
+	for _, channel := range channels {
+		known[channel] = true
+	}
+	for _, channel := range registeredNotificationChannels() {
+		if !known[channel.Name()] {
+			channels = append(channels, channel.Name())
+		}
+	}
+	return channels
+}
+
+// NotificationPreferences returns a user's setting for every event and
+// channel
+func (ns *NotificationService) NotificationPreferences(userID int) ([]models.NotificationPreference, error) {
+	rows, err := ns.db.Query(`SELECT event, channel, enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	stored := map[string]bool{}
+	for rows.Next() {
+		var event, channel string
+		var enabled bool
+		if err := rows.Scan(&event, &channel, &enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		stored[event+"/"+channel] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var preferences []models.NotificationPreference
+	for _, event := range models.NotificationEvents() {
+		for _, channel := range preferenceChannels() {
+			enabled, ok := stored[event+"/"+channel]
+			preferences = append(preferences, models.NotificationPreference{
+				Event:   event,
+				Channel: channel,
+				Enabled: !ok || enabled,
+			})
+		}
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences stores the given settings, leaving the others
+// unchanged
+func (ns *NotificationService) SetNotificationPreferences(userID int, preferences []models.NotificationPreference) error {
+	events := map[string]bool{}
+	for _, event := range models.NotificationEvents() {
+		events[event] = true
+	}
+	channels := map[string]bool{}
+	for _, channel := range preferenceChannels() {
+		channels[channel] = true
+	}
+	for _, preference := range preferences {
+		if !events[preference.Event] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidPreference, preference.Event)
+		}
+		if !channels[preference.Channel] {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, preference.Channel)
+		}
+	}
+
+	tx, err := ns.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, preference := range preferences {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, event, channel, enabled)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, event, channel)
+			DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = CURRENT_TIMESTAMP`,
+			userID, preference.Event, preference.Channel, preference.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to store notification preference: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// NotifyPassportCreated tells admins and certifiers about a new passport.
+// Failures are logged; the passport has already been stored.
+func NotifyPassportCreated(passport *db.AluminiumPassport, userID int, username string) {
+	data := passportNotificationData(passport, username)
+	err := NewNotificationService(db.DB).NotifyRoles(models.EventPassportCreated,
+		[]string{models.RoleAdmin, models.RoleCertifier}, []int{userID}, data)
+	if err != nil {
+		log.Printf("Notification of passport %s creation failed: %v", passport.PassportID, err)
+	}
+}
+
+// NotifyPassportRecycled tells the passport's creator that its recycling
+// record changed
+func NotifyPassportRecycled(passport *db.AluminiumPassport, userID int, username string) {
+	var createdBy sql.NullInt64
+	err := db.DB.QueryRow(`SELECT created_by FROM aluminium_passports WHERE passport_id = $1`, passport.PassportID).Scan(&createdBy)
+	if err != nil || !createdBy.Valid || int(createdBy.Int64) == userID {
+		return
+	}
+
+	data := passportNotificationData(passport, username)
+	if err := NewNotificationService(db.DB).Notify(models.EventPassportRecycled, []int{int(createdBy.Int64)}, data); err != nil {
+		log.Printf("Notification of passport %s recycling failed: %v", passport.PassportID, err)
+	}
+}
+
+func passportNotificationData(passport *db.AluminiumPassport, username string) map[string]interface{} {
+	data := map[string]interface{}{
+		"passport_id":    passport.PassportID,
+		"manufacturer":   passport.Manufacturer,
+		"batch_id":       stringValue(passport.BatchID),
+		"times_recycled": passport.TimesRecycled,
+		"username":       username,
+	}
+	if passport.RecycledContentPercent != nil {
+		data["recycled_content_percent"] = *passport.RecycledContentPercent
+	}
+	return data
+}
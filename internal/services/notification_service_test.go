@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/models"
+)
+
+// testChannel records what it is asked to send and fails with err
+type testChannel struct {
+	err  error
+	sent []int
+}
+
+func (tc *testChannel) Name() string { return models.ChannelEmail }
+
+func (tc *testChannel) Accepts(recipient *NotificationRecipient) bool {
+	return recipient.Email != nil
+}
+
+func (tc *testChannel) Send(recipient *NotificationRecipient, notification *models.Notification) error {
+	tc.sent = append(tc.sent, notification.ID)
+	return tc.err
+}
+
+// withTestChannel registers channel as the only delivery channel for the
+// rest of the test
+func withTestChannel(t *testing.T, channel NotificationChannel) {
+	t.Helper()
+	notificationChannelsMu.Lock()
+	previous := notificationChannels
+	notificationChannels = map[string]NotificationChannel{}
+	notificationChannelsMu.Unlock()
+	RegisterNotificationChannel(channel)
+	t.Cleanup(func() {
+		notificationChannelsMu.Lock()
+		notificationChannels = previous
+		notificationChannelsMu.Unlock()
+	})
+}
+
+func TestRenderNotification(t *testing.T) {
+	data := map[string]interface{}{
+		"request_id": 4, "request_type": "user_role_change", "title": "Promote user 5", "description": "",
+		"expires_at": "2025-03-01T00:00:00Z", "reason": "", "passport_id": "AP-1", "manufacturer": "Example Smelter",
+		"batch_id": "B-1", "times_recycled": 2, "username": "owner",
+	}
+	for _, event := range models.NotificationEvents() {
+		subject, body, err := renderNotification(event, data)
+		if err != nil || subject == "" || body == "" || strings.Contains(subject+body, "<no value>") {
+			t.Errorf("%s: rendered %q, %q, %v", event, subject, body, err)
+		}
+	}
+
+	data["reason"] = "Duplicate request"
+	_, body, _ := renderNotification(models.EventApprovalRejected, data)
+	if want := "Your user_role_change request #4 was rejected.\n\nReason: Duplicate request"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	if _, _, err := renderNotification("passport_deleted", data); err == nil {
+		t.Errorf("rendered an unknown event")
+	}
+}
+
+func TestNotifyFollowsPreferences(t *testing.T) {
+	withTestChannel(t, &testChannel{})
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM users`, []string{"id", "username", "email"},
+		[]interface{}{int64(5), "muted", "muted@example.com"},
+		[]interface{}{int64(6), "email-only", "email-only@example.com"},
+		[]interface{}{int64(7), "no-email", nil})
+	fake.OnQuery(`FROM notification_preferences`, []string{"user_id", "channel"},
+		[]interface{}{int64(5), models.ChannelInApp},
+		[]interface{}{int64(5), models.ChannelEmail},
+		[]interface{}{int64(6), models.ChannelInApp})
+	ids := int64(0)
+	fake.OnQueryFunc(`INSERT INTO notifications`, func(args []interface{}) ([]string, [][]interface{}, error) {
+		ids++
+		return []string{"id"}, [][]interface{}{{ids}}, nil
+	})
+
+	err := NewNotificationService(fake.DB).Notify(models.EventPassportRecycled, []int{5, 6, 7},
+		map[string]interface{}{"passport_id": "AP-1", "username": "recycler", "times_recycled": 1})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var stored [][]interface{}
+	for _, s := range fake.Statements(`INSERT INTO notifications`) {
+		stored = append(stored, []interface{}{s.Args[0], s.Args[5]})
+	}
+	if want := [][]interface{}{{int64(6), false}, {int64(7), true}}; !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored (user, in_app) = %v, want %v", stored, want)
+	}
+	var deliveries [][]interface{}
+	for _, s := range fake.Statements(`INSERT INTO notification_deliveries`) {
+		deliveries = append(deliveries, s.Args)
+	}
+	if want := [][]interface{}{{int64(1), models.ChannelEmail}}; !reflect.DeepEqual(deliveries, want) {
+		t.Errorf("deliveries = %v, want %v", deliveries, want)
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		sent     int
+		update   string
+		args     []interface{}
+	}{
+		{name: "sent", attempts: 1, sent: 1, update: `SET status = 'sent'`, args: []interface{}{int64(9)}},
+		{name: "retried", err: errors.New("connection refused"), attempts: 3, update: `SET next_attempt_at`,
+			args: []interface{}{int64(9), int64(240), "connection refused"}},
+		{name: "given up", err: errors.New("connection refused"), attempts: 5, update: `SET status = 'failed'`,
+			args: []interface{}{int64(9), "connection refused"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := &testChannel{err: tt.err}
+			withTestChannel(t, channel)
+			fake := newTestDB(t)
+			claimed := false
+			fake.OnQueryFunc(`RETURNING id, channel, attempts, notification_id`, func(args []interface{}) ([]string, [][]interface{}, error) {
+				columns := []string{"id", "channel", "attempts", "notification_id"}
+				if claimed {
+					return columns, nil, nil
+				}
+				claimed = true
+				return columns, [][]interface{}{{int64(9), models.ChannelEmail, int64(tt.attempts), int64(4)}}, nil
+			})
+			fake.OnQuery(`FROM notifications n JOIN users u`,
+				[]string{"user_id", "event", "subject", "body", "data", "created_at", "username", "email"},
+				[]interface{}{int64(5), models.EventPassportCreated, "Passport AP-1 created", "body", nil, time.Now(), "owner", "owner@example.com"})
+
+			sent, err := NewNotificationService(fake.DB).DeliverDue(context.Background(), 5)
+			if err != nil {
+				t.Fatalf("DeliverDue: %v", err)
+			}
+			if sent != tt.sent {
+				t.Errorf("sent %d, want %d", sent, tt.sent)
+			}
+			if !reflect.DeepEqual(channel.sent, []int{4}) {
+				t.Errorf("channel sent %v, want notification 4", channel.sent)
+			}
+			updates := fake.Statements(tt.update)
+			if len(updates) != 1 || !reflect.DeepEqual(updates[0].Args, tt.args) {
+				t.Errorf("updates = %+v, want one with %v", updates, tt.args)
+			}
+		})
+	}
+}
+
+func TestSetNotificationPreferencesRejectsUnknownValues(t *testing.T) {
+	withTestChannel(t, &testChannel{})
+	fake := newTestDB(t)
+	service := NewNotificationService(fake.DB)
+
+	for _, preference := range []models.NotificationPreference{
+		{Event: "passport_deleted", Channel: models.ChannelInApp},
+		{Event: models.EventPassportCreated, Channel: "sms"},
+	} {
+		if err := service.SetNotificationPreferences(5, []models.NotificationPreference{preference}); !errors.Is(err, ErrInvalidPreference) {
+			t.Errorf("SetNotificationPreferences(%+v) error = %v, want %v", preference, err, ErrInvalidPreference)
+		}
+	}
+	if stored := fake.Statements(`INSERT INTO notification_preferences`); len(stored) != 0 {
+		t.Errorf("stored invalid preferences")
+	}
+}
+
+func TestNotificationPreferencesDefaultToEnabled(t *testing.T) {
+	withTestChannel(t, &testChannel{})
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM notification_preferences`, []string{"event", "channel", "enabled"},
+		[]interface{}{models.EventPassportCreated, models.ChannelEmail, false})
+
+	preferences, err := NewNotificationService(fake.DB).NotificationPreferences(5)
+	if err != nil {
+		t.Fatalf("NotificationPreferences: %v", err)
+	}
+	if want := len(models.NotificationEvents()) * len(models.NotificationChannels()); len(preferences) != want {
+		t.Fatalf("got %d preferences, want %d", len(preferences), want)
+	}
+	for _, preference := range preferences {
+		disabled := preference.Event == models.EventPassportCreated && preference.Channel == models.ChannelEmail
+		if preference.Enabled == disabled {
+			t.Errorf("%s/%s enabled = %v", preference.Event, preference.Channel, preference.Enabled)
+		}
+	}
+}
+
+func TestWebhookChannelSend(t *testing.T) {
+	var received map[string]interface{}
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	channel := &WebhookChannel{URL: server.URL, Client: server.Client()}
+	notification := &models.Notification{ID: 4, Event: models.EventPassportCreated, Subject: "Passport AP-1 created"}
+	if err := channel.Send(&NotificationRecipient{UserID: 5, Username: "owner"}, notification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if received["event"] != models.EventPassportCreated || received["username"] != "owner" || received["id"] != 4.0 {
+		t.Errorf("payload = %v", received)
+	}
+
+	status = http.StatusBadGateway
+	if err := channel.Send(&NotificationRecipient{UserID: 5}, notification); err == nil {
+		t.Errorf("Send succeeded on a %d response", status)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"aluminium-passport/internal/models"
+)
+
+// notificationTemplate renders the subject and body of one event from the
+// event's data
+type notificationTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newNotificationTemplate(event, subject, body string) notificationTemplate {
+	return notificationTemplate{
+		subject: template.Must(template.New(event + ".subject").Parse(subject)),
+		body:    template.Must(template.New(event + ".body").Parse(body)),
+	}
+}
+
+var notificationTemplates = map[string]notificationTemplate{
+	models.EventApprovalRequested: newNotificationTemplate(models.EventApprovalRequested,
+		`Approval needed: {{.title}}`,
+		`A {{.request_type}} request needs your vote.
+
+{{.title}}
+{{.description}}
+
+Request #{{.request_id}} expires at {{.expires_at}}.`),
+
+	models.EventApprovalApproved: newNotificationTemplate(models.EventApprovalApproved,
+		`Approved: {{.title}}`,
+		`Your {{.request_type}} request #{{.request_id}} was approved.{{if .reason}}
+
+Reason: {{.reason}}{{end}}`),
+
+	models.EventApprovalRejected: newNotificationTemplate(models.EventApprovalRejected,
+		`Rejected: {{.title}}`,
+		`Your {{.request_type}} request #{{.request_id}} was rejected.{{if .reason}}
+
+Reason: {{.reason}}{{end}}`),
+
+	models.EventApprovalExpired: newNotificationTemplate(models.EventApprovalExpired,
+		`Expired: {{.title}}`,
+		`Your {{.request_type}} request #{{.request_id}} expired at {{.expires_at}} before it was decided. An admin can re-open it.`),
+
+	models.EventPassportCreated: newNotificationTemplate(models.EventPassportCreated,
+		`Passport {{.passport_id}} created`,
+		`Passport {{.passport_id}} was created by {{.username}}.
+
+Manufacturer: {{.manufacturer}}
+Batch: {{.batch_id}}`),
+
+	models.EventPassportRecycled: newNotificationTemplate(models.EventPassportRecycled,
+		`Passport {{.passport_id}} recycled`,
+		`{{.username}} recorded recycling of passport {{.passport_id}}.
+
+{{if .recycled_content_percent}}Recycled content: {{.recycled_content_percent}}%
+{{end}}Times recycled: {{.times_recycled}}`),
+}
+
+// renderNotification renders the subject and body of an event
+func renderNotification(event string, data map[string]interface{}) (string, string, error) {
+	tmpl, ok := notificationTemplates[event]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification event %q", event)
+	}
+
+	var subject, body strings.Builder
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s subject: %w", event, err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s body: %w", event, err)
+	}
+	return subject.String(), body.String(), nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrOrganisationNotFound  = errors.New("organisation not found")
+	ErrOrganisationExists    = errors.New("organisation already exists")
+	ErrInvalidOrganisation   = errors.New("invalid organisation")
+	ErrInvalidPassportGrant  = errors.New("invalid passport grant")
+	ErrPassportGrantNotFound = errors.New("passport grant not found")
+	ErrUserNotFound          = errors.New("user not found")
+)
+
+// OrganisationService manages organisations, their members and the grants
+// that share passports between them
+type OrganisationService struct {
+	db *sql.DB
+}
+
+func NewOrganisationService(db *sql.DB) *OrganisationService {
+	return &OrganisationService{db: db}
+}
+
+const organisationColumns = `id, slug, name, is_active, created_by, created_at, updated_at`
+
+func scanOrganisation(row rowScanner) (*models.Organisation, error) {
+	org := &models.Organisation{}
+	err := row.Scan(&org.ID, &org.Slug, &org.Name, &org.IsActive, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt)
+	return org, err
+}
+
+// ListOrganisations returns every organisation by name
+func (oss *OrganisationService) ListOrganisations() ([]*models.Organisation, error) {
+	rows, err := oss.db.Query(`SELECT ` + organisationColumns + ` FROM organisations ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organisations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []*models.Organisation{}
+	for rows.Next() {
+		org, err := scanOrganisation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organisation: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// GetOrganisation returns an organisation by ID
+func (oss *OrganisationService) GetOrganisation(id int) (*models.Organisation, error) {
+	org, err := scanOrganisation(oss.db.QueryRow(`SELECT `+organisationColumns+` FROM organisations WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganisationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organisation: %w", err)
+	}
+	return org, nil
+}
+
+// CreateOrganisation adds an organisation. Its slug, derived from the name,
+// names the organisation's did:web.
+func (oss *OrganisationService) CreateOrganisation(name string, createdBy int) (*models.Organisation, error) {
+	name = strings.TrimSpace(name)
+	slug := OrganisationSlug(name)
+	if slug == "" {
+		return nil, fmt.Errorf("%w: name must contain letters or digits", ErrInvalidOrganisation)
+	}
+
+	org, err := scanOrganisation(oss.db.QueryRow(`
+		INSERT INTO organisations (slug, name, created_by) VALUES ($1, $2, $3)
+		ON CONFLICT (slug) DO NOTHING
+		RETURNING `+organisationColumns, slug, name, createdBy))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrOrganisationExists, slug)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organisation: %w", err)
+	}
+	return org, nil
+}
+
+// CreateOrganisationTx adds a new organisation named companyName and returns
+// its ID, or nil for an empty name. Company names are chosen by whoever
+// registers them, so an existing organisation is never reused: a slug that
+// is taken gets a numeric suffix instead.
+func (oss *OrganisationService) CreateOrganisationTx(tx *sql.Tx, companyName string) (*int, error) {
+	name := strings.TrimSpace(companyName)
+	base := OrganisationSlug(name)
+	if base == "" {
+		return nil, nil
+	}
+
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		var id int
+		err := tx.QueryRow(`
+			INSERT INTO organisations (slug, name) VALUES ($1, $2)
+			ON CONFLICT (slug) DO NOTHING
+			RETURNING id`, slug, name).Scan(&id)
+		if err == nil {
+			return &id, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to create organisation %s: %w", slug, err)
+		}
+	}
+}
+
+// AssignUser moves a user into an organisation, or out of any when
+// organisationID is nil. The change applies from the user's next token.
+func (oss *OrganisationService) AssignUser(userID int, organisationID *int) error {
+	if organisationID != nil {
+		if _, err := oss.GetOrganisation(*organisationID); err != nil {
+			return err
+		}
+	}
+	result, err := oss.db.Exec(`UPDATE users SET organisation_id = $1 WHERE id = $2`, organisationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to assign organisation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+const passportGrantSelect = `
+	SELECT g.id, g.passport_id, g.organisation_id, o.name, g.access, g.sections,
+	       g.granted_by, g.created_at, g.expires_at, g.revoked_at
+	FROM passport_grants g
+	JOIN organisations o ON o.id = g.organisation_id`
+
+func scanPassportGrant(row rowScanner) (*models.PassportGrant, error) {
+	grant := &models.PassportGrant{}
+	err := row.Scan(&grant.ID, &grant.PassportID, &grant.OrganisationID, &grant.OrganisationName, &grant.Access,
+		pq.Array(&grant.Sections), &grant.GrantedBy, &grant.CreatedAt, &grant.ExpiresAt, &grant.RevokedAt)
+	return grant, err
+}
+
+// ListPassportGrants returns the grants of a passport, newest first,
+// including revoked and expired ones
+func (oss *OrganisationService) ListPassportGrants(passportID string) ([]*models.PassportGrant, error) {
+	rows, err := oss.db.Query(passportGrantSelect+` WHERE g.passport_id = $1 ORDER BY g.created_at DESC`, passportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passport grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []*models.PassportGrant{}
+	for rows.Next() {
+		grant, err := scanPassportGrant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan passport grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// GrantPassport shares a passport owned by ownerID, which is nil for
+// passports without an organisation, with another organisation
+func (oss *OrganisationService) GrantPassport(passportID string, ownerID *int, req *models.PassportGrantRequest, grantedBy int) (*models.PassportGrant, error) {
+	switch req.Access {
+	case models.GrantAccessRead:
+		if len(req.Sections) > 0 {
+			return nil, fmt.Errorf("%w: read grants cover the whole passport and take no sections", ErrInvalidPassportGrant)
+		}
+	case models.GrantAccessWrite:
+		if len(req.Sections) == 0 {
+			return nil, fmt.Errorf("%w: write grants need at least one section", ErrInvalidPassportGrant)
+		}
+		for _, section := range req.Sections {
+			if !models.IsValidPassportSection(section) {
+				return nil, fmt.Errorf("%w: unknown section %q", ErrInvalidPassportGrant, section)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: access must be %s or %s", ErrInvalidPassportGrant, models.GrantAccessRead, models.GrantAccessWrite)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidPassportGrant)
+	}
+	if ownerID != nil && *ownerID == req.OrganisationID {
+		return nil, fmt.Errorf("%w: the passport already belongs to this organisation", ErrInvalidPassportGrant)
+	}
+
+	org, err := oss.GetOrganisation(req.OrganisationID)
+	if err != nil {
+		return nil, err
+	}
+	if !org.IsActive {
+		return nil, fmt.Errorf("%w: organisation %s is not active", ErrInvalidPassportGrant, org.Slug)
+	}
+
+	sections := req.Sections
+	if sections == nil {
+		sections = []string{}
+	}
+	grant := &models.PassportGrant{
+		PassportID:       passportID,
+		OrganisationID:   org.ID,
+		OrganisationName: org.Name,
+		Access:           req.Access,
+		Sections:         sections,
+		GrantedBy:        &grantedBy,
+		ExpiresAt:        req.ExpiresAt,
+	}
+	err = oss.db.QueryRow(`
+		INSERT INTO passport_grants (passport_id, organisation_id, access, sections, granted_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		passportID, org.ID, req.Access, pq.Array(sections), grantedBy, req.ExpiresAt,
+	).Scan(&grant.ID, &grant.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create passport grant: %w", err)
+	}
+	return grant, nil
+}
+
+// RevokePassportGrant ends an active grant of a passport
+func (oss *OrganisationService) RevokePassportGrant(passportID string, grantID, revokedBy int) (*models.PassportGrant, error) {
+	result, err := oss.db.Exec(`
+		UPDATE passport_grants SET revoked_at = NOW(), revoked_by = $1
+		WHERE id = $2 AND passport_id = $3 AND revoked_at IS NULL`, revokedBy, grantID, passportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke passport grant: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrPassportGrantNotFound
+	}
+
+	grant, err := scanPassportGrant(oss.db.QueryRow(passportGrantSelect+` WHERE g.id = $1`, grantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passport grant: %w", err)
+	}
+	return grant, nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+)
+
+// ErrPassportNotAnchorable is returned when a passport lacks a field the
+// contract requires
+var ErrPassportNotAnchorable = errors.New("passport cannot be registered on chain")
+
+// Minimal ABI with only the passport creation method of AluminiumPassport
+const passportRegistryABI = `[
+  {"inputs":[{"internalType":"string","name":"passportId","type":"string"},{"internalType":"string","name":"origin","type":"string"},{"internalType":"string","name":"manufacturer","type":"string"},{"internalType":"string","name":"alloyComposition","type":"string"},{"internalType":"string","name":"certifier","type":"string"},{"internalType":"string","name":"ipfsHash","type":"string"},{"internalType":"uint256","name":"esgScore","type":"uint256"},{"internalType":"uint256","name":"recycledContent","type":"uint256"}],"name":"createPassport","outputs":[],"stateMutability":"nonpayable","type":"function"}
+]`
+
+// AnchorPassportOnChain creates the passport on the passport contract and
+// returns the transaction hash. The configured key needs
+// PRODUCT_MANUFACTURER_ROLE. Scores are rounded to whole numbers.
+func AnchorPassportOnChain(cfg *config.Config, passport *db.AluminiumPassport) (string, error) {
+	if cfg.ContractAddress == "" || cfg.PrivateKey == "" {
+		return "", fmt.Errorf("CONTRACT_ADDRESS and PRIVATE_KEY are required")
+	}
+
+	ipfsHash := strings.TrimPrefix(stringValue(passport.IPFSHash), "ipfs://")
+	required := map[string]string{
+		"origin":            passport.Origin,
+		"manufacturer":      passport.Manufacturer,
+		"alloy_composition": stringValue(passport.AlloyComposition),
+		"certifier":         stringValue(passport.Certifier),
+		"ipfs_hash":         ipfsHash,
+	}
+	for _, field := range []string{"origin", "manufacturer", "alloy_composition", "certifier", "ipfs_hash"} {
+		if required[field] == "" {
+			return "", fmt.Errorf("%w: %s is required", ErrPassportNotAnchorable, field)
+		}
+	}
+
+	return transactPassportContract(cfg, passportRegistryABI, "createPassport",
+		passport.PassportID, passport.Origin, passport.Manufacturer, required["alloy_composition"],
+		required["certifier"], ipfsHash, roundedUint(passport.ESGScore), roundedUint(passport.RecycledContentPercent))
+}
+
+func roundedUint(value *float64) *big.Int {
+	if value == nil || *value < 0 {
+		return big.NewInt(0)
+	}
+	return big.NewInt(int64(math.Round(*value)))
+}
@@ -0,0 +1,170 @@
+package services
+
+// Aluminium passport credential vocabulary. The context is served verbatim at
+// PassportContextURL and must stay backwards compatible once published:
+// add new terms, never rename or retype existing ones.
+const PassportContextURL = "https://aluminium-passport.com/contexts/aluminium-passport/v1"
+
+// PassportContextDocument is the JSON-LD @context for AluminiumPassportCredential
+const PassportContextDocument = `{
+  "@context": {
+    "@version": 1.1,
+    "@protected": true,
+    "alu": "https://aluminium-passport.com/vocab#",
+    "xsd": "http://www.w3.org/2001/XMLSchema#",
+    "AluminiumPassportCredential": "alu:AluminiumPassportCredential",
+    "AluminiumPassport": "alu:AluminiumPassport",
+    "mining": {
+      "@id": "alu:mining"
+    },
+    "refining": {
+      "@id": "alu:refining"
+    },
+    "smelting": {
+      "@id": "alu:smelting"
+    },
+    "environmentalImpact": {
+      "@id": "alu:environmentalImpact"
+    },
+    "logistics": {
+      "@id": "alu:logistics"
+    },
+    "recycling": {
+      "@id": "alu:recycling"
+    },
+    "certification": {
+      "@id": "alu:certification"
+    },
+    "esg": {
+      "@id": "alu:esg"
+    },
+    "anchoring": {
+      "@id": "alu:anchoring"
+    },
+    "passportId": "alu:passportId",
+    "batchId": "alu:batchId",
+    "manufacturer": "alu:manufacturer",
+    "origin": "alu:origin",
+    "bauxiteSource": "alu:bauxiteSource",
+    "alloyComposition": "alu:alloyComposition",
+    "traceMetals": "alu:traceMetals",
+    "status": "alu:status",
+    "mineOperator": "alu:mineOperator",
+    "mineLocation": "alu:mineLocation",
+    "extractionMethod": "alu:extractionMethod",
+    "refineryLocation": "alu:refineryLocation",
+    "refinerId": "alu:refinerId",
+    "refiningMethod": "alu:refiningMethod",
+    "smeltingLocation": "alu:smeltingLocation",
+    "energySource": "alu:energySource",
+    "processType": "alu:processType",
+    "manufacturedProduct": "alu:manufacturedProduct",
+    "transportMode": "alu:transportMode",
+    "logisticsPartnerId": "alu:logisticsPartnerId",
+    "recyclerId": "alu:recyclerId",
+    "recyclingMethod": "alu:recyclingMethod",
+    "certificationAgency": "alu:certificationAgency",
+    "certifier": "alu:certifier",
+    "complianceStandards": "alu:complianceStandards",
+    "ipfsHash": "alu:ipfsHash",
+    "blockchainTxHash": "alu:blockchainTxHash",
+    "contractAddress": "alu:contractAddress",
+    "extractionDate": {
+      "@id": "alu:extractionDate",
+      "@type": "xsd:date"
+    },
+    "refiningDate": {
+      "@id": "alu:refiningDate",
+      "@type": "xsd:date"
+    },
+    "manufacturingDate": {
+      "@id": "alu:manufacturingDate",
+      "@type": "xsd:date"
+    },
+    "shipmentDate": {
+      "@id": "alu:shipmentDate",
+      "@type": "xsd:date"
+    },
+    "recyclingDate": {
+      "@id": "alu:recyclingDate",
+      "@type": "xsd:date"
+    },
+    "lastRecyclingDate": {
+      "@id": "alu:lastRecyclingDate",
+      "@type": "xsd:date"
+    },
+    "certificationDate": {
+      "@id": "alu:certificationDate",
+      "@type": "xsd:date"
+    },
+    "certificationExpiry": {
+      "@id": "alu:certificationExpiry",
+      "@type": "xsd:date"
+    },
+    "productWeight": {
+      "@id": "alu:productWeight",
+      "@type": "xsd:decimal"
+    },
+    "energyUsed": {
+      "@id": "alu:energyUsed",
+      "@type": "xsd:decimal"
+    },
+    "waterUsed": {
+      "@id": "alu:waterUsed",
+      "@type": "xsd:decimal"
+    },
+    "wasteGenerated": {
+      "@id": "alu:wasteGenerated",
+      "@type": "xsd:decimal"
+    },
+    "carbonEmissionsPerKg": {
+      "@id": "alu:carbonEmissionsPerKg",
+      "@type": "xsd:decimal"
+    },
+    "co2Footprint": {
+      "@id": "alu:co2Footprint",
+      "@type": "xsd:decimal"
+    },
+    "manufacturingEmissions": {
+      "@id": "alu:manufacturingEmissions",
+      "@type": "xsd:decimal"
+    },
+    "distanceTravelled": {
+      "@id": "alu:distanceTravelled",
+      "@type": "xsd:decimal"
+    },
+    "recycledContentPercent": {
+      "@id": "alu:recycledContentPercent",
+      "@type": "xsd:decimal"
+    },
+    "esgScore": {
+      "@id": "alu:esgScore",
+      "@type": "xsd:decimal"
+    },
+    "environmentalScore": {
+      "@id": "alu:environmentalScore",
+      "@type": "xsd:decimal"
+    },
+    "socialScore": {
+      "@id": "alu:socialScore",
+      "@type": "xsd:decimal"
+    },
+    "governanceScore": {
+      "@id": "alu:governanceScore",
+      "@type": "xsd:decimal"
+    },
+    "timesRecycled": {
+      "@id": "alu:timesRecycled",
+      "@type": "xsd:integer"
+    },
+    "blockNumber": {
+      "@id": "alu:blockNumber",
+      "@type": "xsd:integer"
+    },
+    "esgLastUpdated": {
+      "@id": "alu:esgLastUpdated",
+      "@type": "xsd:dateTime"
+    }
+  }
+}
+`
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+)
+
+const (
+	credentialsContextV2 = "https://www.w3.org/ns/credentials/v2"
+	credentialJWTType    = "vc+jwt"
+	credentialJWTContent = "vc"
+)
+
+var (
+	ErrPassportNotFound = errors.New("passport not found")
+	ErrPassportInactive = errors.New("passport has been deactivated")
+)
+
+// GetPassportRecord loads a full passport row by its passport ID
+func GetPassportRecord(passportID string) (*db.AluminiumPassport, error) {
+	passport, err := ScanPassport(db.DB.QueryRow(
+		`SELECT `+PassportColumns+` FROM aluminium_passports WHERE passport_id = $1`, passportID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrPassportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return passport, nil
+}
+
+// BuildPassportCredential maps a passport onto an unsigned VC 2.0
+// AluminiumPassportCredential. The issuer is filled in when it is signed.
+func BuildPassportCredential(passport *db.AluminiumPassport) *models.VerifiableClaim {
+	claim := &models.VerifiableClaim{
+		Context:           []string{credentialsContextV2, PassportContextURL},
+		ID:                newCredentialID(),
+		Type:              []string{"VerifiableCredential", "AluminiumPassportCredential"},
+		ValidFrom:         time.Now().UTC().Format(time.RFC3339),
+		CredentialSubject: NewPassportSubject(passport),
+	}
+
+	if passport.CertificationExpiry != nil {
+		claim.ValidUntil = passport.CertificationExpiry.UTC().Format(time.RFC3339)
+	}
+
+	return claim
+}
+
+// NewPassportSubject maps the passport columns onto the credential vocabulary
+func NewPassportSubject(p *db.AluminiumPassport) *models.PassportSubject {
+	subject := &models.PassportSubject{
+		ID:               "urn:aluminium-passport:" + p.PassportID,
+		Type:             "AluminiumPassport",
+		PassportID:       p.PassportID,
+		BatchID:          stringValue(p.BatchID),
+		Manufacturer:     p.Manufacturer,
+		Origin:           p.Origin,
+		BauxiteSource:    stringValue(p.BauxiteSource),
+		AlloyComposition: stringValue(p.AlloyComposition),
+		Status:           p.Status,
+	}
+
+	// Trace metals have no column of their own and are kept in metadata
+	if p.Metadata != nil {
+		if traceMetals, ok := (*p.Metadata)["trace_metals"].(string); ok {
+			subject.TraceMetals = traceMetals
+		}
+	}
+
+	mining := &models.MiningSection{
+		MineOperator:     stringValue(p.MineOperator),
+		MineLocation:     stringValue(p.MineLocation),
+		ExtractionDate:   dateValue(p.DateOfExtraction),
+		ExtractionMethod: stringValue(p.ExtractionMethod),
+	}
+	if *mining != (models.MiningSection{}) {
+		subject.Mining = mining
+	}
+
+	refining := &models.RefiningSection{
+		RefineryLocation: stringValue(p.RefineryLocation),
+		RefinerID:        stringValue(p.RefinerID),
+		RefiningDate:     dateValue(p.RefiningDate),
+		RefiningMethod:   stringValue(p.RefiningMethod),
+	}
+	if *refining != (models.RefiningSection{}) {
+		subject.Refining = refining
+	}
+
+	smelting := &models.SmeltingSection{
+		SmeltingLocation:    stringValue(p.SmeltingLocation),
+		EnergySource:        stringValue(p.SmeltingEnergySource),
+		ProcessType:         stringValue(p.ProcessType),
+		ManufacturedProduct: stringValue(p.ManufacturedProduct),
+		ManufacturingDate:   dateValue(p.ManufacturingDate),
+		ProductWeight:       p.ProductWeight,
+		EnergyUsed:          p.EnergyUsed,
+		WaterUsed:           p.WaterUsed,
+		WasteGenerated:      p.WasteGenerated,
+	}
+	if *smelting != (models.SmeltingSection{}) {
+		subject.Smelting = smelting
+	}
+
+	environmental := &models.EnvironmentalSection{
+		CarbonEmissionsPerKg:   p.CarbonEmissionsPerKg,
+		CO2Footprint:           p.CO2Footprint,
+		ManufacturingEmissions: p.ManufacturingEmissions,
+	}
+	if *environmental != (models.EnvironmentalSection{}) {
+		subject.EnvironmentalImpact = environmental
+	}
+
+	logistics := &models.LogisticsSection{
+		TransportMode:      stringValue(p.TransportMode),
+		DistanceTravelled:  p.DistanceTravelled,
+		LogisticsPartnerID: stringValue(p.LogisticsPartnerID),
+		ShipmentDate:       dateValue(p.ShipmentDate),
+	}
+	if *logistics != (models.LogisticsSection{}) {
+		subject.Logistics = logistics
+	}
+
+	recycling := &models.RecyclingSection{
+		RecycledContentPercent: p.RecycledContentPercent,
+		RecyclingDate:          dateValue(p.RecyclingDate),
+		RecyclerID:             stringValue(p.RecyclerID),
+		RecyclingMethod:        stringValue(p.RecyclingMethod),
+		TimesRecycled:          p.TimesRecycled,
+		LastRecyclingDate:      dateValue(p.LastRecyclingDate),
+	}
+	if *recycling != (models.RecyclingSection{}) {
+		subject.Recycling = recycling
+	}
+
+	certification := &models.CertificationSection{
+		CertificationAgency: stringValue(p.CertificationAgency),
+		Certifier:           stringValue(p.Certifier),
+		ComplianceStandards: stringValue(p.ComplianceStandards),
+		CertificationDate:   dateValue(p.DateOfCertification),
+		CertificationExpiry: dateValue(p.CertificationExpiry),
+	}
+	if *certification != (models.CertificationSection{}) {
+		subject.Certification = certification
+	}
+
+	esg := &models.ESGSection{
+		ESGScore:           p.ESGScore,
+		EnvironmentalScore: p.EnvironmentalScore,
+		SocialScore:        p.SocialScore,
+		GovernanceScore:    p.GovernanceScore,
+	}
+	if p.ESGLastUpdated != nil {
+		esg.LastUpdated = p.ESGLastUpdated.UTC().Format(time.RFC3339)
+	}
+	if *esg != (models.ESGSection{}) {
+		subject.ESG = esg
+	}
+
+	anchoring := &models.AnchoringSection{
+		IPFSHash:         stringValue(p.IPFSHash),
+		BlockchainTxHash: stringValue(p.BlockchainTxHash),
+		ContractAddress:  stringValue(p.ContractAddress),
+		BlockNumber:      p.BlockNumber,
+	}
+	if *anchoring != (models.AnchoringSection{}) {
+		subject.Anchoring = anchoring
+	}
+
+	return subject
+}
+
+// PassportIssuerKey picks the signing key of the organisation that owns
+// the passport, falling back to the platform key
+func PassportIssuerKey(passport *db.AluminiumPassport) (*IssuerKey, error) {
+	if passport.OrganisationID == nil {
+		return PlatformIssuerKey()
+	}
+	return OrganisationIssuerKey(*passport.OrganisationID)
+}
+
+// IssuePassportCredential builds and signs the Data Integrity secured
+// credential for a passport, allocating its status list entries
+func IssuePassportCredential(passport *db.AluminiumPassport) (*models.VerifiableClaim, error) {
+	claim, issuer, err := prepareIssuedCredential(passport)
+	if err != nil {
+		return nil, err
+	}
+	return SignVerifiableClaim(claim, issuer)
+}
+
+// IssuePassportCredentialJWT issues the passport credential as a VC-JOSE JWT
+func IssuePassportCredentialJWT(passport *db.AluminiumPassport) (string, error) {
+	claim, issuer, err := prepareIssuedCredential(passport)
+	if err != nil {
+		return "", err
+	}
+	return EncodeCredentialJWT(claim, issuer)
+}
+
+func prepareIssuedCredential(passport *db.AluminiumPassport) (*models.VerifiableClaim, *IssuerKey, error) {
+	if passport.Status == "inactive" {
+		return nil, nil, ErrPassportInactive
+	}
+
+	issuer, err := PassportIssuerKey(passport)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claim := BuildPassportCredential(passport)
+	status, err := NewStatusListService(db.DB).AllocateStatus(claim.ID, passport.PassportID, issuer.Controller)
+	if err != nil {
+		return nil, nil, err
+	}
+	claim.CredentialStatus = status
+
+	return claim, issuer, nil
+}
+
+// EncodeCredentialJWT secures a credential as a VC-JOSE JWT. The unsecured
+// credential is the JWT claims set and the header kid names the issuer's
+// verification method.
+func EncodeCredentialJWT(claim *models.VerifiableClaim, issuer *IssuerKey) (string, error) {
+	unsecured := *claim
+	unsecured.Proof = nil
+	unsecured.Issuer = issuer.Controller
+
+	payload, err := json.Marshal(&unsecured)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credential: %w", err)
+	}
+
+	header := signing.JWSHeader{
+		Kid: issuer.VerificationMethod,
+		Typ: credentialJWTType,
+		Cty: credentialJWTContent,
+	}
+	return signing.SignJWS(header, payload, issuer.PrivateKey)
+}
+
+// VerifyCredentialJWT resolves the key named in the JWT header, checks the
+// signature and returns the credential it secures
+func VerifyCredentialJWT(token string) (*models.VerifiableClaim, error) {
+	header, payload, err := signing.ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+	if header.Typ != credentialJWTType {
+		return nil, fmt.Errorf("%w: unexpected typ %q", signing.ErrInvalidJWS, header.Typ)
+	}
+
+	var claim models.VerifiableClaim
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return nil, fmt.Errorf("invalid credential payload: %w", err)
+	}
+
+	// JWT-VCs carry no proof creation time; validFrom is when we issued it
+	signedAt, err := time.Parse(time.RFC3339, claim.ValidFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validFrom: %w", err)
+	}
+
+	publicKey, err := resolveIssuerKey(claim.Issuer, header.Kid, signedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := signing.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, err := signing.VerifyJWS(token, key); err != nil {
+		return nil, err
+	}
+
+	if err := CheckCredentialStatus(&claim); err != nil {
+		return &claim, err
+	}
+	return &claim, nil
+}
+
+func dateValue(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func newCredentialID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/validation"
+)
+
+// PassportImportColumns are the columns accepted in batch uploads, in
+// template order. They match the fields of a passport create request.
+var PassportImportColumns = []string{
+	"passport_id", "batch_id", "manufacturer", "origin", "bauxite_source", "alloy_composition",
+	"mine_operator", "date_of_extraction", "extraction_method", "mine_location",
+	"refinery_location", "refiner_id", "refining_date", "refining_method",
+	"smelting_location", "smelting_energy_source", "process_type", "manufactured_product", "manufacturing_date",
+	"product_weight", "energy_used", "water_used", "waste_generated",
+	"carbon_emissions_per_kg", "co2_footprint", "manufacturing_emissions",
+	"transport_mode", "distance_travelled", "logistics_partner_id", "shipment_date",
+	"recycled_content_percent", "recycling_date", "recycler_id", "recycling_method", "times_recycled",
+	"certification_agency", "certifier", "compliance_standards", "date_of_certification", "certification_expiry",
+	"verifier_signature", "metadata",
+}
+
+// legacyImportColumns maps column names of the old batch template onto the
+// current schema
+var legacyImportColumns = map[string]string{
+	"bauxite_origin":  "origin",
+	"manufacturer_id": "manufacturer",
+}
+
+// metadataImportColumns have no column of their own and are kept in metadata
+var metadataImportColumns = []string{"trace_metals"}
+
+// passportFieldIndex maps db column names to AluminiumPassport fields
+var passportFieldIndex = func() map[string]int {
+	index := make(map[string]int)
+	t := reflect.TypeOf(db.AluminiumPassport{})
+	for i := 0; i < t.NumField(); i++ {
+		if column := t.Field(i).Tag.Get("db"); column != "" {
+			index[column] = i
+		}
+	}
+	return index
+}()
+
+// importColumnLookup resolves normalised header names to import columns
+var importColumnLookup = func() map[string]string {
+	lookup := make(map[string]string)
+	for _, column := range PassportImportColumns {
+		lookup[normaliseColumnName(column)] = column
+	}
+	for _, column := range metadataImportColumns {
+		lookup[normaliseColumnName(column)] = column
+	}
+	for legacy, column := range legacyImportColumns {
+		lookup[normaliseColumnName(legacy)] = column
+	}
+	return lookup
+}()
+
+// normaliseColumnName ignores case, spaces and punctuation so that
+// "Passport ID", "passportId" and "passport_id" name the same column
+func normaliseColumnName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// PassportFromRecord builds a passport from the text fields of an uploaded
+// row and checks it against the passport validation rules. Every invalid
+// field is reported; the passport is nil if any field failed. Errors carry
+// the field name as written in the upload but no file or row.
+func PassportFromRecord(fields map[string]string) (*db.AluminiumPassport, []models.BatchRowError) {
+	passport := &db.AluminiumPassport{}
+	value := reflect.ValueOf(passport).Elem()
+	metadata := db.JSONMap{}
+
+	var errs []models.BatchRowError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, models.BatchRowError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]string)
+	for _, name := range sortedFieldNames(fields) {
+		text := strings.TrimSpace(fields[name])
+		column, ok := importColumnLookup[normaliseColumnName(name)]
+		if !ok {
+			fail(name, "unknown column")
+			continue
+		}
+		if previous, ok := seen[column]; ok {
+			fail(name, "duplicates column %s", previous)
+			continue
+		}
+		seen[column] = name
+		if text == "" {
+			continue
+		}
+
+		if isMetadataImportColumn(column) {
+			metadata[column] = text
+			continue
+		}
+
+		if column == "metadata" {
+			var object map[string]interface{}
+			if err := json.Unmarshal([]byte(text), &object); err != nil || object == nil {
+				fail(name, "must be a JSON object")
+				continue
+			}
+			for k, v := range object {
+				if _, ok := metadata[k]; !ok {
+					metadata[k] = v
+				}
+			}
+			continue
+		}
+
+		if err := setPassportField(value.Field(passportFieldIndex[column]), text); err != nil {
+			fail(name, "%v", err)
+		}
+	}
+
+	// Fields that failed to parse are left unset and not checked again
+	for _, fe := range validation.ValidatePassport(passport) {
+		name := fe.Field
+		if header, ok := seen[fe.Field]; ok {
+			name = header
+		}
+		fail(name, "%s", fe.Message)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if len(metadata) > 0 {
+		passport.Metadata = &metadata
+	}
+	return passport, nil
+}
+
+// setPassportField parses text into a passport field according to its type
+func setPassportField(field reflect.Value, text string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(text)
+	case *string:
+		field.Set(reflect.ValueOf(&text))
+	case int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		field.SetInt(int64(n))
+	case *float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.Set(reflect.ValueOf(&f))
+	case *time.Time:
+		date, err := time.Parse("2006-01-02", text)
+		if err != nil {
+			return fmt.Errorf("must be a date (YYYY-MM-DD)")
+		}
+		field.Set(reflect.ValueOf(&date))
+	default:
+		return fmt.Errorf("cannot be imported")
+	}
+	return nil
+}
+
+func isMetadataImportColumn(column string) bool {
+	for _, c := range metadataImportColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedFieldNames(fields map[string]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"aluminium-passport/internal/models"
+)
+
+func TestPassportFromRecord(t *testing.T) {
+	passport, errs := PassportFromRecord(map[string]string{
+		"Passport ID":              "AP-1",
+		"manufacturer_id":          "Example Smelter",
+		"bauxite_origin":           "AU",
+		"productWeight":            " 12.5 ",
+		"times_recycled":           "2",
+		"date_of_extraction":       "2024-01-10",
+		"manufacturing_date":       "2024-03-01",
+		"trace_metals":             "Fe 0.1%",
+		"metadata":                 `{"line": 3, "trace_metals": "ignored"}`,
+		"recycled_content_percent": "",
+	})
+	if len(errs) > 0 {
+		t.Fatalf("PassportFromRecord errors = %+v", errs)
+	}
+
+	if passport.PassportID != "AP-1" || passport.Manufacturer != "Example Smelter" || passport.Origin != "AU" {
+		t.Errorf("passport = %s/%s/%s, want AP-1/Example Smelter/AU", passport.PassportID, passport.Manufacturer, passport.Origin)
+	}
+	if passport.ProductWeight == nil || *passport.ProductWeight != 12.5 {
+		t.Errorf("ProductWeight = %v, want 12.5", passport.ProductWeight)
+	}
+	if passport.TimesRecycled != 2 {
+		t.Errorf("TimesRecycled = %d, want 2", passport.TimesRecycled)
+	}
+	if passport.DateOfExtraction == nil || passport.DateOfExtraction.Format("2006-01-02") != "2024-01-10" {
+		t.Errorf("DateOfExtraction = %v, want 2024-01-10", passport.DateOfExtraction)
+	}
+	if passport.RecycledContentPercent != nil {
+		t.Errorf("RecycledContentPercent = %v, want an empty cell left unset", *passport.RecycledContentPercent)
+	}
+	if passport.Metadata == nil || (*passport.Metadata)["trace_metals"] != "Fe 0.1%" || (*passport.Metadata)["line"] != float64(3) {
+		t.Errorf("Metadata = %v, want trace_metals from its column and line from metadata", passport.Metadata)
+	}
+}
+
+func TestPassportFromRecordReportsEveryField(t *testing.T) {
+	_, errs := PassportFromRecord(map[string]string{
+		"passport_id":        "AP-1",
+		"Passport_ID":        "AP-2",
+		"manufacturer":       "Example Smelter",
+		"origin":             "Australia",
+		"product_weight":     "heavy",
+		"times_recycled":     "1.5",
+		"refining_date":      "01/02/2024",
+		"date_of_extraction": "2024-02-01",
+		"manufacturing_date": "2024-01-01",
+		"metadata":           "[1, 2]",
+		"colour":             "silver",
+	})
+
+	got := map[string]bool{}
+	for _, fe := range errs {
+		if fe.Message == "" || fe.File != "" || fe.Row != 0 {
+			t.Errorf("error %+v, want a message and no location", fe)
+		}
+		got[fe.Field] = true
+	}
+	// Headers are read in sorted order, so passport_id repeats Passport_ID
+	want := map[string]bool{
+		"passport_id":        true,
+		"origin":             true,
+		"product_weight":     true,
+		"times_recycled":     true,
+		"refining_date":      true,
+		"manufacturing_date": true,
+		"metadata":           true,
+		"colour":             true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields with errors = %v, want %v (%+v)", got, want, errs)
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQueryFunc(`SELECT EXISTS(SELECT 1 FROM aluminium_passports`, func(args []interface{}) ([]string, [][]interface{}, error) {
+		return []string{"exists"}, [][]interface{}{{args[0] == "AP-TAKEN"}}, nil
+	})
+
+	row := func(number int, passportID string) models.BatchRecord {
+		return models.BatchRecord{File: "passports.csv", Row: number, Fields: map[string]string{
+			"passport_id": passportID, "manufacturer": "Example Smelter", "origin": "AU",
+		}}
+	}
+	invalid := row(5, "AP-4")
+	invalid.Fields["origin"] = "Atlantis"
+	fileErrors := []models.BatchRowError{{File: "notes.txt", Message: "unsupported file format"}}
+
+	result, err := NewBatchService(fake.DB).ValidateBatch(
+		[]models.BatchRecord{row(2, "AP-1"), row(3, "AP-1"), row(4, "AP-TAKEN"), invalid, row(6, "AP-5")},
+		fileErrors, models.RoleManufacturer)
+	if err != nil {
+		t.Fatalf("ValidateBatch: %v", err)
+	}
+
+	if result.Valid || result.TotalRecords != 5 || result.ValidRecords != 2 || result.InvalidRecords != 3 {
+		t.Errorf("result = %+v, want 2 of 5 rows valid", result)
+	}
+	want := []models.BatchRowError{
+		fileErrors[0],
+		{File: "passports.csv", Row: 3, PassportID: "AP-1", Field: "passport_id", Message: "duplicates passports.csv row 2"},
+		{File: "passports.csv", Row: 4, PassportID: "AP-TAKEN", Field: "passport_id", Message: "passport ID already exists"},
+		{File: "passports.csv", Row: 5, PassportID: "AP-4", Field: "origin", Message: "must be an ISO 3166-1 alpha-2 country code, such as AU"},
+	}
+	if !reflect.DeepEqual(result.Errors, want) {
+		t.Errorf("errors = %+v\nwant %+v", result.Errors, want)
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"aluminium-passport/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrRevisionNotFound = errors.New("passport revision not found")
+
+// PassportHistoryService reads the revisions the database records for every
+// change of a passport
+type PassportHistoryService struct {
+	db *sql.DB
+}
+
+func NewPassportHistoryService(db *sql.DB) *PassportHistoryService {
+	return &PassportHistoryService{db: db}
+}
+
+const passportRevisionSelect = `
+	SELECT r.passport_id, r.revision, r.change_type, r.changed_by, u.username, r.changed_at,
+	       r.fields, r.old_values, r.new_values
+	FROM passport_revisions r
+	LEFT JOIN users u ON u.id = r.changed_by`
+
+func scanPassportRevision(row rowScanner) (*models.PassportRevision, error) {
+	rev := &models.PassportRevision{}
+	err := row.Scan(&rev.PassportID, &rev.Revision, &rev.ChangeType, &rev.ChangedBy, &rev.ChangedByUsername,
+		&rev.ChangedAt, pq.Array(&rev.Fields), &rev.OldValues, &rev.NewValues)
+	return rev, err
+}
+
+// Revisions returns a page of the revisions of a passport, newest first,
+// and the total number of revisions
+func (hs *PassportHistoryService) Revisions(passportID string, limit, offset int) ([]*models.PassportRevision, int, error) {
+	var total int
+	if err := hs.db.QueryRow(`SELECT COUNT(*) FROM passport_revisions WHERE passport_id = $1`, passportID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count passport revisions: %w", err)
+	}
+
+	rows, err := hs.db.Query(passportRevisionSelect+`
+		WHERE r.passport_id = $1
+		ORDER BY r.revision DESC
+		LIMIT $2 OFFSET $3`, passportID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list passport revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.PassportRevision{}
+	for rows.Next() {
+		rev, err := scanPassportRevision(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan passport revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, total, rows.Err()
+}
+
+// Revision returns one revision of a passport. Revision 0 means the latest.
+func (hs *PassportHistoryService) Revision(passportID string, revision int) (*models.PassportRevision, error) {
+	query := passportRevisionSelect + ` WHERE r.passport_id = $1 AND r.revision = $2`
+	args := []interface{}{passportID, revision}
+	if revision == 0 {
+		query = passportRevisionSelect + ` WHERE r.passport_id = $1 ORDER BY r.revision DESC LIMIT 1`
+		args = args[:1]
+	}
+	rev, err := scanPassportRevision(hs.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passport revision: %w", err)
+	}
+	return rev, nil
+}
+
+// RevisionAt returns the revision of a passport that was current at a
+// time, or ErrRevisionNotFound if the passport did not exist yet
+func (hs *PassportHistoryService) RevisionAt(passportID string, at time.Time) (*models.PassportRevision, error) {
+	rev, err := scanPassportRevision(hs.db.QueryRow(passportRevisionSelect+`
+		WHERE r.passport_id = $1 AND r.changed_at <= $2
+		ORDER BY r.revision DESC LIMIT 1`, passportID, at))
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passport revision: %w", err)
+	}
+	return rev, nil
+}
+
+// Diff compares the snapshots of two revisions of a passport. Revision 0
+// means the latest. updated_at is not compared.
+func (hs *PassportHistoryService) Diff(passportID string, from, to int) (*models.PassportDiff, error) {
+	fromRev, err := hs.Revision(passportID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := hs.Revision(passportID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromSnapshot, err := hs.snapshot(passportID, fromRev.Revision)
+	if err != nil {
+		return nil, err
+	}
+	toSnapshot, err := hs.snapshot(passportID, toRev.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for field := range fromSnapshot {
+		fields[field] = true
+	}
+	for field := range toSnapshot {
+		fields[field] = true
+	}
+	delete(fields, "updated_at")
+
+	changes := []models.PassportFieldChange{}
+	for field := range fields {
+		if !reflect.DeepEqual(fromSnapshot[field], toSnapshot[field]) {
+			changes = append(changes, models.PassportFieldChange{
+				Field: field,
+				From:  fromSnapshot[field],
+				To:    toSnapshot[field],
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return &models.PassportDiff{
+		PassportID:    passportID,
+		FromRevision:  fromRev.Revision,
+		ToRevision:    toRev.Revision,
+		FromChangedAt: fromRev.ChangedAt,
+		ToChangedAt:   toRev.ChangedAt,
+		Changes:       changes,
+	}, nil
+}
+
+// snapshot returns the stored row of a revision by column name
+func (hs *PassportHistoryService) snapshot(passportID string, revision int) (map[string]interface{}, error) {
+	var raw []byte
+	err := hs.db.QueryRow(`SELECT snapshot FROM passport_revisions WHERE passport_id = $1 AND revision = $2`,
+		passportID, revision).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passport snapshot: %w", err)
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode passport snapshot: %w", err)
+	}
+	return snapshot, nil
+}
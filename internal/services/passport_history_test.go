@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+var revisionColumns = dbtest.Columns(`passport_id, revision, change_type, changed_by, username, changed_at,
+	fields, old_values, new_values`)
+
+// newHistoryDB installs a fake database holding three revisions of AP-1,
+// each with the given snapshot
+func newHistoryDB(t *testing.T, snapshots ...string) *dbtest.DB {
+	t.Helper()
+	fake := newTestDB(t)
+	changedAt := func(revision int64) time.Time {
+		return time.Date(2025, 3, int(revision), 9, 0, 0, 0, time.UTC)
+	}
+	revisionRow := func(revision int64) []interface{} {
+		changeType := models.RevisionUpdate
+		if revision == 1 {
+			changeType = models.RevisionCreate
+		}
+		return []interface{}{"AP-1", revision, changeType, int64(3), "owner", changedAt(revision),
+			"{origin,manufacturer}", []byte(`{"origin":"GN"}`), []byte(`{"origin":"AU"}`)}
+	}
+
+	fake.OnQueryFunc(`SELECT snapshot FROM passport_revisions`, func(args []interface{}) ([]string, [][]interface{}, error) {
+		revision := args[1].(int64)
+		if revision < 1 || int(revision) > len(snapshots) {
+			return []string{"snapshot"}, nil, nil
+		}
+		return []string{"snapshot"}, [][]interface{}{{[]byte(snapshots[revision-1])}}, nil
+	})
+	fake.OnQueryFunc(`FROM passport_revisions r`, func(args []interface{}) ([]string, [][]interface{}, error) {
+		latest := int64(len(snapshots))
+		switch {
+		case len(args) == 1:
+			return revisionColumns, [][]interface{}{revisionRow(latest)}, nil
+		case len(args) == 3:
+			var rows [][]interface{}
+			for revision := latest; revision >= 1; revision-- {
+				rows = append(rows, revisionRow(revision))
+			}
+			return revisionColumns, rows, nil
+		}
+		if at, ok := args[1].(time.Time); ok {
+			for revision := latest; revision >= 1; revision-- {
+				if !changedAt(revision).After(at) {
+					return revisionColumns, [][]interface{}{revisionRow(revision)}, nil
+				}
+			}
+			return revisionColumns, nil, nil
+		}
+		if revision := args[1].(int64); revision >= 1 && revision <= latest {
+			return revisionColumns, [][]interface{}{revisionRow(revision)}, nil
+		}
+		return revisionColumns, nil, nil
+	})
+	fake.OnQuery(`SELECT COUNT(*) FROM passport_revisions`, []string{"count"}, []interface{}{int64(len(snapshots))})
+	return fake
+}
+
+func TestPassportHistoryDiff(t *testing.T) {
+	fake := newHistoryDB(t,
+		`{"passport_id": "AP-1", "origin": "GN", "product_weight": 12.5, "metadata": {"line": 1}, "updated_at": "2025-03-01T09:00:00Z"}`,
+		`{"passport_id": "AP-1", "origin": "AU", "product_weight": 12.5, "metadata": {"line": 1}, "updated_at": "2025-03-02T09:00:00Z"}`,
+		`{"passport_id": "AP-1", "origin": "AU", "product_weight": null, "metadata": {"line": 2}, "batch_id": "B-1", "updated_at": "2025-03-03T09:00:00Z"}`,
+	)
+
+	diff, err := NewPassportHistoryService(fake.DB).Diff("AP-1", 1, 0)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if diff.FromRevision != 1 || diff.ToRevision != 3 {
+		t.Errorf("compared revisions %d and %d, want 1 and 3", diff.FromRevision, diff.ToRevision)
+	}
+	want := []models.PassportFieldChange{
+		{Field: "batch_id", From: nil, To: "B-1"},
+		{Field: "metadata", From: map[string]interface{}{"line": 1.0}, To: map[string]interface{}{"line": 2.0}},
+		{Field: "origin", From: "GN", To: "AU"},
+		{Field: "product_weight", From: 12.5, To: nil},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", diff.Changes, want)
+	}
+}
+
+func TestPassportHistoryDiffUnknownRevision(t *testing.T) {
+	fake := newHistoryDB(t, `{"origin": "GN"}`, `{"origin": "AU"}`)
+
+	if _, err := NewPassportHistoryService(fake.DB).Diff("AP-1", 1, 5); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("Diff error = %v, want %v", err, ErrRevisionNotFound)
+	}
+}
+
+func TestPassportHistoryRevisions(t *testing.T) {
+	fake := newHistoryDB(t, `{}`, `{}`, `{}`)
+
+	revisions, total, err := NewPassportHistoryService(fake.DB).Revisions("AP-1", 20, 40)
+	if err != nil {
+		t.Fatalf("Revisions: %v", err)
+	}
+	if total != 3 || len(revisions) != 3 || revisions[0].Revision != 3 {
+		t.Fatalf("got %d of %d revisions, newest %+v", len(revisions), total, revisions[0])
+	}
+	first := revisions[2]
+	if first.ChangeType != models.RevisionCreate || *first.ChangedByUsername != "owner" ||
+		!reflect.DeepEqual(first.Fields, []string{"origin", "manufacturer"}) || (*first.NewValues)["origin"] != "AU" {
+		t.Errorf("first revision = %+v", first)
+	}
+
+	pages := fake.Statements(`ORDER BY r.revision DESC LIMIT $2 OFFSET $3`)
+	if len(pages) != 1 || !reflect.DeepEqual(pages[0].Args, []interface{}{"AP-1", int64(20), int64(40)}) {
+		t.Errorf("page queries = %+v, want AP-1 limited to 20 from 40", pages)
+	}
+}
+
+func TestPassportHistoryRevisionAt(t *testing.T) {
+	fake := newHistoryDB(t, `{}`, `{}`, `{}`)
+	service := NewPassportHistoryService(fake.DB)
+
+	rev, err := service.RevisionAt("AP-1", time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("RevisionAt: %v", err)
+	}
+	if rev.Revision != 2 {
+		t.Errorf("revision = %d, want 2", rev.Revision)
+	}
+
+	if _, err := service.RevisionAt("AP-1", time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("RevisionAt before creation error = %v, want %v", err, ErrRevisionNotFound)
+	}
+}
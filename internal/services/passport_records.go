@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql"
+
+	"aluminium-passport/internal/db"
+)
+
+const insertPassportQuery = `
+	INSERT INTO aluminium_passports (
+		passport_id, batch_id, manufacturer, origin, bauxite_source, alloy_composition,
+		mine_operator, date_of_extraction, extraction_method, mine_location,
+		refinery_location, refiner_id, refining_date, refining_method,
+		smelting_location, smelting_energy_source, process_type, manufactured_product, manufacturing_date,
+		product_weight, energy_used, water_used, waste_generated,
+		carbon_emissions_per_kg, co2_footprint, manufacturing_emissions,
+		transport_mode, distance_travelled, logistics_partner_id, shipment_date,
+		recycled_content_percent, recycling_date, recycler_id, recycling_method, times_recycled,
+		certification_agency, certifier, compliance_standards, date_of_certification, certification_expiry, verifier_signature,
+		metadata, status, is_verified, created_at, updated_at, created_by, updated_by, organisation_id
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+		$20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35,
+		$36, $37, $38, $39, $40, $41, $42, $43, $44, $45, $46, $47, $48, $49
+	) RETURNING id`
+
+// PassportColumns are the columns read into a db.AluminiumPassport by
+// ScanPassport
+const PassportColumns = `id, passport_id, batch_id, manufacturer, origin, bauxite_source, alloy_composition,
+	mine_operator, date_of_extraction, extraction_method, mine_location,
+	refinery_location, refiner_id, refining_date, refining_method,
+	smelting_location, smelting_energy_source, process_type, manufactured_product, manufacturing_date,
+	product_weight, energy_used, water_used, waste_generated,
+	carbon_emissions_per_kg, co2_footprint, manufacturing_emissions,
+	transport_mode, distance_travelled, logistics_partner_id, shipment_date,
+	recycled_content_percent, recycling_date, recycler_id, recycling_method, times_recycled, last_recycling_date,
+	certification_agency, certifier, compliance_standards, date_of_certification, certification_expiry, verifier_signature,
+	esg_score, environmental_score, social_score, governance_score, esg_last_updated,
+	ipfs_hash, qr_code_data, digital_signature,
+	blockchain_tx_hash, contract_address, block_number,
+	status, is_verified, verification_date,
+	metadata, supply_chain_steps, certifications,
+	created_at, updated_at, created_by, updated_by, organisation_id`
+
+// ScanPassport reads a row selected with PassportColumns
+func ScanPassport(row rowScanner) (*db.AluminiumPassport, error) {
+	passport := &db.AluminiumPassport{}
+	err := row.Scan(
+		&passport.ID, &passport.PassportID, &passport.BatchID, &passport.Manufacturer, &passport.Origin, &passport.BauxiteSource, &passport.AlloyComposition,
+		&passport.MineOperator, &passport.DateOfExtraction, &passport.ExtractionMethod, &passport.MineLocation,
+		&passport.RefineryLocation, &passport.RefinerID, &passport.RefiningDate, &passport.RefiningMethod,
+		&passport.SmeltingLocation, &passport.SmeltingEnergySource, &passport.ProcessType, &passport.ManufacturedProduct, &passport.ManufacturingDate,
+		&passport.ProductWeight, &passport.EnergyUsed, &passport.WaterUsed, &passport.WasteGenerated,
+		&passport.CarbonEmissionsPerKg, &passport.CO2Footprint, &passport.ManufacturingEmissions,
+		&passport.TransportMode, &passport.DistanceTravelled, &passport.LogisticsPartnerID, &passport.ShipmentDate,
+		&passport.RecycledContentPercent, &passport.RecyclingDate, &passport.RecyclerID, &passport.RecyclingMethod, &passport.TimesRecycled, &passport.LastRecyclingDate,
+		&passport.CertificationAgency, &passport.Certifier, &passport.ComplianceStandards, &passport.DateOfCertification, &passport.CertificationExpiry, &passport.VerifierSignature,
+		&passport.ESGScore, &passport.EnvironmentalScore, &passport.SocialScore, &passport.GovernanceScore, &passport.ESGLastUpdated,
+		&passport.IPFSHash, &passport.QRCodeData, &passport.DigitalSignature,
+		&passport.BlockchainTxHash, &passport.ContractAddress, &passport.BlockNumber,
+		&passport.Status, &passport.IsVerified, &passport.VerificationDate,
+		&passport.Metadata, &passport.SupplyChainSteps, &passport.Certifications,
+		&passport.CreatedAt, &passport.UpdatedAt, &passport.CreatedBy, &passport.UpdatedBy, &passport.OrganisationID,
+	)
+	return passport, err
+}
+
+// InsertPassportRecord stores a new passport row and returns its ID
+func InsertPassportRecord(passport *db.AluminiumPassport) (int, error) {
+	var passportID int
+	err := db.DB.QueryRow(insertPassportQuery, passportInsertArgs(passport)...).Scan(&passportID)
+	return passportID, err
+}
+
+func insertPassportRecordTx(tx *sql.Tx, passport *db.AluminiumPassport) (int, error) {
+	var passportID int
+	err := tx.QueryRow(insertPassportQuery, passportInsertArgs(passport)...).Scan(&passportID)
+	return passportID, err
+}
+
+func passportInsertArgs(passport *db.AluminiumPassport) []interface{} {
+	return []interface{}{
+		passport.PassportID, passport.BatchID, passport.Manufacturer, passport.Origin, passport.BauxiteSource, passport.AlloyComposition,
+		passport.MineOperator, passport.DateOfExtraction, passport.ExtractionMethod, passport.MineLocation,
+		passport.RefineryLocation, passport.RefinerID, passport.RefiningDate, passport.RefiningMethod,
+		passport.SmeltingLocation, passport.SmeltingEnergySource, passport.ProcessType, passport.ManufacturedProduct, passport.ManufacturingDate,
+		passport.ProductWeight, passport.EnergyUsed, passport.WaterUsed, passport.WasteGenerated,
+		passport.CarbonEmissionsPerKg, passport.CO2Footprint, passport.ManufacturingEmissions,
+		passport.TransportMode, passport.DistanceTravelled, passport.LogisticsPartnerID, passport.ShipmentDate,
+		passport.RecycledContentPercent, passport.RecyclingDate, passport.RecyclerID, passport.RecyclingMethod, passport.TimesRecycled,
+		passport.CertificationAgency, passport.Certifier, passport.ComplianceStandards, passport.DateOfCertification, passport.CertificationExpiry, passport.VerifierSignature,
+		passport.Metadata, passport.Status, passport.IsVerified, passport.CreatedAt, passport.UpdatedAt, passport.CreatedBy, passport.UpdatedBy,
+		passport.OrganisationID,
+	}
+}
+
+// PassportExists reports whether a passport ID is already taken
+func PassportExists(passportID string) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM aluminium_passports WHERE passport_id = $1)`, passportID).Scan(&exists)
+	return exists, err
+}
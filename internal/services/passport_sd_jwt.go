@@ -0,0 +1,289 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+)
+
+const (
+	sdJWTType = "dc+sd-jwt"
+
+	// PassportCredentialVCT is the SD-JWT VC type of passport credentials
+	PassportCredentialVCT = "https://aluminium-passport.com/credentials/AluminiumPassportCredential"
+)
+
+var (
+	ErrCredentialExpired        = errors.New("credential has expired")
+	ErrUnknownDisclosureProfile = errors.New("unknown disclosure profile")
+	ErrNoClaimsSelected         = errors.New("no claims selected for disclosure")
+)
+
+// DisclosureProfiles are predefined claim selections for common audiences.
+// A section name ("recycling") discloses the whole section; "*" discloses
+// everything.
+var DisclosureProfiles = map[string][]string{
+	"consumer": {
+		"manufacturer", "origin",
+		"recycling.recycledContentPercent",
+		"environmentalImpact.carbonEmissionsPerKg",
+		"esg.esgScore",
+		"certification.certificationAgency",
+	},
+	"recycler": {
+		"alloyComposition", "traceMetals",
+		"smelting.processType", "smelting.manufacturedProduct", "smelting.productWeight",
+		"recycling",
+	},
+	"regulator": {"*"},
+}
+
+// SDJWTPresentation is the result of verifying a presented SD-JWT
+type SDJWTPresentation struct {
+	Issuer    string                 `json:"issuer"`
+	IssuedAt  time.Time              `json:"issued_at"`
+	Claims    map[string]interface{} `json:"claims"`
+	Disclosed []string               `json:"disclosed"`
+}
+
+// IssuePassportSDJWT issues the passport credential as an SD-JWT VC
+func IssuePassportSDJWT(passport *db.AluminiumPassport) (string, error) {
+	claim, issuer, err := prepareIssuedCredential(passport)
+	if err != nil {
+		return "", err
+	}
+	return EncodeCredentialSDJWT(claim, issuer)
+}
+
+// EncodeCredentialSDJWT secures a credential as an SD-JWT VC in which every
+// subject field, and every section, is individually disclosable. Only the
+// passport ID, issuer, validity and status entries are in the clear.
+func EncodeCredentialSDJWT(claim *models.VerifiableClaim, issuer *IssuerKey) (string, error) {
+	raw, err := json.Marshal(claim.CredentialSubject)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credential subject: %w", err)
+	}
+	var subject map[string]interface{}
+	if err := json.Unmarshal(raw, &subject); err != nil {
+		return "", fmt.Errorf("failed to encode credential subject: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"iss":              issuer.Controller,
+		"iat":              time.Now().Unix(),
+		"vct":              PassportCredentialVCT,
+		"jti":              claim.ID,
+		"sub":              subject["id"],
+		"passportId":       subject["passportId"],
+		"credentialStatus": claim.CredentialStatus,
+		"_sd_alg":          signing.SDAlgSHA256,
+	}
+	if claim.ValidUntil != "" {
+		validUntil, err := time.Parse(time.RFC3339, claim.ValidUntil)
+		if err != nil {
+			return "", fmt.Errorf("invalid validUntil: %w", err)
+		}
+		payload["exp"] = validUntil.Unix()
+	}
+
+	var disclosures []*signing.Disclosure
+	var concealed []string
+	for name, value := range subject {
+		switch name {
+		case "id", "type", "passportId", "status":
+			continue
+		}
+		if section, ok := value.(map[string]interface{}); ok {
+			nested, err := signing.Conceal(section, sortedKeys(section))
+			if err != nil {
+				return "", err
+			}
+			disclosures = append(disclosures, nested...)
+		}
+		payload[name] = value
+		concealed = append(concealed, name)
+	}
+	sort.Strings(concealed)
+
+	top, err := signing.Conceal(payload, concealed)
+	if err != nil {
+		return "", err
+	}
+	disclosures = append(top, disclosures...)
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SD-JWT payload: %w", err)
+	}
+
+	header := signing.JWSHeader{
+		Kid: issuer.VerificationMethod,
+		Typ: sdJWTType,
+	}
+	token, err := signing.SignJWS(header, payloadJSON, issuer.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return (&signing.SDJWT{IssuerJWT: token, Disclosures: disclosures}).Serialize(), nil
+}
+
+// DisclosureClaims returns the claims of a named profile, or claims when no
+// profile is given
+func DisclosureClaims(profile string, claims []string) ([]string, error) {
+	if profile != "" {
+		selected, ok := DisclosureProfiles[profile]
+		if !ok {
+			return nil, ErrUnknownDisclosureProfile
+		}
+		return selected, nil
+	}
+	if len(claims) == 0 {
+		return nil, ErrNoClaimsSelected
+	}
+	return claims, nil
+}
+
+// PresentPassportSDJWT derives a presentation from an issued SD-JWT that
+// keeps only the disclosures for the selected claims. Claims are dotted
+// paths such as "refining.refinerId"; the enclosing section is disclosed
+// with them. The issuer signature is untouched, so the holder needs no key.
+func PresentPassportSDJWT(serialized string, claims []string) (string, []string, error) {
+	sd, err := signing.ParseSDJWT(serialized)
+	if err != nil {
+		return "", nil, err
+	}
+
+	payload, err := sdJWTPayload(sd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	paths := signing.DisclosurePaths(payload, sd.Disclosures)
+	presentation := &signing.SDJWT{IssuerJWT: sd.IssuerJWT}
+	var disclosed []string
+	for _, d := range sd.Disclosures {
+		path, ok := paths[d.Digest()]
+		if !ok || !disclosureSelected(path, claims) {
+			continue
+		}
+		presentation.Disclosures = append(presentation.Disclosures, d)
+		disclosed = append(disclosed, path)
+	}
+	sort.Strings(disclosed)
+
+	return presentation.Serialize(), disclosed, nil
+}
+
+// VerifyPassportSDJWT verifies the issuer signature of a presented SD-JWT,
+// checks every disclosure against the signed digests and returns the
+// revealed claims. Expired, revoked and suspended credentials are returned
+// together with the corresponding error.
+func VerifyPassportSDJWT(serialized string) (*SDJWTPresentation, error) {
+	sd, err := signing.ParseSDJWT(serialized)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _, err := signing.ParseJWS(sd.IssuerJWT)
+	if err != nil {
+		return nil, err
+	}
+	if header.Typ != sdJWTType {
+		return nil, fmt.Errorf("%w: unexpected typ %q", signing.ErrInvalidJWS, header.Typ)
+	}
+
+	payload, err := sdJWTPayload(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, _ := payload["iss"].(string)
+	iat, ok := payload["iat"].(float64)
+	if issuer == "" || !ok {
+		return nil, fmt.Errorf("%w: missing iss or iat", signing.ErrInvalidSDJWT)
+	}
+	issuedAt := time.Unix(int64(iat), 0).UTC()
+
+	publicKey, err := resolveIssuerKey(issuer, header.Kid, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := signing.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, err := signing.VerifyJWS(sd.IssuerJWT, key); err != nil {
+		return nil, err
+	}
+
+	claims, disclosed, err := signing.Reveal(payload, sd.Disclosures)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SDJWTPresentation{
+		Issuer:    issuer,
+		IssuedAt:  issuedAt,
+		Claims:    claims,
+		Disclosed: disclosed,
+	}
+
+	if exp, ok := payload["exp"].(float64); ok && time.Now().After(time.Unix(int64(exp), 0)) {
+		return result, ErrCredentialExpired
+	}
+
+	var status struct {
+		CredentialStatus []models.CredentialStatus `json:"credentialStatus"`
+	}
+	raw, _ := json.Marshal(payload)
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, fmt.Errorf("%w: malformed credentialStatus", signing.ErrInvalidSDJWT)
+	}
+	if err := CheckCredentialStatus(&models.VerifiableClaim{CredentialStatus: status.CredentialStatus}); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func sdJWTPayload(sd *signing.SDJWT) (map[string]interface{}, error) {
+	_, raw, err := signing.ParseJWS(sd.IssuerJWT)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("%w: malformed payload", signing.ErrInvalidSDJWT)
+	}
+	return payload, nil
+}
+
+// disclosureSelected reports whether the disclosure at path is needed for the
+// selected claims: the claim itself, anything inside a selected section, or a
+// section enclosing a selected claim
+func disclosureSelected(path string, claims []string) bool {
+	for _, c := range claims {
+		c = strings.TrimSuffix(c, ".*")
+		if c == "*" || path == c || strings.HasPrefix(path, c+".") || strings.HasPrefix(c, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
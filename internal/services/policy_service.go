@@ -0,0 +1,226 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidRolePermissions = errors.New("invalid role permissions")
+
+// policyCacheTTL bounds how long bindings changed by another instance take
+// to apply here; changes made through this instance apply immediately
+const policyCacheTTL = 30 * time.Second
+
+var (
+	policyCacheMu       sync.RWMutex
+	policyCacheBindings map[string]map[models.Permission]bool
+	policyCacheLoadedAt time.Time
+)
+
+// PolicyService decides which permissions a role holds. Roles configured in
+// role_permissions use their stored bindings; other roles use
+// models.DefaultRolePermissions.
+type PolicyService struct {
+	db *sql.DB
+}
+
+func NewPolicyService(db *sql.DB) *PolicyService {
+	return &PolicyService{db: db}
+}
+
+// HasPermission reports whether role holds permission. Lookup failures
+// deny; use PolicyService.Allows to tell them apart.
+func HasPermission(role string, permission models.Permission) bool {
+	allowed, err := NewPolicyService(db.DB).Allows(role, permission)
+	if err != nil {
+		log.Printf("Permission check %s for %s failed: %v", permission, role, err)
+		return false
+	}
+	return allowed
+}
+
+// DeniedPassportFields returns the passport fields, in the order given,
+// that role may not write because it lacks the permission of their section.
+// Fields outside every section are not checked.
+func DeniedPassportFields(role string, fields []string) []string {
+	denied := []string{}
+	for _, field := range fields {
+		section, ok := models.PassportFieldSection(field)
+		if ok && !HasPermission(role, models.SectionPermission(section)) {
+			denied = append(denied, field)
+		}
+	}
+	return denied
+}
+
+// Allows reports whether role holds permission
+func (ps *PolicyService) Allows(role string, permission models.Permission) (bool, error) {
+	policyCacheMu.RLock()
+	bindings, fresh := policyCacheBindings, time.Since(policyCacheLoadedAt) < policyCacheTTL
+	policyCacheMu.RUnlock()
+
+	if bindings == nil || !fresh {
+		loaded, err := ps.load()
+		if err != nil {
+			return false, err
+		}
+		bindings = make(map[string]map[models.Permission]bool, len(loaded))
+		for r, permissions := range loaded {
+			bindings[r] = make(map[models.Permission]bool, len(permissions))
+			for _, p := range permissions {
+				bindings[r][p] = true
+			}
+		}
+		policyCacheMu.Lock()
+		policyCacheBindings, policyCacheLoadedAt = bindings, time.Now()
+		policyCacheMu.Unlock()
+	}
+	return bindings[role][permission], nil
+}
+
+// RolePermissions returns the effective permissions of every role, sorted,
+// and the roles whose permissions differ from the defaults because they
+// were configured
+func (ps *PolicyService) RolePermissions() (map[string][]models.Permission, []string, error) {
+	configured, err := ps.configured()
+	if err != nil {
+		return nil, nil, err
+	}
+	bindings := models.DefaultRolePermissions()
+	customised := []string{}
+	for role, permissions := range configured {
+		bindings[role] = permissions
+		customised = append(customised, role)
+	}
+	for _, permissions := range bindings {
+		sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	}
+	sort.Strings(customised)
+	return bindings, customised, nil
+}
+
+// SetRolePermissions replaces the permissions of the roles in bindings and
+// restores the defaults of the roles in reset. The super admin role must
+// keep config:manage so that the configuration cannot lock itself out.
+func (ps *PolicyService) SetRolePermissions(bindings map[string][]models.Permission, reset []string, updatedBy int) error {
+	for role, permissions := range bindings {
+		if !models.IsValidRole(role) {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidRolePermissions, role)
+		}
+		for _, permission := range permissions {
+			if !models.IsValidPermission(permission) {
+				return fmt.Errorf("%w: unknown permission %q", ErrInvalidRolePermissions, permission)
+			}
+		}
+		if role == models.RoleSuperAdmin && !containsPermission(permissions, models.PermConfigManage) {
+			return fmt.Errorf("%w: %s must keep %s", ErrInvalidRolePermissions, models.RoleSuperAdmin, models.PermConfigManage)
+		}
+	}
+	for _, role := range reset {
+		if !models.IsValidRole(role) {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidRolePermissions, role)
+		}
+		if _, ok := bindings[role]; ok {
+			return fmt.Errorf("%w: %s is both set and reset", ErrInvalidRolePermissions, role)
+		}
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(reset) > 0 {
+		if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role::text = ANY($1)`, pq.Array(reset)); err != nil {
+			return fmt.Errorf("failed to reset role permissions: %w", err)
+		}
+	}
+	for role, permissions := range bindings {
+		var unique []models.Permission
+		names := []string{}
+		for _, permission := range permissions {
+			if !containsPermission(unique, permission) {
+				unique = append(unique, permission)
+				names = append(names, string(permission))
+			}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO role_permissions (role, permissions, updated_by, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (role) DO UPDATE
+			SET permissions = EXCLUDED.permissions, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+			role, pq.Array(names), updatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to store role permissions: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	policyCacheMu.Lock()
+	policyCacheBindings = nil
+	policyCacheMu.Unlock()
+	return nil
+}
+
+// load returns the effective permissions of every role
+func (ps *PolicyService) load() (map[string][]models.Permission, error) {
+	configured, err := ps.configured()
+	if err != nil {
+		return nil, err
+	}
+	bindings := models.DefaultRolePermissions()
+	for role, permissions := range configured {
+		bindings[role] = permissions
+	}
+	return bindings, nil
+}
+
+// configured returns the stored bindings. Permissions that no longer exist
+// are dropped.
+func (ps *PolicyService) configured() (map[string][]models.Permission, error) {
+	rows, err := ps.db.Query(`SELECT role, permissions FROM role_permissions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	configured := map[string][]models.Permission{}
+	for rows.Next() {
+		var role string
+		var names []string
+		if err := rows.Scan(&role, pq.Array(&names)); err != nil {
+			return nil, fmt.Errorf("failed to scan role permissions: %w", err)
+		}
+		permissions := []models.Permission{}
+		for _, name := range names {
+			if models.IsValidPermission(models.Permission(name)) {
+				permissions = append(permissions, models.Permission(name))
+			}
+		}
+		configured[role] = permissions
+	}
+	return configured, rows.Err()
+}
+
+func containsPermission(permissions []models.Permission, permission models.Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// sectionFields is one field of every passport section, and one the
+// platform maintains
+var sectionFields = []string{
+	"manufacturer", "mine_operator", "refinery_location", "product_weight", "shipment_date",
+	"recycled_content_percent", "certifier", "esg_score", "status",
+}
+
+func TestDeniedPassportFields(t *testing.T) {
+	tests := []struct {
+		role    string
+		allowed []string
+	}{
+		{role: models.RoleSuperAdmin, allowed: sectionFields},
+		{role: models.RoleAdmin, allowed: sectionFields},
+		{role: models.RoleMiner, allowed: []string{"manufacturer", "mine_operator", "refinery_location", "shipment_date", "status"}},
+		{role: models.RoleManufacturer, allowed: []string{"manufacturer", "refinery_location", "product_weight", "shipment_date", "status"}},
+		{role: models.RoleRecycler, allowed: []string{"recycled_content_percent", "status"}},
+		{role: models.RoleCertifier, allowed: []string{"certifier", "esg_score", "status"}},
+		{role: models.RoleAuditor, allowed: []string{"status"}},
+		{role: models.RoleViewer, allowed: []string{"status"}},
+		{role: "unknown", allowed: []string{"status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			newTestDB(t)
+			allowed := map[string]bool{}
+			for _, field := range tt.allowed {
+				allowed[field] = true
+			}
+			want := []string{}
+			for _, field := range sectionFields {
+				if !allowed[field] {
+					want = append(want, field)
+				}
+			}
+
+			if got := DeniedPassportFields(tt.role, sectionFields); !reflect.DeepEqual(got, want) {
+				t.Errorf("denied = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDeniedPassportFieldsFollowsConfiguredRoles(t *testing.T) {
+	newTestDB(t)
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"},
+		[]interface{}{models.RoleRecycler, "{section:write_basic,section:write_recycling}"})
+
+	got := DeniedPassportFields(models.RoleRecycler, []string{"manufacturer", "recycler_id", "certifier"})
+	if want := []string{"certifier"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("denied = %v, want %v", got, want)
+	}
+}
+
+func TestPassportAccessDeniedFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		access PassportAccess
+		role   string
+		want   []string
+	}{
+		{
+			name:   "owner is limited by role",
+			access: PassportAccess{Read: true, Owner: true},
+			role:   models.RoleManufacturer,
+			want:   []string{"recycled_content_percent", "certifier"},
+		},
+		{
+			name:   "grant is limited to its sections",
+			access: PassportAccess{Read: true, WriteSections: []string{models.SectionRecycling}},
+			role:   models.RoleAdmin,
+			want:   []string{"manufacturer", "product_weight", "certifier"},
+		},
+		{
+			name:   "grant and role must both allow",
+			access: PassportAccess{Read: true, WriteSections: []string{models.SectionRecycling, models.SectionCertification}},
+			role:   models.RoleRecycler,
+			want:   []string{"manufacturer", "product_weight", "certifier"},
+		},
+		{
+			name:   "read only",
+			access: PassportAccess{Read: true},
+			role:   models.RoleAdmin,
+			want:   []string{"manufacturer", "product_weight", "recycled_content_percent", "certifier"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			fields := []string{"manufacturer", "product_weight", "recycled_content_percent", "certifier", "status"}
+			if got := tt.access.DeniedFields(tt.role, fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("denied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+// sessionTouchInterval limits how often last_used_at is written for a
+// session
+const sessionTouchInterval = time.Minute
+
+// SessionService records login sessions so that tokens can be revoked
+// before they expire. Refreshing rotates both tokens of a session; a
+// refresh token presented twice revokes the whole session.
+type SessionService struct {
+	db *sql.DB
+}
+
+func NewSessionService(db *sql.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// StartSession records a new session for a user who has just signed in
+// and returns its tokens. Expired sessions of the user are removed.
+func (ss *SessionService) StartSession(user *db.User, r *http.Request) (*auth.TokenPair, error) {
+	sessionID, err := auth.NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	tokens, err := ss.tokensFor(sessionID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ss.db.Exec(`DELETE FROM user_sessions WHERE user_id = $1 AND expires_at < NOW()`, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to remove expired sessions: %w", err)
+	}
+
+	_, err = ss.db.Exec(`
+		INSERT INTO user_sessions (id, user_id, access_token_id, refresh_token_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		sessionID, user.ID, tokens.AccessTokenID, tokens.RefreshTokenID,
+		nullableString(r.UserAgent()), nullableString(clientIP(r)), tokens.RefreshExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+	return tokens, nil
+}
+
+func (ss *SessionService) tokensFor(sessionID string, user *db.User) (*auth.TokenPair, error) {
+	organisationID := 0
+	if user.OrganisationID != nil {
+		organisationID = *user.OrganisationID
+	}
+	return auth.GenerateTokenPair(sessionID, user.ID, user.Username, stringValue(user.Email), user.Role,
+		user.WalletAddress, stringValue(user.CompanyName), organisationID)
+}
+
+// Refresh exchanges a refresh token for a new token pair of the same
+// session. The user is read again so role changes take effect. Presenting
+// a refresh token that was already exchanged revokes the session.
+func (ss *SessionService) Refresh(refreshToken string, r *http.Request) (*auth.TokenPair, *db.User, error) {
+	claims, err := auth.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var refreshTokenID string
+	var revoked bool
+	err = tx.QueryRow(`
+		SELECT user_id, refresh_token_id, revoked_at IS NOT NULL
+		FROM user_sessions
+		WHERE id = $1 AND expires_at > NOW()
+		FOR UPDATE`, claims.SessionID,
+	).Scan(&userID, &refreshTokenID, &revoked)
+	if err == sql.ErrNoRows || (err == nil && userID != claims.UserID) {
+		return nil, nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if revoked {
+		return nil, nil, ErrSessionRevoked
+	}
+
+	if refreshTokenID != claims.ID {
+		if err := ss.revokeTx(tx, claims.SessionID, models.SessionRevokedRefreshReuse); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	user := &db.User{}
+	err = tx.QueryRow(`
+		SELECT id, wallet_address, username, email, role, company_name, organisation_id, is_active
+		FROM users WHERE id = $1`, userID,
+	).Scan(&user.ID, &user.WalletAddress, &user.Username, &user.Email, &user.Role, &user.CompanyName,
+		&user.OrganisationID, &user.IsActive)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("failed to read user: %w", err)
+	}
+	if err == sql.ErrNoRows || !user.IsActive {
+		if err := ss.revokeTx(tx, claims.SessionID, models.SessionRevokedUserInactive); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, nil, ErrSessionRevoked
+	}
+
+	tokens, err := ss.tokensFor(claims.SessionID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE user_sessions
+		SET access_token_id = $2, refresh_token_id = $3, expires_at = $4, last_used_at = NOW(),
+		    user_agent = $5, ip_address = $6
+		WHERE id = $1`,
+		claims.SessionID, tokens.AccessTokenID, tokens.RefreshTokenID, tokens.RefreshExpiresAt,
+		nullableString(r.UserAgent()), nullableString(clientIP(r)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to rotate session tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tokens, user, nil
+}
+
+// ValidateAccess checks that an access token is the current one of an
+// active session
+func (ss *SessionService) ValidateAccess(claims *auth.Claims) error {
+	if claims.SessionID == "" || auth.IsRefreshToken(claims) {
+		return ErrSessionNotFound
+	}
+
+	var accessTokenID string
+	var revoked bool
+	var lastUsedAt time.Time
+	err := ss.db.QueryRow(`
+		SELECT access_token_id, revoked_at IS NOT NULL, last_used_at
+		FROM user_sessions
+		WHERE id = $1 AND user_id = $2 AND expires_at > NOW()`, claims.SessionID, claims.UserID,
+	).Scan(&accessTokenID, &revoked, &lastUsedAt)
+	if err == sql.ErrNoRows {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session: %w", err)
+	}
+	// A token superseded by a refresh is treated like a revoked one
+	if revoked || accessTokenID != claims.ID {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(lastUsedAt) > sessionTouchInterval {
+		if _, err := ss.db.Exec(`UPDATE user_sessions SET last_used_at = NOW() WHERE id = $1`, claims.SessionID); err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevokeSession revokes one of a user's active sessions
+func (ss *SessionService) RevokeSession(userID int, sessionID, reason string) error {
+	result, err := ss.db.Exec(`
+		UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of a user and returns
+// how many there were
+func (ss *SessionService) RevokeUserSessions(userID int, reason string) (int, error) {
+	return revokeUserSessions(ss.db.Exec, userID, reason)
+}
+
+// RevokeUserSessionsTx revokes every active session of a user inside tx,
+// so that they end together with a change made in it
+func (ss *SessionService) RevokeUserSessionsTx(tx *sql.Tx, userID int, reason string) (int, error) {
+	return revokeUserSessions(tx.Exec, userID, reason)
+}
+
+func revokeUserSessions(exec func(string, ...interface{}) (sql.Result, error), userID int, reason string) (int, error) {
+	result, err := exec(`
+		UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()`, userID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+func (ss *SessionService) revokeTx(tx *sql.Tx, sessionID, reason string) error {
+	_, err := tx.Exec(`
+		UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL`, sessionID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// ActiveSessions returns a user's active sessions, most recently used
+// first, marking currentSessionID
+func (ss *SessionService) ActiveSessions(userID int, currentSessionID string) ([]*models.Session, error) {
+	rows, err := ss.db.Query(`
+		SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		s := &models.Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		s.Current = s.ID == currentSessionID
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
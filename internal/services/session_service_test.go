@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// newSessionTokens issues the tokens of session sess-1 for user 5
+func newSessionTokens(t *testing.T) (*auth.TokenPair, *auth.Claims, *auth.Claims) {
+	t.Helper()
+	withConfig(t, &config.Config{JWTSecret: "session-test-secret", JWTExpirationHours: 1, JWTRefreshHours: 24})
+	tokens, err := auth.GenerateTokenPair("sess-1", 5, "owner", "owner@example.com", models.RoleManufacturer, "", "", 7)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	access, err := auth.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	refresh, err := auth.ValidateRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken: %v", err)
+	}
+	return tokens, access, refresh
+}
+
+// onSessionRow serves session sess-1 to Refresh
+func onSessionRow(fake *dbtest.DB, userID int, refreshTokenID string, revoked bool) {
+	fake.OnQuery(`FOR UPDATE`, []string{"user_id", "refresh_token_id", "revoked"},
+		[]interface{}{int64(userID), refreshTokenID, revoked})
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	tokens, _, refresh := newSessionTokens(t)
+	fake := newTestDB(t)
+	onSessionRow(fake, 5, refresh.ID, false)
+	// The role changed since the session started
+	fake.OnQuery(`FROM users WHERE id = $1`,
+		dbtest.Columns(`id, wallet_address, username, email, role, company_name, organisation_id, is_active`),
+		[]interface{}{int64(5), "", "owner", "owner@example.com", models.RoleAuditor, nil, int64(7), true})
+
+	rotated, user, err := NewSessionService(fake.DB).Refresh(tokens.RefreshToken, httptest.NewRequest("POST", "/api/auth/refresh", nil))
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if user.Role != models.RoleAuditor || rotated.SessionID != "sess-1" {
+		t.Errorf("refreshed %s session %s, want the auditor's session sess-1", user.Role, rotated.SessionID)
+	}
+	if rotated.AccessTokenID == tokens.AccessTokenID || rotated.RefreshTokenID == tokens.RefreshTokenID {
+		t.Errorf("Refresh did not issue new token IDs")
+	}
+	claims, err := auth.ValidateToken(rotated.AccessToken)
+	if err != nil || claims.Role != models.RoleAuditor {
+		t.Errorf("new access token role = %v, %v; want %s", claims, err, models.RoleAuditor)
+	}
+
+	updates := fake.Statements(`SET access_token_id = $2, refresh_token_id = $3`)
+	if len(updates) != 1 {
+		t.Fatalf("rotated %d times, want once", len(updates))
+	}
+	if args := updates[0].Args; args[0] != "sess-1" || args[1] != rotated.AccessTokenID || args[2] != rotated.RefreshTokenID {
+		t.Errorf("rotation args = %v, want the new token IDs of sess-1", args[:3])
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		reused  bool
+		revoked bool
+		active  bool
+		wantErr error
+		reason  string
+	}{
+		{name: "reused refresh token", userID: 5, reused: true, active: true, wantErr: ErrRefreshTokenReused, reason: models.SessionRevokedRefreshReuse},
+		{name: "revoked session", userID: 5, revoked: true, active: true, wantErr: ErrSessionRevoked},
+		{name: "another user's session", userID: 6, active: true, wantErr: ErrSessionNotFound},
+		{name: "deactivated user", userID: 5, wantErr: ErrSessionRevoked, reason: models.SessionRevokedUserInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, _, refresh := newSessionTokens(t)
+			fake := newTestDB(t)
+			current := refresh.ID
+			if tt.reused {
+				current = "rotated-refresh-token"
+			}
+			onSessionRow(fake, tt.userID, current, tt.revoked)
+			fake.OnQuery(`FROM users WHERE id = $1`,
+				dbtest.Columns(`id, wallet_address, username, email, role, company_name, organisation_id, is_active`),
+				[]interface{}{int64(5), "", "owner", nil, models.RoleManufacturer, nil, nil, tt.active})
+
+			_, _, err := NewSessionService(fake.DB).Refresh(tokens.RefreshToken, httptest.NewRequest("POST", "/api/auth/refresh", nil))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Refresh error = %v, want %v", err, tt.wantErr)
+			}
+
+			if rotations := fake.Statements(`SET access_token_id = $2`); len(rotations) != 0 {
+				t.Errorf("rotated the tokens of a rejected refresh")
+			}
+			revocations := fake.Statements(`SET revoked_at = NOW(), revoked_reason = $2`)
+			if tt.reason == "" {
+				if len(revocations) != 0 {
+					t.Errorf("revoked the session: %+v", revocations)
+				}
+				return
+			}
+			if len(revocations) != 1 || revocations[0].Args[0] != "sess-1" || revocations[0].Args[1] != tt.reason {
+				t.Errorf("revocations = %+v, want sess-1 revoked for %s", revocations, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsAccessTokens(t *testing.T) {
+	tokens, _, _ := newSessionTokens(t)
+	fake := newTestDB(t)
+
+	if _, _, err := NewSessionService(fake.DB).Refresh(tokens.AccessToken, httptest.NewRequest("POST", "/", nil)); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Refresh error = %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		refresh  bool
+		tokenID  string
+		revoked  bool
+		lastUsed time.Duration
+		missing  bool
+		wantErr  error
+		touched  bool
+	}{
+		{name: "current token", lastUsed: time.Second},
+		{name: "idle session is touched", lastUsed: time.Hour, touched: true},
+		{name: "refresh token", refresh: true, wantErr: ErrSessionNotFound},
+		{name: "superseded token", tokenID: "rotated-access-token", wantErr: ErrSessionRevoked},
+		{name: "revoked session", revoked: true, wantErr: ErrSessionRevoked},
+		{name: "expired session", missing: true, wantErr: ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, access, refresh := newSessionTokens(t)
+			fake := newTestDB(t)
+			tokenID := access.ID
+			if tt.tokenID != "" {
+				tokenID = tt.tokenID
+			}
+			var rows [][]interface{}
+			if !tt.missing {
+				rows = append(rows, []interface{}{tokenID, tt.revoked, time.Now().Add(-tt.lastUsed)})
+			}
+			fake.OnQuery(`FROM user_sessions`, []string{"access_token_id", "revoked", "last_used_at"}, rows...)
+
+			claims := access
+			if tt.refresh {
+				claims = refresh
+			}
+			if err := NewSessionService(fake.DB).ValidateAccess(claims); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateAccess error = %v, want %v", err, tt.wantErr)
+			}
+			if touched := len(fake.Statements(`SET last_used_at = NOW()`)) > 0; touched != tt.touched {
+				t.Errorf("touched = %v, want %v", touched, tt.touched)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionNotFound(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnExec(`UPDATE user_sessions`, func(args []interface{}) (int64, error) { return 0, nil })
+
+	if err := NewSessionService(fake.DB).RevokeSession(5, "sess-9", models.SessionRevokedByUser); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
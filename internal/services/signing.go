@@ -1,41 +1,288 @@
 package services
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sort"
+	"log"
+	"sync"
 	"time"
 
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
 )
 
-func SignVerifiableClaim(claim *models.VerifiableClaim, privateKey string) *models.VerifiableClaim {
-	// Serialize the claim's subject to hash it (deterministic ordering)
-	subject := claim.CredentialSubject
-	keys := make([]string, 0, len(subject))
-	for k := range subject {
-		keys = append(keys, k)
+const proofPurposeAssertion = "assertionMethod"
+
+var (
+	ErrInvalidCredential  = errors.New("invalid credential")
+	ErrMissingProof       = errors.New("credential has no proof")
+	ErrUnsupportedPurpose = errors.New("unsupported proof purpose")
+	ErrIssuerMismatch     = errors.New("verification method is not controlled by the credential issuer")
+	ErrKeyMismatch        = errors.New("public key does not match the credential's verification method")
+)
+
+var (
+	devIssuerKey     string
+	devIssuerKeyOnce sync.Once
+)
+
+// IssuerSigningKey returns the multibase-encoded private key used to sign
+// passport credentials. Outside production an ephemeral Ed25519 key is
+// generated when CREDENTIAL_SIGNING_KEY is not set.
+func IssuerSigningKey() (string, error) {
+	cfg := config.AppConfig
+	if cfg != nil && cfg.CredentialSigningKey != "" {
+		return cfg.CredentialSigningKey, nil
+	}
+
+	if cfg != nil && cfg.IsProduction() {
+		return "", fmt.Errorf("CREDENTIAL_SIGNING_KEY is not configured")
+	}
+
+	var genErr error
+	devIssuerKeyOnce.Do(func() {
+		key, err := signing.GenerateKey(signing.KeyTypeEd25519)
+		if err != nil {
+			genErr = err
+			return
+		}
+		devIssuerKey = key.Multibase()
+		log.Printf("Warning: CREDENTIAL_SIGNING_KEY not set, using ephemeral issuer key %s", key.Public().Multibase())
+	})
+	if devIssuerKey == "" {
+		return "", fmt.Errorf("failed to generate ephemeral issuer key: %v", genErr)
+	}
+
+	return devIssuerKey, nil
+}
+
+// IssuerKey is a decrypted signing key together with the DID that controls
+// it and the verification method verifiers use to look it up
+type IssuerKey struct {
+	Controller         string
+	VerificationMethod string
+	PrivateKey         *signing.PrivateKey
+}
+
+// PlatformIssuerKey returns the service's own issuer key. It is published
+// under did:web when DID_WEB_DOMAIN is set and as a did:key otherwise.
+func PlatformIssuerKey() (*IssuerKey, error) {
+	encoded, err := IssuerSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := signing.ParsePrivateKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer key: %w", err)
+	}
+
+	controller, verificationMethod := signing.DIDKey(key.Public())
+	if did := PlatformDID(); did != "" {
+		controller = did
+		verificationMethod = did + "#" + key.Public().Multibase()
+	}
+
+	return &IssuerKey{
+		Controller:         controller,
+		VerificationMethod: verificationMethod,
+		PrivateKey:         key,
+	}, nil
+}
+
+// OrganisationIssuerKey returns the active registry key of an organisation,
+// falling back to the platform key when the organisation has none
+func OrganisationIssuerKey(organisationID int) (*IssuerKey, error) {
+	key, err := NewKeyRegistry(db.DB).SigningKey(organisationID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return PlatformIssuerKey()
+	}
+	return key, err
+}
+
+// SignVerifiableClaim attaches a Data Integrity proof to the claim, making
+// the key's controller the credential issuer
+func SignVerifiableClaim(claim *models.VerifiableClaim, issuer *IssuerKey) (*models.VerifiableClaim, error) {
+	key := issuer.PrivateKey
+	claim.Issuer = issuer.Controller
+
+	cryptosuite, err := signing.CryptosuiteForKey(key.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := signing.ProofOptions{
+		Context:            claim.Context,
+		Type:               signing.ProofTypeDataIntegrity,
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: issuer.VerificationMethod,
+	}
+
+	// The proof covers the credential without any existing proof
+	unsigned := *claim
+	unsigned.Proof = nil
+
+	proofValue, err := signing.CreateProofValue(&unsigned, opts, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign credential: %w", err)
+	}
+
+	claim.Proof = &models.Proof{
+		Context:            opts.Context,
+		Type:               opts.Type,
+		Cryptosuite:        opts.Cryptosuite,
+		Created:            opts.Created,
+		ProofPurpose:       opts.ProofPurpose,
+		VerificationMethod: opts.VerificationMethod,
+		ProofValue:         proofValue,
+	}
+
+	return claim, nil
+}
+
+// VerifyVerifiableClaim verifies a Data Integrity secured credential as
+// received and returns it. The proof's verification method is resolved and
+// the key must belong to the credential issuer, be an assertion method and
+// not have been revoked or rotated out before the proof was created.
+func VerifyVerifiableClaim(data []byte) (*models.VerifiableClaim, error) {
+	return VerifyVerifiableClaimWithKey(data, "")
+}
+
+// VerifyVerifiableClaimWithKey verifies the credential like
+// VerifyVerifiableClaim and, when publicKey is set, also requires the
+// resolved verification method to hold that multibase-encoded key. A
+// supplied key never replaces resolution, so it cannot vouch for a key the
+// issuer does not control.
+func VerifyVerifiableClaimWithKey(data []byte, publicKey string) (*models.VerifiableClaim, error) {
+	claim, unsecured, err := parseSecuredClaim(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyClaimSignature(claim, unsecured, publicKey); err != nil {
+		return claim, err
+	}
+	return claim, CheckCredentialStatus(claim)
+}
+
+// parseSecuredClaim decodes a secured credential and returns it with the
+// document its proof covers: the JSON object as received without its proof,
+// so members the credential model does not know are covered too. Proof
+// members the signature does not cover are rejected.
+func parseSecuredClaim(data []byte) (*models.VerifiableClaim, map[string]interface{}, error) {
+	var unsecured map[string]interface{}
+	if err := json.Unmarshal(data, &unsecured); err != nil || unsecured == nil {
+		return nil, nil, fmt.Errorf("%w: not a JSON object", ErrInvalidCredential)
 	}
-	sort.Strings(keys)
 
-	hash := sha256.New()
-	for _, k := range keys {
-		hash.Write([]byte(k))
-		hash.Write([]byte(":"))
-		hash.Write([]byte(fmt.Sprintf("%v", subject[k])))
-		hash.Write([]byte(";"))
+	var claim models.VerifiableClaim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
 	}
 
-	signature := hex.EncodeToString(hash.Sum(nil))
+	rawProof, ok := unsecured["proof"]
+	if !ok || rawProof == nil {
+		return nil, nil, ErrMissingProof
+	}
+	delete(unsecured, "proof")
+
+	encoded, err := json.Marshal(rawProof)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	var proof models.Proof
+	if err := decoder.Decode(&proof); err != nil {
+		return nil, nil, fmt.Errorf("%w: proof: %v", ErrInvalidCredential, err)
+	}
+	claim.Proof = &proof
+
+	return &claim, unsecured, nil
+}
+
+// verifyClaimSignature resolves the claim's verification method and checks
+// its proof over the unsecured document, without looking at the claim's
+// credential status
+func verifyClaimSignature(claim *models.VerifiableClaim, unsecured interface{}, publicKey string) error {
+	if claim.Proof == nil || claim.Proof.ProofValue == "" {
+		return ErrMissingProof
+	}
+
+	created, err := time.Parse(time.RFC3339, claim.Proof.Created)
+	if err != nil {
+		return fmt.Errorf("invalid proof creation time: %w", err)
+	}
+
+	resolvedKey, err := resolveIssuerKey(claim.Issuer, claim.Proof.VerificationMethod, created)
+	if err != nil {
+		return err
+	}
 
-	claim.Proof = models.Proof{
-		Type:               "Sha256Signature2025",
-		Created:            time.Now().Format(time.RFC3339),
-		ProofPurpose:       "assertionMethod",
-		VerificationMethod: "did:example:issuer#key-1",
-		SignatureValue:     signature,
+	if publicKey != "" {
+		expected, err := signing.ParsePublicKey(publicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %w", err)
+		}
+		resolved, err := signing.ParsePublicKey(resolvedKey)
+		if err != nil || resolved.Multibase() != expected.Multibase() {
+			return ErrKeyMismatch
+		}
+	}
+
+	return verifyProofWithKey(claim.Proof, unsecured, resolvedKey)
+}
+
+// verifyProofWithKey checks a proof over the unsecured document against a
+// multibase-encoded public key
+func verifyProofWithKey(proof *models.Proof, unsecured interface{}, publicKey string) error {
+	if proof == nil || proof.ProofValue == "" {
+		return ErrMissingProof
+	}
+
+	if proof.ProofPurpose != proofPurposeAssertion {
+		return ErrUnsupportedPurpose
+	}
+
+	key, err := signing.ParsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	opts := signing.ProofOptions{
+		Context:            proof.Context,
+		Type:               proof.Type,
+		Cryptosuite:        proof.Cryptosuite,
+		Created:            proof.Created,
+		ProofPurpose:       proof.ProofPurpose,
+		VerificationMethod: proof.VerificationMethod,
+	}
+
+	return signing.VerifyProofValue(unsecured, opts, proof.ProofValue, key)
+}
+
+// resolveIssuerKey resolves a verification method and returns its public key
+// if the issuer controls it and it was usable for assertions at signedAt
+func resolveIssuerKey(issuer, verificationMethod string, signedAt time.Time) (string, error) {
+	vm, doc, err := ResolveVerificationMethod(verificationMethod)
+	if err != nil {
+		return "", err
+	}
+
+	if vm.Controller != issuer || doc.ID != issuer {
+		return "", ErrIssuerMismatch
+	}
+	if err := vm.ValidAt(signedAt); err != nil {
+		return "", err
+	}
+	if !doc.IsAssertionMethod(vm.ID) {
+		return "", fmt.Errorf("verification method %s is not authorized for assertions", vm.ID)
 	}
 
-	return claim
+	return vm.PublicKeyMultibase, nil
 }
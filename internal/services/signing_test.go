@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+)
+
+// signedTestCredential returns a credential signed with a new did:key
+// issuer, encoded as a generic JSON object
+func signedTestCredential(t *testing.T, keyType signing.KeyType) map[string]interface{} {
+	t.Helper()
+	key, err := signing.GenerateKey(keyType)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	controller, verificationMethod := signing.DIDKey(key.Public())
+
+	claim := &models.VerifiableClaim{
+		Context:   []string{credentialsContextV2, PassportContextURL},
+		ID:        "urn:uuid:5d2f1b7e",
+		Type:      []string{"VerifiableCredential", "AluminiumPassportCredential"},
+		ValidFrom: "2025-01-15T10:00:00Z",
+		CredentialSubject: map[string]interface{}{
+			"id":                     "urn:aluminium-passport:ALU-2025-001",
+			"passportId":             "ALU-2025-001",
+			"recycledContentPercent": 35,
+		},
+	}
+	signed, err := SignVerifiableClaim(claim, &IssuerKey{
+		Controller:         controller,
+		VerificationMethod: verificationMethod,
+		PrivateKey:         key,
+	})
+	if err != nil {
+		t.Fatalf("SignVerifiableClaim: %v", err)
+	}
+
+	data, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("decode credential: %v", err)
+	}
+	return document
+}
+
+func TestVerifyVerifiableClaim(t *testing.T) {
+	for _, keyType := range []signing.KeyType{signing.KeyTypeEd25519, signing.KeyTypeSecp256k1} {
+		t.Run(string(keyType), func(t *testing.T) {
+			data, err := json.Marshal(signedTestCredential(t, keyType))
+			if err != nil {
+				t.Fatalf("encode credential: %v", err)
+			}
+			claim, err := VerifyVerifiableClaim(data)
+			if err != nil {
+				t.Fatalf("VerifyVerifiableClaim: %v", err)
+			}
+			if claim.ID != "urn:uuid:5d2f1b7e" {
+				t.Errorf("claim ID = %q", claim.ID)
+			}
+		})
+	}
+}
+
+func TestVerifyVerifiableClaimRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(document map[string]interface{})
+		wantErr error
+	}{
+		{
+			name: "member unknown to the credential model",
+			tamper: func(document map[string]interface{}) {
+				document["evidence"] = []interface{}{map[string]interface{}{"type": "Audit", "verifier": "did:example:auditor"}}
+			},
+		},
+		{
+			name: "member added to the subject",
+			tamper: func(document map[string]interface{}) {
+				document["credentialSubject"].(map[string]interface{})["carbonNeutral"] = true
+			},
+		},
+		{
+			name: "changed subject",
+			tamper: func(document map[string]interface{}) {
+				document["credentialSubject"].(map[string]interface{})["recycledContentPercent"] = 95
+			},
+		},
+		{
+			name: "changed context",
+			tamper: func(document map[string]interface{}) {
+				document["@context"] = []interface{}{credentialsContextV2}
+			},
+		},
+		{
+			name: "changed cryptosuite",
+			tamper: func(document map[string]interface{}) {
+				document["proof"].(map[string]interface{})["cryptosuite"] = signing.CryptosuiteECDSAJCS2019
+			},
+		},
+		{
+			name: "proof member outside the signature",
+			tamper: func(document map[string]interface{}) {
+				document["proof"].(map[string]interface{})["domain"] = "passports.example.com"
+			},
+			wantErr: ErrInvalidCredential,
+		},
+		{
+			name: "proof removed",
+			tamper: func(document map[string]interface{}) {
+				delete(document, "proof")
+			},
+			wantErr: ErrMissingProof,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := signedTestCredential(t, signing.KeyTypeEd25519)
+			tt.tamper(document)
+			data, err := json.Marshal(document)
+			if err != nil {
+				t.Fatalf("encode credential: %v", err)
+			}
+
+			_, err = VerifyVerifiableClaim(data)
+			if err == nil {
+				t.Fatalf("VerifyVerifiableClaim accepted a tampered credential")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyVerifiableClaim error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyVerifiableClaimRejectsMalformedInput(t *testing.T) {
+	for _, data := range []string{``, `null`, `[]`, `"credential"`, `{"proof": "z3FXQ"}`} {
+		if _, err := VerifyVerifiableClaim([]byte(data)); err == nil {
+			t.Errorf("VerifyVerifiableClaim(%s) succeeded", data)
+		}
+	}
+}
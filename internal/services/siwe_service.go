@@ -0,0 +1,157 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrInvalidSIWE         = errors.New("invalid sign-in with ethereum request")
+	ErrSIWENonce           = errors.New("sign-in nonce is unknown, used or expired")
+	ErrWalletNotRegistered = errors.New("no account uses this wallet address")
+)
+
+const (
+	siweStatement = "Sign in to the Aluminium Passport platform."
+
+	// siweClockSkew is how far in the future a message may claim to have
+	// been issued
+	siweClockSkew = 5 * time.Minute
+)
+
+// SIWEService issues nonces for Sign-In with Ethereum (EIP-4361) and
+// verifies signed messages against users' wallet addresses
+type SIWEService struct {
+	db *sql.DB
+}
+
+func NewSIWEService(db *sql.DB) *SIWEService {
+	return &SIWEService{db: db}
+}
+
+// IssueNonce stores a single-use nonce. When address is given the nonce
+// is bound to it and the message to sign is prepared.
+func (ss *SIWEService) IssueNonce(address string) (*models.SIWEChallenge, error) {
+	cfg := config.AppConfig
+	if address != "" && (!common.IsHexAddress(address) || !strings.HasPrefix(address, "0x")) {
+		return nil, fmt.Errorf("%w: invalid wallet address", ErrInvalidSIWE)
+	}
+
+	nonce, err := auth.NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	challenge := &models.SIWEChallenge{
+		Nonce:     nonce,
+		Domain:    cfg.SIWEDomain,
+		URI:       cfg.PublicBaseURL,
+		ChainID:   cfg.ChainID,
+		Statement: siweStatement,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(cfg.SIWENonceTTL),
+	}
+
+	if _, err := ss.db.Exec(`DELETE FROM siwe_nonces WHERE expires_at < NOW() - INTERVAL '1 day'`); err != nil {
+		return nil, fmt.Errorf("failed to remove expired nonces: %w", err)
+	}
+	_, err = ss.db.Exec(`INSERT INTO siwe_nonces (nonce, address, issued_at, expires_at) VALUES ($1, $2, $3, $4)`,
+		nonce, nullableString(address), challenge.IssuedAt, challenge.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store nonce: %w", err)
+	}
+
+	if address != "" {
+		message := &auth.SIWEMessage{
+			Domain:         challenge.Domain,
+			Address:        common.HexToAddress(address).Hex(),
+			Statement:      challenge.Statement,
+			URI:            challenge.URI,
+			Version:        "1",
+			ChainID:        challenge.ChainID,
+			Nonce:          nonce,
+			IssuedAt:       challenge.IssuedAt,
+			ExpirationTime: &challenge.ExpiresAt,
+		}
+		challenge.Message = message.String()
+	}
+	return challenge, nil
+}
+
+// Verify checks a signed message and consumes its nonce, then returns the
+// user whose wallet signed it. Inactive users are returned too; callers
+// decide how to treat them.
+func (ss *SIWEService) Verify(message, signature string) (*db.User, error) {
+	cfg := config.AppConfig
+
+	msg, err := auth.ParseSIWEMessage(message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSIWE, err)
+	}
+	if !strings.EqualFold(msg.Domain, cfg.SIWEDomain) {
+		return nil, fmt.Errorf("%w: message is for domain %s", ErrInvalidSIWE, msg.Domain)
+	}
+	if msg.Version != "1" {
+		return nil, fmt.Errorf("%w: unsupported version %s", ErrInvalidSIWE, msg.Version)
+	}
+	if msg.ChainID != cfg.ChainID {
+		return nil, fmt.Errorf("%w: message is for chain %d", ErrInvalidSIWE, msg.ChainID)
+	}
+
+	now := time.Now()
+	if msg.IssuedAt.After(now.Add(siweClockSkew)) {
+		return nil, fmt.Errorf("%w: message is issued in the future", ErrInvalidSIWE)
+	}
+	if msg.ExpirationTime != nil && !now.Before(*msg.ExpirationTime) {
+		return nil, fmt.Errorf("%w: message has expired", ErrInvalidSIWE)
+	}
+	if msg.NotBefore != nil && now.Before(*msg.NotBefore) {
+		return nil, fmt.Errorf("%w: message is not valid yet", ErrInvalidSIWE)
+	}
+
+	signer, err := auth.RecoverSIWESigner(message, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSIWE, err)
+	}
+	if !strings.EqualFold(signer, msg.Address) {
+		return nil, fmt.Errorf("%w: signature was not made by %s", ErrInvalidSIWE, msg.Address)
+	}
+
+	// Consume the nonce only once the signature checks out
+	result, err := ss.db.Exec(`
+		UPDATE siwe_nonces SET used_at = NOW()
+		WHERE nonce = $1 AND used_at IS NULL AND expires_at > NOW()
+		  AND (address IS NULL OR LOWER(address) = LOWER($2))`, msg.Nonce, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrSIWENonce
+	}
+
+	user := &db.User{}
+	err = ss.db.QueryRow(`
+		SELECT id, wallet_address, username, email, role, company_name, organisation_id, contact_info,
+		       is_active, created_at, last_login
+		FROM users WHERE LOWER(wallet_address) = LOWER($1)`, signer,
+	).Scan(&user.ID, &user.WalletAddress, &user.Username, &user.Email, &user.Role, &user.CompanyName,
+		&user.OrganisationID, &user.ContactInfo, &user.IsActive, &user.CreatedAt, &user.LastLogin)
+	if err == sql.ErrNoRows {
+		return nil, ErrWalletNotRegistered
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db/dbtest"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// siweWallet is a wallet signing in to passports.example.com on chain 137
+type siweWallet struct {
+	key     *ecdsa.PrivateKey
+	address string
+}
+
+func newSIWEWallet(t *testing.T) *siweWallet {
+	t.Helper()
+	withConfig(t, &config.Config{
+		SIWEDomain:    "passports.example.com",
+		PublicBaseURL: "https://passports.example.com",
+		ChainID:       137,
+		SIWENonceTTL:  10 * time.Minute,
+	})
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return &siweWallet{key: key, address: crypto.PubkeyToAddress(key.PublicKey).Hex()}
+}
+
+// message returns a sign-in message for the wallet, changed by modify
+func (w *siweWallet) message(modify func(m *auth.SIWEMessage)) string {
+	expires := time.Now().Add(5 * time.Minute).UTC().Truncate(time.Second)
+	m := &auth.SIWEMessage{
+		Domain:         "passports.example.com",
+		Address:        w.address,
+		URI:            "https://passports.example.com",
+		Version:        "1",
+		ChainID:        137,
+		Nonce:          "nonce-1",
+		IssuedAt:       time.Now().Add(-time.Minute).UTC().Truncate(time.Second),
+		ExpirationTime: &expires,
+	}
+	if modify != nil {
+		modify(m)
+	}
+	return m.String()
+}
+
+func (w *siweWallet) sign(t *testing.T, message string) string {
+	t.Helper()
+	hash := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	sig, err := crypto.Sign(hash, w.key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	sig[64] += 27
+	return "0x" + hex.EncodeToString(sig)
+}
+
+// onSIWEUser serves the user whose wallet signs in, consuming nonces as
+// the database would: once each
+func onSIWEUser(fake *dbtest.DB, address string) {
+	used := map[interface{}]bool{}
+	fake.OnExec(`UPDATE siwe_nonces`, func(args []interface{}) (int64, error) {
+		if used[args[0]] {
+			return 0, nil
+		}
+		used[args[0]] = true
+		return 1, nil
+	})
+	fake.OnQuery(`FROM users WHERE LOWER(wallet_address)`,
+		dbtest.Columns(`id, wallet_address, username, email, role, company_name, organisation_id, contact_info,
+			is_active, created_at, last_login`),
+		[]interface{}{int64(5), address, "wallet-user", nil, "manufacturer", nil, int64(7), nil, true, time.Now(), nil})
+}
+
+func TestSIWEVerifyConsumesNonce(t *testing.T) {
+	wallet := newSIWEWallet(t)
+	fake := newTestDB(t)
+	onSIWEUser(fake, strings.ToLower(wallet.address))
+
+	message := wallet.message(nil)
+	signature := wallet.sign(t, message)
+	service := NewSIWEService(fake.DB)
+
+	user, err := service.Verify(message, signature)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if user.ID != 5 {
+		t.Errorf("signed in as user %d, want 5", user.ID)
+	}
+	consumed := fake.Statements(`UPDATE siwe_nonces`)
+	if len(consumed) != 1 || consumed[0].Args[0] != "nonce-1" || consumed[0].Args[1] != wallet.address {
+		t.Errorf("nonce updates = %+v, want nonce-1 consumed by %s", consumed, wallet.address)
+	}
+
+	if _, err := service.Verify(message, signature); !errors.Is(err, ErrSIWENonce) {
+		t.Errorf("replayed message: Verify error = %v, want %v", err, ErrSIWENonce)
+	}
+}
+
+func TestSIWEVerifyRejects(t *testing.T) {
+	wallet := newSIWEWallet(t)
+	other := newSIWEWallet(t)
+
+	tests := []struct {
+		name   string
+		modify func(m *auth.SIWEMessage)
+		signer *siweWallet
+	}{
+		{name: "other domain", modify: func(m *auth.SIWEMessage) { m.Domain = "passports.example.org" }},
+		{name: "other chain", modify: func(m *auth.SIWEMessage) { m.ChainID = 1 }},
+		{name: "other version", modify: func(m *auth.SIWEMessage) { m.Version = "2" }},
+		{name: "expired", modify: func(m *auth.SIWEMessage) {
+			expired := time.Now().Add(-time.Second).UTC().Truncate(time.Second)
+			m.ExpirationTime = &expired
+		}},
+		{name: "not valid yet", modify: func(m *auth.SIWEMessage) {
+			notBefore := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			m.NotBefore = &notBefore
+		}},
+		{name: "issued in the future", modify: func(m *auth.SIWEMessage) { m.IssuedAt = time.Now().Add(time.Hour).UTC().Truncate(time.Second) }},
+		{name: "signed by another wallet", signer: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestDB(t)
+			onSIWEUser(fake, wallet.address)
+
+			signer := wallet
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+			message := wallet.message(tt.modify)
+			if _, err := NewSIWEService(fake.DB).Verify(message, signer.sign(t, message)); !errors.Is(err, ErrInvalidSIWE) {
+				t.Fatalf("Verify error = %v, want %v", err, ErrInvalidSIWE)
+			}
+			if consumed := fake.Statements(`UPDATE siwe_nonces`); len(consumed) != 0 {
+				t.Errorf("consumed the nonce of a rejected message")
+			}
+		})
+	}
+}
+
+func TestSIWEVerifyUnregisteredWallet(t *testing.T) {
+	wallet := newSIWEWallet(t)
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM users WHERE LOWER(wallet_address)`, dbtest.Columns(`id`))
+
+	message := wallet.message(nil)
+	if _, err := NewSIWEService(fake.DB).Verify(message, wallet.sign(t, message)); !errors.Is(err, ErrWalletNotRegistered) {
+		t.Errorf("Verify error = %v, want %v", err, ErrWalletNotRegistered)
+	}
+}
+
+func TestSIWEIssueNonce(t *testing.T) {
+	wallet := newSIWEWallet(t)
+	fake := newTestDB(t)
+	service := NewSIWEService(fake.DB)
+
+	challenge, err := service.IssueNonce(strings.ToLower(wallet.address))
+	if err != nil {
+		t.Fatalf("IssueNonce: %v", err)
+	}
+	stored := fake.Statements(`INSERT INTO siwe_nonces`)
+	if len(stored) != 1 || stored[0].Args[0] != challenge.Nonce || stored[0].Args[1] != strings.ToLower(wallet.address) {
+		t.Fatalf("stored nonces = %+v, want %s bound to the wallet", stored, challenge.Nonce)
+	}
+
+	// The prepared message is what the wallet signs and the server accepts
+	message, err := auth.ParseSIWEMessage(challenge.Message)
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage: %v", err)
+	}
+	if message.Address != wallet.address || message.Nonce != challenge.Nonce || message.Domain != "passports.example.com" {
+		t.Errorf("message = %+v, want the checksummed wallet address and the new nonce", message)
+	}
+
+	if _, err := service.IssueNonce("not-an-address"); !errors.Is(err, ErrInvalidSIWE) {
+		t.Errorf("IssueNonce error = %v, want %v", err, ErrInvalidSIWE)
+	}
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+)
+
+// Status purposes of a Bitstring Status List. Revocation is permanent;
+// suspension bits are cleared again when the cause goes away.
+const (
+	StatusPurposeRevocation = "revocation"
+	StatusPurposeSuspension = "suspension"
+)
+
+// Reasons recorded against set status bits
+const (
+	StatusReasonPassportDeactivated  = "passport_deactivated"
+	StatusReasonCertificationExpired = "certification_expired"
+)
+
+// statusListSize is the number of entries per list. 131072 bits (16KB) is
+// the minimum the spec recommends for group privacy.
+const statusListSize = 131072
+
+// maxStatusListBytes bounds a decompressed status list, 16M entries
+const maxStatusListBytes = 1 << 21
+
+const (
+	statusListEntryType      = "BitstringStatusListEntry"
+	statusListCredentialType = "BitstringStatusListCredential"
+	statusListSubjectType    = "BitstringStatusList"
+)
+
+var (
+	ErrCredentialRevoked   = errors.New("credential has been revoked")
+	ErrCredentialSuspended = errors.New("credential is suspended")
+	ErrStatusListNotFound  = errors.New("status list not found")
+	ErrUntrustedStatusList = errors.New("status list host is not trusted")
+)
+
+var statusListClient = newPublicHTTPClient(10 * time.Second)
+
+// StatusListService allocates status entries for issued credentials and
+// maintains the published bitstrings
+type StatusListService struct {
+	db *sql.DB
+}
+
+func NewStatusListService(db *sql.DB) *StatusListService {
+	return &StatusListService{db: db}
+}
+
+// AllocateStatus reserves a revocation and a suspension entry for a new
+// credential and returns the credentialStatus entries to embed in it
+func (ss *StatusListService) AllocateStatus(credentialID, passportID, issuer string) ([]models.CredentialStatus, error) {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entries []models.CredentialStatus
+	for _, purpose := range []string{StatusPurposeRevocation, StatusPurposeSuspension} {
+		listID, index, err := ss.nextIndexTx(tx, purpose)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO credential_status_entries (
+				credential_id, passport_id, issuer, status_list_id, status_index, status_purpose
+			) VALUES ($1, $2, $3, $4, $5, $6)`,
+			credentialID, passportID, issuer, listID, index, purpose,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store status entry: %w", err)
+		}
+
+		listURL := StatusListURL(listID)
+		entries = append(entries, models.CredentialStatus{
+			ID:                   fmt.Sprintf("%s#%d", listURL, index),
+			Type:                 statusListEntryType,
+			StatusPurpose:        purpose,
+			StatusListIndex:      strconv.Itoa(index),
+			StatusListCredential: listURL,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return entries, nil
+}
+
+// SetPassportStatus sets or clears the bit of every credential issued for a
+// passport. When clearing, only bits set for the given reason are cleared.
+// It returns the number of credentials whose status changed.
+func (ss *StatusListService) SetPassportStatus(passportID, purpose, reason string, set bool) (int, error) {
+	if purpose == StatusPurposeRevocation && !set {
+		return 0, fmt.Errorf("revocation is permanent")
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, status_list_id, status_index
+		FROM credential_status_entries
+		WHERE passport_id = $1 AND status_purpose = $2 AND is_set = $3`
+	args := []interface{}{passportID, purpose, !set}
+	if !set {
+		query += ` AND reason = $4`
+		args = append(args, reason)
+	}
+
+	rows, err := tx.Query(query+` ORDER BY status_list_id FOR UPDATE`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	type entry struct{ id, listID, index int }
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.listID, &e.index); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Apply all changes to each list in a single read-modify-write
+	lists := map[int][]byte{}
+	for _, e := range entries {
+		bits, ok := lists[e.listID]
+		if !ok {
+			if err := tx.QueryRow(`SELECT bitstring FROM status_lists WHERE id = $1 FOR UPDATE`, e.listID).Scan(&bits); err != nil {
+				return 0, err
+			}
+			lists[e.listID] = bits
+		}
+		setBit(bits, e.index, set)
+
+		var entryReason *string
+		if set {
+			entryReason = &reason
+		}
+		_, err := tx.Exec(
+			`UPDATE credential_status_entries SET is_set = $1, reason = $2 WHERE id = $3`,
+			set, entryReason, e.id,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for listID, bits := range lists {
+		if _, err := tx.Exec(`UPDATE status_lists SET bitstring = $1 WHERE id = $2`, bits, listID); err != nil {
+			return 0, fmt.Errorf("failed to update status list: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(entries), nil
+}
+
+// SweepCertificationExpiry suspends credentials of passports whose
+// certification has expired and lifts the suspension once they are
+// recertified
+func (ss *StatusListService) SweepCertificationExpiry() error {
+	expired, err := ss.passportIDs(`
+		SELECT DISTINCT e.passport_id
+		FROM credential_status_entries e
+		JOIN aluminium_passports p ON p.passport_id = e.passport_id
+		WHERE e.status_purpose = $1 AND e.is_set = false
+		  AND p.certification_expiry < CURRENT_DATE`, StatusPurposeSuspension)
+	if err != nil {
+		return err
+	}
+
+	for _, passportID := range expired {
+		if _, err := ss.SetPassportStatus(passportID, StatusPurposeSuspension, StatusReasonCertificationExpired, true); err != nil {
+			return fmt.Errorf("failed to suspend %s: %w", passportID, err)
+		}
+	}
+
+	renewed, err := ss.passportIDs(`
+		SELECT DISTINCT e.passport_id
+		FROM credential_status_entries e
+		JOIN aluminium_passports p ON p.passport_id = e.passport_id
+		WHERE e.status_purpose = $1 AND e.is_set = true AND e.reason = $2
+		  AND (p.certification_expiry IS NULL OR p.certification_expiry >= CURRENT_DATE)`,
+		StatusPurposeSuspension, StatusReasonCertificationExpired)
+	if err != nil {
+		return err
+	}
+
+	for _, passportID := range renewed {
+		if _, err := ss.SetPassportStatus(passportID, StatusPurposeSuspension, StatusReasonCertificationExpired, false); err != nil {
+			return fmt.Errorf("failed to reinstate %s: %w", passportID, err)
+		}
+	}
+
+	if len(expired) > 0 || len(renewed) > 0 {
+		log.Printf("Credential status sweep: %d suspended, %d reinstated", len(expired), len(renewed))
+	}
+	return nil
+}
+
+// RunExpirySweeper runs SweepCertificationExpiry on an interval until ctx is
+// cancelled
+func (ss *StatusListService) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := ss.SweepCertificationExpiry(); err != nil {
+			log.Printf("Credential status sweep failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StatusListCredential builds the signed BitstringStatusListCredential for
+// a list, reflecting its current bits
+func (ss *StatusListService) StatusListCredential(listID int) (*models.VerifiableClaim, error) {
+	purpose, bits, err := readStatusList(ss.db, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := encodeStatusList(bits)
+	if err != nil {
+		return nil, err
+	}
+
+	listURL := StatusListURL(listID)
+	claim := &models.VerifiableClaim{
+		Context:   []string{credentialsContextV2},
+		ID:        listURL,
+		Type:      []string{"VerifiableCredential", statusListCredentialType},
+		ValidFrom: time.Now().UTC().Format(time.RFC3339),
+		CredentialSubject: &models.StatusListSubject{
+			ID:            listURL + "#list",
+			Type:          statusListSubjectType,
+			StatusPurpose: purpose,
+			EncodedList:   encoded,
+		},
+	}
+
+	issuer, err := PlatformIssuerKey()
+	if err != nil {
+		return nil, err
+	}
+	return SignVerifiableClaim(claim, issuer)
+}
+
+// CheckCredentialStatus looks up every status entry of a credential and
+// fails if any of its bits is set. Lists hosted here are read from the
+// database; others are fetched over HTTPS from TRUSTED_STATUS_LIST_HOSTS and
+// their proof verified.
+func CheckCredentialStatus(claim *models.VerifiableClaim) error {
+	for _, status := range claim.CredentialStatus {
+		if status.Type != statusListEntryType {
+			return fmt.Errorf("unsupported credential status type %q", status.Type)
+		}
+
+		index, err := strconv.Atoi(status.StatusListIndex)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid status list index %q", status.StatusListIndex)
+		}
+
+		purpose, bits, err := loadStatusList(status.StatusListCredential)
+		if err != nil {
+			return err
+		}
+		if purpose != status.StatusPurpose {
+			return fmt.Errorf("status list %s is for %s, not %s", status.StatusListCredential, purpose, status.StatusPurpose)
+		}
+		if index >= len(bits)*8 {
+			return fmt.Errorf("status list index %d out of range", index)
+		}
+
+		if getBit(bits, index) {
+			if purpose == StatusPurposeSuspension {
+				return ErrCredentialSuspended
+			}
+			return ErrCredentialRevoked
+		}
+	}
+	return nil
+}
+
+// StatusListURL is the public URL of a status list credential
+func StatusListURL(listID int) string {
+	return fmt.Sprintf("%s/status/%d", strings.TrimRight(publicBaseURL(), "/"), listID)
+}
+
+func (ss *StatusListService) nextIndexTx(tx *sql.Tx, purpose string) (int, int, error) {
+	var listID, index int
+	err := tx.QueryRow(`
+		SELECT id, next_index FROM status_lists
+		WHERE status_purpose = $1 AND next_index < size
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE`, purpose,
+	).Scan(&listID, &index)
+
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(
+			`INSERT INTO status_lists (status_purpose, size, bitstring) VALUES ($1, $2, $3) RETURNING id, next_index`,
+			purpose, statusListSize, make([]byte, statusListSize/8),
+		).Scan(&listID, &index)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to allocate status index: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE status_lists SET next_index = next_index + 1 WHERE id = $1`, listID); err != nil {
+		return 0, 0, err
+	}
+	return listID, index, nil
+}
+
+func (ss *StatusListService) passportIDs(query string, args ...interface{}) ([]string, error) {
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func loadStatusList(listURL string) (string, []byte, error) {
+	localPrefix := strings.TrimRight(publicBaseURL(), "/") + "/status/"
+	if strings.HasPrefix(listURL, localPrefix) {
+		listID, err := strconv.Atoi(strings.TrimPrefix(listURL, localPrefix))
+		if err != nil {
+			return "", nil, ErrStatusListNotFound
+		}
+
+		return readStatusList(db.DB, listID)
+	}
+
+	if !isTrustedStatusListURL(listURL) {
+		return "", nil, fmt.Errorf("%w: %s", ErrUntrustedStatusList, listURL)
+	}
+	return fetchStatusList(listURL)
+}
+
+// isTrustedStatusListURL reports whether listURL is an HTTPS URL on one of
+// the configured status list hosts
+func isTrustedStatusListURL(listURL string) bool {
+	u, err := url.Parse(listURL)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	if config.AppConfig == nil {
+		return false
+	}
+	for _, host := range config.AppConfig.StatusListHosts {
+		if strings.EqualFold(u.Host, strings.TrimSpace(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+func readStatusList(conn *sql.DB, listID int) (string, []byte, error) {
+	var purpose string
+	var bits []byte
+	err := conn.QueryRow(
+		`SELECT status_purpose, bitstring FROM status_lists WHERE id = $1`, listID,
+	).Scan(&purpose, &bits)
+	if err == sql.ErrNoRows {
+		return "", nil, ErrStatusListNotFound
+	}
+	return purpose, bits, err
+}
+
+func fetchStatusList(listURL string) (string, []byte, error) {
+	resp, err := statusListClient.Get(listURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch status list %s: %w", listURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%w: %s returned %d", ErrStatusListNotFound, listURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDocumentSize))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read status list %s: %w", listURL, err)
+	}
+	claim, unsecured, err := parseSecuredClaim(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid status list credential: %w", err)
+	}
+	// Only the list's signature is checked. Checking its own status would
+	// fetch more lists, without end for a list that points at itself.
+	if err := verifyClaimSignature(claim, unsecured, ""); err != nil {
+		return "", nil, fmt.Errorf("status list credential failed verification: %w", err)
+	}
+
+	raw, err := json.Marshal(claim.CredentialSubject)
+	if err != nil {
+		return "", nil, err
+	}
+	var subject models.StatusListSubject
+	if err := json.Unmarshal(raw, &subject); err != nil || subject.Type != statusListSubjectType {
+		return "", nil, fmt.Errorf("invalid status list credential subject")
+	}
+
+	bits, err := decodeStatusList(subject.EncodedList)
+	if err != nil {
+		return "", nil, err
+	}
+	return subject.StatusPurpose, bits, nil
+}
+
+// encodeStatusList GZIP-compresses the bitstring and encodes it as a
+// base64url multibase string ("u" prefix)
+func encodeStatusList(bits []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(bits); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return "u" + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodeStatusList(encoded string) ([]byte, error) {
+	if !strings.HasPrefix(encoded, "u") {
+		return nil, fmt.Errorf("encodedList is not base64url multibase")
+	}
+
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid encodedList: %w", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encodedList: %w", err)
+	}
+	defer zr.Close()
+
+	bits, err := io.ReadAll(io.LimitReader(zr, maxStatusListBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encodedList: %w", err)
+	}
+	if len(bits) > maxStatusListBytes {
+		return nil, fmt.Errorf("encodedList exceeds %d entries", maxStatusListBytes*8)
+	}
+	return bits, nil
+}
+
+// Bit 0 is the most significant bit of the first byte
+func getBit(bits []byte, index int) bool {
+	return bits[index/8]&(0x80>>(index%8)) != 0
+}
+
+func setBit(bits []byte, index int, value bool) {
+	if value {
+		bits[index/8] |= 0x80 >> (index % 8)
+	} else {
+		bits[index/8] &^= 0x80 >> (index % 8)
+	}
+}
+
+func publicBaseURL() string {
+	if config.AppConfig == nil {
+		return ""
+	}
+	return config.AppConfig.PublicBaseURL
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/models"
+)
+
+// withConfig installs cfg as the application config for the rest of the test
+func withConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func TestStatusListEncodingRoundTrip(t *testing.T) {
+	bits := make([]byte, statusListSize/8)
+	for _, index := range []int{0, 7, 8, 4242, statusListSize - 1} {
+		setBit(bits, index, true)
+	}
+	setBit(bits, 4242, false)
+
+	encoded, err := encodeStatusList(bits)
+	if err != nil {
+		t.Fatalf("encodeStatusList() error = %v", err)
+	}
+	decoded, err := decodeStatusList(encoded)
+	if err != nil {
+		t.Fatalf("decodeStatusList() error = %v", err)
+	}
+	if !bytes.Equal(decoded, bits) {
+		t.Fatalf("status list did not round-trip")
+	}
+
+	tests := []struct {
+		index int
+		want  bool
+	}{
+		{0, true},
+		{1, false},
+		{7, true},
+		{8, true},
+		{4242, false},
+		{statusListSize - 1, true},
+	}
+	for _, tt := range tests {
+		if got := getBit(decoded, tt.index); got != tt.want {
+			t.Errorf("getBit(%d) = %v, want %v", tt.index, got, tt.want)
+		}
+	}
+	if decoded[0] != 0x81 {
+		t.Errorf("first byte = %#x, want bit 0 as the most significant bit", decoded[0])
+	}
+}
+
+func TestDecodeStatusListRejectsMalformedInput(t *testing.T) {
+	// A small gzip stream that inflates past the size limit
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	if _, err := zw.Write(make([]byte, maxStatusListBytes+1)); err != nil {
+		t.Fatalf("gzip write error = %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"no multibase prefix", base64.RawURLEncoding.EncodeToString(bomb.Bytes())},
+		{"not base64url", "u!!!"},
+		{"not gzip", "u" + base64.RawURLEncoding.EncodeToString([]byte("plain bits"))},
+		{"exceeds size limit", "u" + base64.RawURLEncoding.EncodeToString(bomb.Bytes())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeStatusList(tt.encoded); err == nil {
+				t.Errorf("decodeStatusList() succeeded")
+			}
+		})
+	}
+}
+
+func TestIsTrustedStatusListURL(t *testing.T) {
+	withConfig(t, &config.Config{StatusListHosts: []string{"status.example.org", " lists.example.com "}})
+
+	tests := []struct {
+		name    string
+		listURL string
+		want    bool
+	}{
+		{"configured host", "https://status.example.org/lists/1", true},
+		{"host compared case-insensitively", "https://STATUS.example.org/lists/1", true},
+		{"configured host with spaces", "https://lists.example.com/1", true},
+		{"plain HTTP", "http://status.example.org/lists/1", false},
+		{"other host", "https://evil.example.net/lists/1", false},
+		{"configured host as subdomain", "https://status.example.org.evil.example.net/lists/1", false},
+		{"other port", "https://status.example.org:8443/lists/1", false},
+		{"user info", "https://status.example.org@169.254.169.254/lists/1", false},
+		{"not a URL", "://", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTrustedStatusListURL(tt.listURL); got != tt.want {
+				t.Errorf("isTrustedStatusListURL(%q) = %v, want %v", tt.listURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTrustedStatusListURLWithoutConfig(t *testing.T) {
+	withConfig(t, nil)
+	if isTrustedStatusListURL("https://status.example.org/lists/1") {
+		t.Errorf("isTrustedStatusListURL() trusted a host without configuration")
+	}
+}
+
+func TestCheckCredentialStatusRejectsUntrustedList(t *testing.T) {
+	withConfig(t, &config.Config{PublicBaseURL: "https://passport.example.org"})
+
+	tests := []struct {
+		name   string
+		status models.CredentialStatus
+		want   error
+	}{
+		{
+			name: "untrusted host",
+			status: models.CredentialStatus{
+				Type:                 statusListEntryType,
+				StatusPurpose:        StatusPurposeRevocation,
+				StatusListIndex:      "12",
+				StatusListCredential: "https://169.254.169.254/latest/meta-data",
+			},
+			want: ErrUntrustedStatusList,
+		},
+		{
+			name: "local list with malformed ID",
+			status: models.CredentialStatus{
+				Type:                 statusListEntryType,
+				StatusPurpose:        StatusPurposeRevocation,
+				StatusListIndex:      "12",
+				StatusListCredential: "https://passport.example.org/status/abc",
+			},
+			want: ErrStatusListNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &models.VerifiableClaim{CredentialStatus: []models.CredentialStatus{tt.status}}
+			if err := CheckCredentialStatus(claim); !errors.Is(err, tt.want) {
+				t.Errorf("CheckCredentialStatus() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// TenantScope is the set of passports a user may see and change: those of
+// the user's organisation, those shared with it through a passport grant,
+// and every passport for roles holding passport:read_any or
+// passport:write_any
+type TenantScope struct {
+	OrganisationID int
+	ReadAny        bool
+	WriteAny       bool
+}
+
+// NewTenantScope returns the scope of the user the claims were issued to
+func NewTenantScope(claims *auth.Claims) TenantScope {
+	writeAny := HasPermission(claims.Role, models.PermPassportWriteAny)
+	return TenantScope{
+		OrganisationID: claims.OrganisationID,
+		ReadAny:        writeAny || HasPermission(claims.Role, models.PermPassportReadAny),
+		WriteAny:       writeAny,
+	}
+}
+
+// ReadCondition returns an SQL condition selecting the rows of table, an
+// aluminium_passports table or alias, that the scope may read. Its
+// placeholder is numbered argIndex.
+func (s TenantScope) ReadCondition(table string, argIndex int) (string, []interface{}) {
+	if s.ReadAny {
+		return "TRUE", nil
+	}
+	if s.OrganisationID == 0 {
+		return "FALSE", nil
+	}
+	condition := fmt.Sprintf(`(%[1]s.organisation_id = $%[2]d OR EXISTS (
+		SELECT 1 FROM passport_grants g
+		WHERE g.passport_id = %[1]s.passport_id AND g.organisation_id = $%[2]d
+		  AND g.revoked_at IS NULL AND (g.expires_at IS NULL OR g.expires_at > NOW())))`, table, argIndex)
+	return condition, []interface{}{s.OrganisationID}
+}
+
+// PassportAccess is what a scope may do with one passport. Owners may
+// change every section and manage the passport's grants.
+type PassportAccess struct {
+	Read          bool
+	Owner         bool
+	WriteSections []string
+}
+
+// CanWrite reports whether section of the passport may be changed
+func (a PassportAccess) CanWrite(section string) bool {
+	if a.Owner {
+		return true
+	}
+	for _, s := range a.WriteSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedFields returns the passport fields, in the order given, that a
+// user with role may not write to the passport: those of sections the role
+// does not own and those of sections the passport is not writable in
+func (a PassportAccess) DeniedFields(role string, fields []string) []string {
+	denied := []string{}
+	for _, field := range fields {
+		section, ok := models.PassportFieldSection(field)
+		if !ok {
+			continue
+		}
+		if !a.CanWrite(section) || !HasPermission(role, models.SectionPermission(section)) {
+			denied = append(denied, field)
+		}
+	}
+	return denied
+}
+
+// Access returns the access of the scope to a passport owned by
+// organisationID, which is nil for passports without an organisation
+func (s TenantScope) Access(passportID string, organisationID *int) (PassportAccess, error) {
+	if s.WriteAny || (s.OrganisationID != 0 && organisationID != nil && *organisationID == s.OrganisationID) {
+		return PassportAccess{Read: true, Owner: true}, nil
+	}
+
+	access := PassportAccess{Read: s.ReadAny}
+	if s.OrganisationID == 0 {
+		return access, nil
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT access, sections FROM passport_grants
+		WHERE passport_id = $1 AND organisation_id = $2
+		  AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`,
+		passportID, s.OrganisationID)
+	if err != nil {
+		return access, fmt.Errorf("failed to read passport grants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var grantAccess string
+		var sections []string
+		if err := rows.Scan(&grantAccess, pq.Array(&sections)); err != nil {
+			return access, fmt.Errorf("failed to scan passport grant: %w", err)
+		}
+		access.Read = true
+		if grantAccess == models.GrantAccessWrite {
+			access.WriteSections = append(access.WriteSections, sections...)
+		}
+	}
+	return access, rows.Err()
+}
+
+// AccessByID looks up the organisation of a passport and returns the access
+// of the scope to it. It returns sql.ErrNoRows when the passport does not
+// exist.
+func (s TenantScope) AccessByID(passportID string) (PassportAccess, error) {
+	var organisationID *int
+	err := db.DB.QueryRow(`SELECT organisation_id FROM aluminium_passports WHERE passport_id = $1`, passportID).Scan(&organisationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PassportAccess{}, err
+		}
+		return PassportAccess{}, fmt.Errorf("failed to read passport organisation: %w", err)
+	}
+	return s.Access(passportID, organisationID)
+}
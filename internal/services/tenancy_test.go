@@ -0,0 +1,154 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/auth"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+// newTestDB installs a fake database that holds the default role
+// permissions
+func newTestDB(t *testing.T) *dbtest.DB {
+	t.Helper()
+	fake := dbtest.New(t)
+	fake.OnQuery(`FROM role_permissions`, []string{"role", "permissions"})
+
+	resetPolicyCache := func() {
+		policyCacheMu.Lock()
+		policyCacheBindings = nil
+		policyCacheMu.Unlock()
+	}
+	resetPolicyCache()
+	t.Cleanup(resetPolicyCache)
+	return fake
+}
+
+// passportRow returns an aluminium_passports row selected with
+// PassportColumns, overriding the given columns
+func passportRow(passportID string, values map[string]interface{}) []interface{} {
+	now := time.Now().UTC()
+	row := map[string]interface{}{
+		"id":             int64(1),
+		"passport_id":    passportID,
+		"manufacturer":   "Example Smelter",
+		"origin":         "Guinea",
+		"times_recycled": int64(0),
+		"status":         "active",
+		"is_verified":    false,
+		"created_at":     now,
+		"updated_at":     now,
+	}
+	for column, value := range values {
+		row[column] = value
+	}
+	return dbtest.Row(dbtest.Columns(PassportColumns), row)
+}
+
+func TestGetPassportRecordReadsOrganisation(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM aluminium_passports WHERE passport_id = $1`, dbtest.Columns(PassportColumns),
+		passportRow("AP-1", map[string]interface{}{"organisation_id": int64(7)}))
+
+	passport, err := GetPassportRecord("AP-1")
+	if err != nil {
+		t.Fatalf("GetPassportRecord: %v", err)
+	}
+	if passport.OrganisationID == nil || *passport.OrganisationID != 7 {
+		t.Fatalf("OrganisationID = %v, want 7", passport.OrganisationID)
+	}
+}
+
+func TestGetPassportRecordNotFound(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM aluminium_passports WHERE passport_id = $1`, dbtest.Columns(PassportColumns))
+
+	if _, err := GetPassportRecord("AP-404"); err != ErrPassportNotFound {
+		t.Fatalf("GetPassportRecord error = %v, want %v", err, ErrPassportNotFound)
+	}
+}
+
+func TestTenantScopeAccess(t *testing.T) {
+	owner := 7
+	other := 8
+
+	tests := []struct {
+		name         string
+		claims       auth.Claims
+		organisation *int
+		grants       [][]interface{}
+		want         PassportAccess
+	}{
+		{
+			name:         "owning organisation",
+			claims:       auth.Claims{Role: models.RoleManufacturer, OrganisationID: owner},
+			organisation: &owner,
+			want:         PassportAccess{Read: true, Owner: true},
+		},
+		{
+			name:         "write any",
+			claims:       auth.Claims{Role: models.RoleAdmin},
+			organisation: &other,
+			want:         PassportAccess{Read: true, Owner: true},
+		},
+		{
+			name:         "read any",
+			claims:       auth.Claims{Role: models.RoleAuditor},
+			organisation: &owner,
+			want:         PassportAccess{Read: true},
+		},
+		{
+			name:         "other organisation without a grant",
+			claims:       auth.Claims{Role: models.RoleManufacturer, OrganisationID: other},
+			organisation: &owner,
+			want:         PassportAccess{},
+		},
+		{
+			name:         "read grant",
+			claims:       auth.Claims{Role: models.RoleManufacturer, OrganisationID: other},
+			organisation: &owner,
+			grants:       [][]interface{}{{models.GrantAccessRead, []string{}}},
+			want:         PassportAccess{Read: true},
+		},
+		{
+			name:         "write grants",
+			claims:       auth.Claims{Role: models.RoleRecycler, OrganisationID: other},
+			organisation: &owner,
+			grants: [][]interface{}{
+				{models.GrantAccessWrite, []string{models.SectionRecycling}},
+				{models.GrantAccessWrite, []string{models.SectionLogistics}},
+			},
+			want: PassportAccess{Read: true, WriteSections: []string{models.SectionRecycling, models.SectionLogistics}},
+		},
+		{
+			name:         "passport without an organisation",
+			claims:       auth.Claims{Role: models.RoleManufacturer, OrganisationID: other},
+			organisation: nil,
+			want:         PassportAccess{},
+		},
+		{
+			name:         "user without an organisation",
+			claims:       auth.Claims{Role: models.RoleViewer},
+			organisation: &owner,
+			want:         PassportAccess{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestDB(t)
+			fake.OnQuery(`FROM passport_grants`, []string{"access", "sections"}, tt.grants...)
+
+			got, err := NewTenantScope(&tt.claims).Access("AP-1", tt.organisation)
+			if err != nil {
+				t.Fatalf("Access: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Access = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
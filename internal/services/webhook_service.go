@@ -0,0 +1,723 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrInvalidWebhook          = errors.New("invalid webhook subscription")
+	ErrInvalidReplay           = errors.New("invalid webhook replay")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookDeliveryNotDead  = errors.New("webhook delivery is not dead-lettered")
+)
+
+const (
+	// webhookLease is how long a claimed delivery is held before another
+	// worker may retry it
+	webhookLease = 2 * time.Minute
+
+	// maxWebhookReplayWindow bounds how much history one replay may queue
+	maxWebhookReplayWindow = 31 * 24 * time.Hour
+
+	// webhookResponseLimit is how much of a response body is kept per attempt
+	webhookResponseLimit = 1024
+)
+
+// webhookWake wakes the delivery worker when deliveries are queued
+var webhookWake = make(chan struct{}, 1)
+
+func wakeWebhookDelivery() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// WebhookService manages webhook subscriptions and delivers passport events
+// to them, signed with each subscription's secret
+type WebhookService struct {
+	db     *sql.DB
+	client *http.Client
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	timeout := 10 * time.Second
+	if config.AppConfig != nil && config.AppConfig.WebhookTimeout > 0 {
+		timeout = config.AppConfig.WebhookTimeout
+	}
+	// Endpoints are supplied by users, so deliveries may only reach public
+	// addresses, and a redirect is treated as a failed delivery rather than
+	// followed
+	client := newPublicHTTPClient(timeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &WebhookService{db: db, client: client}
+}
+
+const webhookSubscriptionColumns = `id, organisation_id, url, event_types, description, is_active, created_by, created_at, updated_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{}
+	err := row.Scan(&sub.ID, &sub.OrganisationID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Description, &sub.IsActive,
+		&sub.CreatedBy, &sub.CreatedAt, &sub.UpdatedAt)
+	return sub, err
+}
+
+// validateWebhook checks the endpoint URL and event types of a subscription.
+// The endpoint host must resolve to public addresses only.
+func validateWebhook(endpoint string, eventTypes []string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: url must be absolute", ErrInvalidWebhook)
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || (config.AppConfig != nil && config.AppConfig.IsProduction())) {
+		return fmt.Errorf("%w: url must use https", ErrInvalidWebhook)
+	}
+
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("%w: at least one event type is required", ErrInvalidWebhook)
+	}
+	known := map[string]bool{}
+	for _, eventType := range models.WebhookEventTypes() {
+		known[eventType] = true
+	}
+	for _, eventType := range eventTypes {
+		if !known[eventType] {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := checkPublicHost(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	return nil
+}
+
+// newWebhookSecret returns a random signing secret
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func sealWebhookSecret(secret string) (string, error) {
+	encryptionSecret, err := keyEncryptionSecret()
+	if err != nil {
+		return "", err
+	}
+	return signing.SealSecret(secret, encryptionSecret)
+}
+
+// CreateSubscription stores a subscription for sub.OrganisationID, or for
+// every organisation when it is nil. Callers check that the creator may
+// subscribe to it. A secret is generated when none is given; sub.Secret
+// holds it afterwards so it can be shown once.
+func (ws *WebhookService) CreateSubscription(sub *models.WebhookSubscription, createdBy int) error {
+	if err := validateWebhook(sub.URL, sub.EventTypes); err != nil {
+		return err
+	}
+	if sub.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		sub.Secret = secret
+	}
+	sealed, err := sealWebhookSecret(sub.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+
+	sub.CreatedBy = &createdBy
+	err = ws.db.QueryRow(`
+		INSERT INTO webhook_subscriptions (organisation_id, url, event_types, encrypted_secret, description, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		sub.OrganisationID, sub.URL, pq.Array(sub.EventTypes), sealed, sub.Description, sub.IsActive, createdBy,
+	).Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListSubscriptions returns the subscriptions of an organisation, or every
+// subscription when organisationID is 0, without secrets
+func (ws *WebhookService) ListSubscriptions(organisationID int) ([]*models.WebhookSubscription, error) {
+	rows, err := ws.db.Query(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions
+		WHERE $1 = 0 OR organisation_id = $1
+		ORDER BY id`, organisationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []*models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// Subscription returns one subscription, without its secret
+func (ws *WebhookService) Subscription(id int) (*models.WebhookSubscription, error) {
+	sub, err := scanWebhookSubscription(ws.db.QueryRow(
+		`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// UpdateSubscription applies an update and returns the subscription as
+// stored. Pending deliveries of a disabled subscription wait until it is
+// enabled again.
+func (ws *WebhookService) UpdateSubscription(id int, update *models.WebhookSubscriptionUpdate) (*models.WebhookSubscription, error) {
+	sub, err := ws.Subscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if update.URL != nil {
+		sub.URL = *update.URL
+	}
+	if update.EventTypes != nil {
+		sub.EventTypes = update.EventTypes
+	}
+	if update.Description != nil {
+		sub.Description = nullableString(*update.Description)
+	}
+	if update.IsActive != nil {
+		sub.IsActive = *update.IsActive
+	}
+	if err := validateWebhook(sub.URL, sub.EventTypes); err != nil {
+		return nil, err
+	}
+
+	err = ws.db.QueryRow(`
+		UPDATE webhook_subscriptions
+		SET url = $2, event_types = $3, description = $4, is_active = $5
+		WHERE id = $1
+		RETURNING updated_at`,
+		id, sub.URL, pq.Array(sub.EventTypes), sub.Description, sub.IsActive,
+	).Scan(&sub.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	if sub.IsActive {
+		wakeWebhookDelivery()
+	}
+	return sub, nil
+}
+
+// RotateSecret replaces a subscription's signing secret and returns the
+// new one. Deliveries sent from now on are signed with it.
+func (ws *WebhookService) RotateSecret(id int) (string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	sealed, err := sealWebhookSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+
+	result, err := ws.db.Exec(`UPDATE webhook_subscriptions SET encrypted_secret = $2 WHERE id = $1`, id, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", ErrWebhookNotFound
+	}
+	return secret, nil
+}
+
+// DeleteSubscription removes a subscription with its delivery history
+func (ws *WebhookService) DeleteSubscription(id int) error {
+	result, err := ws.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Publish stores an event about the passport resourceID and queues it for
+// every active subscription to its type that may see the passport
+func (ws *WebhookService) Publish(eventType, resourceID string, data interface{}) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queued, err := publishWebhookEventTx(tx, eventType, resourceID, data)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if queued > 0 {
+		wakeWebhookDelivery()
+	}
+	return nil
+}
+
+// publishWebhookEventTx stores an event inside tx, so it is only published
+// if tx commits, and returns how many deliveries were queued. The event is
+// queued for subscriptions of the passport's organisation and those of no
+// organisation. Callers wake the delivery worker after committing.
+func publishWebhookEventTx(tx *sql.Tx, eventType, resourceID string, data interface{}) (int, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	var eventID int
+	var organisationID *int
+	err = tx.QueryRow(`
+		INSERT INTO webhook_events (event_type, resource_id, payload, organisation_id)
+		VALUES ($1, $2, $3, (SELECT organisation_id FROM aluminium_passports WHERE passport_id = $2))
+		RETURNING id, organisation_id`, eventType, nullableString(resourceID), string(payload),
+	).Scan(&eventID, &organisationID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store %s event: %w", eventType, err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id)
+		SELECT id, $1 FROM webhook_subscriptions
+		WHERE is_active AND $2 = ANY(event_types) AND (organisation_id IS NULL OR organisation_id = $3)`,
+		eventID, eventType, organisationID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue %s event: %w", eventType, err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// PublishWebhookEvent publishes an event for something that has already
+// happened. Failures are logged rather than returned.
+func PublishWebhookEvent(eventType, resourceID string, data interface{}) {
+	if err := NewWebhookService(db.DB).Publish(eventType, resourceID, data); err != nil {
+		log.Printf("Publishing %s webhook event for %s failed: %v", eventType, resourceID, err)
+	}
+}
+
+// PublishPassportEvent publishes a passport event carrying the passport as
+// stored and, for updates, the fields that changed
+func PublishPassportEvent(eventType string, passport *db.AluminiumPassport, changed []string) {
+	PublishWebhookEvent(eventType, passport.PassportID, passportWebhookData(passport, changed))
+}
+
+func passportWebhookData(passport *db.AluminiumPassport, changed []string) map[string]interface{} {
+	data := map[string]interface{}{
+		"passport": passport,
+	}
+	if changed != nil {
+		data["changed_fields"] = changed
+	}
+	return data
+}
+
+// webhookDelivery is a claimed delivery with what is needed to send it
+type webhookDelivery struct {
+	id              int
+	attempts        int
+	url             string
+	encryptedSecret string
+	event           models.WebhookEvent
+	payload         json.RawMessage
+	replay          bool
+}
+
+// claimDelivery takes the oldest due delivery of an active subscription and
+// holds it for webhookLease, or returns nil if none is due
+func (ws *WebhookService) claimDelivery() (*webhookDelivery, error) {
+	d := &webhookDelivery{}
+	err := ws.db.QueryRow(`
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $1 * INTERVAL '1 second'
+		WHERE id = (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.is_active
+			ORDER BY d.next_attempt_at, d.id
+			LIMIT 1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING id, attempts, event_id, replay`, int(webhookLease.Seconds()),
+	).Scan(&d.id, &d.attempts, &d.event.ID, &d.replay)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+
+	var resourceID sql.NullString
+	var payload []byte
+	err = ws.db.QueryRow(`
+		SELECT s.url, s.encrypted_secret, e.event_type, e.resource_id, e.payload, e.created_at
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		JOIN webhook_events e ON e.id = d.event_id
+		WHERE d.id = $1`, d.id,
+	).Scan(&d.url, &d.encryptedSecret, &d.event.EventType, &resourceID, &payload, &d.event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook delivery %d: %w", d.id, err)
+	}
+	d.event.ResourceID = resourceID.String
+	d.payload = payload
+	return d, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+// under secret. Receivers compute the same value to verify a delivery.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// send posts a delivery to its subscription and returns the response status
+// and the start of the response body
+func (ws *WebhookService) send(d *webhookDelivery) (int, string, error) {
+	encryptionSecret, err := keyEncryptionSecret()
+	if err != nil {
+		return 0, "", err
+	}
+	secret, err := signing.OpenSecret(d.encryptedSecret, encryptionSecret)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to decrypt webhook secret: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":          d.event.ID,
+		"type":        d.event.EventType,
+		"resource_id": d.event.ResourceID,
+		"created_at":  d.event.CreatedAt.UTC(),
+		"replay":      d.replay,
+		"data":        d.payload,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AluminiumPassport-Webhooks/1.0")
+	req.Header.Set("X-Passport-Event", d.event.EventType)
+	req.Header.Set("X-Passport-Delivery", strconv.Itoa(d.id))
+	req.Header.Set("X-Passport-Signature",
+		fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(secret, timestamp, body)))
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(snippet), fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// DeliverDue sends every due delivery and records each attempt. Failed
+// deliveries are retried after one minute, doubling each time; after
+// maxAttempts they are dead-lettered.
+func (ws *WebhookService) DeliverDue(ctx context.Context, maxAttempts int) (int, error) {
+	delivered := 0
+	for ctx.Err() == nil {
+		d, err := ws.claimDelivery()
+		if err != nil {
+			return delivered, err
+		}
+		if d == nil {
+			break
+		}
+
+		started := time.Now()
+		statusCode, responseBody, sendErr := ws.send(d)
+		var code *int
+		if statusCode != 0 {
+			code = &statusCode
+		}
+		var errText *string
+		if sendErr != nil {
+			errText = nullableString(sendErr.Error())
+		}
+
+		_, err = ws.db.Exec(`
+			INSERT INTO webhook_attempts (delivery_id, status_code, error, response_body, duration_ms)
+			VALUES ($1, $2, $3, $4, $5)`,
+			d.id, code, errText, nullableString(responseBody), int(time.Since(started).Milliseconds()))
+		if err != nil {
+			return delivered, fmt.Errorf("failed to record webhook attempt: %w", err)
+		}
+
+		if sendErr == nil {
+			delivered++
+			_, err = ws.db.Exec(`
+				UPDATE webhook_deliveries
+				SET status = 'delivered', delivered_at = NOW(), last_status_code = $2, last_error = NULL
+				WHERE id = $1`, d.id, code)
+		} else if d.attempts >= maxAttempts {
+			log.Printf("Webhook delivery %d of %s to %s dead-lettered after %d attempts: %v",
+				d.id, d.event.EventType, d.url, d.attempts, sendErr)
+			_, err = ws.db.Exec(`
+				UPDATE webhook_deliveries
+				SET status = 'dead', next_attempt_at = NULL, last_status_code = $2, last_error = $3
+				WHERE id = $1`, d.id, code, sendErr.Error())
+		} else {
+			backoff := time.Minute << uint(d.attempts-1)
+			_, err = ws.db.Exec(`
+				UPDATE webhook_deliveries
+				SET next_attempt_at = NOW() + $2 * INTERVAL '1 second', last_status_code = $3, last_error = $4
+				WHERE id = $1`, d.id, int(backoff.Seconds()), code, sendErr.Error())
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("failed to update webhook delivery: %w", err)
+		}
+	}
+	return delivered, nil
+}
+
+// RunWebhookDelivery sends due deliveries whenever events are queued, and
+// otherwise every interval, until ctx is cancelled
+func (ws *WebhookService) RunWebhookDelivery(ctx context.Context, interval time.Duration, maxAttempts int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ws.DeliverDue(ctx, maxAttempts); err != nil {
+			log.Printf("Webhook delivery failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-webhookWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ListDeliveries returns a page of a subscription's deliveries, newest
+// first, optionally only those with the given status
+func (ws *WebhookService) ListDeliveries(subscriptionID int, status string, page, limit int) ([]*models.WebhookDelivery, int, error) {
+	if _, err := ws.Subscription(subscriptionID); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err := ws.db.QueryRow(`
+		SELECT COUNT(*) FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)`, subscriptionID, status,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT d.id, d.subscription_id, d.event_id, e.event_type, d.status, d.attempts, d.next_attempt_at,
+		       d.last_status_code, d.last_error, d.replay, d.delivered_at, d.created_at
+		FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $3 OFFSET $4`, subscriptionID, status, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.Replay, &d.DeliveredAt, &d.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// DeliveryAttempts returns every attempt of one of a subscription's
+// deliveries, oldest first
+func (ws *WebhookService) DeliveryAttempts(subscriptionID, deliveryID int) ([]*models.WebhookAttempt, error) {
+	var exists bool
+	err := ws.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2)`,
+		deliveryID, subscriptionID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook delivery: %w", err)
+	}
+	if !exists {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT id, delivery_id, status_code, error, response_body, duration_ms, attempted_at
+		FROM webhook_attempts WHERE delivery_id = $1
+		ORDER BY attempted_at, id`, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []*models.WebhookAttempt{}
+	for rows.Next() {
+		a := &models.WebhookAttempt{}
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.StatusCode, &a.Error, &a.ResponseBody, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// RetryDelivery puts a dead-lettered delivery back in the queue with a
+// fresh set of attempts
+func (ws *WebhookService) RetryDelivery(subscriptionID, deliveryID int) error {
+	result, err := ws.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND subscription_id = $2 AND status = 'dead'`, deliveryID, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		err := ws.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2)`,
+			deliveryID, subscriptionID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook delivery: %w", err)
+		}
+		if !exists {
+			return ErrWebhookDeliveryNotFound
+		}
+		return ErrWebhookDeliveryNotDead
+	}
+	wakeWebhookDelivery()
+	return nil
+}
+
+// RetryDeadDeliveries puts every dead-lettered delivery of a subscription
+// back in the queue and returns how many there were
+func (ws *WebhookService) RetryDeadDeliveries(subscriptionID int) (int, error) {
+	if _, err := ws.Subscription(subscriptionID); err != nil {
+		return 0, err
+	}
+
+	result, err := ws.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE subscription_id = $1 AND status = 'dead'`, subscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry webhook deliveries: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		wakeWebhookDelivery()
+	}
+	return int(n), nil
+}
+
+// Replay queues every stored event of the window [From, To) again for a
+// subscription, oldest first, and returns how many were queued. Only
+// events the subscription received when published are queued. Replayed
+// deliveries carry "replay": true so receivers can deduplicate by event ID.
+func (ws *WebhookService) Replay(subscriptionID int, replay *models.WebhookReplay) (int, error) {
+	if replay.From.IsZero() || replay.To.IsZero() || !replay.From.Before(replay.To) {
+		return 0, fmt.Errorf("%w: from must be before to", ErrInvalidReplay)
+	}
+	if replay.To.Sub(replay.From) > maxWebhookReplayWindow {
+		return 0, fmt.Errorf("%w: window may not exceed %d days", ErrInvalidReplay, int(maxWebhookReplayWindow.Hours()/24))
+	}
+
+	sub, err := ws.Subscription(subscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	if !sub.IsActive {
+		return 0, fmt.Errorf("%w: subscription is disabled", ErrInvalidReplay)
+	}
+
+	eventTypes := sub.EventTypes
+	if len(replay.EventTypes) > 0 {
+		subscribed := map[string]bool{}
+		for _, eventType := range sub.EventTypes {
+			subscribed[eventType] = true
+		}
+		for _, eventType := range replay.EventTypes {
+			if !subscribed[eventType] {
+				return 0, fmt.Errorf("%w: subscription does not receive %q", ErrInvalidReplay, eventType)
+			}
+		}
+		eventTypes = replay.EventTypes
+	}
+
+	result, err := ws.db.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, replay)
+		SELECT $1, id, true FROM webhook_events
+		WHERE created_at >= $2 AND created_at < $3 AND event_type = ANY($4)
+		  AND ($5::INTEGER IS NULL OR organisation_id = $5)
+		ORDER BY id`, subscriptionID, replay.From, replay.To, pq.Array(eventTypes), sub.OrganisationID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue webhook replay: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		wakeWebhookDelivery()
+	}
+	return int(n), nil
+}
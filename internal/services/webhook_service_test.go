@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/config"
+	"aluminium-passport/internal/db/dbtest"
+	"aluminium-passport/internal/models"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	withConfig(t, &config.Config{Environment: "development"})
+	events := []string{models.WebhookPassportCreated}
+
+	tests := []struct {
+		name       string
+		url        string
+		eventTypes []string
+		wantErr    bool
+	}{
+		{name: "public address", url: "https://93.184.216.34/hooks", eventTypes: events},
+		{name: "plain http outside production", url: "http://93.184.216.34/hooks", eventTypes: events},
+		{name: "relative url", url: "/hooks", eventTypes: events, wantErr: true},
+		{name: "other scheme", url: "ftp://93.184.216.34/hooks", eventTypes: events, wantErr: true},
+		{name: "no event types", url: "https://93.184.216.34/hooks", wantErr: true},
+		{name: "unknown event type", url: "https://93.184.216.34/hooks", eventTypes: []string{"passport.deleted"}, wantErr: true},
+		{name: "loopback", url: "https://127.0.0.1/hooks", eventTypes: events, wantErr: true},
+		{name: "loopback name", url: "https://localhost:8080/hooks", eventTypes: events, wantErr: true},
+		{name: "IPv6 loopback", url: "https://[::1]/hooks", eventTypes: events, wantErr: true},
+		{name: "private network", url: "https://10.0.0.5/hooks", eventTypes: events, wantErr: true},
+		{name: "metadata service", url: "http://169.254.169.254/latest/meta-data", eventTypes: events, wantErr: true},
+		{name: "unspecified address", url: "https://0.0.0.0/hooks", eventTypes: events, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWebhook(tt.url, tt.eventTypes)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidWebhook) {
+					t.Errorf("validateWebhook(%q) error = %v, want %v", tt.url, err, ErrInvalidWebhook)
+				}
+			} else if err != nil {
+				t.Errorf("validateWebhook(%q) error = %v", tt.url, err)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookRequiresHTTPSInProduction(t *testing.T) {
+	withConfig(t, &config.Config{Environment: "production"})
+	err := validateWebhook("http://93.184.216.34/hooks", []string{models.WebhookPassportCreated})
+	if !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("validateWebhook error = %v, want %v", err, ErrInvalidWebhook)
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	withConfig(t, &config.Config{Environment: "test"})
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	resp, err := NewWebhookService(nil).client.Post(server.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("delivery to %s error = %v, want %v", server.URL, err, ErrBlockedAddress)
+	}
+	if reached {
+		t.Errorf("delivery reached a loopback server")
+	}
+}
+
+func TestPublishQueuesForPassportOrganisation(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`INSERT INTO webhook_events`, []string{"id", "organisation_id"}, []interface{}{int64(12), int64(7)})
+
+	data := map[string]interface{}{"passport_id": "AP-1"}
+	if err := NewWebhookService(fake.DB).Publish(models.WebhookPassportCreated, "AP-1", data); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	events := fake.Statements(`INSERT INTO webhook_events`)
+	if len(events) != 1 || events[0].Args[1] != "AP-1" {
+		t.Fatalf("stored events = %+v, want one for AP-1", events)
+	}
+	queued := fake.Statements(`organisation_id IS NULL OR organisation_id = $3`)
+	if len(queued) != 1 {
+		t.Fatalf("deliveries were not queued by organisation")
+	}
+	if got := queued[0].Args; got[0] != int64(12) || got[1] != models.WebhookPassportCreated || got[2] != int64(7) {
+		t.Errorf("queued with %v, want event 12 of organisation 7", got)
+	}
+}
+
+func TestReplayKeepsSubscriptionOrganisation(t *testing.T) {
+	fake := newTestDB(t)
+	fake.OnQuery(`FROM webhook_subscriptions WHERE id = $1`,
+		dbtest.Columns(webhookSubscriptionColumns),
+		[]interface{}{int64(1), int64(7), "https://erp.example.com/hooks", []string{models.WebhookPassportCreated},
+			nil, true, int64(1), time.Now(), time.Now()})
+
+	to := time.Now()
+	_, err := NewWebhookService(fake.DB).Replay(1, &models.WebhookReplay{From: to.Add(-time.Hour), To: to})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	replays := fake.Statements(`INSERT INTO webhook_deliveries (subscription_id, event_id, replay)`)
+	if len(replays) != 1 {
+		t.Fatalf("replay was not queued")
+	}
+	if got := replays[0].Args[4]; got != int64(7) {
+		t.Errorf("replayed events of organisation %v, want 7", got)
+	}
+}
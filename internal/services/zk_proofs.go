@@ -0,0 +1,386 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aluminium-passport/internal/db"
+	"aluminium-passport/internal/models"
+	"aluminium-passport/internal/signing"
+	"aluminium-passport/internal/zk"
+)
+
+var (
+	ErrZKAttributeUnsupported = errors.New("attribute cannot be proven")
+	ErrZKAttributeMissing     = errors.New("passport has no value for the attribute")
+	ErrZKProofNotFound        = errors.New("zk proof not found")
+	ErrZKIssuerMismatch       = errors.New("statement is not signed by the passport's issuer")
+)
+
+// zkProofAttributes maps the attributes a range proof can be made over to
+// their passport column
+var zkProofAttributes = map[string]string{
+	"recycled_content_percent": "recycled_content_percent",
+	"carbon_emissions_per_kg":  "carbon_emissions_per_kg",
+	"co2_footprint":            "co2_footprint",
+	"esg_score":                "esg_score",
+	"environmental_score":      "environmental_score",
+}
+
+// RangeProofRequest asks for a proof that a passport attribute is at least
+// (gte) or at most (lte) a threshold
+type RangeProofRequest struct {
+	PassportID string  `json:"passport_id"`
+	Attribute  string  `json:"attribute"`
+	Operator   string  `json:"operator"`
+	Threshold  float64 `json:"threshold"`
+}
+
+// attestedStatement is a range proof statement signed by the issuer of the
+// passport. The commitment alone could be made to any value; the signature
+// binds it, the passport, the attribute and the threshold to the value the
+// issuer holds.
+type attestedStatement struct {
+	zk.Statement
+	Issuer string        `json:"issuer"`
+	Proof  *models.Proof `json:"proof,omitempty"`
+}
+
+// ZKProofService generates, stores and verifies range proofs over passport
+// values
+type ZKProofService struct {
+	db *sql.DB
+}
+
+func NewZKProofService(db *sql.DB) *ZKProofService {
+	return &ZKProofService{db: db}
+}
+
+// GenerateRangeProof proves a threshold claim about a passport value without
+// disclosing it and stores the proof in zk_proofs
+func (zs *ZKProofService) GenerateRangeProof(req RangeProofRequest, createdBy *int) (*db.ZKProof, error) {
+	column, ok := zkProofAttributes[req.Attribute]
+	if !ok {
+		return nil, ErrZKAttributeUnsupported
+	}
+
+	passport := &db.AluminiumPassport{PassportID: req.PassportID}
+	var value sql.NullFloat64
+	err := zs.db.QueryRow(fmt.Sprintf("SELECT organisation_id, %s FROM aluminium_passports WHERE passport_id = $1", column), req.PassportID).
+		Scan(&passport.OrganisationID, &value)
+	if err == sql.ErrNoRows {
+		return nil, ErrPassportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load passport: %w", err)
+	}
+	if !value.Valid {
+		return nil, ErrZKAttributeMissing
+	}
+
+	statement, proof, err := zk.Prove(zk.Statement{
+		Attribute: req.Attribute,
+		Operator:  zk.Operator(req.Operator),
+		Threshold: zk.ToFixed(req.Threshold),
+		Context:   req.PassportID,
+	}, zk.ToFixed(value.Float64))
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := PassportIssuerKey(passport)
+	if err != nil {
+		return nil, err
+	}
+	attested, err := signStatement(statement, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	publicInputs, err := statementInputs(attested)
+	if err != nil {
+		return nil, err
+	}
+	proofData, err := json.Marshal(proof)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode proof: %w", err)
+	}
+	verificationKey, err := json.Marshal(zk.Key())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode verification key: %w", err)
+	}
+
+	record := &db.ZKProof{
+		PassportID:      req.PassportID,
+		ProofType:       nullableString(zk.ProofType),
+		ProofData:       nullableString(string(proofData)),
+		PublicInputs:    &publicInputs,
+		VerificationKey: nullableString(string(verificationKey)),
+		CreatedBy:       createdBy,
+	}
+
+	err = zs.db.QueryRow(`
+		INSERT INTO zk_proofs (passport_id, proof_type, proof_data, public_inputs, verification_key, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		record.PassportID, record.ProofType, record.ProofData, record.PublicInputs, record.VerificationKey, record.CreatedBy,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store zk proof: %w", err)
+	}
+
+	return record, nil
+}
+
+// GetProof loads a stored proof
+func (zs *ZKProofService) GetProof(id int) (*db.ZKProof, error) {
+	record := &db.ZKProof{}
+	err := zs.db.QueryRow(`
+		SELECT id, passport_id, proof_type, proof_data, public_inputs, verification_key,
+		       is_verified, verifier_address, created_by, created_at, verified_at
+		FROM zk_proofs WHERE id = $1`, id,
+	).Scan(
+		&record.ID, &record.PassportID, &record.ProofType, &record.ProofData, &record.PublicInputs, &record.VerificationKey,
+		&record.IsVerified, &record.VerifierAddress, &record.CreatedBy, &record.CreatedAt, &record.VerifiedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrZKProofNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load zk proof: %w", err)
+	}
+	return record, nil
+}
+
+// VerifyStoredProof verifies a stored proof and, when it holds, records who
+// verified it. A proof that does not verify is returned with an error
+// wrapping zk.ErrInvalidProof.
+func (zs *ZKProofService) VerifyStoredProof(id int, verifierAddress string) (*db.ZKProof, error) {
+	record, err := zs.GetProof(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var proofData string
+	var publicInputs db.JSONMap
+	if record.ProofData != nil {
+		proofData = *record.ProofData
+	}
+	if record.PublicInputs != nil {
+		publicInputs = *record.PublicInputs
+	}
+	if err := VerifyRangeProof(proofData, publicInputs); err != nil {
+		return record, err
+	}
+
+	now := time.Now()
+	record.IsVerified = true
+	record.VerifiedAt = &now
+	record.VerifierAddress = nullableString(verifierAddress)
+
+	_, err = zs.db.Exec(`
+		UPDATE zk_proofs SET is_verified = true, verified_at = $1, verifier_address = $2
+		WHERE id = $3`,
+		record.VerifiedAt, record.VerifierAddress, record.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record verification: %w", err)
+	}
+
+	return record, nil
+}
+
+// VerifyRangeProof checks a proof against its public inputs, which must be
+// signed by the issuer of the passport they name, so holders can verify
+// proofs they were sent. Proofs that do not hold return an error wrapping
+// zk.ErrInvalidProof.
+func VerifyRangeProof(proofData string, publicInputs db.JSONMap) error {
+	statement, err := inputsStatement(publicInputs)
+	if err != nil {
+		return err
+	}
+	if err := verifyStatementIssuer(statement); err != nil {
+		return err
+	}
+
+	var proof zk.RangeProof
+	if err := json.Unmarshal([]byte(proofData), &proof); err != nil {
+		return fmt.Errorf("%w: malformed proof data", zk.ErrInvalidProof)
+	}
+
+	return zk.Verify(&statement.Statement, &proof)
+}
+
+// signStatement attaches a Data Integrity proof by the issuer to a statement
+func signStatement(statement *zk.Statement, issuer *IssuerKey) (*attestedStatement, error) {
+	cryptosuite, err := signing.CryptosuiteForKey(issuer.PrivateKey.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := signing.ProofOptions{
+		Type:               signing.ProofTypeDataIntegrity,
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: issuer.VerificationMethod,
+	}
+
+	attested := &attestedStatement{Statement: *statement, Issuer: issuer.Controller}
+	proofValue, err := signing.CreateProofValue(attested, opts, issuer.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign statement: %w", err)
+	}
+
+	attested.Proof = &models.Proof{
+		Type:               opts.Type,
+		Cryptosuite:        opts.Cryptosuite,
+		Created:            opts.Created,
+		ProofPurpose:       opts.ProofPurpose,
+		VerificationMethod: opts.VerificationMethod,
+		ProofValue:         proofValue,
+	}
+	return attested, nil
+}
+
+// verifyStatementIssuer resolves the key that signed a statement, checks the
+// signature and requires the key to be one the passport could be issued with
+func verifyStatementIssuer(statement *attestedStatement) error {
+	if statement.Proof == nil || statement.Proof.ProofValue == "" {
+		return fmt.Errorf("%w: statement is not signed", zk.ErrInvalidProof)
+	}
+	if statement.Proof.ProofPurpose != proofPurposeAssertion {
+		return fmt.Errorf("%w: %v", zk.ErrInvalidProof, ErrUnsupportedPurpose)
+	}
+
+	created, err := time.Parse(time.RFC3339, statement.Proof.Created)
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature creation time", zk.ErrInvalidProof)
+	}
+
+	publicKey, err := resolveIssuerKey(statement.Issuer, statement.Proof.VerificationMethod, created)
+	if err != nil {
+		return fmt.Errorf("%w: %v", zk.ErrInvalidProof, err)
+	}
+	key, err := signing.ParsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: invalid issuer key", zk.ErrInvalidProof)
+	}
+
+	opts := signing.ProofOptions{
+		Context:            statement.Proof.Context,
+		Type:               statement.Proof.Type,
+		Cryptosuite:        statement.Proof.Cryptosuite,
+		Created:            statement.Proof.Created,
+		ProofPurpose:       statement.Proof.ProofPurpose,
+		VerificationMethod: statement.Proof.VerificationMethod,
+	}
+	unsigned := *statement
+	unsigned.Proof = nil
+	if err := signing.VerifyProofValue(&unsigned, opts, statement.Proof.ProofValue, key); err != nil {
+		return fmt.Errorf("%w: statement signature: %v", zk.ErrInvalidProof, err)
+	}
+
+	return checkPassportIssuer(statement.Context, key)
+}
+
+// checkPassportIssuer requires key to be the platform key, which signs for
+// organisations without keys of their own, or a registry key of the
+// organisation that owns the passport
+func checkPassportIssuer(passportID string, key *signing.PublicKey) error {
+	var organisationID sql.NullInt64
+	err := db.DB.QueryRow("SELECT organisation_id FROM aluminium_passports WHERE passport_id = $1", passportID).Scan(&organisationID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown passport", zk.ErrInvalidProof)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load passport: %w", err)
+	}
+
+	platform, err := PlatformIssuerKey()
+	if err != nil {
+		return err
+	}
+	if platform.PrivateKey.Public().Multibase() == key.Multibase() {
+		return nil
+	}
+
+	if organisationID.Valid {
+		registered, err := NewKeyRegistry(db.DB).GetKeyByPublicKey(key.Multibase())
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		if err == nil && int64(registered.OrganisationID) == organisationID.Int64 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %v", zk.ErrInvalidProof, ErrZKIssuerMismatch)
+}
+
+// GenerateZKProof proves a threshold claim for a passport. claims holds
+// attribute, operator (gte or lte) and threshold.
+func GenerateZKProof(passportID string, claims map[string]interface{}) (*db.ZKProof, error) {
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	req := RangeProofRequest{}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	req.PassportID = passportID
+
+	return NewZKProofService(db.DB).GenerateRangeProof(req, nil)
+}
+
+// VerifyZKProof verifies proof data against the public inputs in claims
+func VerifyZKProof(proof string, claims map[string]interface{}) (bool, error) {
+	err := VerifyRangeProof(proof, db.JSONMap(claims))
+	if errors.Is(err, zk.ErrInvalidProof) || errors.Is(err, zk.ErrInvalidOperator) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ZKStatementSummary renders public inputs as a readable claim, e.g.
+// "recycled_content_percent >= 50"
+func ZKStatementSummary(publicInputs db.JSONMap) string {
+	statement, err := inputsStatement(publicInputs)
+	if err != nil {
+		return ""
+	}
+
+	operator := ">="
+	if statement.Operator == zk.OperatorLTE {
+		operator = "<="
+	}
+	return fmt.Sprintf("%s %s %g", statement.Attribute, operator, zk.FromFixed(statement.Threshold))
+}
+
+func statementInputs(statement *attestedStatement) (db.JSONMap, error) {
+	raw, err := json.Marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public inputs: %w", err)
+	}
+	inputs := db.JSONMap{}
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to encode public inputs: %w", err)
+	}
+	return inputs, nil
+}
+
+func inputsStatement(publicInputs db.JSONMap) (*attestedStatement, error) {
+	raw, err := json.Marshal(publicInputs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed public inputs", zk.ErrInvalidProof)
+	}
+	statement := &attestedStatement{}
+	if err := json.Unmarshal(raw, statement); err != nil {
+		return nil, fmt.Errorf("%w: malformed public inputs", zk.ErrInvalidProof)
+	}
+	return statement, nil
+}
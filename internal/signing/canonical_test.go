@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"whitespace removed", `{ "b" : [ 1 , 2 ] , "a" : true }`, `{"a":true,"b":[1,2]}`},
+		{"nested objects sorted", `{"z":{"y":1,"x":2},"a":null}`, `{"a":null,"z":{"x":2,"y":1}}`},
+		{"numbers in ES6 form", `[333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001, -0]`, `[333333333.3333333,1e+30,4.5,0.002,1e-27,0]`},
+		{"minimal string escaping", `"\u20ac\/\u000f\"\\\n"`, `"€/\u000f\"\\\n"`},
+		// U+1F600 is encoded as a surrogate pair, which sorts below U+FB33
+		// in UTF-16 although its code point is higher
+		{"keys sorted by UTF-16 code units", `{"\ufb33":1,"\ud83d\ude00":2,"\u00f6":3,"1":4,"\r":5}`, "{\"\\r\":5,\"1\":4,\"ö\":3,\"😀\":2,\"דּ\":1}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input interface{}
+			if err := json.Unmarshal([]byte(tt.input), &input); err != nil {
+				t.Fatalf("invalid test input: %v", err)
+			}
+			got, err := Canonicalize(input)
+			if err != nil {
+				t.Fatalf("Canonicalize() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonicalize() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeStructMatchesMap(t *testing.T) {
+	type subject struct {
+		Name    string  `json:"name"`
+		Percent float64 `json:"percent"`
+	}
+	fromStruct, err := Canonicalize(struct {
+		Subject subject `json:"subject"`
+		ID      string  `json:"id"`
+	}{subject{"alloy", 42.5}, "urn:uuid:1"})
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+	fromMap, err := Canonicalize(map[string]interface{}{
+		"id":      "urn:uuid:1",
+		"subject": map[string]interface{}{"percent": 42.5, "name": "alloy"},
+	})
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+	if string(fromStruct) != string(fromMap) {
+		t.Errorf("struct and map canonicalize differently: %s != %s", fromStruct, fromMap)
+	}
+}
@@ -0,0 +1,191 @@
+package signing
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Contexts used by DID documents
+const (
+	DIDContextV1      = "https://www.w3.org/ns/did/v1"
+	MultikeyContextV1 = "https://w3id.org/security/multikey/v1"
+)
+
+const verificationMethodTypeMultikey = "Multikey"
+
+var (
+	ErrUnsupportedDIDMethod = errors.New("unsupported DID method")
+	ErrInvalidDID           = errors.New("invalid DID")
+)
+
+// DIDDocument is the subset of a DID document needed to verify credentials
+type DIDDocument struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	AssertionMethod    []string             `json:"assertionMethod"`
+}
+
+// VerificationMethod is a Multikey verification method. Expires is set once a
+// key has been rotated out and Revoked once it must no longer be trusted.
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+	Expires            string `json:"expires,omitempty"`
+	Revoked            string `json:"revoked,omitempty"`
+}
+
+// NewDIDDocument returns an empty DID document for the given DID
+func NewDIDDocument(did string) *DIDDocument {
+	return &DIDDocument{
+		Context:            []string{DIDContextV1, MultikeyContextV1},
+		ID:                 did,
+		VerificationMethod: []VerificationMethod{},
+		AssertionMethod:    []string{},
+	}
+}
+
+// AddVerificationMethod adds a key to the document. Revoked keys are listed
+// so verifiers can see the revocation, but are never assertion methods.
+func (d *DIDDocument) AddVerificationMethod(vm VerificationMethod) {
+	if vm.Type == "" {
+		vm.Type = verificationMethodTypeMultikey
+	}
+	if vm.Controller == "" {
+		vm.Controller = d.ID
+	}
+
+	d.VerificationMethod = append(d.VerificationMethod, vm)
+	if vm.Revoked == "" {
+		d.AssertionMethod = append(d.AssertionMethod, vm.ID)
+	}
+}
+
+// FindVerificationMethod looks up a verification method by absolute or
+// relative ("#fragment") DID URL
+func (d *DIDDocument) FindVerificationMethod(id string) (*VerificationMethod, bool) {
+	for i := range d.VerificationMethod {
+		if d.absoluteID(d.VerificationMethod[i].ID) == d.absoluteID(id) {
+			return &d.VerificationMethod[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsAssertionMethod reports whether the key may be used to issue credentials
+func (d *DIDDocument) IsAssertionMethod(id string) bool {
+	for _, ref := range d.AssertionMethod {
+		if d.absoluteID(ref) == d.absoluteID(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DIDDocument) absoluteID(id string) string {
+	if strings.HasPrefix(id, "#") {
+		return d.ID + id
+	}
+	return id
+}
+
+// ValidAt checks that the verification method could sign at the given time
+func (vm *VerificationMethod) ValidAt(t time.Time) error {
+	if vm.Revoked != "" {
+		return fmt.Errorf("verification method %s was revoked at %s", vm.ID, vm.Revoked)
+	}
+	if vm.Expires != "" {
+		expires, err := time.Parse(time.RFC3339, vm.Expires)
+		if err != nil {
+			return fmt.Errorf("verification method %s has invalid expiry: %w", vm.ID, err)
+		}
+		if t.After(expires) {
+			return fmt.Errorf("verification method %s was rotated out at %s", vm.ID, vm.Expires)
+		}
+	}
+	return nil
+}
+
+// SplitDIDURL splits a DID URL into the DID and its fragment
+func SplitDIDURL(didURL string) (string, string) {
+	if i := strings.Index(didURL, "#"); i >= 0 {
+		return didURL[:i], didURL[i+1:]
+	}
+	return didURL, ""
+}
+
+// DIDMethod returns the method name of a DID ("key", "web", ...)
+func DIDMethod(did string) (string, error) {
+	parts := strings.SplitN(did, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", ErrInvalidDID
+	}
+	return parts[1], nil
+}
+
+// DIDKey returns the did:key identifier and verification method ID for a key
+func DIDKey(key *PublicKey) (string, string) {
+	mb := key.Multibase()
+	did := "did:key:" + mb
+	return did, did + "#" + mb
+}
+
+// ResolveDIDKey expands a did:key identifier into its DID document
+func ResolveDIDKey(did string) (*DIDDocument, error) {
+	if !strings.HasPrefix(did, "did:key:") {
+		return nil, ErrUnsupportedDIDMethod
+	}
+
+	mb := strings.TrimPrefix(did, "did:key:")
+	if _, err := ParsePublicKey(mb); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDID, err)
+	}
+
+	doc := NewDIDDocument(did)
+	doc.AddVerificationMethod(VerificationMethod{
+		ID:                 did + "#" + mb,
+		PublicKeyMultibase: mb,
+	})
+	return doc, nil
+}
+
+// WebDID builds a did:web identifier for a domain and optional path
+func WebDID(domain string, path ...string) string {
+	segments := []string{"did:web", strings.ReplaceAll(domain, ":", "%3A")}
+	for _, p := range path {
+		segments = append(segments, url.PathEscape(p))
+	}
+	return strings.Join(segments, ":")
+}
+
+// DIDWebURL returns the HTTPS location of a did:web document
+func DIDWebURL(did string) (string, error) {
+	if !strings.HasPrefix(did, "did:web:") {
+		return "", ErrUnsupportedDIDMethod
+	}
+
+	segments := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	domain, err := url.PathUnescape(segments[0])
+	if err != nil || domain == "" {
+		return "", ErrInvalidDID
+	}
+
+	if len(segments) == 1 {
+		return "https://" + domain + "/.well-known/did.json", nil
+	}
+
+	path := make([]string, 0, len(segments)-1)
+	for _, s := range segments[1:] {
+		p, err := url.PathUnescape(s)
+		if err != nil || p == "" {
+			return "", ErrInvalidDID
+		}
+		path = append(path, url.PathEscape(p))
+	}
+	return "https://" + domain + "/" + strings.Join(path, "/") + "/did.json", nil
+}
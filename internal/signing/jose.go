@@ -0,0 +1,122 @@
+package signing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JWS algorithms for the supported key types (RFC 8037, RFC 8812)
+const (
+	JWSAlgEdDSA  = "EdDSA"
+	JWSAlgES256K = "ES256K"
+)
+
+var ErrInvalidJWS = errors.New("invalid JWS")
+
+// JWSHeader is the protected header of a compact JWS
+type JWSHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// JWSAlgorithmForKey returns the JWS algorithm used for a key type
+func JWSAlgorithmForKey(keyType KeyType) (string, error) {
+	switch keyType {
+	case KeyTypeEd25519:
+		return JWSAlgEdDSA, nil
+	case KeyTypeSecp256k1:
+		return JWSAlgES256K, nil
+	default:
+		return "", ErrUnsupportedKeyType
+	}
+}
+
+// SignJWS produces a compact JWS over payload. The header algorithm is set
+// from the key type.
+func SignJWS(header JWSHeader, payload []byte, key *PrivateKey) (string, error) {
+	alg, err := JWSAlgorithmForKey(key.Type)
+	if err != nil {
+		return "", err
+	}
+	header.Alg = alg
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWS header: %w", err)
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(payload)
+	signature, err := key.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// ParseJWS splits a compact JWS without verifying it, so the caller can
+// resolve the key named in the header
+func ParseJWS(token string) (*JWSHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	var header JWSHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	return &header, payload, nil
+}
+
+// VerifyJWS checks a compact JWS signature against a public key and returns
+// its payload
+func VerifyJWS(token string, key *PublicKey) ([]byte, error) {
+	header, payload, err := ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := JWSAlgorithmForKey(key.Type)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != alg {
+		return nil, fmt.Errorf("%w: algorithm %q does not match key", ErrInvalidJWS, header.Alg)
+	}
+
+	i := strings.LastIndex(token, ".")
+	signature, err := decodeSegment(token[i+1:])
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+
+	if err := key.Verify([]byte(token[:i]), signature); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
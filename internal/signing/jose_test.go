@@ -0,0 +1,75 @@
+package signing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJWSRoundTrip(t *testing.T) {
+	key, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	payload := []byte(`{"iss":"did:example:issuer","credentialSubject":{"id":"ALU-2024-001"}}`)
+
+	token, err := SignJWS(JWSHeader{Kid: "did:example:issuer#key-1", Typ: "vc+jwt"}, payload, key)
+	if err != nil {
+		t.Fatalf("SignJWS() error = %v", err)
+	}
+
+	header, _, err := ParseJWS(token)
+	if err != nil {
+		t.Fatalf("ParseJWS() error = %v", err)
+	}
+	if header.Alg != JWSAlgEdDSA || header.Kid != "did:example:issuer#key-1" {
+		t.Errorf("ParseJWS() header = %+v", header)
+	}
+
+	got, err := VerifyJWS(token, key.Public())
+	if err != nil {
+		t.Fatalf("VerifyJWS() error = %v", err)
+	}
+	if string(got) != string(payload) {
+		t.Errorf("VerifyJWS() payload = %s, want %s", got, payload)
+	}
+}
+
+func TestVerifyJWSRejectsTampering(t *testing.T) {
+	key, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	other, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	token, err := SignJWS(JWSHeader{Typ: "vc+jwt"}, []byte(`{"recycled":40}`), key)
+	if err != nil {
+		t.Fatalf("SignJWS() error = %v", err)
+	}
+	parts := strings.Split(token, ".")
+
+	forgedHeader := encodeSegment([]byte(`{"alg":"none"}`))
+	forgedPayload := encodeSegment([]byte(`{"recycled":90}`))
+
+	tests := []struct {
+		name  string
+		token string
+		key   *PublicKey
+	}{
+		{"changed payload", parts[0] + "." + forgedPayload + "." + parts[2], key.Public()},
+		{"changed algorithm", forgedHeader + "." + parts[1] + "." + parts[2], key.Public()},
+		{"signature removed", parts[0] + "." + parts[1] + ".", key.Public()},
+		{"missing segment", parts[0] + "." + parts[1], key.Public()},
+		{"other key", token, other.Public()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyJWS(tt.token, tt.key); err == nil {
+				t.Errorf("VerifyJWS() accepted a tampered token")
+			}
+		})
+	}
+}
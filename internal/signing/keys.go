@@ -0,0 +1,205 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeyType identifies the signature algorithm of a key pair
+type KeyType string
+
+const (
+	KeyTypeEd25519   KeyType = "Ed25519"
+	KeyTypeSecp256k1 KeyType = "secp256k1"
+)
+
+// Multicodec prefixes used for Multikey encoding (varint-encoded)
+var (
+	codecEd25519Pub    = []byte{0xed, 0x01}
+	codecEd25519Priv   = []byte{0x80, 0x26}
+	codecSecp256k1Pub  = []byte{0xe7, 0x01}
+	codecSecp256k1Priv = []byte{0x81, 0x26}
+)
+
+var (
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+	ErrInvalidKey         = errors.New("invalid key encoding")
+	ErrInvalidSignature   = errors.New("signature verification failed")
+)
+
+// PrivateKey is an issuer signing key
+type PrivateKey struct {
+	Type      KeyType
+	ed25519   ed25519.PrivateKey
+	secp256k1 *ecdsa.PrivateKey
+}
+
+// PublicKey is the verification half of a PrivateKey
+type PublicKey struct {
+	Type      KeyType
+	ed25519   ed25519.PublicKey
+	secp256k1 *ecdsa.PublicKey
+}
+
+// GenerateKey creates a new random key pair of the given type
+func GenerateKey(keyType KeyType) (*PrivateKey, error) {
+	switch keyType {
+	case KeyTypeEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return &PrivateKey{Type: keyType, ed25519: priv}, nil
+	case KeyTypeSecp256k1:
+		priv, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secp256k1 key: %w", err)
+		}
+		return &PrivateKey{Type: keyType, secp256k1: priv}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// Public returns the public key for this private key
+func (k *PrivateKey) Public() *PublicKey {
+	switch k.Type {
+	case KeyTypeEd25519:
+		return &PublicKey{Type: k.Type, ed25519: k.ed25519.Public().(ed25519.PublicKey)}
+	case KeyTypeSecp256k1:
+		return &PublicKey{Type: k.Type, secp256k1: &k.secp256k1.PublicKey}
+	}
+	return nil
+}
+
+// Sign signs data. Ed25519 signs the message directly; secp256k1 signs its
+// SHA-256 digest and returns the 64-byte R||S form.
+func (k *PrivateKey) Sign(data []byte) ([]byte, error) {
+	switch k.Type {
+	case KeyTypeEd25519:
+		return ed25519.Sign(k.ed25519, data), nil
+	case KeyTypeSecp256k1:
+		digest := sha256.Sum256(data)
+		sig, err := crypto.Sign(digest[:], k.secp256k1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with secp256k1 key: %w", err)
+		}
+		return sig[:64], nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// Verify checks a signature produced by PrivateKey.Sign
+func (k *PublicKey) Verify(data, signature []byte) error {
+	switch k.Type {
+	case KeyTypeEd25519:
+		if len(signature) != ed25519.SignatureSize || !ed25519.Verify(k.ed25519, data, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case KeyTypeSecp256k1:
+		digest := sha256.Sum256(data)
+		if len(signature) != 64 || !crypto.VerifySignature(crypto.CompressPubkey(k.secp256k1), digest[:], signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return ErrUnsupportedKeyType
+	}
+}
+
+// Multibase encodes the private key as a base58btc Multikey string
+func (k *PrivateKey) Multibase() string {
+	switch k.Type {
+	case KeyTypeEd25519:
+		return encodeMultikey(codecEd25519Priv, k.ed25519.Seed())
+	case KeyTypeSecp256k1:
+		return encodeMultikey(codecSecp256k1Priv, crypto.FromECDSA(k.secp256k1))
+	}
+	return ""
+}
+
+// Multibase encodes the public key as a base58btc Multikey string
+func (k *PublicKey) Multibase() string {
+	switch k.Type {
+	case KeyTypeEd25519:
+		return encodeMultikey(codecEd25519Pub, k.ed25519)
+	case KeyTypeSecp256k1:
+		return encodeMultikey(codecSecp256k1Pub, crypto.CompressPubkey(k.secp256k1))
+	}
+	return ""
+}
+
+// ParsePrivateKey decodes a base58btc Multikey private key
+func ParsePrivateKey(encoded string) (*PrivateKey, error) {
+	codec, raw, err := decodeMultikey(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(codec, codecEd25519Priv):
+		if len(raw) != ed25519.SeedSize {
+			return nil, ErrInvalidKey
+		}
+		return &PrivateKey{Type: KeyTypeEd25519, ed25519: ed25519.NewKeyFromSeed(raw)}, nil
+	case bytes.Equal(codec, codecSecp256k1Priv):
+		priv, err := crypto.ToECDSA(raw)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		return &PrivateKey{Type: KeyTypeSecp256k1, secp256k1: priv}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// ParsePublicKey decodes a base58btc Multikey public key
+func ParsePublicKey(encoded string) (*PublicKey, error) {
+	codec, raw, err := decodeMultikey(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(codec, codecEd25519Pub):
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, ErrInvalidKey
+		}
+		return &PublicKey{Type: KeyTypeEd25519, ed25519: ed25519.PublicKey(raw)}, nil
+	case bytes.Equal(codec, codecSecp256k1Pub):
+		pub, err := crypto.DecompressPubkey(raw)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		return &PublicKey{Type: KeyTypeSecp256k1, secp256k1: pub}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+func encodeMultikey(codec, raw []byte) string {
+	buf := make([]byte, 0, len(codec)+len(raw))
+	buf = append(buf, codec...)
+	buf = append(buf, raw...)
+	return EncodeMultibase(buf)
+}
+
+func decodeMultikey(encoded string) ([]byte, []byte, error) {
+	data, err := DecodeMultibase(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < 3 {
+		return nil, nil, ErrInvalidKey
+	}
+	return data[:2], data[2:], nil
+}
@@ -0,0 +1,79 @@
+package signing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrSealedValue is returned when a sealed value cannot be decrypted
+var ErrSealedValue = errors.New("cannot decrypt sealed value")
+
+// SealPrivateKey encrypts a private key with AES-256-GCM so it can be stored
+// at rest. The encryption key is derived from secret with SHA-256.
+func SealPrivateKey(key *PrivateKey, secret string) (string, error) {
+	return SealSecret(key.Multibase(), secret)
+}
+
+// OpenPrivateKey decrypts a private key produced by SealPrivateKey
+func OpenPrivateKey(sealed, secret string) (*PrivateKey, error) {
+	plaintext, err := OpenSecret(sealed, secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decrypt stored key", ErrInvalidKey)
+	}
+	return ParsePrivateKey(plaintext)
+}
+
+// SealSecret encrypts any value the same way as SealPrivateKey
+func SealSecret(value, secret string) (string, error) {
+	gcm, err := newKeyCipher(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSecret decrypts a value produced by SealSecret
+func OpenSecret(sealed, secret string) (string, error) {
+	gcm, err := newKeyCipher(secret)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", ErrSealedValue
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSealedValue
+	}
+	return string(plaintext), nil
+}
+
+func newKeyCipher(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("key encryption secret is empty")
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
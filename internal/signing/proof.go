@@ -0,0 +1,121 @@
+// Package signing implements the asymmetric key handling and Data Integrity
+// proof suites used to sign passport credentials.
+//
+// Proofs are DataIntegrityProofs using the JCS cryptosuites: eddsa-jcs-2022
+// for Ed25519 keys and ecdsa-jcs-2019 for secp256k1 keys. Documents are
+// canonicalized with JCS (RFC 8785) rather than RDF dataset normalization,
+// so the signed bytes depend only on the JSON data model of the credential
+// and not on remote JSON-LD contexts.
+package signing
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// ProofTypeDataIntegrity is the type of every proof created here
+const ProofTypeDataIntegrity = "DataIntegrityProof"
+
+// Supported cryptosuites. ecdsa-jcs-2019 names P-256 and P-384; secp256k1
+// keys use its algorithm unchanged, SHA-256 and a 64-byte r||s signature,
+// with the curve given by the Multikey of the verification method.
+const (
+	CryptosuiteEdDSAJCS2022 = "eddsa-jcs-2022"
+	CryptosuiteECDSAJCS2019 = "ecdsa-jcs-2019"
+)
+
+// ProofOptions are the proof fields covered by the signature. Context is the
+// @context of the secured document, when it has one.
+type ProofOptions struct {
+	Context            []string `json:"@context,omitempty"`
+	Type               string   `json:"type"`
+	Cryptosuite        string   `json:"cryptosuite"`
+	Created            string   `json:"created"`
+	ProofPurpose       string   `json:"proofPurpose"`
+	VerificationMethod string   `json:"verificationMethod"`
+}
+
+// CryptosuiteForKey returns the cryptosuite used for a key type
+func CryptosuiteForKey(keyType KeyType) (string, error) {
+	switch keyType {
+	case KeyTypeEd25519:
+		return CryptosuiteEdDSAJCS2022, nil
+	case KeyTypeSecp256k1:
+		return CryptosuiteECDSAJCS2019, nil
+	default:
+		return "", ErrUnsupportedKeyType
+	}
+}
+
+// checkSuite requires opts to name the proof type and cryptosuite of keyType
+func checkSuite(opts ProofOptions, keyType KeyType) error {
+	expected, err := CryptosuiteForKey(keyType)
+	if err != nil {
+		return err
+	}
+	if opts.Type != ProofTypeDataIntegrity {
+		return fmt.Errorf("unsupported proof type %q", opts.Type)
+	}
+	if opts.Cryptosuite != expected {
+		return fmt.Errorf("cryptosuite %q does not match %s key", opts.Cryptosuite, keyType)
+	}
+	return nil
+}
+
+// CreateProofValue signs document (without its proof) under the given options
+// and returns the multibase-encoded signature
+func CreateProofValue(document interface{}, opts ProofOptions, key *PrivateKey) (string, error) {
+	if err := checkSuite(opts, key.Type); err != nil {
+		return "", err
+	}
+
+	hashData, err := proofHashData(document, opts)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := key.Sign(hashData)
+	if err != nil {
+		return "", err
+	}
+
+	return EncodeMultibase(signature), nil
+}
+
+// VerifyProofValue checks a proof value created by CreateProofValue
+func VerifyProofValue(document interface{}, opts ProofOptions, proofValue string, key *PublicKey) error {
+	if err := checkSuite(opts, key.Type); err != nil {
+		return err
+	}
+
+	signature, err := DecodeMultibase(proofValue)
+	if err != nil {
+		return fmt.Errorf("invalid proof value: %w", err)
+	}
+
+	hashData, err := proofHashData(document, opts)
+	if err != nil {
+		return err
+	}
+
+	return key.Verify(hashData, signature)
+}
+
+// proofHashData follows the hashing step of the JCS cryptosuites:
+// SHA-256(canonical proof options) || SHA-256(canonical document)
+func proofHashData(document interface{}, opts ProofOptions) ([]byte, error) {
+	canonicalOpts, err := Canonicalize(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize proof options: %w", err)
+	}
+
+	canonicalDoc, err := Canonicalize(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize document: %w", err)
+	}
+
+	optsHash := sha256.Sum256(canonicalOpts)
+	docHash := sha256.Sum256(canonicalDoc)
+
+	return append(optsHash[:], docHash[:]...), nil
+}
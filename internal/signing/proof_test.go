@@ -0,0 +1,181 @@
+package signing
+
+import (
+	"errors"
+	"testing"
+)
+
+var keyTypes = []KeyType{KeyTypeEd25519, KeyTypeSecp256k1}
+
+func TestKeyMultibaseRoundTrip(t *testing.T) {
+	for _, keyType := range keyTypes {
+		t.Run(string(keyType), func(t *testing.T) {
+			key, err := GenerateKey(keyType)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+
+			parsed, err := ParsePrivateKey(key.Multibase())
+			if err != nil {
+				t.Fatalf("ParsePrivateKey() error = %v", err)
+			}
+			if parsed.Type != keyType || parsed.Multibase() != key.Multibase() {
+				t.Errorf("private key did not round-trip")
+			}
+
+			public, err := ParsePublicKey(key.Public().Multibase())
+			if err != nil {
+				t.Fatalf("ParsePublicKey() error = %v", err)
+			}
+			if public.Multibase() != parsed.Public().Multibase() {
+				t.Errorf("public key did not round-trip")
+			}
+		})
+	}
+}
+
+func TestParseKeyRejectsMalformedInput(t *testing.T) {
+	key, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"no multibase prefix", key.Public().Multibase()[1:]},
+		{"not base58", "z0OIl"},
+		{"private key as public key", key.Multibase()},
+		{"truncated key", key.Public().Multibase()[:20]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePublicKey(tt.encoded); err == nil {
+				t.Errorf("ParsePublicKey(%q) succeeded", tt.encoded)
+			}
+		})
+	}
+}
+
+func TestProofValue(t *testing.T) {
+	document := map[string]interface{}{
+		"id":                "urn:uuid:5d2f1b7e",
+		"credentialSubject": map[string]interface{}{"passportId": "ALU-2024-001", "recycledContentPercent": 42.5},
+	}
+
+	for _, keyType := range keyTypes {
+		t.Run(string(keyType), func(t *testing.T) {
+			key, err := GenerateKey(keyType)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			other, err := GenerateKey(keyType)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			cryptosuite, err := CryptosuiteForKey(keyType)
+			if err != nil {
+				t.Fatalf("CryptosuiteForKey() error = %v", err)
+			}
+
+			opts := ProofOptions{
+				Context:            []string{"https://www.w3.org/ns/credentials/v2"},
+				Type:               ProofTypeDataIntegrity,
+				Cryptosuite:        cryptosuite,
+				Created:            "2024-05-01T10:00:00Z",
+				ProofPurpose:       "assertionMethod",
+				VerificationMethod: "did:example:issuer#key-1",
+			}
+			proofValue, err := CreateProofValue(document, opts, key)
+			if err != nil {
+				t.Fatalf("CreateProofValue() error = %v", err)
+			}
+
+			// The same data with members in another order is the same document
+			reordered := map[string]interface{}{
+				"credentialSubject": map[string]interface{}{"recycledContentPercent": 42.5, "passportId": "ALU-2024-001"},
+				"id":                "urn:uuid:5d2f1b7e",
+			}
+			if err := VerifyProofValue(reordered, opts, proofValue, key.Public()); err != nil {
+				t.Errorf("VerifyProofValue() error = %v", err)
+			}
+
+			tampered := map[string]interface{}{
+				"id":                "urn:uuid:5d2f1b7e",
+				"credentialSubject": map[string]interface{}{"passportId": "ALU-2024-001", "recycledContentPercent": 95.0},
+			}
+			otherMethod := opts
+			otherMethod.VerificationMethod = "did:example:attacker#key-1"
+			otherCreated := opts
+			otherCreated.Created = "2025-05-01T10:00:00Z"
+			otherContext := opts
+			otherContext.Context = []string{"https://www.w3.org/2018/credentials/v1"}
+
+			tests := []struct {
+				name       string
+				document   interface{}
+				opts       ProofOptions
+				proofValue string
+				key        *PublicKey
+			}{
+				{"changed document", tampered, opts, proofValue, key.Public()},
+				{"changed verification method", document, otherMethod, proofValue, key.Public()},
+				{"changed creation time", document, otherCreated, proofValue, key.Public()},
+				{"changed proof context", document, otherContext, proofValue, key.Public()},
+				{"other key", document, opts, proofValue, other.Public()},
+				{"truncated proof value", document, opts, proofValue[:len(proofValue)-2], key.Public()},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if err := VerifyProofValue(tt.document, tt.opts, tt.proofValue, tt.key); err == nil {
+						t.Errorf("VerifyProofValue() accepted a tampered proof")
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCreateProofValueRejectsWrongSuite(t *testing.T) {
+	key, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts ProofOptions
+	}{
+		{"cryptosuite of another key type", ProofOptions{Type: ProofTypeDataIntegrity, Cryptosuite: CryptosuiteECDSAJCS2019}},
+		{"no cryptosuite", ProofOptions{Type: ProofTypeDataIntegrity}},
+		{"legacy proof type", ProofOptions{Type: "Ed25519Signature2020", Cryptosuite: CryptosuiteEdDSAJCS2022}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.ProofPurpose = "assertionMethod"
+			if _, err := CreateProofValue(map[string]interface{}{}, tt.opts, key); err == nil {
+				t.Errorf("CreateProofValue() signed with %+v", tt.opts)
+			}
+		})
+	}
+}
+
+func TestSignatureVerifyRejectsOtherData(t *testing.T) {
+	key, err := GenerateKey(KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signature, err := key.Sign([]byte("passport"))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := key.Public().Verify([]byte("passport"), signature); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := key.Public().Verify([]byte("passpork"), signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of other data = %v, want ErrInvalidSignature", err)
+	}
+}
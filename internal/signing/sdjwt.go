@@ -0,0 +1,283 @@
+package signing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Selective Disclosure for JWTs (RFC 9901). Each concealed claim is replaced
+// by the digest of a disclosure, base64url([salt, name, value]), listed in
+// the "_sd" array of the object that held it. The issuer signs the JWT with
+// the digests; a holder presents it with only the disclosures it chooses to
+// reveal.
+
+// SDAlgSHA256 is the only supported disclosure digest algorithm
+const SDAlgSHA256 = "sha-256"
+
+const (
+	sdClaim    = "_sd"
+	sdAlgClaim = "_sd_alg"
+	sdSep      = "~"
+)
+
+var (
+	ErrInvalidSDJWT      = errors.New("invalid SD-JWT")
+	ErrInvalidDisclosure = errors.New("invalid disclosure")
+)
+
+// Disclosure reveals one concealed object property
+type Disclosure struct {
+	Salt    string
+	Name    string
+	Value   interface{}
+	Encoded string
+}
+
+// SDJWT is an issuer-signed JWT together with the disclosures sent with it.
+// Key binding JWTs are not supported.
+type SDJWT struct {
+	IssuerJWT   string
+	Disclosures []*Disclosure
+}
+
+// NewDisclosure creates a salted disclosure for a property
+func NewDisclosure(name string, value interface{}) (*Disclosure, error) {
+	if name == sdClaim || name == "..." {
+		return nil, fmt.Errorf("%w: reserved claim name %q", ErrInvalidDisclosure, name)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	d := &Disclosure{Salt: encodeSegment(salt), Name: name, Value: value}
+	raw, err := json.Marshal([]interface{}{d.Salt, d.Name, d.Value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode disclosure: %w", err)
+	}
+	d.Encoded = encodeSegment(raw)
+	return d, nil
+}
+
+// ParseDisclosure decodes a base64url disclosure
+func ParseDisclosure(encoded string) (*Disclosure, error) {
+	raw, err := decodeSegment(encoded)
+	if err != nil {
+		return nil, ErrInvalidDisclosure
+	}
+
+	var parts []interface{}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, ErrInvalidDisclosure
+	}
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: array element disclosures are not supported", ErrInvalidDisclosure)
+	}
+
+	salt, ok1 := parts[0].(string)
+	name, ok2 := parts[1].(string)
+	if !ok1 || !ok2 || name == sdClaim || name == "..." {
+		return nil, ErrInvalidDisclosure
+	}
+
+	return &Disclosure{Salt: salt, Name: name, Value: parts[2], Encoded: encoded}, nil
+}
+
+// Digest is the value listed in "_sd" for this disclosure
+func (d *Disclosure) Digest() string {
+	sum := sha256.Sum256([]byte(d.Encoded))
+	return encodeSegment(sum[:])
+}
+
+// Conceal replaces the named properties of obj with disclosure digests and
+// returns their disclosures. Digests are sorted so their order does not give
+// away the original claim order.
+func Conceal(obj map[string]interface{}, names []string) ([]*Disclosure, error) {
+	var disclosures []*Disclosure
+	digests, _ := obj[sdClaim].([]interface{})
+
+	for _, name := range names {
+		value, ok := obj[name]
+		if !ok {
+			continue
+		}
+		d, err := NewDisclosure(name, value)
+		if err != nil {
+			return nil, err
+		}
+		delete(obj, name)
+		disclosures = append(disclosures, d)
+		digests = append(digests, d.Digest())
+	}
+
+	if len(digests) > 0 {
+		sort.Slice(digests, func(i, j int) bool { return digests[i].(string) < digests[j].(string) })
+		obj[sdClaim] = digests
+	}
+	return disclosures, nil
+}
+
+// Reveal rebuilds the claims of a verified payload from the disclosures that
+// were presented. Every disclosure must be referenced exactly once. It
+// returns the processed claims and the dotted path of each revealed claim.
+func Reveal(payload map[string]interface{}, disclosures []*Disclosure) (map[string]interface{}, []string, error) {
+	if alg, ok := payload[sdAlgClaim]; ok && alg != SDAlgSHA256 {
+		return nil, nil, fmt.Errorf("%w: unsupported _sd_alg %v", ErrInvalidSDJWT, alg)
+	}
+
+	byDigest := make(map[string]*Disclosure, len(disclosures))
+	for _, d := range disclosures {
+		digest := d.Digest()
+		if _, dup := byDigest[digest]; dup {
+			return nil, nil, fmt.Errorf("%w: duplicate disclosure", ErrInvalidSDJWT)
+		}
+		byDigest[digest] = d
+	}
+
+	used := make(map[string]bool, len(disclosures))
+	var revealed []string
+	claims, err := revealObject(payload, "", byDigest, used, &revealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	delete(claims, sdAlgClaim)
+
+	if len(used) != len(byDigest) {
+		return nil, nil, fmt.Errorf("%w: disclosure not referenced by the issuer", ErrInvalidSDJWT)
+	}
+
+	sort.Strings(revealed)
+	return claims, revealed, nil
+}
+
+// DisclosurePaths maps the digest of every disclosure in an SD-JWT to the
+// dotted path of the claim it reveals, e.g. "refining.refinerId"
+func DisclosurePaths(payload map[string]interface{}, disclosures []*Disclosure) map[string]string {
+	byDigest := make(map[string]*Disclosure, len(disclosures))
+	for _, d := range disclosures {
+		byDigest[d.Digest()] = d
+	}
+
+	paths := make(map[string]string, len(disclosures))
+	var walk func(obj map[string]interface{}, prefix string)
+	walk = func(obj map[string]interface{}, prefix string) {
+		digests, _ := obj[sdClaim].([]interface{})
+		for _, digest := range digests {
+			s, _ := digest.(string)
+			d, ok := byDigest[s]
+			if !ok {
+				continue
+			}
+			paths[s] = prefix + d.Name
+			if nested, ok := d.Value.(map[string]interface{}); ok {
+				walk(nested, prefix+d.Name+".")
+			}
+		}
+		for name, value := range obj {
+			if nested, ok := value.(map[string]interface{}); ok && name != sdClaim {
+				walk(nested, prefix+name+".")
+			}
+		}
+	}
+	walk(payload, "")
+	return paths
+}
+
+// ParseSDJWT splits a serialized SD-JWT (issuer JWT~disclosure~...~)
+func ParseSDJWT(serialized string) (*SDJWT, error) {
+	parts := strings.Split(serialized, sdSep)
+	if len(parts) < 2 || parts[0] == "" {
+		return nil, ErrInvalidSDJWT
+	}
+	if parts[len(parts)-1] != "" {
+		return nil, fmt.Errorf("%w: key binding is not supported", ErrInvalidSDJWT)
+	}
+
+	sd := &SDJWT{IssuerJWT: parts[0]}
+	for _, encoded := range parts[1 : len(parts)-1] {
+		d, err := ParseDisclosure(encoded)
+		if err != nil {
+			return nil, err
+		}
+		sd.Disclosures = append(sd.Disclosures, d)
+	}
+	return sd, nil
+}
+
+// Serialize encodes the SD-JWT in compact form
+func (sd *SDJWT) Serialize() string {
+	var b strings.Builder
+	b.WriteString(sd.IssuerJWT)
+	b.WriteString(sdSep)
+	for _, d := range sd.Disclosures {
+		b.WriteString(d.Encoded)
+		b.WriteString(sdSep)
+	}
+	return b.String()
+}
+
+func revealObject(obj map[string]interface{}, prefix string, byDigest map[string]*Disclosure, used map[string]bool, revealed *[]string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(obj))
+	for name, value := range obj {
+		if name == sdClaim {
+			continue
+		}
+		processed, err := revealValue(value, prefix+name+".", byDigest, used, revealed)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = processed
+	}
+
+	digests, _ := obj[sdClaim].([]interface{})
+	for _, digest := range digests {
+		s, ok := digest.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed _sd", ErrInvalidSDJWT)
+		}
+		d, ok := byDigest[s]
+		if !ok {
+			continue // not disclosed, or a decoy
+		}
+		if used[s] {
+			return nil, fmt.Errorf("%w: digest referenced twice", ErrInvalidSDJWT)
+		}
+		used[s] = true
+
+		if _, exists := out[d.Name]; exists {
+			return nil, fmt.Errorf("%w: disclosed claim %q already present", ErrInvalidSDJWT, d.Name)
+		}
+		processed, err := revealValue(d.Value, prefix+d.Name+".", byDigest, used, revealed)
+		if err != nil {
+			return nil, err
+		}
+		out[d.Name] = processed
+		*revealed = append(*revealed, prefix+d.Name)
+	}
+	return out, nil
+}
+
+func revealValue(value interface{}, prefix string, byDigest map[string]*Disclosure, used map[string]bool, revealed *[]string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return revealObject(v, prefix, byDigest, used, revealed)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			processed, err := revealValue(item, prefix, byDigest, used, revealed)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = processed
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,160 @@
+package signing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// concealedPayload returns an issuer payload with origin and the nested
+// refiner ID concealed, and the disclosures for both
+func concealedPayload(t *testing.T) (map[string]interface{}, []*Disclosure) {
+	t.Helper()
+
+	refining := map[string]interface{}{"refinerId": "REF-7", "method": "Bayer"}
+	nested, err := Conceal(refining, []string{"refinerId"})
+	if err != nil {
+		t.Fatalf("Conceal() error = %v", err)
+	}
+
+	payload := map[string]interface{}{
+		"passportId": "ALU-2024-001",
+		"origin":     "Guinea",
+		"refining":   refining,
+		"_sd_alg":    SDAlgSHA256,
+	}
+	top, err := Conceal(payload, []string{"origin", "missing"})
+	if err != nil {
+		t.Fatalf("Conceal() error = %v", err)
+	}
+	if _, ok := payload["origin"]; ok {
+		t.Fatalf("Conceal() left origin in the payload")
+	}
+	return payload, append(top, nested...)
+}
+
+func TestRevealSelectedDisclosures(t *testing.T) {
+	payload, disclosures := concealedPayload(t)
+	origin, refinerID := disclosures[0], disclosures[1]
+
+	tests := []struct {
+		name        string
+		disclosures []*Disclosure
+		want        map[string]interface{}
+		revealed    []string
+	}{
+		{
+			name: "none",
+			want: map[string]interface{}{
+				"passportId": "ALU-2024-001",
+				"refining":   map[string]interface{}{"method": "Bayer"},
+			},
+		},
+		{
+			name:        "top level only",
+			disclosures: []*Disclosure{origin},
+			want: map[string]interface{}{
+				"passportId": "ALU-2024-001",
+				"origin":     "Guinea",
+				"refining":   map[string]interface{}{"method": "Bayer"},
+			},
+			revealed: []string{"origin"},
+		},
+		{
+			name:        "all",
+			disclosures: []*Disclosure{refinerID, origin},
+			want: map[string]interface{}{
+				"passportId": "ALU-2024-001",
+				"origin":     "Guinea",
+				"refining":   map[string]interface{}{"method": "Bayer", "refinerId": "REF-7"},
+			},
+			revealed: []string{"origin", "refining.refinerId"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, revealed, err := Reveal(payload, tt.disclosures)
+			if err != nil {
+				t.Fatalf("Reveal() error = %v", err)
+			}
+			if !reflect.DeepEqual(claims, tt.want) {
+				t.Errorf("Reveal() claims = %v, want %v", claims, tt.want)
+			}
+			if len(revealed) != len(tt.revealed) || (len(revealed) > 0 && !reflect.DeepEqual(revealed, tt.revealed)) {
+				t.Errorf("Reveal() revealed = %v, want %v", revealed, tt.revealed)
+			}
+		})
+	}
+}
+
+func TestRevealRejectsForgedDisclosures(t *testing.T) {
+	payload, disclosures := concealedPayload(t)
+	origin := disclosures[0]
+
+	// A disclosure with a changed value no longer matches its digest
+	forged, err := ParseDisclosure(encodeSegment([]byte(`["` + origin.Salt + `","origin","Australia"]`)))
+	if err != nil {
+		t.Fatalf("ParseDisclosure() error = %v", err)
+	}
+	unknown, err := NewDisclosure("origin", "Australia")
+	if err != nil {
+		t.Fatalf("NewDisclosure() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		disclosures []*Disclosure
+	}{
+		{"changed value", []*Disclosure{forged}},
+		{"not issued", []*Disclosure{unknown}},
+		{"duplicate", []*Disclosure{origin, origin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Reveal(payload, tt.disclosures); !errors.Is(err, ErrInvalidSDJWT) {
+				t.Errorf("Reveal() error = %v, want ErrInvalidSDJWT", err)
+			}
+		})
+	}
+}
+
+func TestSDJWTSerializeRoundTrip(t *testing.T) {
+	_, disclosures := concealedPayload(t)
+	sd := &SDJWT{IssuerJWT: "header.payload.signature", Disclosures: disclosures}
+
+	parsed, err := ParseSDJWT(sd.Serialize())
+	if err != nil {
+		t.Fatalf("ParseSDJWT() error = %v", err)
+	}
+	if parsed.IssuerJWT != sd.IssuerJWT || len(parsed.Disclosures) != len(disclosures) {
+		t.Fatalf("ParseSDJWT() = %+v", parsed)
+	}
+	for i, d := range parsed.Disclosures {
+		if d.Digest() != disclosures[i].Digest() || d.Name != disclosures[i].Name {
+			t.Errorf("disclosure %d did not round-trip", i)
+		}
+	}
+}
+
+func TestParseSDJWTRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		serialized string
+	}{
+		{"no separator", "header.payload.signature"},
+		{"no issuer JWT", "~"},
+		{"key binding JWT", "header.payload.signature~kb.jwt.here"},
+		{"malformed disclosure", "header.payload.signature~not-base64!~"},
+		{"reserved claim name", "header.payload.signature~" + encodeSegment([]byte(`["salt","_sd","x"]`)) + "~"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSDJWT(tt.serialized); err == nil {
+				t.Errorf("ParseSDJWT(%q) succeeded", tt.serialized)
+			}
+		})
+	}
+}
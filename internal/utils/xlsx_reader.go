@@ -0,0 +1,477 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"aluminium-passport/internal/models"
+)
+
+// Spreadsheet parts are read straight from the XLSX package (a ZIP of
+// SpreadsheetML documents). Only what batch uploads need is decoded: sheet
+// names, cell values, shared strings and which number formats are dates.
+
+type xlsxWorkbook struct {
+	WorkbookPr struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a string item: plain text, or rich text runs
+type xlsxText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.R {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID         int    `xml:"numFmtId,attr"`
+		FormatCode string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string    `xml:"r,attr"`
+			T  string    `xml:"t,attr"`
+			S  int       `xml:"s,attr"`
+			V  string    `xml:"v"`
+			IS *xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxCell is a decoded cell value
+type xlsxCell struct {
+	ref   models.CellRef
+	value string
+	err   string
+}
+
+// xlsxSheet is the decoded content of one worksheet
+type xlsxSheet struct {
+	name string
+	rows [][]xlsxCell
+}
+
+// processXLSXFile reads every sheet of a workbook. Each sheet starts with a
+// header row naming its columns and must include passport_id. Rows of
+// different sheets with the same passport ID are merged into one record, so
+// a workbook may split a passport across sheets, one per production stage.
+func (zp *ZipProcessor) processXLSXFile(name string, reader io.Reader) ([]models.BatchRecord, []models.BatchRowError, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, MaxZipSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read XLSX: %w", err)
+	}
+	if len(data) > MaxZipSize {
+		return nil, nil, fmt.Errorf("XLSX file too large (max: %d bytes)", MaxZipSize)
+	}
+
+	sheets, err := readXLSX(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []models.BatchRecord
+	var errors []models.BatchRowError
+	merged := make(map[string]int) // passport ID -> index in records, across sheets
+	rejected := make(map[int]bool)
+
+	for _, sheet := range sheets {
+		header, rows := xlsxHeader(sheet.rows)
+		if header == nil {
+			continue // empty sheet
+		}
+
+		idColumn := ""
+		for column, title := range header {
+			if isPassportIDHeader(title) {
+				idColumn = column
+			}
+		}
+		if idColumn == "" {
+			errors = append(errors, models.BatchRowError{File: name, Sheet: sheet.name, Message: "sheet has no passport_id column"})
+			continue
+		}
+
+		sheetIDs := make(map[string]bool)
+		for _, row := range rows {
+			rowNumber := row[0].ref.Row
+			fields := make(map[string]string)
+			cells := make(map[string]models.CellRef)
+			failed := false
+
+			for _, cell := range row {
+				title, ok := header[cell.ref.Column]
+				if !ok {
+					if cell.value != "" || cell.err != "" {
+						errors = append(errors, xlsxCellError(name, cell.ref, "", "value in a column without a header"))
+						failed = true
+					}
+					continue
+				}
+				if cell.err != "" {
+					errors = append(errors, xlsxCellError(name, cell.ref, title, "cell contains error "+cell.err))
+					failed = true
+					continue
+				}
+				if cell.value != "" {
+					fields[title] = cell.value
+					cells[title] = cell.ref
+				}
+			}
+			if len(fields) == 0 && !failed {
+				continue // blank row
+			}
+
+			// Merge with the row for the same passport on an earlier sheet
+			passportID := fields[header[idColumn]]
+			index, ok := merged[passportID]
+			if passportID == "" || sheetIDs[passportID] || !ok {
+				records = append(records, models.BatchRecord{
+					File:   name,
+					Sheet:  sheet.name,
+					Row:    rowNumber,
+					Fields: fields,
+					Cells:  cells,
+				})
+				index = len(records) - 1
+				if passportID != "" && !ok {
+					merged[passportID] = index
+				}
+			} else {
+				record := &records[index]
+				for title, value := range fields {
+					if existing, ok := record.Fields[title]; ok && title != header[idColumn] {
+						if existing != value {
+							first := record.Cells[title]
+							errors = append(errors, xlsxCellError(name, cells[title], title,
+								fmt.Sprintf("conflicts with %s!%s%d", first.Sheet, first.Column, first.Row)))
+							failed = true
+						}
+						continue
+					}
+					if title != header[idColumn] {
+						record.Fields[title] = value
+						record.Cells[title] = cells[title]
+					}
+				}
+			}
+			if passportID != "" {
+				sheetIDs[passportID] = true
+			}
+			if failed {
+				rejected[index] = true
+			}
+		}
+	}
+
+	// Rows with unreadable cells are reported above and left out
+	var accepted []models.BatchRecord
+	for i, record := range records {
+		if !rejected[i] {
+			accepted = append(accepted, record)
+		}
+	}
+
+	if len(accepted) == 0 && len(errors) == 0 {
+		return nil, nil, fmt.Errorf("XLSX file must have at least one sheet with a header and one data row")
+	}
+
+	return accepted, errors, nil
+}
+
+// readXLSX decodes the worksheets of a workbook in workbook order
+func readXLSX(data []byte) ([]xlsxSheet, error) {
+	pkg, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XLSX: %w", err)
+	}
+	parts := make(map[string]*zip.File, len(pkg.File))
+	for _, f := range pkg.File {
+		parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := readXLSXPart(parts, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(parts, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := parts["xl/styles.xml"]; ok {
+		if err := readXLSXPart(parts, "xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+
+	dateStyles := xlsxDateStyles(styles)
+
+	var sheets []xlsxSheet
+	for _, s := range workbook.Sheets {
+		target, ok := targets[s.RID]
+		if !ok {
+			return nil, fmt.Errorf("failed to read XLSX: sheet %q has no worksheet", s.Name)
+		}
+		var ws xlsxWorksheet
+		if err := readXLSXPart(parts, target, &ws); err != nil {
+			return nil, err
+		}
+
+		sheet := xlsxSheet{name: s.Name}
+		for i, r := range ws.Rows {
+			rowNumber := r.R
+			if rowNumber == 0 {
+				rowNumber = i + 1
+			}
+			var row []xlsxCell
+			for j, c := range r.Cells {
+				column, cellRow := splitCellRef(c.R)
+				if column == "" {
+					column = columnName(j)
+				}
+				if cellRow == 0 {
+					cellRow = rowNumber
+				}
+				cell := xlsxCell{ref: models.CellRef{Sheet: s.Name, Row: cellRow, Column: column}}
+
+				switch c.T {
+				case "s":
+					index, err := strconv.Atoi(strings.TrimSpace(c.V))
+					if err != nil || index < 0 || index >= len(shared.Items) {
+						cell.err = "#REF!"
+					} else {
+						cell.value = shared.Items[index].String()
+					}
+				case "inlineStr":
+					if c.IS != nil {
+						cell.value = c.IS.String()
+					}
+				case "str":
+					cell.value = c.V
+				case "b":
+					cell.value = strconv.FormatBool(c.V == "1")
+				case "e":
+					cell.err = c.V
+				case "d":
+					cell.value = c.V
+					if t, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(c.V, "Z")); err == nil {
+						cell.value = t.Format("2006-01-02")
+					}
+				default:
+					cell.value = xlsxNumber(c.V, dateStyles[c.S], workbook.WorkbookPr.Date1904)
+				}
+				cell.value = strings.TrimSpace(cell.value)
+				row = append(row, cell)
+			}
+			if len(row) > 0 {
+				sheet.rows = append(sheet.rows, row)
+			}
+		}
+		sheets = append(sheets, sheet)
+	}
+
+	return sheets, nil
+}
+
+func readXLSXPart(parts map[string]*zip.File, name string, v interface{}) error {
+	f, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("failed to read XLSX: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read XLSX: %w", err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(rc, MaxZipSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to read XLSX %s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxHeader returns the first non-empty row as column -> title, and the
+// rows below it
+func xlsxHeader(rows [][]xlsxCell) (map[string]string, [][]xlsxCell) {
+	for i, row := range rows {
+		header := make(map[string]string)
+		for _, cell := range row {
+			if cell.value != "" {
+				header[cell.ref.Column] = cell.value
+			}
+		}
+		if len(header) > 0 {
+			return header, rows[i+1:]
+		}
+	}
+	return nil, nil
+}
+
+// xlsxDateStyles reports which cell styles display numbers as dates
+func xlsxDateStyles(styles xlsxStyles) map[int]bool {
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.FormatCode
+	}
+
+	dates := make(map[int]bool)
+	for i, xf := range styles.CellXfs {
+		id := xf.NumFmtID
+		switch {
+		case (id >= 14 && id <= 22) || (id >= 45 && id <= 47):
+			dates[i] = true
+		case custom[id] != "":
+			dates[i] = isDateFormat(custom[id])
+		}
+	}
+	return dates
+}
+
+// isDateFormat reports whether a number format code displays a date, i.e.
+// uses day, month or year outside quoted text and [colour] sections
+func isDateFormat(code string) bool {
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '\\':
+			i++
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case inBrackets:
+		case c == 'd' || c == 'D' || c == 'm' || c == 'M' || c == 'y' || c == 'Y':
+			return true
+		}
+	}
+	return false
+}
+
+// xlsxNumber renders a numeric cell as text: dates as YYYY-MM-DD, other
+// numbers without exponent or binary noise
+func xlsxNumber(v string, isDate, date1904 bool) string {
+	v = strings.TrimSpace(v)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if isDate {
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if date1904 {
+			epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return epoch.AddDate(0, 0, int(math.Floor(f))).Format("2006-01-02")
+	}
+	// Excel keeps 15 significant digits; round away the rest before printing
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// splitCellRef splits "AB12" into "AB" and 12
+func splitCellRef(ref string) (string, int) {
+	i := strings.IndexFunc(ref, unicode.IsDigit)
+	if i <= 0 {
+		return "", 0
+	}
+	row, err := strconv.Atoi(ref[i:])
+	if err != nil {
+		return "", 0
+	}
+	return strings.ToUpper(ref[:i]), row
+}
+
+// columnName returns the spreadsheet column letters for a zero-based index
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func xlsxCellError(file string, ref models.CellRef, field, message string) models.BatchRowError {
+	return models.BatchRowError{
+		File:    file,
+		Sheet:   ref.Sheet,
+		Row:     ref.Row,
+		Column:  ref.Column,
+		Field:   field,
+		Message: message,
+	}
+}
+
+// isPassportIDHeader matches the passport_id column regardless of case,
+// spaces and punctuation, as the services header mapping does
+func isPassportIDHeader(title string) bool {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String() == "passportid"
+}
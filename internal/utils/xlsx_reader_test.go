@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aluminium-passport/internal/models"
+)
+
+// newXLSX returns a workbook with the given shared strings and sheets, each
+// a sheet name and the rows of its sheetData. Cell style 1 is a built-in
+// date format and style 2 a custom one.
+func newXLSX(t *testing.T, shared []string, sheets ...[2]string) io.Reader {
+	t.Helper()
+	var workbook, rels, strs strings.Builder
+	files := [][2]string{}
+	for i, sheet := range sheets {
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, sheet[0], i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		files = append(files, [2]string{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1),
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet[1] + `</sheetData></worksheet>`})
+	}
+	for _, s := range shared {
+		strs.WriteString(`<si>` + s + `</si>`)
+	}
+	files = append(files,
+		[2]string{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + workbook.String() + `</sheets></workbook>`},
+		[2]string{"xl/_rels/workbook.xml.rels", `<Relationships>` + rels.String() + `</Relationships>`},
+		[2]string{"xl/sharedStrings.xml", `<sst>` + strs.String() + `</sst>`},
+		[2]string{"xl/styles.xml", `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="dd/mm/yyyy"/></numFmts>
+			<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/></cellXfs></styleSheet>`},
+	)
+	return newZip(t, files...)
+}
+
+func TestProcessXLSXFileMergesSheets(t *testing.T) {
+	book := newXLSX(t, []string{`<t>passport_id</t>`, `<r><t>AP-</t></r><r><t>1</t></r>`},
+		[2]string{"Mining", `
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>origin</t></is></c><c r="C1" t="str"><v>date_of_extraction</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2" t="inlineStr"><is><t> AU </t></is></c><c r="C2" s="1"><v>45306</v></c></row>
+			<row r="3"><c r="A3" t="str"><v>AP-2</v></c><c r="C3" t="e"><v>#N/A</v></c></row>
+			<row r="4"><c r="A4" t="str"><v>AP-3</v></c><c r="D4" t="str"><v>stray</v></c></row>
+			<row r="5"><c r="A5" t="inlineStr"><is><t></t></is></c></row>`},
+		[2]string{"Smelting", `
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="str"><v>product_weight</v></c><c r="C1" t="str"><v>origin</v></c><c r="D1" t="str"><v>date_of_manufacture</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><v>12.500000000000002</v></c><c r="C2" t="str"><v>AU</v></c></row>
+			<row r="3"><c r="A3" t="str"><v>AP-5</v></c><c r="B3"><v>3</v></c><c r="D3" s="2"><v>45306</v></c></row>`},
+		[2]string{"Notes", `<row r="1"><c r="A1" t="str"><v>comment</v></c></row>`},
+	)
+
+	records, errs, err := NewZipProcessor().processXLSXFile("book.xlsx", book)
+	if err != nil {
+		t.Fatalf("processXLSXFile: %v", err)
+	}
+
+	mining := func(column string, row int) models.CellRef {
+		return models.CellRef{Sheet: "Mining", Row: row, Column: column}
+	}
+	smelting := func(column string, row int) models.CellRef {
+		return models.CellRef{Sheet: "Smelting", Row: row, Column: column}
+	}
+	wantRecords := []models.BatchRecord{
+		{
+			File: "book.xlsx", Sheet: "Mining", Row: 2,
+			Fields: map[string]string{"passport_id": "AP-1", "origin": "AU", "date_of_extraction": "2024-01-15", "product_weight": "12.5"},
+			Cells: map[string]models.CellRef{"passport_id": mining("A", 2), "origin": mining("B", 2),
+				"date_of_extraction": mining("C", 2), "product_weight": smelting("B", 2)},
+		},
+		{
+			File: "book.xlsx", Sheet: "Smelting", Row: 3,
+			Fields: map[string]string{"passport_id": "AP-5", "product_weight": "3", "date_of_manufacture": "2024-01-15"},
+			Cells: map[string]models.CellRef{"passport_id": smelting("A", 3), "product_weight": smelting("B", 3),
+				"date_of_manufacture": smelting("D", 3)},
+		},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("records = %+v, want %+v", records, wantRecords)
+	}
+
+	wantErrors := []models.BatchRowError{
+		{File: "book.xlsx", Sheet: "Mining", Row: 3, Column: "C", Field: "date_of_extraction", Message: "cell contains error #N/A"},
+		{File: "book.xlsx", Sheet: "Mining", Row: 4, Column: "D", Message: "value in a column without a header"},
+		{File: "book.xlsx", Sheet: "Notes", Message: "sheet has no passport_id column"},
+	}
+	if !reflect.DeepEqual(errs, wantErrors) {
+		t.Errorf("errors = %+v, want %+v", errs, wantErrors)
+	}
+}
+
+func TestProcessXLSXFileReportsConflicts(t *testing.T) {
+	book := newXLSX(t, nil,
+		[2]string{"Mining", `
+			<row r="1"><c r="A1" t="str"><v>Passport ID</v></c><c r="B1" t="str"><v>origin</v></c></row>
+			<row r="2"><c r="A2" t="str"><v>AP-1</v></c><c r="B2" t="str"><v>AU</v></c></row>`},
+		[2]string{"Smelting", `
+			<row r="1"><c r="A1" t="str"><v>Passport ID</v></c><c r="B1" t="str"><v>origin</v></c></row>
+			<row r="2"><c r="A2" t="str"><v>AP-1</v></c><c r="B2" t="str"><v>GN</v></c></row>`},
+	)
+
+	records, errs, err := NewZipProcessor().processXLSXFile("book.xlsx", book)
+	if err != nil {
+		t.Fatalf("processXLSXFile: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("records = %+v, want the conflicting passport left out", records)
+	}
+	want := []models.BatchRowError{
+		{File: "book.xlsx", Sheet: "Smelting", Row: 2, Column: "B", Field: "origin", Message: "conflicts with Mining!B2"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %+v, want %+v", errs, want)
+	}
+}
+
+func TestProcessXLSXFileRejectsEmptyWorkbooks(t *testing.T) {
+	for name, book := range map[string]io.Reader{
+		"not a workbook": newZip(t, [2]string{"passports.csv", "passport_id\nAP-1\n"}),
+		"empty sheet":    newXLSX(t, nil, [2]string{"Sheet1", ``}),
+	} {
+		if _, _, err := NewZipProcessor().processXLSXFile("book.xlsx", book); err == nil {
+			t.Errorf("%s: processXLSXFile succeeded", name)
+		}
+	}
+}
+
+func TestXLSXNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		isDate   bool
+		date1904 bool
+		want     string
+	}{
+		{value: "45306", isDate: true, want: "2024-01-15"},
+		{value: "45306.75", isDate: true, want: "2024-01-15"},
+		{value: "43844", isDate: true, date1904: true, want: "2024-01-15"},
+		{value: "0.1", want: "0.1"},
+		{value: "0.30000000000000004", want: "0.3"},
+		{value: "1E+21", want: "1000000000000000000000"},
+		{value: "42", want: "42"},
+		{value: "n/a", want: "n/a"},
+	}
+	for _, tt := range tests {
+		if got := xlsxNumber(tt.value, tt.isDate, tt.date1904); got != tt.want {
+			t.Errorf("xlsxNumber(%q, %v, %v) = %q, want %q", tt.value, tt.isDate, tt.date1904, got, tt.want)
+		}
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := map[string]bool{
+		"dd/mm/yyyy":       true,
+		"[$-409]mmm d, yy": true,
+		"0.00":             false,
+		`0.0 "days"`:       false,
+		`#,##0\d`:          false,
+		"[Red]0.00":        false,
+	}
+	for code, want := range tests {
+		if got := isDateFormat(code); got != want {
+			t.Errorf("isDateFormat(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"aluminium-passport/internal/models"
 )
@@ -29,80 +31,69 @@ func NewZipProcessor() *ZipProcessor {
 	}
 }
 
-// ProcessZipFile processes uploaded ZIP file and extracts passport data
-func (zp *ZipProcessor) ProcessZipFile(fileHeader *multipart.FileHeader) (*models.BatchUploadResponse, error) {
+// ProcessZipFile extracts the passport rows of every supported file in an
+// uploaded ZIP. Files and rows that cannot be read are reported as errors
+// without stopping the rest of the archive.
+func (zp *ZipProcessor) ProcessZipFile(fileHeader *multipart.FileHeader) ([]models.BatchRecord, []models.BatchRowError, error) {
 	// Validate file size
 	if fileHeader.Size > MaxZipSize {
-		return nil, fmt.Errorf("ZIP file too large: %d bytes (max: %d)", fileHeader.Size, MaxZipSize)
+		return nil, nil, fmt.Errorf("ZIP file too large: %d bytes (max: %d)", fileHeader.Size, MaxZipSize)
 	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ZIP file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open ZIP file: %w", err)
 	}
 	defer file.Close()
 
+	return zp.ReadZip(file, fileHeader.Size)
+}
+
+// ReadZip extracts the passport rows of a ZIP archive
+func (zp *ZipProcessor) ReadZip(r io.ReaderAt, size int64) ([]models.BatchRecord, []models.BatchRowError, error) {
 	// Create ZIP reader
-	zipReader, err := zip.NewReader(file, fileHeader.Size)
+	zipReader, err := zip.NewReader(r, size)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ZIP file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read ZIP file: %w", err)
 	}
 
 	// Validate number of files
 	if len(zipReader.File) > MaxFiles {
-		return nil, fmt.Errorf("too many files in ZIP: %d (max: %d)", len(zipReader.File), MaxFiles)
+		return nil, nil, fmt.Errorf("too many files in ZIP: %d (max: %d)", len(zipReader.File), MaxFiles)
 	}
 
-	var allPassports []models.AluminiumPassport
-	var errors []string
-	processedFiles := 0
+	var records []models.BatchRecord
+	var errors []models.BatchRowError
 
 	// Process each file in the ZIP
 	for _, f := range zipReader.File {
-		if f.FileInfo().IsDir() {
+		if f.FileInfo().IsDir() || zp.isHiddenFile(f.Name) {
 			continue
 		}
 
 		ext := strings.ToLower(filepath.Ext(f.Name))
 		if !zp.isSupportedFormat(ext) {
-			errors = append(errors, fmt.Sprintf("unsupported file format: %s", f.Name))
+			errors = append(errors, models.BatchRowError{File: f.Name, Message: "unsupported file format"})
 			continue
 		}
 
-		passports, err := zp.processFile(f)
+		fileRecords, fileErrors, err := zp.processFile(f)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("error processing %s: %v", f.Name, err))
+			errors = append(errors, models.BatchRowError{File: f.Name, Message: err.Error()})
 			continue
 		}
 
-		allPassports = append(allPassports, passports...)
-		processedFiles++
-	}
-
-	batchID := GenerateBatchID()
-
-	response := &models.BatchUploadResponse{
-		BatchID:        batchID,
-		TotalProcessed: processedFiles,
-		Successful:     len(allPassports),
-		Failed:         len(errors),
-		Errors:         errors,
+		records = append(records, fileRecords...)
+		errors = append(errors, fileErrors...)
 	}
 
-	// Assign batch ID to all passports
-	for i := range allPassports {
-		if allPassports[i].BatchID == "" {
-			allPassports[i].BatchID = batchID
-		}
-	}
-
-	return response, nil
+	return records, errors, nil
 }
 
-func (zp *ZipProcessor) processFile(f *zip.File) ([]models.AluminiumPassport, error) {
+func (zp *ZipProcessor) processFile(f *zip.File) ([]models.BatchRecord, []models.BatchRowError, error) {
 	rc, err := f.Open()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rc.Close()
 
@@ -110,164 +101,130 @@ func (zp *ZipProcessor) processFile(f *zip.File) ([]models.AluminiumPassport, er
 
 	switch ext {
 	case ".json":
-		return zp.processJSONFile(rc)
+		return zp.processJSONFile(f.Name, rc)
 	case ".csv":
-		return zp.processCSVFile(rc)
+		return zp.processCSVFile(f.Name, rc)
+	case ".xlsx":
+		return zp.processXLSXFile(f.Name, rc)
 	default:
-		return nil, fmt.Errorf("unsupported file format: %s", ext)
+		return nil, nil, fmt.Errorf("unsupported file format: %s", ext)
 	}
 }
 
-func (zp *ZipProcessor) processJSONFile(reader io.Reader) ([]models.AluminiumPassport, error) {
-	var passports []models.AluminiumPassport
+// processJSONFile reads an array of passport objects, or a single object.
+// Rows are numbered from 1 in array order.
+func (zp *ZipProcessor) processJSONFile(name string, reader io.Reader) ([]models.BatchRecord, []models.BatchRowError, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read JSON: %w", err)
+	}
 
-	decoder := json.NewDecoder(reader)
+	var objects []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
 
 	// Try to decode as array first
-	if err := decoder.Decode(&passports); err != nil {
+	if err := decoder.Decode(&objects); err != nil {
 		// If array decode fails, try single object
-		var singlePassport models.AluminiumPassport
-		decoder = json.NewDecoder(reader)
-		if err := decoder.Decode(&singlePassport); err != nil {
-			return nil, fmt.Errorf("failed to decode JSON: %w", err)
+		var single map[string]interface{}
+		decoder = json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&single); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode JSON: %w", err)
 		}
-		passports = []models.AluminiumPassport{singlePassport}
+		objects = []map[string]interface{}{single}
+	}
+
+	var records []models.BatchRecord
+	var errors []models.BatchRowError
+	for i, object := range objects {
+		fields := make(map[string]string, len(object))
+		for key, value := range object {
+			text, err := zp.jsonFieldValue(value)
+			if err != nil {
+				errors = append(errors, models.BatchRowError{File: name, Row: i + 1, Field: key, Message: err.Error()})
+				continue
+			}
+			if text != "" {
+				fields[key] = text
+			}
+		}
+		records = append(records, models.BatchRecord{File: name, Row: i + 1, Fields: fields})
 	}
 
-	return passports, nil
+	return records, errors, nil
 }
 
-func (zp *ZipProcessor) processCSVFile(reader io.Reader) ([]models.AluminiumPassport, error) {
+// processCSVFile reads a CSV with a header row. Rows are numbered as in a
+// spreadsheet, so the first data row is row 2.
+func (zp *ZipProcessor) processCSVFile(name string, reader io.Reader) ([]models.BatchRecord, []models.BatchRowError, error) {
 	csvReader := csv.NewReader(reader)
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
-	}
+	csvReader.FieldsPerRecord = -1 // row length is checked per row below
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file must have at least header and one data row")
+	headers, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("CSV file must have at least header and one data row")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
 	}
 
-	headers := records[0]
-	var passports []models.AluminiumPassport
+	var records []models.BatchRecord
+	var errors []models.BatchRowError
 
-	for i, record := range records[1:] {
-		if len(record) != len(headers) {
-			return nil, fmt.Errorf("row %d has %d columns, expected %d", i+2, len(record), len(headers))
+	for row := 2; ; row++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
 		}
-
-		passport, err := zp.csvRecordToPassport(headers, record)
 		if err != nil {
-			return nil, fmt.Errorf("error processing row %d: %w", i+2, err)
-		}
-
-		passports = append(passports, passport)
-	}
-
-	return passports, nil
-}
-
-func (zp *ZipProcessor) csvRecordToPassport(headers, record []string) (models.AluminiumPassport, error) {
-	passport := models.AluminiumPassport{}
-
-	for i, header := range headers {
-		if i >= len(record) {
+			errors = append(errors, models.BatchRowError{File: name, Row: row, Message: fmt.Sprintf("failed to read CSV: %v", err)})
 			continue
 		}
 
-		value := strings.TrimSpace(record[i])
-		if value == "" {
+		if len(record) != len(headers) {
+			errors = append(errors, models.BatchRowError{
+				File:    name,
+				Row:     row,
+				Message: fmt.Sprintf("row has %d columns, expected %d", len(record), len(headers)),
+			})
 			continue
 		}
 
-		switch strings.ToLower(header) {
-		case "passport_id", "passportid":
-			passport.PassportID = value
-		case "batch_id", "batchid":
-			passport.BatchID = value
-		case "bauxite_origin", "bauxiteorigin":
-			passport.BauxiteOrigin = value
-		case "mine_operator", "mineoperator":
-			passport.MineOperator = value
-		case "date_of_extraction", "dateofextraction":
-			passport.DateOfExtraction = value
-		case "refinery_location", "refinerylocation":
-			passport.RefineryLocation = value
-		case "refiner_id", "refinerid":
-			passport.RefinerID = value
-		case "smelting_energy_source", "smeltingenergysource":
-			passport.SmeltingEnergySource = value
-		case "carbon_emissions_per_kg", "carbonemissionsperkg":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.CarbonEmissionsPerKg = f
-			}
-		case "alloy_composition", "alloycomposition":
-			passport.AlloyComposition = value
-		case "trace_metals", "tracemetals":
-			passport.TraceMetals = value
-		case "manufacturer_id", "manufacturerid":
-			passport.ManufacturerID = value
-		case "process_type", "processtype":
-			passport.ProcessType = value
-		case "manufactured_product", "manufacturedproduct":
-			passport.ManufacturedProduct = value
-		case "product_weight", "productweight":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.ProductWeight = f
-			}
-		case "energy_used", "energyused":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.EnergyUsed = f
-			}
-		case "manufacturing_emissions", "manufacturingemissions":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.ManufacturingEmissions = f
+		fields := make(map[string]string, len(headers))
+		for i, header := range headers {
+			if value := strings.TrimSpace(record[i]); value != "" {
+				fields[strings.TrimSpace(header)] = value
 			}
-		case "transport_mode", "transportmode":
-			passport.TransportMode = value
-		case "distance_travelled", "distancetravelled":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.DistanceTravelled = f
-			}
-		case "logistics_partner_id", "logisticspartnerid":
-			passport.LogisticsPartnerID = value
-		case "shipment_date", "shipmentdate":
-			passport.ShipmentDate = value
-		case "recycled_content_percent", "recycledcontentpercent":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.RecycledContentPercent = f
-			}
-		case "recycling_date", "recyclingdate":
-			passport.RecyclingDate = value
-		case "recycler_id", "recyclerid":
-			passport.RecyclerID = value
-		case "recycling_method", "recyclingmethod":
-			passport.RecyclingMethod = value
-		case "times_recycled", "timesrecycled":
-			if i, err := strconv.Atoi(value); err == nil {
-				passport.TimesRecycled = i
-			}
-		case "certification_agency", "certificationagency":
-			passport.CertificationAgency = value
-		case "esg_score", "esgscore":
-			if f, err := strconv.ParseFloat(value, 64); err == nil {
-				passport.ESGScore = f
-			}
-		case "compliance_standards", "compliancestandards":
-			passport.ComplianceStandards = value
-		case "date_of_certification", "dateofcertification":
-			passport.DateOfCertification = value
-		case "verifier_signature", "verifiersignature":
-			passport.VerifierSignature = value
 		}
+		records = append(records, models.BatchRecord{File: name, Row: row, Fields: fields})
 	}
 
-	// Validate required fields
-	if passport.PassportID == "" {
-		return passport, fmt.Errorf("passport_id is required")
+	if len(records) == 0 && len(errors) == 0 {
+		return nil, nil, fmt.Errorf("CSV file must have at least header and one data row")
 	}
 
-	return passport, nil
+	return records, errors, nil
+}
+
+// jsonFieldValue renders a JSON value as the text a CSV cell would hold.
+// Objects (metadata) are kept as JSON.
+func (zp *ZipProcessor) jsonFieldValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
 }
 
 func (zp *ZipProcessor) isSupportedFormat(ext string) bool {
@@ -279,6 +236,11 @@ func (zp *ZipProcessor) isSupportedFormat(ext string) bool {
 	return false
 }
 
+// isHiddenFile skips archive metadata such as __MACOSX/ entries and dotfiles
+func (zp *ZipProcessor) isHiddenFile(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(filepath.Base(name), ".")
+}
+
 // GenerateBatchID generates a unique batch ID
 func GenerateBatchID() string {
 	return fmt.Sprintf("BATCH_%d", GenerateTimestamp())
@@ -286,5 +248,5 @@ func GenerateBatchID() string {
 
 // GenerateTimestamp generates current timestamp
 func GenerateTimestamp() int64 {
-	return 1640995200000 // Placeholder - should use time.Now().UnixMilli() in real implementation
+	return time.Now().UnixMilli()
 }
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"aluminium-passport/internal/models"
+)
+
+// newZip returns a ZIP archive holding files, written in the order given
+func newZip(t *testing.T, files ...[2]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, file := range files {
+		f, err := w.Create(file[0])
+		if err != nil {
+			t.Fatalf("create %s: %v", file[0], err)
+		}
+		if _, err := f.Write([]byte(file[1])); err != nil {
+			t.Fatalf("write %s: %v", file[0], err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close ZIP: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadZip(t *testing.T) {
+	archive := newZip(t,
+		[2]string{"passports.csv", "passport_id,manufacturer,origin\nAP-1, Example Smelter ,AU\nAP-2,Other Smelter\nAP-3,,GN\n"},
+		[2]string{"more/passports.json", `[{"passport_id": "AP-4", "product_weight": 12.5, "metadata": {"line": 3}, "batch_id": null},
+			{"passport_id": "AP-5", "tags": ["a"]}]`},
+		[2]string{"single.json", `{"passport_id": "AP-6", "is_verified": true}`},
+		[2]string{"notes.txt", "not a passport"},
+		[2]string{"__MACOSX/._passports.csv", "resource fork"},
+		[2]string{".DS_Store", "finder"},
+	)
+
+	records, errs, err := NewZipProcessor().ReadZip(archive, archive.Size())
+	if err != nil {
+		t.Fatalf("ReadZip: %v", err)
+	}
+
+	wantRecords := []models.BatchRecord{
+		{File: "passports.csv", Row: 2, Fields: map[string]string{"passport_id": "AP-1", "manufacturer": "Example Smelter", "origin": "AU"}},
+		{File: "passports.csv", Row: 4, Fields: map[string]string{"passport_id": "AP-3", "origin": "GN"}},
+		{File: "more/passports.json", Row: 1, Fields: map[string]string{"passport_id": "AP-4", "product_weight": "12.5", "metadata": `{"line":3}`}},
+		{File: "more/passports.json", Row: 2, Fields: map[string]string{"passport_id": "AP-5"}},
+		{File: "single.json", Row: 1, Fields: map[string]string{"passport_id": "AP-6", "is_verified": "true"}},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("records = %+v\nwant %+v", records, wantRecords)
+	}
+
+	wantErrors := []models.BatchRowError{
+		{File: "passports.csv", Row: 3, Message: "row has 2 columns, expected 3"},
+		{File: "more/passports.json", Row: 2, Field: "tags", Message: "unsupported value type []interface {}"},
+		{File: "notes.txt", Message: "unsupported file format"},
+	}
+	if !reflect.DeepEqual(errs, wantErrors) {
+		t.Errorf("errors = %+v\nwant %+v", errs, wantErrors)
+	}
+}
+
+func TestReadZipReportsUnreadableFiles(t *testing.T) {
+	archive := newZip(t,
+		[2]string{"empty.csv", ""},
+		[2]string{"header.csv", "passport_id,manufacturer\n"},
+		[2]string{"broken.json", `[{"passport_id": `},
+	)
+
+	records, errs, err := NewZipProcessor().ReadZip(archive, archive.Size())
+	if err != nil {
+		t.Fatalf("ReadZip: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("records = %+v, want none", records)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("errors = %+v, want one per file", errs)
+	}
+	for i, file := range []string{"empty.csv", "header.csv", "broken.json"} {
+		if errs[i].File != file || errs[i].Row != 0 || errs[i].Message == "" {
+			t.Errorf("error %d = %+v, want a file error for %s", i, errs[i], file)
+		}
+	}
+}
+
+func TestReadZipRejectsNonZip(t *testing.T) {
+	data := bytes.NewReader([]byte("passport_id\nAP-1\n"))
+	if _, _, err := NewZipProcessor().ReadZip(data, data.Size()); err == nil {
+		t.Errorf("ReadZip accepted a file that is not a ZIP archive")
+	}
+}
@@ -0,0 +1,353 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+// Error codes reported in FieldError.Code
+const (
+	CodeRequired      = "required"
+	CodeTooLong       = "too_long"
+	CodeOutOfRange    = "out_of_range"
+	CodeInvalidFormat = "invalid_format"
+	CodeInvalidDate   = "invalid_date"
+	CodeCountryCode   = "invalid_country_code"
+	CodeDateOrder     = "date_order"
+)
+
+// FieldError is a validation failure of one passport field. Field is the
+// database column name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of field errors of a passport
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Rule checks one or more passport fields
+type Rule struct {
+	Fields []string
+	Check  func(p *db.AluminiumPassport) []FieldError
+}
+
+// PassportRules are the rules every passport must satisfy, whether it is
+// created on its own, uploaded in a batch or updated
+var PassportRules = []Rule{
+	Required("passport_id"),
+	MaxLength("passport_id", 100),
+	Pattern("passport_id", `^[A-Za-z0-9][A-Za-z0-9._-]*$`, "may contain only letters, digits, '.', '_' and '-'"),
+	MaxLength("batch_id", 100),
+
+	Required("manufacturer"),
+	MaxLength("manufacturer", 255),
+	Required("origin"),
+	CountryCode("origin"),
+	MaxLength("bauxite_source", 255),
+	AlloyComposition("alloy_composition"),
+
+	MaxLength("mine_operator", 255),
+	MaxLength("extraction_method", 100),
+	MaxLength("mine_location", 255),
+	MaxLength("refinery_location", 255),
+	MaxLength("refiner_id", 100),
+	MaxLength("refining_method", 100),
+	MaxLength("smelting_location", 255),
+	MaxLength("smelting_energy_source", 255),
+	MaxLength("process_type", 100),
+	MaxLength("manufactured_product", 255),
+
+	// Upper bounds follow the precision of the DECIMAL columns
+	Range("product_weight", 0, 9999999.999),
+	Range("energy_used", 0, 99999999.99),
+	Range("water_used", 0, 99999999.99),
+	Range("waste_generated", 0, 99999999.99),
+	Range("carbon_emissions_per_kg", 0, 100),
+	Range("co2_footprint", 0, 99999999.99),
+	Range("manufacturing_emissions", 0, 99999999.99),
+	Range("distance_travelled", 0, 99999999.99),
+
+	MaxLength("transport_mode", 100),
+	MaxLength("logistics_partner_id", 100),
+
+	Range("recycled_content_percent", 0, 100),
+	MaxLength("recycler_id", 100),
+	MaxLength("recycling_method", 255),
+	Range("times_recycled", 0, 1000),
+
+	MaxLength("certification_agency", 255),
+	MaxLength("certifier", 255),
+	MaxLength("verifier_signature", 500),
+
+	Range("esg_score", 0, 100),
+	Range("environmental_score", 0, 100),
+	Range("social_score", 0, 100),
+	Range("governance_score", 0, 100),
+
+	// Supply chain stages happen in order
+	DateOrder("date_of_extraction", "refining_date", "manufacturing_date", "shipment_date"),
+	DateOrder("date_of_certification", "certification_expiry"),
+}
+
+// ValidatePassport checks a passport against every rule
+func ValidatePassport(p *db.AluminiumPassport) Errors {
+	var errs Errors
+	for _, rule := range PassportRules {
+		errs = append(errs, rule.Check(p)...)
+	}
+	return errs
+}
+
+// ValidateChanges checks an updated passport against the rules that read
+// any of the changed fields. Stored values that predate a rule do not block
+// unrelated updates.
+func ValidateChanges(p *db.AluminiumPassport, changed []string) Errors {
+	touched := make(map[string]bool, len(changed))
+	for _, field := range changed {
+		touched[field] = true
+	}
+
+	var errs Errors
+	for _, rule := range PassportRules {
+		for _, field := range rule.Fields {
+			if touched[field] {
+				errs = append(errs, rule.Check(p)...)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// ParseDate parses a YYYY-MM-DD date field. An empty value leaves dst unset.
+func ParseDate(field, value string, dst **time.Time) *FieldError {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return &FieldError{Field: field, Code: CodeInvalidDate, Message: "must be a date (YYYY-MM-DD)"}
+	}
+	*dst = &date
+	return nil
+}
+
+// Required fails when a field is empty
+func Required(field string) Rule {
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		if s, ok := stringField(v); !ok || strings.TrimSpace(s) == "" {
+			return &FieldError{Field: field, Code: CodeRequired, Message: "is required"}
+		}
+		return nil
+	})
+}
+
+// MaxLength limits the number of characters of a text field
+func MaxLength(field string, max int) Rule {
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		if s, ok := stringField(v); ok && len([]rune(s)) > max {
+			return &FieldError{Field: field, Code: CodeTooLong, Message: fmt.Sprintf("must be at most %d characters", max)}
+		}
+		return nil
+	})
+}
+
+// Pattern requires a text field to match a regular expression
+func Pattern(field, pattern, message string) Rule {
+	re := regexp.MustCompile(pattern)
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		if s, ok := stringField(v); ok && s != "" && !re.MatchString(s) {
+			return &FieldError{Field: field, Code: CodeInvalidFormat, Message: message}
+		}
+		return nil
+	})
+}
+
+// Range bounds a numeric field, inclusive
+func Range(field string, min, max float64) Rule {
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		n, ok := numberField(v)
+		if ok && (n < min || n > max) {
+			return &FieldError{
+				Field:   field,
+				Code:    CodeOutOfRange,
+				Message: fmt.Sprintf("must be between %s and %s", formatNumber(min), formatNumber(max)),
+			}
+		}
+		return nil
+	})
+}
+
+// CountryCode requires an ISO 3166-1 alpha-2 country code such as "AU"
+func CountryCode(field string) Rule {
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		if s, ok := stringField(v); ok && s != "" && !isoCountryCodes[s] {
+			return &FieldError{Field: field, Code: CodeCountryCode, Message: "must be an ISO 3166-1 alpha-2 country code, such as AU"}
+		}
+		return nil
+	})
+}
+
+var (
+	// Aluminum Association designations: wrought "AA6061" or cast "AA356.0",
+	// with an optional temper such as "-T6"
+	alloyDesignation = regexp.MustCompile(`^AA ?(?:[1-9]\d{3}[A-Z]?|[A-Z]?[1-9]\d{2}\.\d)(?:-[FOHWT][0-9]*)?$`)
+	alloyElement     = regexp.MustCompile(`^([A-Z][a-z]?)-(\d+(?:\.\d+)?)%$`)
+)
+
+// AlloyComposition accepts an Aluminum Association designation ("AA6061",
+// "AA6061-T6", "AA356.0") or an element breakdown ("Al-99.7%, Si-0.2%")
+// whose percentages add up to no more than 100
+func AlloyComposition(field string) Rule {
+	return fieldRule(field, func(v reflect.Value) *FieldError {
+		s, ok := stringField(v)
+		if !ok || s == "" || alloyDesignation.MatchString(s) {
+			return nil
+		}
+
+		invalid := &FieldError{
+			Field:   field,
+			Code:    CodeInvalidFormat,
+			Message: `must be an alloy designation such as "AA6061" or an element breakdown such as "Al-99.7%, Si-0.3%"`,
+		}
+		total := 0.0
+		for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+			m := alloyElement.FindStringSubmatch(part)
+			if m == nil {
+				return invalid
+			}
+			percent, _ := strconv.ParseFloat(m[2], 64)
+			total += percent
+		}
+		if total > 100.0001 {
+			return &FieldError{Field: field, Code: CodeOutOfRange, Message: "element percentages must not add up to more than 100"}
+		}
+		return nil
+	})
+}
+
+// DateOrder requires the given date fields, where set, to be in
+// chronological order
+func DateOrder(fields ...string) Rule {
+	return Rule{
+		Fields: fields,
+		Check: func(p *db.AluminiumPassport) []FieldError {
+			var errs []FieldError
+			var latest *time.Time
+			latestField := ""
+			for _, field := range fields {
+				date := dateField(passportField(p, field))
+				if date == nil {
+					continue
+				}
+				if latest != nil && date.Before(*latest) {
+					errs = append(errs, FieldError{
+						Field:   field,
+						Code:    CodeDateOrder,
+						Message: fmt.Sprintf("must not be before %s (%s)", latestField, latest.Format("2006-01-02")),
+					})
+					continue
+				}
+				latest, latestField = date, field
+			}
+			return errs
+		},
+	}
+}
+
+// fieldRule builds a rule that checks a single field
+func fieldRule(field string, check func(reflect.Value) *FieldError) Rule {
+	if _, ok := passportFieldIndex[field]; !ok {
+		panic("validation: unknown passport field " + field)
+	}
+	return Rule{
+		Fields: []string{field},
+		Check: func(p *db.AluminiumPassport) []FieldError {
+			if fe := check(passportField(p, field)); fe != nil {
+				return []FieldError{*fe}
+			}
+			return nil
+		},
+	}
+}
+
+// passportFieldIndex maps db column names to AluminiumPassport fields
+var passportFieldIndex = func() map[string]int {
+	index := make(map[string]int)
+	t := reflect.TypeOf(db.AluminiumPassport{})
+	for i := 0; i < t.NumField(); i++ {
+		if column := t.Field(i).Tag.Get("db"); column != "" {
+			index[column] = i
+		}
+	}
+	return index
+}()
+
+func passportField(p *db.AluminiumPassport, field string) reflect.Value {
+	return reflect.ValueOf(p).Elem().Field(passportFieldIndex[field])
+}
+
+// FieldValue returns the value of a passport column, or false if the
+// passport has no such column
+func FieldValue(p *db.AluminiumPassport, field string) (interface{}, bool) {
+	if _, ok := passportFieldIndex[field]; !ok {
+		return nil, false
+	}
+	return passportField(p, field).Interface(), true
+}
+
+func stringField(v reflect.Value) (string, bool) {
+	switch s := v.Interface().(type) {
+	case string:
+		return s, true
+	case *string:
+		if s != nil {
+			return *s, true
+		}
+	}
+	return "", false
+}
+
+func numberField(v reflect.Value) (float64, bool) {
+	switch n := v.Interface().(type) {
+	case int:
+		return float64(n), true
+	case *int:
+		if n != nil {
+			return float64(*n), true
+		}
+	case *float64:
+		if n != nil {
+			return *n, true
+		}
+	}
+	return 0, false
+}
+
+func dateField(v reflect.Value) *time.Time {
+	if t, ok := v.Interface().(*time.Time); ok {
+		return t
+	}
+	return nil
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
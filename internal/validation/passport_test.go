@@ -0,0 +1,185 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"aluminium-passport/internal/db"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func date(s string) *time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// validPassport returns a passport that satisfies every rule
+func validPassport() *db.AluminiumPassport {
+	return &db.AluminiumPassport{
+		PassportID:             "AP-2025.001",
+		Manufacturer:           "Example Smelter",
+		Origin:                 "AU",
+		AlloyComposition:       ptr("AA6061-T6"),
+		ProductWeight:          ptr(12.5),
+		RecycledContentPercent: ptr(100.0),
+		DateOfExtraction:       date("2025-01-10"),
+		RefiningDate:           date("2025-01-10"),
+		ManufacturingDate:      date("2025-02-01"),
+	}
+}
+
+// codes returns the field -> code pairs of errs
+func codes(errs Errors) map[string]string {
+	got := make(map[string]string)
+	for _, fe := range errs {
+		got[fe.Field] = fe.Code
+	}
+	return got
+}
+
+func TestValidatePassport(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *db.AluminiumPassport)
+		want   map[string]string
+	}{
+		{name: "valid", modify: func(p *db.AluminiumPassport) {}, want: map[string]string{}},
+		{
+			name:   "required fields",
+			modify: func(p *db.AluminiumPassport) { p.PassportID, p.Manufacturer, p.Origin = "", " ", "" },
+			want:   map[string]string{"passport_id": CodeRequired, "manufacturer": CodeRequired, "origin": CodeRequired},
+		},
+		{
+			name:   "passport ID format",
+			modify: func(p *db.AluminiumPassport) { p.PassportID = "-AP 1" },
+			want:   map[string]string{"passport_id": CodeInvalidFormat},
+		},
+		{
+			name:   "too long",
+			modify: func(p *db.AluminiumPassport) { p.BatchID = ptr(string(make([]rune, 101))) },
+			want:   map[string]string{"batch_id": CodeTooLong},
+		},
+		{
+			name:   "country code",
+			modify: func(p *db.AluminiumPassport) { p.Origin = "Australia" },
+			want:   map[string]string{"origin": CodeCountryCode},
+		},
+		{
+			name:   "lower case country code",
+			modify: func(p *db.AluminiumPassport) { p.Origin = "au" },
+			want:   map[string]string{"origin": CodeCountryCode},
+		},
+		{
+			name: "ranges",
+			modify: func(p *db.AluminiumPassport) {
+				p.ProductWeight = ptr(-1.0)
+				p.RecycledContentPercent = ptr(100.5)
+				p.TimesRecycled = 1001
+			},
+			want: map[string]string{"product_weight": CodeOutOfRange, "recycled_content_percent": CodeOutOfRange, "times_recycled": CodeOutOfRange},
+		},
+		{
+			name: "dates out of order",
+			modify: func(p *db.AluminiumPassport) {
+				p.RefiningDate = nil
+				p.ManufacturingDate = date("2025-01-09")
+			},
+			want: map[string]string{"manufacturing_date": CodeDateOrder},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := validPassport()
+			tt.modify(p)
+			if got := codes(ValidatePassport(p)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlloyComposition(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "AA6061"},
+		{value: "AA 7075-T6"},
+		{value: "AA356.0"},
+		{value: "AA1100-H14"},
+		{value: "Al-99.7%, Si-0.2%; Fe-0.1%"},
+		{value: "Al-100%"},
+		{value: "AA60", want: CodeInvalidFormat},
+		{value: "6061", want: CodeInvalidFormat},
+		{value: "Al 99.7%", want: CodeInvalidFormat},
+		{value: "al-99%", want: CodeInvalidFormat},
+		{value: "Al-99.7%, Si-0.4%", want: CodeOutOfRange},
+	}
+
+	for _, tt := range tests {
+		p := validPassport()
+		p.AlloyComposition = ptr(tt.value)
+		if got := codes(ValidatePassport(p))["alloy_composition"]; got != tt.want {
+			t.Errorf("AlloyComposition(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDateOrderReportsEachLateField(t *testing.T) {
+	p := validPassport()
+	p.DateOfExtraction = date("2025-03-01")
+	p.ShipmentDate = date("2025-04-01")
+
+	want := Errors{
+		{Field: "refining_date", Code: CodeDateOrder, Message: "must not be before date_of_extraction (2025-03-01)"},
+		{Field: "manufacturing_date", Code: CodeDateOrder, Message: "must not be before date_of_extraction (2025-03-01)"},
+	}
+	if got := ValidatePassport(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateChanges(t *testing.T) {
+	// A stored passport that predates the country code rule
+	p := validPassport()
+	p.Origin = "Australia"
+	p.ManufacturingDate = date("2024-12-01")
+
+	if errs := ValidateChanges(p, []string{"manufacturer", "product_weight"}); len(errs) != 0 {
+		t.Errorf("unrelated update rejected: %v", errs)
+	}
+
+	got := codes(ValidateChanges(p, []string{"origin", "shipment_date"}))
+	want := map[string]string{"origin": CodeCountryCode, "manufacturing_date": CodeDateOrder}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	var dst *time.Time
+	if fe := ParseDate("shipment_date", " ", &dst); fe != nil || dst != nil {
+		t.Errorf("empty date = %v, %v; want unset", dst, fe)
+	}
+	if fe := ParseDate("shipment_date", "2025-02-30", &dst); fe == nil || fe.Code != CodeInvalidDate {
+		t.Errorf("invalid date error = %v, want %s", fe, CodeInvalidDate)
+	}
+	if fe := ParseDate("shipment_date", "2025-02-28", &dst); fe != nil || dst == nil || !dst.Equal(*date("2025-02-28")) {
+		t.Errorf("ParseDate = %v, %v; want 2025-02-28", dst, fe)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	if v, ok := FieldValue(validPassport(), "origin"); !ok || v != "AU" {
+		t.Errorf("FieldValue(origin) = %v, %v", v, ok)
+	}
+	if _, ok := FieldValue(validPassport(), "password"); ok {
+		t.Errorf("FieldValue accepted an unknown column")
+	}
+}
@@ -0,0 +1,423 @@
+package zk
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Range proofs over Pedersen commitments on P-256.
+//
+// A value v is committed to as C = v·G + r·H, where nobody knows the discrete
+// log of H with respect to G. To prove v >= t the prover shows that
+// D = C - t·G commits to a number in [0, 2^Bits); for v <= t it uses
+// D = t·G - C. D is split into one commitment per bit, each with a CDS
+// OR-proof that it opens to either 0 or 1, and the verifier checks that the
+// weighted bit commitments sum back to D. Neither the value nor the blinding
+// factor is revealed. Challenges are derived with Fiat-Shamir over the whole
+// statement, so a proof cannot be replayed against another passport,
+// attribute or threshold.
+
+// ProofType identifies proofs produced by this package
+const ProofType = "pedersen-bit-range-p256"
+
+const (
+	// Bits is the width of the proven range: the difference between the
+	// value and the threshold must be below 2^Bits
+	Bits = 32
+	// Scale converts decimal values to the fixed-point integers that are
+	// committed to (four decimal places)
+	Scale = 10000
+)
+
+type Operator string
+
+const (
+	OperatorGTE Operator = "gte"
+	OperatorLTE Operator = "lte"
+)
+
+var (
+	ErrInvalidOperator = errors.New("operator must be gte or lte")
+	ErrStatementFalse  = errors.New("value does not satisfy the statement")
+	ErrInvalidProof    = errors.New("invalid range proof")
+)
+
+// Statement is the public part of a range proof
+type Statement struct {
+	Attribute  string   `json:"attribute"`
+	Operator   Operator `json:"operator"`
+	Threshold  int64    `json:"threshold"` // fixed-point, multiplied by Scale
+	Scale      int64    `json:"scale"`
+	Context    string   `json:"context"`    // binds the proof, e.g. to a passport ID
+	Commitment string   `json:"commitment"` // hex compressed point C
+}
+
+// RangeProof proves that the committed value satisfies a Statement
+type RangeProof struct {
+	Bits []BitProof `json:"bits"`
+}
+
+// BitProof is a commitment to one bit of the difference and an OR-proof that
+// it opens to 0 or 1
+type BitProof struct {
+	Commitment string `json:"commitment"`
+	E0         string `json:"e0"`
+	E1         string `json:"e1"`
+	S0         string `json:"s0"`
+	S1         string `json:"s1"`
+}
+
+// VerificationKey describes the public parameters proofs are checked against
+type VerificationKey struct {
+	ProofType string `json:"proof_type"`
+	Curve     string `json:"curve"`
+	G         string `json:"g"`
+	H         string `json:"h"`
+	Bits      int    `json:"bits"`
+	Scale     int64  `json:"scale"`
+}
+
+var (
+	curve = elliptic.P256()
+	order = curve.Params().N
+	g     = point{curve.Params().Gx, curve.Params().Gy}
+	h     = deriveGenerator("aluminium-passport/zk/pedersen-h")
+)
+
+// ToFixed converts a decimal value to the fixed-point integer committed to
+func ToFixed(value float64) int64 {
+	if value < 0 {
+		return -int64(-value*Scale + 0.5)
+	}
+	return int64(value*Scale + 0.5)
+}
+
+// FromFixed converts a fixed-point integer back to a decimal value
+func FromFixed(value int64) float64 {
+	return float64(value) / Scale
+}
+
+// Prove commits to value and proves the statement about it. The commitment
+// is filled into the returned statement; the blinding factor is discarded.
+func Prove(statement Statement, value int64) (*Statement, *RangeProof, error) {
+	var diff int64
+	switch statement.Operator {
+	case OperatorGTE:
+		diff = value - statement.Threshold
+	case OperatorLTE:
+		diff = statement.Threshold - value
+	default:
+		return nil, nil, ErrInvalidOperator
+	}
+	if diff < 0 || diff >= 1<<Bits {
+		return nil, nil, ErrStatementFalse
+	}
+
+	blinding, err := randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt := statement
+	stmt.Scale = Scale
+	stmt.Commitment = commit(big.NewInt(value), blinding).encode()
+
+	// Blinding factor of D: r for C - t·G, -r for t·G - C
+	target := new(big.Int).Set(blinding)
+	if stmt.Operator == OperatorLTE {
+		target.Neg(target).Mod(target, order)
+	}
+
+	transcript, err := stmt.transcript()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Random blinding for every bit but the last, which is chosen so that
+	// the weighted sum equals the blinding of D
+	blindings := make([]*big.Int, Bits)
+	sum := new(big.Int)
+	for i := 0; i < Bits-1; i++ {
+		if blindings[i], err = randomScalar(); err != nil {
+			return nil, nil, err
+		}
+		sum.Add(sum, new(big.Int).Lsh(blindings[i], uint(i)))
+	}
+	last := new(big.Int).Sub(target, sum)
+	last.Mul(last, new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), Bits-1), order))
+	blindings[Bits-1] = last.Mod(last, order)
+
+	proof := &RangeProof{Bits: make([]BitProof, Bits)}
+	for i := 0; i < Bits; i++ {
+		bp, err := proveBit(transcript, i, (diff>>uint(i))&1 == 1, blindings[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		proof.Bits[i] = *bp
+	}
+
+	return &stmt, proof, nil
+}
+
+// Verify checks a range proof against its statement
+func Verify(statement *Statement, proof *RangeProof) error {
+	if statement.Operator != OperatorGTE && statement.Operator != OperatorLTE {
+		return ErrInvalidOperator
+	}
+	if statement.Scale != Scale {
+		return fmt.Errorf("%w: unsupported scale %d", ErrInvalidProof, statement.Scale)
+	}
+	if proof == nil || len(proof.Bits) != Bits {
+		return fmt.Errorf("%w: expected %d bit proofs", ErrInvalidProof, Bits)
+	}
+
+	commitment, err := decodePoint(statement.Commitment)
+	if err != nil {
+		return err
+	}
+
+	threshold := g.mul(scalar(big.NewInt(statement.Threshold)))
+	var target point
+	if statement.Operator == OperatorGTE {
+		target = commitment.add(threshold.neg())
+	} else {
+		target = threshold.add(commitment.neg())
+	}
+
+	transcript, err := statement.transcript()
+	if err != nil {
+		return err
+	}
+
+	sum := infinity()
+	for i := range proof.Bits {
+		c, err := verifyBit(transcript, i, &proof.Bits[i])
+		if err != nil {
+			return err
+		}
+		sum = sum.add(c.mul(new(big.Int).Lsh(big.NewInt(1), uint(i))))
+	}
+
+	if !sum.equal(target) {
+		return fmt.Errorf("%w: bit commitments do not match the statement", ErrInvalidProof)
+	}
+	return nil
+}
+
+// Key returns the public parameters of the proof system
+func Key() VerificationKey {
+	return VerificationKey{
+		ProofType: ProofType,
+		Curve:     "P-256",
+		G:         g.encode(),
+		H:         h.encode(),
+		Bits:      Bits,
+		Scale:     Scale,
+	}
+}
+
+// proveBit commits to one bit with blinding r and builds the OR-proof that
+// the commitment C opens to 0 (C = r·H) or to 1 (C - G = r·H). The branch
+// that does not hold is simulated from a chosen challenge and response.
+func proveBit(transcript []byte, index int, bit bool, r *big.Int) (*BitProof, error) {
+	c := h.mul(r)
+	if bit {
+		c = c.add(g)
+	}
+	statements := [2]point{c, c.add(g.neg())}
+
+	known, simulated := 0, 1
+	if bit {
+		known, simulated = 1, 0
+	}
+
+	var e, s [2]*big.Int
+	var t [2]point
+
+	nonce, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	if e[simulated], err = randomScalar(); err != nil {
+		return nil, err
+	}
+	if s[simulated], err = randomScalar(); err != nil {
+		return nil, err
+	}
+	t[known] = h.mul(nonce)
+	t[simulated] = h.mul(s[simulated]).add(statements[simulated].mul(e[simulated]).neg())
+
+	challenge := bitChallenge(transcript, index, c, t[0], t[1])
+	e[known] = new(big.Int).Sub(challenge, e[simulated])
+	e[known].Mod(e[known], order)
+	s[known] = new(big.Int).Mul(e[known], r)
+	s[known].Add(s[known], nonce).Mod(s[known], order)
+
+	return &BitProof{
+		Commitment: c.encode(),
+		E0:         encodeScalar(e[0]),
+		E1:         encodeScalar(e[1]),
+		S0:         encodeScalar(s[0]),
+		S1:         encodeScalar(s[1]),
+	}, nil
+}
+
+// verifyBit checks one OR-proof and returns its bit commitment
+func verifyBit(transcript []byte, index int, bp *BitProof) (point, error) {
+	c, err := decodePoint(bp.Commitment)
+	if err != nil {
+		return point{}, err
+	}
+
+	var scalars [4]*big.Int
+	for i, enc := range []string{bp.E0, bp.E1, bp.S0, bp.S1} {
+		if scalars[i], err = decodeScalar(enc); err != nil {
+			return point{}, err
+		}
+	}
+	e := [2]*big.Int{scalars[0], scalars[1]}
+	s := [2]*big.Int{scalars[2], scalars[3]}
+
+	statements := [2]point{c, c.add(g.neg())}
+	var t [2]point
+	for i := range t {
+		t[i] = h.mul(s[i]).add(statements[i].mul(e[i]).neg())
+	}
+
+	sum := new(big.Int).Add(e[0], e[1])
+	if sum.Mod(sum, order).Cmp(bitChallenge(transcript, index, c, t[0], t[1])) != 0 {
+		return point{}, fmt.Errorf("%w: bit %d", ErrInvalidProof, index)
+	}
+	return c, nil
+}
+
+func (s *Statement) transcript() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func bitChallenge(transcript []byte, index int, c, t0, t1 point) *big.Int {
+	hash := sha256.New()
+	hash.Write([]byte(ProofType))
+	hash.Write(transcript)
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], uint32(index))
+	hash.Write(idx[:])
+	for _, p := range []point{c, t0, t1} {
+		hash.Write(p.bytes())
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(hash.Sum(nil)), order)
+}
+
+func commit(value, blinding *big.Int) point {
+	return g.mul(scalar(value)).add(h.mul(blinding))
+}
+
+// point is an affine P-256 point; (0, 0) is the point at infinity
+type point struct {
+	x, y *big.Int
+}
+
+func infinity() point {
+	return point{new(big.Int), new(big.Int)}
+}
+
+func (p point) isInfinity() bool {
+	return p.x.Sign() == 0 && p.y.Sign() == 0
+}
+
+func (p point) add(q point) point {
+	x, y := curve.Add(p.x, p.y, q.x, q.y)
+	return point{x, y}
+}
+
+func (p point) neg() point {
+	if p.isInfinity() {
+		return p
+	}
+	return point{p.x, new(big.Int).Sub(curve.Params().P, p.y)}
+}
+
+func (p point) mul(k *big.Int) point {
+	x, y := curve.ScalarMult(p.x, p.y, k.Bytes())
+	return point{x, y}
+}
+
+func (p point) equal(q point) bool {
+	return p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) == 0
+}
+
+func (p point) bytes() []byte {
+	if p.isInfinity() {
+		return []byte{0}
+	}
+	return elliptic.MarshalCompressed(curve, p.x, p.y)
+}
+
+func (p point) encode() string {
+	return hex.EncodeToString(p.bytes())
+}
+
+func decodePoint(s string) (point, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return point{}, fmt.Errorf("%w: malformed point", ErrInvalidProof)
+	}
+	x, y := elliptic.UnmarshalCompressed(curve, raw)
+	if x == nil {
+		return point{}, fmt.Errorf("%w: point is not on the curve", ErrInvalidProof)
+	}
+	return point{x, y}, nil
+}
+
+// deriveGenerator hashes a label to a curve point (try-and-increment), so
+// its discrete log relative to G is unknown
+func deriveGenerator(label string) point {
+	for counter := uint32(0); ; counter++ {
+		var ctr [4]byte
+		binary.BigEndian.PutUint32(ctr[:], counter)
+		digest := sha256.Sum256(append([]byte(label), ctr[:]...))
+		x, y := elliptic.UnmarshalCompressed(curve, append([]byte{0x02}, digest[:]...))
+		if x != nil {
+			return point{x, y}
+		}
+	}
+}
+
+func scalar(v *big.Int) *big.Int {
+	return new(big.Int).Mod(v, order)
+}
+
+func randomScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, order)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+func encodeScalar(k *big.Int) string {
+	return hex.EncodeToString(k.FillBytes(make([]byte, 32)))
+}
+
+func decodeScalar(s string) (*big.Int, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("%w: malformed scalar", ErrInvalidProof)
+	}
+	k := new(big.Int).SetBytes(raw)
+	if k.Cmp(order) >= 0 {
+		return nil, fmt.Errorf("%w: scalar out of range", ErrInvalidProof)
+	}
+	return k, nil
+}
@@ -0,0 +1,111 @@
+package zk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProveVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		operator  Operator
+		threshold float64
+		value     float64
+	}{
+		{"gte above threshold", OperatorGTE, 30, 42.5},
+		{"gte at threshold", OperatorGTE, 42.5, 42.5},
+		{"lte below threshold", OperatorLTE, 10000, 8125.75},
+		{"lte at threshold", OperatorLTE, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, proof, err := Prove(Statement{
+				Attribute: "recycled_content_percent",
+				Operator:  tt.operator,
+				Threshold: ToFixed(tt.threshold),
+				Context:   "ALU-2024-001",
+			}, ToFixed(tt.value))
+			if err != nil {
+				t.Fatalf("Prove() error = %v", err)
+			}
+			if err := Verify(statement, proof); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestProveRejectsFalseStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement Statement
+		value     int64
+		want      error
+	}{
+		{"gte below threshold", Statement{Operator: OperatorGTE, Threshold: ToFixed(50)}, ToFixed(49.9999), ErrStatementFalse},
+		{"lte above threshold", Statement{Operator: OperatorLTE, Threshold: ToFixed(50)}, ToFixed(50.0001), ErrStatementFalse},
+		{"difference out of range", Statement{Operator: OperatorGTE, Threshold: 0}, 1 << Bits, ErrStatementFalse},
+		{"unknown operator", Statement{Operator: "eq", Threshold: ToFixed(50)}, ToFixed(50), ErrInvalidOperator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Prove(tt.statement, tt.value); !errors.Is(err, tt.want) {
+				t.Errorf("Prove() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	statement, proof, err := Prove(Statement{
+		Attribute: "recycled_content_percent",
+		Operator:  OperatorGTE,
+		Threshold: ToFixed(30),
+		Context:   "ALU-2024-001",
+	}, ToFixed(42.5))
+	if err != nil {
+		t.Fatalf("Prove() error = %v", err)
+	}
+
+	// A commitment to another value, proven with the same statement
+	other, _, err := Prove(*statement, ToFixed(99))
+	if err != nil {
+		t.Fatalf("Prove() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *Statement, p *RangeProof)
+	}{
+		{"raised threshold", func(s *Statement, p *RangeProof) { s.Threshold = ToFixed(50) }},
+		{"other context", func(s *Statement, p *RangeProof) { s.Context = "ALU-2024-002" }},
+		{"other attribute", func(s *Statement, p *RangeProof) { s.Attribute = "carbon_footprint" }},
+		{"other commitment", func(s *Statement, p *RangeProof) { s.Commitment = other.Commitment }},
+		{"other scale", func(s *Statement, p *RangeProof) { s.Scale = 100 }},
+		{"swapped bit proofs", func(s *Statement, p *RangeProof) { p.Bits[0], p.Bits[1] = p.Bits[1], p.Bits[0] }},
+		{"changed bit response", func(s *Statement, p *RangeProof) { p.Bits[3].S0 = p.Bits[3].S1 }},
+		{"missing bit proof", func(s *Statement, p *RangeProof) { p.Bits = p.Bits[:Bits-1] }},
+		{"malformed commitment", func(s *Statement, p *RangeProof) { s.Commitment = "02ff" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *statement
+			p := RangeProof{Bits: append([]BitProof(nil), proof.Bits...)}
+			tt.change(&s, &p)
+			if err := Verify(&s, &p); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("Verify() error = %v, want ErrInvalidProof", err)
+			}
+		})
+	}
+}
+
+func TestFixedPointRoundTrip(t *testing.T) {
+	for _, value := range []float64{0, 0.0001, 42.5, -3.25, 8125.75} {
+		if got := FromFixed(ToFixed(value)); got != value {
+			t.Errorf("FromFixed(ToFixed(%v)) = %v", value, got)
+		}
+	}
+}
@@ -1,16 +0,0 @@
-
-package zk
-
-import (
-    "crypto/sha256"
-    "encoding/hex"
-    "fmt"
-)
-
-// SimulateZKProof returns a mock proof for a given input claim
-func SimulateZKProof(claim string, threshold int) string {
-    // This simulates proving that a value is above a threshold without revealing the exact value
-    // e.g., recycledContentPercent > 50
-    hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", claim, threshold)))
-    return hex.EncodeToString(hash[:])
-}
@@ -13,6 +13,7 @@ import (
 	"aluminium-passport/internal/db"
 	"aluminium-passport/internal/ipfs"
 	"aluminium-passport/internal/routes"
+	"aluminium-passport/internal/services"
 )
 
 func main() {
@@ -28,6 +29,27 @@ func main() {
 	}
 	defer db.CloseDB()
 
+	// Keep credential status lists in line with certification expiry
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	go services.NewStatusListService(db.DB).RunExpirySweeper(sweepCtx, cfg.StatusSweepInterval)
+
+	// Deliver email and webhook notifications in the background
+	services.RegisterConfiguredNotificationChannels(cfg)
+	go services.NewNotificationService(db.DB).RunNotificationDelivery(sweepCtx, cfg.NotificationPollInterval, cfg.NotificationMaxAttempts)
+
+	// Deliver signed passport events to webhook subscriptions
+	go services.NewWebhookService(db.DB).RunWebhookDelivery(sweepCtx, cfg.WebhookPollInterval, cfg.WebhookMaxAttempts)
+
+	// Expire approval requests that were not decided in time
+	go services.NewApprovalService(db.DB).RunExpirySweeper(sweepCtx, cfg.ApprovalExpiryInterval)
+
+	// Process queued batch uploads in the background
+	batchQueue, err := services.StartBatchQueue(context.Background(), db.DB, cfg.BatchWorkers, cfg.BatchPollInterval)
+	if err != nil {
+		log.Fatalf("Failed to start batch queue: %v", err)
+	}
+
 	// Initialize IPFS client
 	if err := ipfs.InitializeIPFS(); err != nil {
 		log.Printf("Warning: Failed to initialize IPFS client: %v", err)
@@ -36,6 +58,9 @@ func main() {
 		log.Println("IPFS client initialized successfully")
 	}
 
+	// Publish the daily Merkle root of the audit chain
+	go services.NewAuditService(db.DB).RunAuditAnchoring(sweepCtx, cfg.AuditAnchorInterval)
+
 	// Setup routes
 	router := routes.SetupRoutes()
 
@@ -86,6 +111,9 @@ func main() {
 		log.Println("✅ Server shutdown completed")
 	}
 
+	// Let batch workers finish their current chunk
+	batchQueue.Stop()
+
 	// Close database connection
 	if err := db.CloseDB(); err != nil {
 		log.Printf("Error closing database: %v", err)
@@ -0,0 +1,22 @@
+-- Issuer signing keys, one active key per organisation
+CREATE TABLE IF NOT EXISTS issuer_keys (
+    id SERIAL PRIMARY KEY,
+    organisation VARCHAR(255) NOT NULL, -- slug derived from company name or wallet address
+    company_name VARCHAR(255),
+    wallet_address VARCHAR(42),
+    controller_did VARCHAR(500) NOT NULL,
+    verification_method VARCHAR(600) UNIQUE NOT NULL,
+    key_type VARCHAR(20) NOT NULL, -- 'Ed25519', 'secp256k1'
+    public_key_multibase VARCHAR(255) UNIQUE NOT NULL,
+    encrypted_private_key TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active', -- 'active', 'rotated', 'revoked'
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revocation_reason TEXT
+);
+
+CREATE INDEX idx_issuer_keys_organisation ON issuer_keys(organisation);
+CREATE INDEX idx_issuer_keys_status ON issuer_keys(status);
+CREATE UNIQUE INDEX idx_issuer_keys_active_organisation ON issuer_keys(organisation) WHERE status = 'active';
@@ -0,0 +1,33 @@
+-- Bitstring status lists for issued credentials
+CREATE TABLE IF NOT EXISTS status_lists (
+    id SERIAL PRIMARY KEY,
+    status_purpose VARCHAR(20) NOT NULL, -- 'revocation', 'suspension'
+    size INTEGER NOT NULL, -- number of bits
+    bitstring BYTEA NOT NULL,
+    next_index INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Status entries allocated to each issued credential, one per purpose
+CREATE TABLE IF NOT EXISTS credential_status_entries (
+    id SERIAL PRIMARY KEY,
+    credential_id VARCHAR(100) NOT NULL,
+    passport_id VARCHAR(100) REFERENCES aluminium_passports(passport_id) ON DELETE CASCADE,
+    issuer VARCHAR(500),
+    status_list_id INTEGER NOT NULL REFERENCES status_lists(id),
+    status_index INTEGER NOT NULL,
+    status_purpose VARCHAR(20) NOT NULL,
+    is_set BOOLEAN DEFAULT false,
+    reason VARCHAR(100), -- 'passport_deactivated', 'certification_expired'
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (status_list_id, status_index)
+);
+
+CREATE INDEX idx_status_lists_purpose ON status_lists(status_purpose);
+CREATE INDEX idx_credential_status_passport_id ON credential_status_entries(passport_id);
+CREATE INDEX idx_credential_status_credential_id ON credential_status_entries(credential_id);
+
+CREATE TRIGGER update_status_lists_updated_at BEFORE UPDATE ON status_lists FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_credential_status_entries_updated_at BEFORE UPDATE ON credential_status_entries FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,12 @@
+-- Background processing of batch uploads. Uploaded rows are kept with the
+-- batch until it finishes; processed_records is the resume position.
+ALTER TABLE batch_operations ADD COLUMN IF NOT EXISTS payload JSONB;
+ALTER TABLE batch_operations ADD COLUMN IF NOT EXISTS processed_records INTEGER DEFAULT 0;
+ALTER TABLE batch_operations ADD COLUMN IF NOT EXISTS cancel_requested BOOLEAN DEFAULT false;
+ALTER TABLE batch_operations ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE batch_operations ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- status: 'pending', 'processing', 'completed', 'failed', 'cancelled'
+CREATE INDEX IF NOT EXISTS idx_batch_operations_created_at ON batch_operations(created_at);
+
+CREATE TRIGGER update_batch_operations_updated_at BEFORE UPDATE ON batch_operations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
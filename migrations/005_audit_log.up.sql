@@ -0,0 +1,7 @@
+-- Audit entries are written for every controller mutation, whose action
+-- names (BATCH_UPLOAD, KEY_ROTATE, ...) and legacy roles go beyond the
+-- action_type and user_role enums
+ALTER TABLE audit_logs ALTER COLUMN action TYPE VARCHAR(100) USING action::text;
+ALTER TABLE audit_logs ALTER COLUMN user_role TYPE VARCHAR(50) USING user_role::text;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id);
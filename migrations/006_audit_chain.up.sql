@@ -0,0 +1,31 @@
+-- Hash chain over audit entries: entry_hash covers the entry and the
+-- previous entry's hash, so editing or removing an entry breaks every later
+-- link. Entries written before this migration stay unchained.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS entry_hash VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_entry_hash ON audit_logs(entry_hash);
+
+-- Audit entries are append-only
+CREATE OR REPLACE FUNCTION reject_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
+
+-- Daily Merkle roots of the chain, published to IPFS and optionally on chain
+CREATE TABLE IF NOT EXISTS audit_anchors (
+    id SERIAL PRIMARY KEY,
+    anchor_date DATE UNIQUE NOT NULL, -- UTC day covered
+    first_entry_id INTEGER NOT NULL,
+    last_entry_id INTEGER NOT NULL,
+    entry_count INTEGER NOT NULL,
+    merkle_root VARCHAR(64) NOT NULL,
+    chain_head VARCHAR(64) NOT NULL, -- entry_hash of last_entry_id
+    ipfs_hash VARCHAR(100),
+    tx_hash VARCHAR(66),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE
+);
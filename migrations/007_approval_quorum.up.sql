@@ -0,0 +1,33 @@
+-- Multi-party approval. A policy lists how many distinct voters of each role
+-- must approve a request type; types without a policy need a single
+-- approval from the request's approver_role.
+CREATE TABLE IF NOT EXISTS approval_policies (
+    request_type approval_type NOT NULL,
+    approver_role user_role NOT NULL,
+    required_votes INTEGER NOT NULL CHECK (required_votes > 0),
+    updated_by INTEGER REFERENCES users(id),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (request_type, approver_role)
+);
+
+-- One vote per user per request; decision is 'approved' or 'rejected'
+CREATE TABLE IF NOT EXISTS approval_votes (
+    id SERIAL PRIMARY KEY,
+    approval_request_id INTEGER NOT NULL REFERENCES approval_requests(id) ON DELETE CASCADE,
+    voter_id INTEGER NOT NULL REFERENCES users(id),
+    voter_role user_role NOT NULL,
+    decision approval_status NOT NULL CHECK (decision IN ('approved', 'rejected')),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (approval_request_id, voter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_votes_request ON approval_votes(approval_request_id);
+
+INSERT INTO approval_policies (request_type, approver_role, required_votes)
+VALUES
+    ('supplier_onboarding', 'super_admin', 1),
+    ('supplier_onboarding', 'auditor', 1),
+    ('system_configuration', 'super_admin', 1),
+    ('system_configuration', 'auditor', 1)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+-- Single-use nonces for Sign-In with Ethereum (EIP-4361). A nonce issued
+-- for an address can only be used by that address.
+CREATE TABLE IF NOT EXISTS siwe_nonces (
+    nonce VARCHAR(64) PRIMARY KEY,
+    address VARCHAR(42),
+    issued_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_siwe_nonces_expires_at ON siwe_nonces(expires_at);
+
+-- Wallet logins match addresses case-insensitively
+CREATE INDEX IF NOT EXISTS idx_users_wallet_address_lower ON users(LOWER(wallet_address));
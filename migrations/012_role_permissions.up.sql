@@ -0,0 +1,8 @@
+-- Permissions granted to a role, replacing the built-in defaults for that
+-- role. Roles without a row keep the defaults defined in the application.
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role user_role PRIMARY KEY,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    updated_by INTEGER REFERENCES users(id),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
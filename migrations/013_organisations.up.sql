@@ -0,0 +1,61 @@
+-- Organisations own users and passports. Slugs match the organisation
+-- slugs used for issuer keys.
+CREATE TABLE IF NOT EXISTS organisations (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(255) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    is_active BOOLEAN DEFAULT true,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_organisations_updated_at BEFORE UPDATE ON organisations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organisation_id INTEGER REFERENCES organisations(id);
+ALTER TABLE aluminium_passports ADD COLUMN IF NOT EXISTS organisation_id INTEGER REFERENCES organisations(id);
+
+CREATE INDEX IF NOT EXISTS idx_users_organisation ON users(organisation_id);
+CREATE INDEX IF NOT EXISTS idx_passports_organisation ON aluminium_passports(organisation_id);
+
+-- Existing company names become organisations; passports belong to the
+-- organisation of the user who created them. Company names were typed in by
+-- the users themselves, so only the first user to register a company joins
+-- its organisation; admins assign the others.
+INSERT INTO organisations (slug, name, created_by)
+SELECT DISTINCT ON (slug) slug, company_name, id
+FROM (
+    SELECT TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(company_name), '[^a-z0-9]+', '-', 'g')) AS slug, company_name, id
+    FROM users
+    WHERE company_name IS NOT NULL
+) named
+WHERE slug <> ''
+ORDER BY slug, id
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE users u SET organisation_id = o.id
+FROM organisations o
+WHERE u.organisation_id IS NULL AND u.id = o.created_by;
+
+UPDATE aluminium_passports p SET organisation_id = u.organisation_id
+FROM users u
+WHERE p.organisation_id IS NULL AND p.created_by = u.id;
+
+-- Access to a passport shared with another organisation. Any active grant
+-- lets the organisation read the passport; write grants also let it change
+-- the listed sections.
+CREATE TABLE IF NOT EXISTS passport_grants (
+    id SERIAL PRIMARY KEY,
+    passport_id VARCHAR(100) NOT NULL REFERENCES aluminium_passports(passport_id) ON DELETE CASCADE,
+    organisation_id INTEGER NOT NULL REFERENCES organisations(id),
+    access VARCHAR(10) NOT NULL CHECK (access IN ('read', 'write')),
+    sections TEXT[] NOT NULL DEFAULT '{}',
+    granted_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_by INTEGER REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_passport_grants_passport ON passport_grants(passport_id);
+CREATE INDEX IF NOT EXISTS idx_passport_grants_organisation ON passport_grants(organisation_id) WHERE revoked_at IS NULL;
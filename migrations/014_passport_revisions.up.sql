@@ -0,0 +1,75 @@
+-- Immutable history of passports. Every insert or update of a passport
+-- stores a revision with the changed fields, their old and new values and a
+-- snapshot of the whole row, so that a passport can be read as it was at
+-- any time.
+CREATE TABLE IF NOT EXISTS passport_revisions (
+    id SERIAL PRIMARY KEY,
+    passport_id VARCHAR(100) NOT NULL REFERENCES aluminium_passports(passport_id),
+    revision INTEGER NOT NULL,
+    change_type VARCHAR(20) NOT NULL CHECK (change_type IN ('baseline', 'create', 'update')),
+    changed_by INTEGER REFERENCES users(id),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    fields TEXT[] NOT NULL DEFAULT '{}',
+    old_values JSONB,
+    new_values JSONB,
+    snapshot JSONB NOT NULL,
+    UNIQUE (passport_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_passport_revisions_changed_at ON passport_revisions(passport_id, changed_at);
+
+-- Revisions are append-only
+CREATE OR REPLACE FUNCTION reject_passport_revision_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'passport_revisions is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER passport_revisions_append_only BEFORE UPDATE OR DELETE ON passport_revisions FOR EACH ROW EXECUTE FUNCTION reject_passport_revision_change();
+
+-- updated_at changes with every update and is not recorded as a change.
+-- The row lock taken by the update serialises revisions of one passport.
+CREATE OR REPLACE FUNCTION record_passport_revision()
+RETURNS TRIGGER AS $$
+DECLARE
+    new_row JSONB := to_jsonb(NEW) - 'updated_at';
+    old_row JSONB := '{}';
+    changed TEXT[];
+    old_values JSONB;
+    new_values JSONB;
+    next_revision INTEGER;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        old_row := to_jsonb(OLD) - 'updated_at';
+    END IF;
+
+    SELECT COALESCE(array_agg(n.key ORDER BY n.key), '{}') INTO changed
+    FROM jsonb_each(new_row) n
+    WHERE TG_OP = 'INSERT' OR n.value IS DISTINCT FROM old_row -> n.key;
+
+    IF TG_OP = 'UPDATE' THEN
+        IF cardinality(changed) = 0 THEN
+            RETURN NULL;
+        END IF;
+        SELECT jsonb_object_agg(f, old_row -> f), jsonb_object_agg(f, new_row -> f)
+        INTO old_values, new_values
+        FROM unnest(changed) f;
+    END IF;
+
+    SELECT COALESCE(MAX(revision), 0) + 1 INTO next_revision
+    FROM passport_revisions WHERE passport_id = NEW.passport_id;
+
+    INSERT INTO passport_revisions (passport_id, revision, change_type, changed_by, fields, old_values, new_values, snapshot)
+    VALUES (NEW.passport_id, next_revision, LOWER(TG_OP), NEW.updated_by, changed, old_values, new_values, to_jsonb(NEW));
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_passport_revisions AFTER INSERT OR UPDATE ON aluminium_passports FOR EACH ROW EXECUTE FUNCTION record_passport_revision();
+
+-- Passports created before this migration start from their current state
+INSERT INTO passport_revisions (passport_id, revision, change_type, changed_by, changed_at, snapshot)
+SELECT p.passport_id, 1, 'baseline', p.updated_by, COALESCE(p.updated_at, p.created_at, CURRENT_TIMESTAMP), to_jsonb(p)
+FROM aluminium_passports p
+WHERE NOT EXISTS (SELECT 1 FROM passport_revisions r WHERE r.passport_id = p.passport_id);
@@ -0,0 +1,22 @@
+-- Issuer keys belong to an organisation by ID. The organisation column
+-- keeps the slug that names the key's did:web, but is no longer used to
+-- decide who may manage a key.
+ALTER TABLE issuer_keys ADD COLUMN IF NOT EXISTS organisation_id INTEGER REFERENCES organisations(id);
+
+-- Keys of slugs without an organisation get one of their own
+INSERT INTO organisations (slug, name)
+SELECT DISTINCT ON (k.organisation) k.organisation, COALESCE(k.company_name, k.wallet_address, k.organisation)
+FROM issuer_keys k
+WHERE NOT EXISTS (SELECT 1 FROM organisations o WHERE o.slug = k.organisation)
+ORDER BY k.organisation, k.id
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE issuer_keys k SET organisation_id = o.id
+FROM organisations o
+WHERE k.organisation_id IS NULL AND o.slug = k.organisation;
+
+ALTER TABLE issuer_keys ALTER COLUMN organisation_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_issuer_keys_active_organisation;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_issuer_keys_active_organisation_id ON issuer_keys(organisation_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_issuer_keys_organisation_id ON issuer_keys(organisation_id);
@@ -0,0 +1,15 @@
+-- Subscriptions belong to an organisation and receive only the events of
+-- its passports. Subscriptions without one receive every event; only users
+-- who may read any passport can create those, so existing subscriptions,
+-- created by admins, keep receiving everything.
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS organisation_id INTEGER REFERENCES organisations(id) ON DELETE CASCADE;
+
+-- Events record the organisation of their passport when published, so
+-- replays are filtered the same way
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS organisation_id INTEGER REFERENCES organisations(id);
+
+UPDATE webhook_events e SET organisation_id = p.organisation_id
+FROM aluminium_passports p
+WHERE e.organisation_id IS NULL AND p.passport_id = e.resource_id;
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_organisation ON webhook_subscriptions(organisation_id);